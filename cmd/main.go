@@ -1,14 +1,33 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	// Embedded zone database, masters' time zones must load without system tzdata
+	_ "time/tzdata"
 
+	"beep-backend/internal/auth"
+	"beep-backend/internal/booking"
 	"beep-backend/internal/config"
 	"beep-backend/internal/database"
 	"beep-backend/internal/handlers"
+	"beep-backend/internal/mail"
+	"beep-backend/internal/pricing"
 	"beep-backend/internal/repository"
 	"beep-backend/internal/router"
+	"beep-backend/internal/seed"
+	"beep-backend/internal/sms"
+	"beep-backend/internal/worker"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -21,15 +40,39 @@ func main() {
 	}
 
 	// Load config
-	cfg := config.Load()
+	cfg, err := config.Load("")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// "config show" needs no database
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		if err := runConfig(cfg, os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
 	// Initialize database
-	db, err := database.Connect(cfg.DatabaseURL)
+	db, err := database.Connect(cfg.Database.URL, database.PoolSettings{
+		MaxOpenConns:    cfg.Database.MaxOpenConns,
+		MaxIdleConns:    cfg.Database.MaxIdleConns,
+		ConnMaxLifetime: cfg.Database.ConnMaxLifetime,
+		ConnMaxIdleTime: cfg.Database.ConnMaxIdleTime,
+	})
 	if err != nil {
 		log.Fatal("Failed to connect to database:", err)
 	}
 	defer db.Close()
 
+	// Subcommands, e.g. "migrate status"; no arguments starts the server
+	if len(os.Args) > 1 {
+		if err := runCommand(db, os.Args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	// Run migrations
 	if err := database.RunMigrations(db); err != nil {
 		log.Fatal("Failed to run migrations:", err)
@@ -39,20 +82,294 @@ func main() {
 	repos := repository.New(db)
 
 	// Initialize handlers
-	appHandlers := handlers.New(repos)
+	if cfg.Auth.DevMode {
+		log.Println("WARNING: AUTH_DEV_MODE is enabled, mock tokens are accepted")
+	}
+	if cfg.IsDevelopment() && (cfg.Auth.JWTSecret == config.DefaultJWTSecret || len(cfg.Auth.JWTSecret) < config.MinJWTSecretLength) {
+		log.Println("WARNING: JWT_SECRET is the default or too short; it is only accepted with APP_ENV=development")
+	}
+	mailer, err := newMailer(cfg)
+	if err != nil {
+		log.Fatal("Failed to initialize mailer:", err)
+	}
+
+	appHandlers := handlers.New(repos, handlers.Options{
+		Tokens:               auth.NewTokenManager(cfg.Auth.JWTSecret, cfg.Auth.AccessTokenTTL),
+		Mailer:               mailer,
+		RefreshTokenTTL:      cfg.Auth.RefreshTokenTTL,
+		PasswordResetTTL:     cfg.Auth.PasswordResetTTL,
+		EmailVerificationTTL: cfg.Auth.EmailVerificationTTL,
+		AppBaseURL:           cfg.Server.BaseURL,
+		SMS:                  sms.NewLogSender(),
+		OTP: handlers.OTPSettings{
+			TTL:            cfg.OTP.TTL,
+			ResendInterval: cfg.OTP.ResendInterval,
+			MaxPerHour:     cfg.OTP.MaxPerHour,
+			MaxAttempts:    cfg.OTP.MaxAttempts,
+			Length:         cfg.OTP.Length,
+		},
+		Uploads: handlers.UploadSettings{
+			Dir:      cfg.Uploads.Dir,
+			URLPath:  cfg.Uploads.URLPath,
+			MaxBytes: cfg.Uploads.MaxBytes(),
+		},
+		DevAuth: cfg.Auth.DevMode,
+		Booking: booking.Settings{
+			SlotGranularity: cfg.Booking.SlotGranularity,
+			MinLeadTime:     cfg.Booking.MinLeadTime,
+		},
+		Pricing: pricing.Settings{
+			QuoteTTL: cfg.Pricing.QuoteTTL,
+		},
+	})
 
 	// Setup router
 	gin.SetMode(gin.ReleaseMode)
-	r := router.SetupRouter(appHandlers)
+	limiter, err := newRateLimiter(cfg)
+	if err != nil {
+		log.Fatal("Failed to configure rate limiting:", err)
+	}
+	r := router.SetupRouter(appHandlers, router.Options{
+		Limiter:        limiter,
+		CORSOrigins:    cfg.CORS.AllowedOrigins,
+		TrustedProxies: cfg.Server.TrustedProxies,
+		RequestTimeout: cfg.Database.RequestTimeout,
+		UploadDir:      cfg.Uploads.Dir,
+		UploadURLPath:  cfg.Uploads.URLPath,
+		Registration:   cfg.Features.Registration,
+		PhoneLogin:     cfg.Features.PhoneLogin,
+	})
+
+	// Catch SIGINT/SIGTERM before starting anything that must be drained
+	signals, stopSignals := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stopSignals()
+
+	// Background jobs
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	workers := worker.NewRunner(worker.Job{
+		Name:     "purge_expired_auth_data",
+		Interval: cfg.Workers.CleanupInterval,
+		Run: func(ctx context.Context) error {
+			deleted, err := repos.PurgeExpiredAuthData(ctx)
+			if err == nil && deleted > 0 {
+				log.Printf("Purged %d expired tokens and codes", deleted)
+			}
+			return err
+		},
+	}, worker.Job{
+		Name:     "purge_expired_price_quotes",
+		Interval: cfg.Workers.CleanupInterval,
+		Run: func(ctx context.Context) error {
+			deleted, err := repos.PurgeExpiredPriceQuotes(ctx)
+			if err == nil && deleted > 0 {
+				log.Printf("Purged %d expired price quotes", deleted)
+			}
+			return err
+		},
+	})
+	workers.Start(workerCtx)
 
 	// Start server
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	srv := &http.Server{
+		Addr:         ":" + cfg.Server.Port,
+		Handler:      r,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Printf("Server starting on port %s (%s)", cfg.Server.Port, cfg.Env)
+		serverErr <- srv.ListenAndServe()
+	}()
+
+	// Wait for SIGINT/SIGTERM, then stop accepting connections and drain in-flight requests and jobs.
+	// A server that fails to start goes through the same shutdown before exiting with an error.
+	failed := false
+	select {
+	case err := <-serverErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("Failed to start server: %v", err)
+			failed = true
+		}
+	case <-signals.Done():
+		log.Println("Shutting down...")
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Warning: Server did not shut down cleanly: %v", err)
+	}
+	stopWorkers()
+	if !workers.Wait(shutdownCtx) {
+		log.Println("Warning: Background jobs did not finish before the shutdown timeout")
+	}
+	log.Println("Server stopped")
+
+	if failed {
+		// os.Exit skips deferred calls
+		cancel()
+		stopSignals()
+		db.Close()
+		os.Exit(1)
+	}
+}
+
+// newMailer sends mail over SMTP when a host is configured and writes it to files otherwise
+func newMailer(cfg *config.Config) (mail.Mailer, error) {
+	if cfg.Mail.SMTPHost != "" {
+		return mail.NewSMTPMailer(cfg.Mail.SMTPHost, cfg.Mail.SMTPPort, cfg.Mail.SMTPUsername, cfg.Mail.SMTPPassword, cfg.Mail.From), nil
+	}
+	log.Printf("SMTP_HOST is not set, outgoing mail is written to %s", cfg.Mail.Dir)
+	return mail.NewFileMailer(cfg.Mail.Dir)
+}
+
+// newRateLimiter builds the in-memory rate limiter from the router defaults and configured overrides
+func newRateLimiter(cfg *config.Config) (*router.RateLimiter, error) {
+	if !cfg.RateLimit.Enabled {
+		log.Println("WARNING: Rate limiting is disabled, requests are not throttled")
+		return nil, nil
 	}
 
-	log.Printf("Server starting on port %s", port)
-	if err := r.Run(":" + port); err != nil {
-		log.Fatal("Failed to start server:", err)
+	for name := range cfg.RateLimit.Policies {
+		if _, ok := router.DefaultRateLimitPolicies[name]; !ok {
+			return nil, fmt.Errorf("unknown rate limit policy %q", name)
+		}
+	}
+
+	policies := make(map[string]router.RateLimitPolicy, len(router.DefaultRateLimitPolicies))
+	for name, spec := range router.DefaultRateLimitPolicies {
+		if override, ok := cfg.RateLimit.Policies[name]; ok {
+			spec = override
+		}
+		policy, err := router.ParseRateLimitPolicy(spec)
+		if err != nil {
+			return nil, fmt.Errorf("rate limit policy %s: %w", name, err)
+		}
+		policies[name] = policy
+	}
+
+	return router.NewRateLimiter(router.NewMemoryRateLimitStore(), policies), nil
+}
+
+// runCommand executes a maintenance subcommand instead of starting the server
+func runCommand(db *sql.DB, args []string) error {
+	switch args[0] {
+	case "migrate":
+		return runMigrate(db, args[1:])
+	case "seed":
+		return runSeed(db, args[1:])
+	default:
+		return fmt.Errorf("unknown command %q, usage: %s [migrate up|down [n]|status|redo] [seed [-file path] [set]] [config show]", args[0], os.Args[0])
+	}
+}
+
+// runMigrate handles "migrate up", "migrate down [n]", "migrate status" and "migrate redo"
+func runMigrate(db *sql.DB, args []string) error {
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	action := "up"
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	switch action {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		log.Printf("%d migrations applied", applied)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps <= 0 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		log.Printf("%d migrations reverted", reverted)
+	case "redo":
+		return migrator.Redo(ctx)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.AppliedAt != nil {
+				state = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if status.Modified {
+				state += " (modified since applied)"
+			}
+			name := status.Name
+			if name == "" {
+				name = "(unknown to this build)"
+			}
+			fmt.Printf("%04d  %-30s %s\n", status.Version, name, state)
+		}
+	default:
+		return fmt.Errorf("unknown migrate action %q, expected up, down, status or redo", action)
+	}
+	return nil
+}
+
+// runSeed handles "seed [set]" and "seed -file <path.sql|path.json>". The schema is migrated first.
+func runSeed(db *sql.DB, args []string) error {
+	flags := flag.NewFlagSet("seed", flag.ContinueOnError)
+	file := flags.String("file", "", "load a .sql script or a .json fixture instead of a built-in set")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if err := database.RunMigrations(db); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	switch {
+	case strings.HasSuffix(*file, ".sql"):
+		return seed.LoadSQLFile(ctx, db, *file)
+	case strings.HasSuffix(*file, ".json"):
+		fixture, err := seed.LoadFixtureFile(*file)
+		if err != nil {
+			return err
+		}
+		return seed.Apply(ctx, db, fixture)
+	case *file != "":
+		return fmt.Errorf("unsupported fixture file %q, expected .sql or .json", *file)
+	}
+
+	set := seed.SetDemo
+	if flags.NArg() > 0 {
+		set = flags.Arg(0)
+	}
+	return seed.Run(ctx, db, set)
+}
+
+// runConfig handles "config show", printing the effective configuration with secrets masked
+func runConfig(cfg *config.Config, args []string) error {
+	if len(args) == 0 || args[0] != "show" {
+		return fmt.Errorf("usage: %s config show", os.Args[0])
+	}
+	out, err := cfg.Redacted().YAML()
+	if err != nil {
+		return err
 	}
+	fmt.Print(out)
+	return nil
 }
@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// legacyHashPrefix marks passwords stored by early builds as "hashed-" + plaintext
+const legacyHashPrefix = "hashed-"
+
+// MaxPasswordLength is the longest password in bytes bcrypt can hash
+const MaxPasswordLength = 72
+
+var (
+	ErrPasswordMismatch = errors.New("password mismatch")
+	ErrPasswordTooLong  = errors.New("password must be at most 72 bytes long")
+)
+
+// dummyHash is compared against when the user does not exist so that lookups take the same time
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("beep-dummy-password"), bcrypt.DefaultCost)
+
+// HashPassword hashes a password with bcrypt.
+// Returns ErrPasswordTooLong for a password longer than MaxPasswordLength bytes.
+func HashPassword(password string) (string, error) {
+	if len(password) > MaxPasswordLength {
+		return "", ErrPasswordTooLong
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword compares a password with a stored hash in constant time.
+// needsRehash is true when the hash uses the legacy format or an outdated bcrypt cost.
+func CheckPassword(hash, password string) (needsRehash bool, err error) {
+	if strings.HasPrefix(hash, legacyHashPrefix) {
+		stored := []byte(strings.TrimPrefix(hash, legacyHashPrefix))
+		if subtle.ConstantTimeCompare(stored, []byte(password)) != 1 {
+			return false, ErrPasswordMismatch
+		}
+		return true, nil
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+		return false, ErrPasswordMismatch
+	}
+
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err == nil && cost < bcrypt.DefaultCost, nil
+}
+
+// SimulatePasswordCheck spends the same time as CheckPassword for callers that have no user to check against
+func SimulatePasswordCheck(password string) {
+	bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+}
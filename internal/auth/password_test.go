@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestHashPassword(t *testing.T) {
+	hash, err := HashPassword("password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.HasPrefix(hash, legacyHashPrefix) || bcrypt.CompareHashAndPassword([]byte(hash), []byte("password123")) != nil {
+		t.Errorf("HashPassword() = %q, want a bcrypt hash of the password", hash)
+	}
+	if _, err := HashPassword(strings.Repeat("a", MaxPasswordLength)); err != nil {
+		t.Errorf("HashPassword() of %d bytes = %v", MaxPasswordLength, err)
+	}
+	if _, err := HashPassword(strings.Repeat("a", MaxPasswordLength+1)); !errors.Is(err, ErrPasswordTooLong) {
+		t.Errorf("HashPassword() of %d bytes = %v, want ErrPasswordTooLong", MaxPasswordLength+1, err)
+	}
+}
+
+func TestCheckPassword(t *testing.T) {
+	current, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.DefaultCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cheap, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		hash       string
+		password   string
+		wantRehash bool
+		wantErr    error
+	}{
+		{name: "bcrypt hash", hash: string(current), password: "password123"},
+		{name: "bcrypt hash, wrong password", hash: string(current), password: "password124", wantErr: ErrPasswordMismatch},
+		{name: "outdated bcrypt cost", hash: string(cheap), password: "password123", wantRehash: true},
+		{name: "legacy hash", hash: "hashed-password123", password: "password123", wantRehash: true},
+		{name: "legacy hash, wrong password", hash: "hashed-password123", password: "password", wantErr: ErrPasswordMismatch},
+		{name: "legacy hash, empty password", hash: "hashed-password123", password: "", wantErr: ErrPasswordMismatch},
+		{name: "plaintext is not a hash", hash: "password123", password: "password123", wantErr: ErrPasswordMismatch},
+		{name: "empty hash", hash: "", password: "", wantErr: ErrPasswordMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			needsRehash, err := CheckPassword(tt.hash, tt.password)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CheckPassword() error = %v, want %v", err, tt.wantErr)
+			}
+			if needsRehash != tt.wantRehash {
+				t.Errorf("needsRehash = %v, want %v", needsRehash, tt.wantRehash)
+			}
+		})
+	}
+}
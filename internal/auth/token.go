@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrExpiredToken = errors.New("token expired")
+)
+
+// Claims is the payload carried by an access token
+type Claims struct {
+	Subject   string `json:"sub"`
+	UserID    int    `json:"uid"`
+	Role      string `json:"role"`
+	SessionID string `json:"sid,omitempty"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+}
+
+// TokenManager issues and validates HS256-signed JWT access tokens
+type TokenManager struct {
+	secret []byte
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+func NewTokenManager(secret string, ttl time.Duration) *TokenManager {
+	return &TokenManager{secret: []byte(secret), ttl: ttl, now: time.Now}
+}
+
+// TTL returns the lifetime of issued access tokens
+func (m *TokenManager) TTL() time.Duration {
+	return m.ttl
+}
+
+// Issue creates a signed access token for the user's session and returns it with its expiry time
+func (m *TokenManager) Issue(userID int, role, sessionID string) (string, time.Time, error) {
+	now := m.now()
+	expiresAt := now.Add(m.ttl)
+
+	headerJSON, err := json.Marshal(header{Alg: "HS256", Typ: "JWT"})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	claimsJSON, err := json.Marshal(Claims{
+		Subject:   strconv.Itoa(userID),
+		UserID:    userID,
+		Role:      role,
+		SessionID: sessionID,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expiresAt.Unix(),
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	unsigned := encodeSegment(headerJSON) + "." + encodeSegment(claimsJSON)
+	return unsigned + "." + encodeSegment(m.sign(unsigned)), expiresAt, nil
+}
+
+// Parse verifies the token signature and expiry and returns its claims
+func (m *TokenManager) Parse(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	headerJSON, err := decodeSegment(parts[0])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var h header
+	if err := json.Unmarshal(headerJSON, &h); err != nil || h.Alg != "HS256" {
+		return nil, ErrInvalidToken
+	}
+
+	signature, err := decodeSegment(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	if !hmac.Equal(signature, m.sign(parts[0]+"."+parts[1])) {
+		return nil, ErrInvalidToken
+	}
+
+	claimsJSON, err := decodeSegment(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var claims Claims
+	if err := json.Unmarshal(claimsJSON, &claims); err != nil || claims.UserID <= 0 {
+		return nil, ErrInvalidToken
+	}
+	if m.now().Unix() >= claims.ExpiresAt {
+		return nil, ErrExpiredToken
+	}
+
+	return &claims, nil
+}
+
+func (m *TokenManager) sign(data string) []byte {
+	mac := hmac.New(sha256.New, m.secret)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func encodeSegment(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeSegment(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// forge builds a token from raw header and claims, signed with secret unless signature is given
+func forge(t *testing.T, secret string, h header, claims Claims, signature *string) string {
+	t.Helper()
+	headerJSON, err := json.Marshal(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsigned := encodeSegment(headerJSON) + "." + encodeSegment(claimsJSON)
+	if signature != nil {
+		return unsigned + "." + *signature
+	}
+	m := NewTokenManager(secret, time.Hour)
+	return unsigned + "." + encodeSegment(m.sign(unsigned))
+}
+
+func TestTokenManagerParse(t *testing.T) {
+	const secret = "0123456789abcdef0123456789abcdef"
+	issuedAt := time.Date(2030, time.January, 7, 12, 0, 0, 0, time.UTC)
+	manager := NewTokenManager(secret, time.Hour)
+	manager.now = func() time.Time { return issuedAt }
+
+	valid, _, err := manager.Issue(42, "customer", "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hs256 := header{Alg: "HS256", Typ: "JWT"}
+	claims := Claims{Subject: "42", UserID: 42, Role: "admin", IssuedAt: issuedAt.Unix(), ExpiresAt: issuedAt.Add(time.Hour).Unix()}
+	empty := ""
+	parts := strings.Split(valid, ".")
+
+	tests := []struct {
+		name    string
+		token   string
+		now     time.Time
+		wantErr error
+	}{
+		{name: "valid token", token: valid, now: issuedAt.Add(59 * time.Minute)},
+		{name: "expired token", token: valid, now: issuedAt.Add(time.Hour), wantErr: ErrExpiredToken},
+		{name: "signed with another secret", token: forge(t, "another secret", hs256, claims, nil), now: issuedAt, wantErr: ErrInvalidToken},
+		{name: "claims changed after signing", token: parts[0] + "." + encodeSegment([]byte(`{"uid":1,"role":"admin","exp":4102444800}`)) + "." + parts[2], now: issuedAt, wantErr: ErrInvalidToken},
+		{name: "alg none without signature", token: forge(t, secret, header{Alg: "none", Typ: "JWT"}, claims, &empty), now: issuedAt, wantErr: ErrInvalidToken},
+		{name: "alg none with a valid signature", token: forge(t, secret, header{Alg: "none", Typ: "JWT"}, claims, nil), now: issuedAt, wantErr: ErrInvalidToken},
+		{name: "alg HS512", token: forge(t, secret, header{Alg: "HS512", Typ: "JWT"}, claims, nil), now: issuedAt, wantErr: ErrInvalidToken},
+		{name: "alg RS256", token: forge(t, secret, header{Alg: "RS256", Typ: "JWT"}, claims, nil), now: issuedAt, wantErr: ErrInvalidToken},
+		{name: "empty signature", token: forge(t, secret, hs256, claims, &empty), now: issuedAt, wantErr: ErrInvalidToken},
+		{name: "no user", token: forge(t, secret, hs256, Claims{ExpiresAt: claims.ExpiresAt}, nil), now: issuedAt, wantErr: ErrInvalidToken},
+		{name: "two segments", token: parts[0] + "." + parts[1], now: issuedAt, wantErr: ErrInvalidToken},
+		{name: "not base64", token: "a.b.c!", now: issuedAt, wantErr: ErrInvalidToken},
+		{name: "legacy mock token", token: "mock-jwt-token-user@example.com", now: issuedAt, wantErr: ErrInvalidToken},
+		{name: "empty token", token: "", now: issuedAt, wantErr: ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager.now = func() time.Time { return tt.now }
+			got, err := manager.Parse(tt.token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Parse() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if got.UserID != 42 || got.Role != "customer" || got.SessionID != "session" {
+				t.Errorf("Parse() = %+v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,362 @@
+// Package booking holds appointment workflows that span several records and must
+// succeed or fail as a whole.
+package booking
+
+import (
+	"beep-backend/internal/models"
+	"beep-backend/internal/repository"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// GuaranteePeriod is how long work is guaranteed after the service date
+const GuaranteePeriod = 14 * 24 * time.Hour
+
+var (
+	ErrUserNotFound    = errors.New("user not found")
+	ErrMasterNotFound  = errors.New("master not found")
+	ErrServiceNotFound = errors.New("service not found")
+	ErrCarNotFound     = errors.New("car not found")
+	ErrInvalidTime     = errors.New("invalid time format, use HH:MM")
+	// ErrServiceNotOffered is returned when the master does not offer the service or has it unavailable
+	ErrServiceNotOffered = errors.New("the master does not offer this service")
+	ErrNoServices        = errors.New("at least one service is required")
+	ErrDuplicateService  = errors.New("each service can be booked only once per appointment")
+	ErrQuoteNotFound     = errors.New("price quote not found")
+	ErrQuoteExpired      = errors.New("price quote has expired")
+	// ErrQuoteMismatch is returned when a quote is for another master, car or other services than booked
+	ErrQuoteMismatch = errors.New("price quote does not match the master, car and services booked")
+)
+
+// Service creates and changes appointments
+type Service struct {
+	store           repository.Store
+	slotGranularity time.Duration
+	minLeadTime     time.Duration
+	now             func() time.Time
+}
+
+// Settings tunes how appointments are offered
+type Settings struct {
+	// SlotGranularity is the distance between offered start times, DefaultSlotGranularity if zero
+	SlotGranularity time.Duration
+	// MinLeadTime is how long before its start an appointment can be booked at the latest
+	MinLeadTime time.Duration
+}
+
+func NewService(store repository.Store, settings Settings) *Service {
+	if settings.SlotGranularity <= 0 {
+		settings.SlotGranularity = DefaultSlotGranularity
+	}
+	return &Service{
+		store:           store,
+		slotGranularity: settings.SlotGranularity,
+		minLeadTime:     settings.MinLeadTime,
+		now:             time.Now,
+	}
+}
+
+// Request describes an appointment a customer wants to book.
+// ServiceIDs are performed in the given order, one after another.
+// With a QuoteID the services may be left out and are then the quote's.
+// The car is optional: a catalog car CarID or UserCarID, a car from the customer's garage.
+// Booked with a quote it is the quote's car.
+// Date and Time are the wall-clock start in the master's time zone.
+type Request struct {
+	UserID     int
+	MasterID   int
+	ServiceIDs []int
+	QuoteID    int
+	CarID      int
+	UserCarID  int
+	Date       time.Time
+	Time       string
+	Comment    string
+}
+
+// Book creates a pending appointment together with its guarantee, the first history event and
+// the notifications for the customer and the master. Nothing is stored if any part fails.
+// The appointment has an item per service at the master's price and lasts as long as the master
+// takes for all of them. Booked with a quote, the items and the appointment's price are the quoted
+// prices instead. Returns ErrNoServices or ErrDuplicateService for a malformed service list,
+// ErrQuoteNotFound, ErrQuoteExpired or ErrQuoteMismatch for an unusable quote, ErrCarNotFound
+// for an unknown car or one that is not in the customer's garage,
+// ErrServiceNotOffered if the master cannot be booked for one of the services and
+// a *SlotUnavailableError if the master does not work or is busy at that time.
+func (s *Service) Book(ctx context.Context, req Request) (*models.Appointment, error) {
+	var quote *models.PriceQuote
+	if req.QuoteID != 0 {
+		var err error
+		quote, err = s.quote(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		if len(req.ServiceIDs) == 0 {
+			for _, item := range quote.Items {
+				req.ServiceIDs = append(req.ServiceIDs, item.ServiceID)
+			}
+		}
+	}
+	if len(req.ServiceIDs) == 0 {
+		return nil, ErrNoServices
+	}
+	seen := make(map[int]bool, len(req.ServiceIDs))
+	for _, serviceID := range req.ServiceIDs {
+		if seen[serviceID] {
+			return nil, ErrDuplicateService
+		}
+		seen[serviceID] = true
+	}
+	if quote != nil && len(quote.Items) != len(req.ServiceIDs) {
+		return nil, ErrQuoteMismatch
+	}
+
+	user, err := s.store.GetUserByID(ctx, req.UserID)
+	if err != nil {
+		return nil, notFound(err, ErrUserNotFound)
+	}
+	master, err := s.store.GetMasterByID(ctx, req.MasterID)
+	if err != nil {
+		return nil, notFound(err, ErrMasterNotFound)
+	}
+	carID, userCarID, err := s.car(ctx, req, quote)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		items    []models.AppointmentItem
+		names    []string
+		duration time.Duration
+	)
+	for _, serviceID := range req.ServiceIDs {
+		service, err := s.offeredService(ctx, master.ID, serviceID)
+		if err != nil {
+			return nil, err
+		}
+		price := service.BasePrice
+		if quote != nil {
+			quoted := quote.Item(service.ID)
+			if quoted == nil {
+				return nil, ErrQuoteMismatch
+			}
+			price = quoted.FinalPrice
+		}
+		serviceTime := serviceDuration(service)
+		items = append(items, models.AppointmentItem{
+			ServiceID:       service.ID,
+			ServiceName:     service.Name,
+			Price:           price,
+			DurationMinutes: int(serviceTime / time.Minute),
+		})
+		names = append(names, service.Name)
+		duration += serviceTime
+	}
+	serviceNames := strings.Join(names, ", ")
+	services := servicesPhrase(names)
+
+	schedule, err := s.loadDay(ctx, master, req.Date)
+	if err != nil {
+		return nil, err
+	}
+	start, err := atClock(schedule.date, req.Time)
+	if err != nil {
+		return nil, ErrInvalidTime
+	}
+	if err := schedule.check(start, duration); err != nil {
+		return nil, err
+	}
+	req.Time = start.Format("15:04")
+
+	booking := &models.Booking{
+		Appointment: models.Appointment{
+			UserID:    user.ID,
+			MasterID:  master.ID,
+			ServiceID: items[0].ServiceID,
+			Date:      req.Date,
+			Time:      req.Time,
+			Status:    models.AppointmentPending,
+			Comment:   req.Comment,
+			Items:     items,
+			CarID:     carID,
+			UserCarID: userCarID,
+		},
+		Slot: models.TimeRange{Start: start, End: start.Add(duration)},
+		Guarantee: &models.Guarantee{
+			UserID:      user.ID,
+			ServiceName: serviceNames,
+			MasterName:  master.Name,
+			ServiceDate: req.Date,
+			ExpiryDate:  req.Date.Add(GuaranteePeriod),
+		},
+		Event: &models.AppointmentEvent{
+			Type:      models.AppointmentEventCreated,
+			ToStatus:  models.AppointmentPending,
+			ActorID:   &user.ID,
+			ActorRole: models.RoleCustomer,
+		},
+	}
+
+	// Each side sees the time in their own zone
+	customerTime := start.In(userLocation(user, start.Location()))
+	booking.Notifications = append(booking.Notifications, models.Notification{
+		UserID: user.ID,
+		Type:   "appointment_created",
+		Title:  "Запись создана",
+		Message: fmt.Sprintf("Вы записаны к мастеру %s на %s. Дата: %s, Время: %s. Статус: Ожидание подтверждения",
+			master.Name, services, customerTime.Format("02.01.2006"), customerTime.Format("15:04")),
+	})
+	if master.UserID > 0 {
+		booking.Notifications = append(booking.Notifications, models.Notification{
+			UserID: master.UserID,
+			Type:   "new_appointment",
+			Title:  "Новая запись",
+			Message: fmt.Sprintf("Клиент %s записался к вам на %s. Дата: %s, Время: %s. Телефон: %s",
+				user.Name, services, start.Format("02.01.2006"), start.Format("15:04"), user.Phone),
+		})
+	}
+
+	if quote != nil {
+		booking.Appointment.QuoteID = &quote.ID
+		booking.Appointment.Price = &quote.Total
+	}
+
+	if err := s.store.CreateBooking(ctx, booking); err != nil {
+		if errors.Is(err, repository.ErrSlotTaken) {
+			// Another request booked an overlapping time after our check
+			return nil, s.slotTaken(ctx, master, start, duration)
+		}
+		return nil, err
+	}
+	return &booking.Appointment, nil
+}
+
+// quote loads the quote a request is booked with and checks it can be booked by the customer
+// for the master and car. Quotes of other customers are reported as not found.
+func (s *Service) quote(ctx context.Context, req Request) (*models.PriceQuote, error) {
+	quote, err := s.store.GetPriceQuote(ctx, req.QuoteID)
+	if err != nil {
+		return nil, notFound(err, ErrQuoteNotFound)
+	}
+	if quote.UserID != req.UserID {
+		return nil, ErrQuoteNotFound
+	}
+	if quote.Expired(s.now()) {
+		return nil, ErrQuoteExpired
+	}
+	if quote.MasterID != req.MasterID {
+		return nil, ErrQuoteMismatch
+	}
+	if (req.CarID != 0 && req.CarID != quote.CarID) || (req.UserCarID != 0 && req.UserCarID != quote.UserCarID) {
+		return nil, ErrQuoteMismatch
+	}
+	return quote, nil
+}
+
+// car returns the catalog car and the customer's own car an appointment is for, either may be nil.
+// A quote's car is taken as it is, otherwise the requested car must exist and a user car
+// must be in the customer's garage. Returns ErrCarNotFound.
+func (s *Service) car(ctx context.Context, req Request, quote *models.PriceQuote) (carID, userCarID *int, err error) {
+	if quote != nil {
+		carID = &quote.CarID
+		if quote.UserCarID != 0 {
+			userCarID = &quote.UserCarID
+		}
+		return carID, userCarID, nil
+	}
+	if req.UserCarID != 0 {
+		userCar, err := s.store.GetUserCar(ctx, req.UserCarID, req.UserID)
+		if err != nil {
+			return nil, nil, notFound(err, ErrCarNotFound)
+		}
+		return userCar.CarID, &userCar.ID, nil
+	}
+	if req.CarID != 0 {
+		car, err := s.store.GetCarByID(ctx, req.CarID)
+		if err != nil {
+			return nil, nil, notFound(err, ErrCarNotFound)
+		}
+		return &car.ID, nil, nil
+	}
+	return nil, nil, nil
+}
+
+// offeredService is the service as the master offers it, with the master's price and duration
+// in place of the catalog's. Returns ErrServiceNotFound or ErrServiceNotOffered.
+func (s *Service) offeredService(ctx context.Context, masterID, serviceID int) (*models.Service, error) {
+	service, err := s.store.GetServiceByID(ctx, serviceID)
+	if err != nil {
+		return nil, notFound(err, ErrServiceNotFound)
+	}
+	offer, err := s.store.GetMasterService(ctx, masterID, serviceID)
+	if err != nil {
+		return nil, notFound(err, ErrServiceNotOffered)
+	}
+	if !offer.IsAvailable {
+		return nil, ErrServiceNotOffered
+	}
+	service.BasePrice = offer.BasePrice
+	service.DurationMinutes = offer.DurationMinutes
+	return service, nil
+}
+
+// servicesPhrase names the services of an appointment for messages, e.g. "услугу Мойка"
+// or "услуги Мойка, Полировка", fitting after "на" or a verb
+func servicesPhrase(names []string) string {
+	switch len(names) {
+	case 0:
+		return "услугу"
+	case 1:
+		return "услугу " + names[0]
+	default:
+		return "услуги " + strings.Join(names, ", ")
+	}
+}
+
+// appointmentServices is the servicesPhrase of an appointment's services. Appointments without
+// items fall back to their serviceID.
+func (s *Service) appointmentServices(ctx context.Context, appointmentID, serviceID int) string {
+	var names []string
+	if items, err := s.store.GetAppointmentItems(ctx, appointmentID); err == nil {
+		for _, item := range items {
+			names = append(names, item.ServiceName)
+		}
+	}
+	if len(names) == 0 {
+		if service, err := s.store.GetServiceByID(ctx, serviceID); err == nil {
+			names = append(names, service.Name)
+		}
+	}
+	return servicesPhrase(names)
+}
+
+func notFound(err, notFoundErr error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return notFoundErr
+	}
+	return err
+}
+
+// slotTaken builds the conflict error with suggestions from the bookings as they are now
+func (s *Service) slotTaken(ctx context.Context, master *models.Master, start time.Time, duration time.Duration) error {
+	conflict := &SlotUnavailableError{Reason: "This time is already booked"}
+	if schedule, err := s.loadDay(ctx, master, start); err == nil {
+		conflict.Suggestions = schedule.nearestFree(start, duration)
+	}
+	return conflict
+}
+
+// userLocation is the user's time zone, or fallback if the user has none or it is invalid
+func userLocation(user *models.User, fallback *time.Location) *time.Location {
+	if user.TimeZone == "" {
+		return fallback
+	}
+	loc, err := time.LoadLocation(user.TimeZone)
+	if err != nil {
+		return fallback
+	}
+	return loc
+}
@@ -0,0 +1,295 @@
+package booking
+
+import (
+	"beep-backend/internal/models"
+	"beep-backend/internal/repository/memory"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fixture is a master offering two services, a customer and a catalog car in a memory store,
+// with the clock a day before testDate
+type fixture struct {
+	store    *memory.Store
+	service  *Service
+	customer *models.User
+	master   *models.Master
+	wash     models.Service
+	polish   models.Service
+	car      models.Car
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	ctx := context.Background()
+	f := &fixture{store: memory.New()}
+	f.service = NewService(f.store, Settings{SlotGranularity: time.Hour})
+	f.service.now = func() time.Time { return testDate.AddDate(0, 0, -1) }
+
+	f.wash = f.store.AddService(models.Service{Name: "Мойка", BasePrice: 100, DurationMinutes: 60})
+	f.polish = f.store.AddService(models.Service{Name: "Полировка", BasePrice: 200, DurationMinutes: 60})
+	f.car = f.store.AddCar(models.Car{Brand: "Kia", Model: "Rio", Year: 2018, Type: "Economy"})
+
+	var err error
+	if f.customer, err = f.store.CreateUser(ctx, "Клиент", "customer@example.com", "+77001112233", "hash"); err != nil {
+		t.Fatal(err)
+	}
+	masterUser, err := f.store.CreateUser(ctx, "Мастер", "master@example.com", "+77001112244", "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.master, err = f.store.CreateMaster(ctx, masterUser.ID, "Мастер", "master@example.com", "+77001112244", "Детейлинг", ""); err != nil {
+		t.Fatal(err)
+	}
+	for _, service := range []models.Service{f.wash, f.polish} {
+		offer := &models.MasterService{MasterID: f.master.ID, ServiceID: service.ID, BasePrice: service.BasePrice, DurationMinutes: service.DurationMinutes, IsAvailable: true}
+		if err := f.store.SaveMasterService(ctx, offer); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return f
+}
+
+// quote stores a quote of the customer for the wash at the master, changed by edit
+func (f *fixture) quote(t *testing.T, edit func(quote *models.PriceQuote)) *models.PriceQuote {
+	t.Helper()
+	quote := &models.PriceQuote{
+		UserID:    f.customer.ID,
+		CarID:     f.car.ID,
+		MasterID:  f.master.ID,
+		Items:     []models.CalculatePriceResponse{{ServiceID: f.wash.ID, FinalPrice: 90}},
+		Total:     90,
+		ExpiresAt: testDate,
+	}
+	if edit != nil {
+		edit(quote)
+	}
+	if err := f.store.CreatePriceQuote(context.Background(), quote); err != nil {
+		t.Fatal(err)
+	}
+	return quote
+}
+
+func TestBookWithQuote(t *testing.T) {
+	f := newFixture(t)
+	otherCar := f.store.AddCar(models.Car{Brand: "BMW", Model: "X5", Year: 2020, Type: "Premium"})
+	stranger, err := f.store.CreateUser(context.Background(), "Другой", "other@example.com", "+77001112255", "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		edit    func(quote *models.PriceQuote)
+		request func(req *Request)
+		wantErr error
+	}{
+		{name: "own quote"},
+		{name: "own quote for the same car", request: func(req *Request) { req.CarID = f.car.ID }},
+		{name: "quote of another customer", edit: func(q *models.PriceQuote) { q.UserID = stranger.ID }, wantErr: ErrQuoteNotFound},
+		{name: "expired quote", edit: func(q *models.PriceQuote) { q.ExpiresAt = testDate.AddDate(0, 0, -2) }, wantErr: ErrQuoteExpired},
+		// Quotes without a master can no longer be made; ones stored before are not booked at another master's prices
+		{name: "quote without a master", edit: func(q *models.PriceQuote) { q.MasterID = 0 }, wantErr: ErrQuoteMismatch},
+		{name: "another car than quoted", request: func(req *Request) { req.CarID = otherCar.ID }, wantErr: ErrQuoteMismatch},
+		{name: "another user car than quoted", request: func(req *Request) { req.UserCarID = 1 }, wantErr: ErrQuoteMismatch},
+		{name: "other services than quoted", request: func(req *Request) { req.ServiceIDs = []int{f.polish.ID} }, wantErr: ErrQuoteMismatch},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quote := f.quote(t, tt.edit)
+			req := Request{
+				UserID:   f.customer.ID,
+				MasterID: f.master.ID,
+				QuoteID:  quote.ID,
+				Date:     testDate,
+				// Each case books its own hour so successful ones do not collide
+				Time: time.Date(0, 1, 1, 9+i, 0, 0, 0, time.UTC).Format("15:04"),
+			}
+			if tt.request != nil {
+				tt.request(&req)
+			}
+
+			appointment, err := f.service.Book(context.Background(), req)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Book() = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Book() = %v", err)
+			}
+			if appointment.QuoteID == nil || *appointment.QuoteID != quote.ID {
+				t.Errorf("QuoteID = %v, want %d", appointment.QuoteID, quote.ID)
+			}
+			if appointment.Price == nil || *appointment.Price != quote.Total {
+				t.Errorf("Price = %v, want the quoted %v", appointment.Price, quote.Total)
+			}
+			if appointment.CarID == nil || *appointment.CarID != f.car.ID {
+				t.Errorf("CarID = %v, want the quoted car %d", appointment.CarID, f.car.ID)
+			}
+		})
+	}
+}
+
+func TestBookCar(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	ownCar, err := f.store.CreateUserCar(ctx, f.customer.ID, &f.car.ID, "Моя Kia", 2015, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stranger, err := f.store.CreateUser(ctx, "Другой", "other@example.com", "+77001112255", "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	strangerCar, err := f.store.CreateUserCar(ctx, stranger.ID, &f.car.ID, "Чужая Kia", 2016, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		carID         int
+		userCarID     int
+		wantCarID     *int
+		wantUserCarID *int
+		wantErr       error
+	}{
+		{name: "no car"},
+		{name: "catalog car", carID: f.car.ID, wantCarID: &f.car.ID},
+		{name: "own car", userCarID: ownCar.ID, wantCarID: &f.car.ID, wantUserCarID: &ownCar.ID},
+		{name: "unknown catalog car", carID: 999, wantErr: ErrCarNotFound},
+		{name: "car of another customer", userCarID: strangerCar.ID, wantErr: ErrCarNotFound},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			appointment, err := f.service.Book(ctx, Request{
+				UserID:     f.customer.ID,
+				MasterID:   f.master.ID,
+				ServiceIDs: []int{f.wash.ID},
+				CarID:      tt.carID,
+				UserCarID:  tt.userCarID,
+				Date:       testDate,
+				Time:       time.Date(0, 1, 1, 9+i, 0, 0, 0, time.UTC).Format("15:04"),
+			})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Book() = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Book() = %v", err)
+			}
+			if !reflect.DeepEqual(appointment.CarID, tt.wantCarID) || !reflect.DeepEqual(appointment.UserCarID, tt.wantUserCarID) {
+				t.Errorf("car = %v/%v, want %v/%v", appointment.CarID, appointment.UserCarID, tt.wantCarID, tt.wantUserCarID)
+			}
+		})
+	}
+}
+
+func TestBookNamesEveryService(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	appointment, err := f.service.Book(ctx, Request{
+		UserID:     f.customer.ID,
+		MasterID:   f.master.ID,
+		ServiceIDs: []int{f.wash.ID, f.polish.ID},
+		Date:       testDate,
+		Time:       "10:00",
+	})
+	if err != nil {
+		t.Fatalf("Book() = %v", err)
+	}
+	if len(appointment.Items) != 2 {
+		t.Fatalf("got %d items, want 2", len(appointment.Items))
+	}
+
+	_, err = f.service.ChangeStatus(ctx, appointment, Actor{UserID: f.master.UserID, Role: models.RoleMaster}, models.AppointmentConfirmed, "")
+	if err != nil {
+		t.Fatalf("ChangeStatus() = %v", err)
+	}
+	notifications, err := f.store.GetUserNotifications(ctx, f.customer.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notifications) != 2 {
+		t.Fatalf("got %d notifications, want 2", len(notifications))
+	}
+	for _, notification := range notifications {
+		if !strings.Contains(notification.Message, "услуги Мойка, Полировка") {
+			t.Errorf("%s message does not name both services: %q", notification.Type, notification.Message)
+		}
+	}
+}
+
+func TestBookSlotTaken(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	req := Request{UserID: f.customer.ID, MasterID: f.master.ID, ServiceIDs: []int{f.wash.ID, f.polish.ID}, Date: testDate, Time: "10:00"}
+	if _, err := f.service.Book(ctx, req); err != nil {
+		t.Fatalf("Book() = %v", err)
+	}
+
+	// The first booking runs until 12:00
+	req.ServiceIDs, req.Time = []int{f.wash.ID}, "11:00"
+	_, err := f.service.Book(ctx, req)
+	var slotErr *SlotUnavailableError
+	if !errors.As(err, &slotErr) {
+		t.Fatalf("Book() = %v, want a *SlotUnavailableError", err)
+	}
+	if want := []string{"09:00", "12:00", "13:00"}; !reflect.DeepEqual(slotErr.Suggestions, want) {
+		t.Errorf("Suggestions = %v, want %v", slotErr.Suggestions, want)
+	}
+}
+
+func TestAvailableSlotsAfterMidnightBooking(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	loc, err := location(f.master)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := testDate.AddDate(0, 0, 1)
+
+	// The master works from midnight on the next day, right after a late booking
+	err = f.store.CreateScheduleException(ctx, &models.ScheduleException{
+		MasterID:  f.master.ID,
+		Type:      models.ExceptionCustomHours,
+		StartDate: next,
+		EndDate:   next,
+		StartTime: "00:00",
+		EndTime:   "04:00",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(testDate.Year(), testDate.Month(), testDate.Day(), 23, 0, 0, 0, loc)
+	err = f.store.CreateBooking(ctx, &models.Booking{
+		Appointment: models.Appointment{
+			UserID:    f.customer.ID,
+			MasterID:  f.master.ID,
+			ServiceID: f.wash.ID,
+			Date:      testDate,
+			Time:      "23:00",
+			Status:    models.AppointmentConfirmed,
+		},
+		Slot: models.TimeRange{Start: start, End: start.Add(2 * time.Hour)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	availability, err := f.service.AvailableSlots(ctx, f.master.ID, next, []int{f.wash.ID})
+	if err != nil {
+		t.Fatalf("AvailableSlots() = %v", err)
+	}
+	if want := []string{"01:00", "02:00", "03:00"}; !reflect.DeepEqual(availability.Slots, want) {
+		t.Errorf("Slots = %v, want %v", availability.Slots, want)
+	}
+}
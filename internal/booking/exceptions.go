@@ -0,0 +1,214 @@
+package booking
+
+import (
+	"beep-backend/internal/models"
+	"beep-backend/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// What to do with appointments that no longer fit the master's schedule
+const (
+	ResolveCancel     = "cancel"
+	ResolveReschedule = "reschedule"
+)
+
+// rescheduleSearchDays is how many days ahead a new time is looked for before an appointment is cancelled
+const rescheduleSearchDays = 14
+
+// ErrInvalidResolveAction is returned for an action other than ResolveCancel or ResolveReschedule
+var ErrInvalidResolveAction = errors.New("action must be cancel or reschedule")
+
+// Resolution reports what happened to one affected appointment
+type Resolution struct {
+	AppointmentID int `json:"appointment_id"`
+	// Status is cancelled or rescheduled
+	Status string `json:"status"`
+	Date   string `json:"date"`
+	Time   string `json:"time"`
+}
+
+// AffectedAppointments lists the master's active appointments from..to that are no longer
+// within working hours, e.g. after a day off or a break was added. Past dates are skipped.
+func (s *Service) AffectedAppointments(ctx context.Context, masterID int, from, to time.Time) ([]models.ScheduledAppointment, error) {
+	master, err := s.store.GetMasterByID(ctx, masterID)
+	if err != nil {
+		return nil, notFound(err, ErrMasterNotFound)
+	}
+	return s.affectedAppointments(ctx, master, from, to)
+}
+
+func (s *Service) affectedAppointments(ctx context.Context, master *models.Master, from, to time.Time) ([]models.ScheduledAppointment, error) {
+	loc, err := location(master)
+	if err != nil {
+		return nil, err
+	}
+	from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, loc)
+	to = time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, loc)
+	if today := s.today(loc); from.Before(today) {
+		from = today
+	}
+	if to.Before(from) {
+		return nil, nil
+	}
+
+	appointments, err := s.store.GetMasterAppointmentsBetween(ctx, master.ID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	var affected []models.ScheduledAppointment
+	days := make(map[string][]models.TimeRange)
+	for _, appointment := range appointments {
+		key := appointment.Date.Format("2006-01-02")
+		windows, ok := days[key]
+		if !ok {
+			date := time.Date(appointment.Date.Year(), appointment.Date.Month(), appointment.Date.Day(), 0, 0, 0, 0, loc)
+			if windows, err = s.workingWindows(ctx, master.ID, date); err != nil {
+				return nil, err
+			}
+			days[key] = windows
+		}
+		if !(&day{windows: windows}).withinHours(appointment.Slot) {
+			affected = append(affected, appointment)
+		}
+	}
+	return affected, nil
+}
+
+// ResolveAffected cancels or moves every affected appointment from..to and notifies the customers.
+// Rescheduling picks the first free time of the same length on the same or a following day and
+// cancels the appointment if there is none within rescheduleSearchDays. reason is added to the notifications.
+func (s *Service) ResolveAffected(ctx context.Context, masterID int, from, to time.Time, action, reason string) ([]Resolution, error) {
+	if action != ResolveCancel && action != ResolveReschedule {
+		return nil, ErrInvalidResolveAction
+	}
+
+	master, err := s.store.GetMasterByID(ctx, masterID)
+	if err != nil {
+		return nil, notFound(err, ErrMasterNotFound)
+	}
+	loc, err := location(master)
+	if err != nil {
+		return nil, err
+	}
+	affected, err := s.affectedAppointments(ctx, master, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	resolutions := []Resolution{}
+	for _, appointment := range affected {
+		services := s.appointmentServices(ctx, appointment.ID, appointment.ServiceID)
+		// Customers see times in their own zone
+		customerLoc := loc
+		if customer, err := s.store.GetUserByID(ctx, appointment.UserID); err == nil {
+			customerLoc = userLocation(customer, loc)
+		}
+		oldStart := appointment.Slot.Start.In(customerLoc)
+		was := fmt.Sprintf("Мастер %s не сможет принять вас %s в %s.",
+			master.Name, oldStart.Format("02.01.2006"), oldStart.Format("15:04"))
+		if reason != "" {
+			was += " Причина: " + reason + "."
+		}
+
+		if action == ResolveReschedule {
+			moved, err := s.moveToNextFree(ctx, master, appointment, reason, func(slot models.TimeRange) []models.Notification {
+				newStart := slot.Start.In(customerLoc)
+				return []models.Notification{{
+					UserID: appointment.UserID,
+					Type:   "appointment_rescheduled",
+					Title:  "Запись перенесена",
+					Message: fmt.Sprintf("%s Запись на %s перенесена на %s в %s.",
+						was, services, newStart.Format("02.01.2006"), newStart.Format("15:04")),
+				}}
+			})
+			if err != nil {
+				return resolutions, err
+			}
+			if moved != nil {
+				resolutions = append(resolutions, Resolution{
+					AppointmentID: appointment.ID,
+					Status:        "rescheduled",
+					Date:          moved.Start.Format("2006-01-02"),
+					Time:          moved.Start.Format("15:04"),
+				})
+				continue
+			}
+		}
+
+		event := &models.AppointmentEvent{
+			FromStatus: appointment.Status,
+			ToStatus:   models.AppointmentCancelled,
+			ActorID:    userRef(master.UserID),
+			ActorRole:  models.RoleMaster,
+			Note:       reason,
+		}
+		err := s.store.ChangeAppointmentStatus(ctx, appointment.ID, event, []models.Notification{{
+			UserID:  appointment.UserID,
+			Type:    "appointment_cancelled",
+			Title:   "Запись отменена",
+			Message: fmt.Sprintf("%s Запись на %s отменена.", was, services),
+		}})
+		if err != nil {
+			return resolutions, err
+		}
+		resolutions = append(resolutions, Resolution{
+			AppointmentID: appointment.ID,
+			Status:        "cancelled",
+			Date:          appointment.Slot.Start.In(loc).Format("2006-01-02"),
+			Time:          appointment.Slot.Start.In(loc).Format("15:04"),
+		})
+	}
+	return resolutions, nil
+}
+
+// moveToNextFree moves the appointment to the first free slot of the same length after its current start,
+// recording the move with reason as made by the master.
+// Returns nil without an error if no slot is free within rescheduleSearchDays.
+func (s *Service) moveToNextFree(ctx context.Context, master *models.Master, appointment models.ScheduledAppointment, reason string, notify func(models.TimeRange) []models.Notification) (*models.TimeRange, error) {
+	loc, err := location(master)
+	if err != nil {
+		return nil, err
+	}
+	first := appointment.Slot.Start.In(loc)
+	duration := appointment.Slot.End.Sub(appointment.Slot.Start)
+	for offset := 0; offset <= rescheduleSearchDays; offset++ {
+		schedule, err := s.loadDay(ctx, master, first.AddDate(0, 0, offset))
+		if err != nil {
+			return nil, err
+		}
+		schedule.release(appointment.Slot)
+
+		for _, start := range schedule.free(duration) {
+			if start.Before(appointment.Slot.Start) {
+				continue
+			}
+			slot := models.TimeRange{Start: start, End: start.Add(duration)}
+			event := &models.AppointmentEvent{ActorID: userRef(master.UserID), ActorRole: models.RoleMaster, Note: reason}
+			err := s.store.MoveBooking(ctx, appointment.ID, slot, event, notify(slot))
+			if errors.Is(err, repository.ErrSlotTaken) {
+				// Booked by someone else in the meantime, try the next start
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			return &slot, nil
+		}
+	}
+	return nil, nil
+}
+
+// release drops slot from the booked intervals, so an appointment does not block its own move
+func (d *day) release(slot models.TimeRange) {
+	booked := d.booked[:0]
+	for _, interval := range d.booked {
+		if !interval.Start.Equal(slot.Start) || !interval.End.Equal(slot.End) {
+			booked = append(booked, interval)
+		}
+	}
+	d.booked = booked
+}
@@ -0,0 +1,229 @@
+package booking
+
+import (
+	"beep-backend/internal/models"
+	"beep-backend/internal/repository"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrNotReschedulable   = errors.New("only pending or confirmed appointments can be rescheduled")
+	ErrSameTime           = errors.New("the appointment is already at this time")
+	ErrRescheduleNotFound = errors.New("reschedule request not found")
+	ErrRescheduleClosed   = errors.New("reschedule request has already been resolved")
+	ErrRescheduleExpired  = errors.New("the proposed time has already passed")
+)
+
+// RescheduleProposal is a new time a customer proposes for an appointment.
+// Date and Time are the wall-clock start in the master's time zone.
+type RescheduleProposal struct {
+	Date    time.Time
+	Time    string
+	Comment string
+}
+
+// RequestReschedule checks the proposed time against the master's availability like Book and holds it
+// until the master accepts or declines. The appointment keeps its current time meanwhile; a previous
+// pending request of the appointment is replaced. Returns a *SlotUnavailableError if the time is not free.
+func (s *Service) RequestReschedule(ctx context.Context, appointmentID int, actor Actor, proposal RescheduleProposal) (*models.RescheduleRequest, error) {
+	appointment, err := s.store.GetScheduledAppointment(ctx, appointmentID)
+	if err != nil {
+		return nil, err
+	}
+	if !movable(appointment.Status) {
+		return nil, ErrNotReschedulable
+	}
+	master, err := s.store.GetMasterByID(ctx, appointment.MasterID)
+	if err != nil {
+		return nil, notFound(err, ErrMasterNotFound)
+	}
+
+	schedule, err := s.loadDay(ctx, master, proposal.Date)
+	if err != nil {
+		return nil, err
+	}
+	start, err := atClock(schedule.date, proposal.Time)
+	if err != nil {
+		return nil, ErrInvalidTime
+	}
+	if start.Equal(appointment.Slot.Start) {
+		return nil, ErrSameTime
+	}
+	duration := appointment.Slot.End.Sub(appointment.Slot.Start)
+
+	// Neither the appointment itself nor the request being replaced stand in the way
+	schedule.release(appointment.Slot)
+	requests, err := s.store.GetAppointmentRescheduleRequests(ctx, appointment.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, previous := range requests {
+		if previous.Status == models.ReschedulePending {
+			schedule.release(previous.Slot)
+		}
+	}
+	if err := schedule.check(start, duration); err != nil {
+		return nil, err
+	}
+
+	request := &models.RescheduleRequest{
+		AppointmentID: appointment.ID,
+		MasterID:      master.ID,
+		RequestedBy:   actor.UserID,
+		Date:          schedule.date,
+		Time:          start.Format("15:04"),
+		Slot:          models.TimeRange{Start: start, End: start.Add(duration)},
+		Comment:       proposal.Comment,
+	}
+	event := &models.AppointmentEvent{
+		FromStatus: appointment.Status,
+		ToStatus:   appointment.Status,
+		ActorID:    userRef(actor.UserID),
+		ActorRole:  actor.Role,
+		Note:       "Предложено время " + start.Format("02.01.2006 15:04"),
+	}
+	if proposal.Comment != "" {
+		event.Note += ". " + proposal.Comment
+	}
+
+	var notifications []models.Notification
+	if master.UserID > 0 {
+		customerName := "Клиент"
+		if customer, err := s.store.GetUserByID(ctx, appointment.UserID); err == nil {
+			customerName = "Клиент " + customer.Name
+		}
+		old := appointment.Slot.Start.In(start.Location())
+		message := fmt.Sprintf("%s просит перенести запись на %s с %s в %s на %s в %s.",
+			customerName, s.appointmentServices(ctx, appointment.ID, appointment.ServiceID),
+			old.Format("02.01.2006"), old.Format("15:04"), start.Format("02.01.2006"), start.Format("15:04"))
+		if proposal.Comment != "" {
+			message += " Комментарий: " + proposal.Comment
+		}
+		notifications = append(notifications, models.Notification{
+			UserID:  master.UserID,
+			Type:    "reschedule_requested",
+			Title:   "Запрос на перенос записи",
+			Message: message,
+		})
+	}
+
+	if err := s.store.CreateRescheduleRequest(ctx, request, event, notifications); err != nil {
+		if errors.Is(err, repository.ErrSlotTaken) {
+			return nil, s.slotTaken(ctx, master, start, duration)
+		}
+		return nil, err
+	}
+	return request, nil
+}
+
+// AcceptReschedule moves the appointment to the time held by the master's pending request and releases
+// the old time. A request whose time has passed is closed as expired and ErrRescheduleExpired returned.
+func (s *Service) AcceptReschedule(ctx context.Context, master *models.Master, requestID int) (*models.RescheduleRequest, error) {
+	request, appointment, err := s.openRescheduleRequest(ctx, master, requestID)
+	if err != nil {
+		return nil, err
+	}
+	if request.Slot.Start.Before(s.now()) {
+		if err := s.store.CloseRescheduleRequest(ctx, requestID, models.RescheduleExpired, "", nil, nil); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, ErrRescheduleExpired
+	}
+
+	start := s.customerTime(ctx, master, appointment.UserID, request.Slot.Start)
+	event := &models.AppointmentEvent{ActorID: userRef(master.UserID), ActorRole: models.RoleMaster}
+	notifications := []models.Notification{{
+		UserID: appointment.UserID,
+		Type:   "appointment_rescheduled",
+		Title:  "Запись перенесена",
+		Message: fmt.Sprintf("Мастер %s согласился перенести вашу запись на %s на %s в %s.",
+			master.Name, s.appointmentServices(ctx, appointment.ID, appointment.ServiceID), start.Format("02.01.2006"), start.Format("15:04")),
+	}}
+
+	if err := s.store.AcceptRescheduleRequest(ctx, requestID, event, notifications); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRescheduleClosed
+		}
+		return nil, err
+	}
+	return s.store.GetRescheduleRequest(ctx, requestID)
+}
+
+// DeclineReschedule closes the master's pending request, releasing the held time.
+// The appointment keeps its time; reason is passed on to the customer.
+func (s *Service) DeclineReschedule(ctx context.Context, master *models.Master, requestID int, reason string) (*models.RescheduleRequest, error) {
+	request, appointment, err := s.openRescheduleRequest(ctx, master, requestID)
+	if err != nil {
+		return nil, err
+	}
+
+	proposed := s.customerTime(ctx, master, appointment.UserID, request.Slot.Start)
+	current := s.customerTime(ctx, master, appointment.UserID, appointment.Slot.Start)
+	event := &models.AppointmentEvent{
+		Type:       models.AppointmentEventRescheduleDeclined,
+		FromStatus: appointment.Status,
+		ToStatus:   appointment.Status,
+		ActorID:    userRef(master.UserID),
+		ActorRole:  models.RoleMaster,
+		Note:       reason,
+	}
+	message := fmt.Sprintf("Мастер %s не может перенести вашу запись на %s на %s в %s. Запись остаётся %s в %s.",
+		master.Name, s.appointmentServices(ctx, appointment.ID, appointment.ServiceID),
+		proposed.Format("02.01.2006"), proposed.Format("15:04"), current.Format("02.01.2006"), current.Format("15:04"))
+	if reason != "" {
+		message += " Причина: " + reason + "."
+	}
+	notifications := []models.Notification{{
+		UserID:  appointment.UserID,
+		Type:    "reschedule_declined",
+		Title:   "Перенос записи отклонён",
+		Message: message,
+	}}
+
+	if err := s.store.CloseRescheduleRequest(ctx, requestID, models.RescheduleDeclined, reason, event, notifications); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRescheduleClosed
+		}
+		return nil, err
+	}
+	return s.store.GetRescheduleRequest(ctx, requestID)
+}
+
+// openRescheduleRequest loads a pending request addressed to master together with its appointment
+func (s *Service) openRescheduleRequest(ctx context.Context, master *models.Master, requestID int) (*models.RescheduleRequest, *models.ScheduledAppointment, error) {
+	request, err := s.store.GetRescheduleRequest(ctx, requestID)
+	if err != nil {
+		return nil, nil, notFound(err, ErrRescheduleNotFound)
+	}
+	if request.MasterID != master.ID {
+		return nil, nil, ErrRescheduleNotFound
+	}
+	if request.Status != models.ReschedulePending {
+		return nil, nil, ErrRescheduleClosed
+	}
+	appointment, err := s.store.GetScheduledAppointment(ctx, request.AppointmentID)
+	if err != nil {
+		return nil, nil, notFound(err, ErrRescheduleNotFound)
+	}
+	return request, appointment, nil
+}
+
+// movable reports whether an appointment in status has not started and can still be moved
+func movable(status string) bool {
+	return status == models.AppointmentPending || status == models.AppointmentConfirmed
+}
+
+// customerTime is t in the time zone of the user, falling back to the master's zone
+func (s *Service) customerTime(ctx context.Context, master *models.Master, userID int, t time.Time) time.Time {
+	if loc, err := location(master); err == nil {
+		t = t.In(loc)
+	}
+	if customer, err := s.store.GetUserByID(ctx, userID); err == nil {
+		return t.In(userLocation(customer, t.Location()))
+	}
+	return t
+}
@@ -0,0 +1,388 @@
+package booking
+
+import (
+	"beep-backend/internal/models"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultDuration is assumed for services without a duration and when no service is given
+	defaultDuration = 60 * time.Minute
+	// DefaultSlotGranularity is the distance between offered start times unless configured
+	DefaultSlotGranularity = time.Hour
+	// suggestionCount is how many alternatives are offered when a slot is unavailable
+	suggestionCount = 3
+)
+
+// Working hours used for days a master has no schedule entry for
+const (
+	defaultScheduleStart = "08:00"
+	defaultScheduleEnd   = "19:00"
+)
+
+// SlotUnavailableError is returned when the requested time cannot be booked.
+// Suggestions holds the nearest free start times of the same day, as HH:MM.
+type SlotUnavailableError struct {
+	Reason      string
+	Suggestions []string
+}
+
+func (e *SlotUnavailableError) Error() string {
+	return e.Reason
+}
+
+// day is what decides which times of one date can be booked with a master.
+// All times are in the master's location.
+type day struct {
+	date time.Time
+	// windows are the working hours in chronological order, empty on days off
+	windows []models.TimeRange
+	step    time.Duration
+	booked  []models.TimeRange
+	// earliest is the first start that can still be booked, now plus the minimum lead time
+	earliest time.Time
+}
+
+// loadDay reads the master's working hours, schedule exceptions and booked intervals for the
+// calendar date of date in the master's time zone
+func (s *Service) loadDay(ctx context.Context, master *models.Master, date time.Time) (*day, error) {
+	loc, err := location(master)
+	if err != nil {
+		return nil, err
+	}
+	date = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, loc)
+
+	windows, err := s.workingWindows(ctx, master.ID, date)
+	if err != nil {
+		return nil, err
+	}
+
+	// Bookings from the day before that run past midnight are included
+	booked, err := s.store.GetBookedIntervals(ctx, master.ID, date, date.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+
+	return &day{
+		date:     date,
+		windows:  windows,
+		step:     s.slotGranularity,
+		booked:   booked,
+		earliest: s.now().Add(s.minLeadTime).In(loc),
+	}, nil
+}
+
+// location is the master's time zone, models.DefaultTimeZone if none is set
+func location(master *models.Master) (*time.Location, error) {
+	zone := master.TimeZone
+	if zone == "" {
+		zone = models.DefaultTimeZone
+	}
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone of master %d: %w", master.ID, err)
+	}
+	return loc, nil
+}
+
+// today is the current calendar date in loc
+func (s *Service) today(loc *time.Location) time.Time {
+	now := s.now().In(loc)
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+}
+
+// workingWindows applies the schedule exceptions for date to the weekly schedule.
+// A day off or vacation closes the day, custom hours replace the weekly windows and breaks are cut out.
+func (s *Service) workingWindows(ctx context.Context, masterID int, date time.Time) ([]models.TimeRange, error) {
+	exceptions, err := s.store.GetScheduleExceptions(ctx, masterID, date, date)
+	if err != nil {
+		return nil, err
+	}
+
+	var custom, breaks []models.TimeRange
+	for _, exception := range exceptions {
+		switch exception.Type {
+		case models.ExceptionDayOff, models.ExceptionVacation:
+			return nil, nil
+		case models.ExceptionCustomHours, models.ExceptionBreak:
+			window, err := clockRange(date, exception.StartTime, exception.EndTime)
+			if err != nil {
+				return nil, fmt.Errorf("invalid schedule exception %d: %w", exception.ID, err)
+			}
+			if exception.Type == models.ExceptionBreak {
+				breaks = append(breaks, window)
+			} else {
+				custom = append(custom, window)
+			}
+		}
+	}
+
+	windows := custom
+	if len(windows) == 0 {
+		if windows, err = s.weeklyWindows(ctx, masterID, date); err != nil {
+			return nil, err
+		}
+	}
+	for _, cut := range breaks {
+		windows = subtract(windows, cut)
+	}
+	return merge(windows), nil
+}
+
+// weeklyWindows returns the active weekly schedule entries for the weekday of date.
+// Days without an active entry use the default working hours.
+func (s *Service) weeklyWindows(ctx context.Context, masterID int, date time.Time) ([]models.TimeRange, error) {
+	schedules, err := s.store.GetMasterSchedule(ctx, masterID)
+	if err != nil {
+		return nil, err
+	}
+
+	var windows []models.TimeRange
+	dayOfWeek := (int(date.Weekday()) + 6) % 7 // 0=Monday, 6=Sunday
+	for _, schedule := range schedules {
+		if schedule.DayOfWeek != dayOfWeek || !schedule.IsActive {
+			continue
+		}
+		window, err := clockRange(date, schedule.StartTime, schedule.EndTime)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %d: %w", schedule.ID, err)
+		}
+		windows = append(windows, window)
+	}
+	if len(windows) == 0 {
+		window, err := clockRange(date, defaultScheduleStart, defaultScheduleEnd)
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, window)
+	}
+	return windows, nil
+}
+
+// clockRange is [start, end) on date for HH:MM clock times
+func clockRange(date time.Time, start, end string) (models.TimeRange, error) {
+	from, err := atClock(date, start)
+	if err != nil {
+		return models.TimeRange{}, fmt.Errorf("invalid start time %q", start)
+	}
+	to, err := atClock(date, end)
+	if err != nil {
+		return models.TimeRange{}, fmt.Errorf("invalid end time %q", end)
+	}
+	if !from.Before(to) {
+		return models.TimeRange{}, fmt.Errorf("start time %s is not before end time %s", start, end)
+	}
+	return models.TimeRange{Start: from, End: to}, nil
+}
+
+// subtract removes cut from each window, splitting windows it falls into
+func subtract(windows []models.TimeRange, cut models.TimeRange) []models.TimeRange {
+	var rest []models.TimeRange
+	for _, window := range windows {
+		if !window.Overlaps(cut) {
+			rest = append(rest, window)
+			continue
+		}
+		if window.Start.Before(cut.Start) {
+			rest = append(rest, models.TimeRange{Start: window.Start, End: cut.Start})
+		}
+		if cut.End.Before(window.End) {
+			rest = append(rest, models.TimeRange{Start: cut.End, End: window.End})
+		}
+	}
+	return rest
+}
+
+// merge sorts windows and joins the ones that overlap or touch
+func merge(windows []models.TimeRange) []models.TimeRange {
+	sort.Slice(windows, func(i, j int) bool { return windows[i].Start.Before(windows[j].Start) })
+	var merged []models.TimeRange
+	for _, window := range windows {
+		if last := len(merged) - 1; last >= 0 && !window.Start.After(merged[last].End) {
+			if window.End.After(merged[last].End) {
+				merged[last].End = window.End
+			}
+			continue
+		}
+		merged = append(merged, window)
+	}
+	return merged
+}
+
+// atClock returns the calendar date of date at the HH:MM (or HH:MM:SS) clock time in date's location
+func atClock(date time.Time, clock string) (time.Time, error) {
+	clock = strings.TrimSpace(clock)
+	if len(clock) > 5 {
+		clock = clock[:5]
+	}
+	parsed, err := time.Parse("15:04", clock)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Date(date.Year(), date.Month(), date.Day(), parsed.Hour(), parsed.Minute(), 0, 0, date.Location()), nil
+}
+
+// check returns a SlotUnavailableError if [start, start+duration) is outside working hours or already booked
+func (d *day) check(start time.Time, duration time.Duration) error {
+	slot := models.TimeRange{Start: start, End: start.Add(duration)}
+	if start.Before(d.earliest) {
+		return &SlotUnavailableError{
+			Reason:      "This time has already passed or is too soon to book",
+			Suggestions: d.nearestFree(start, duration),
+		}
+	}
+	if !d.withinHours(slot) {
+		return &SlotUnavailableError{
+			Reason:      d.hoursReason(),
+			Suggestions: d.nearestFree(start, duration),
+		}
+	}
+	for _, booked := range d.booked {
+		if slot.Overlaps(booked) {
+			return &SlotUnavailableError{
+				Reason:      "This time is already booked",
+				Suggestions: d.nearestFree(start, duration),
+			}
+		}
+	}
+	return nil
+}
+
+// withinHours reports whether slot lies entirely inside one working window
+func (d *day) withinHours(slot models.TimeRange) bool {
+	for _, window := range d.windows {
+		if window.Contains(slot) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *day) hoursReason() string {
+	if len(d.windows) == 0 {
+		return "The master does not work on this day"
+	}
+	hours := make([]string, 0, len(d.windows))
+	for _, window := range d.windows {
+		hours = append(hours, window.Start.Format("15:04")+"-"+window.End.Format("15:04"))
+	}
+	return fmt.Sprintf("The master works %s on this day", strings.Join(hours, ", "))
+}
+
+// free lists start times on the slot grid of each working window where the whole duration
+// fits into the window without overlapping a booking, including bookings that start before the slot
+func (d *day) free(duration time.Duration) []time.Time {
+	var starts []time.Time
+	for _, window := range d.windows {
+		for start := window.Start; !start.Add(duration).After(window.End); start = start.Add(d.step) {
+			if start.Before(d.earliest) {
+				continue
+			}
+			slot := models.TimeRange{Start: start, End: start.Add(duration)}
+			available := true
+			for _, booked := range d.booked {
+				if slot.Overlaps(booked) {
+					available = false
+					break
+				}
+			}
+			if available {
+				starts = append(starts, start)
+			}
+		}
+	}
+	return starts
+}
+
+// nearestFree returns up to suggestionCount free start times closest to wanted, in chronological order
+func (d *day) nearestFree(wanted time.Time, duration time.Duration) []string {
+	starts := d.free(duration)
+	sort.SliceStable(starts, func(i, j int) bool {
+		return absDuration(starts[i].Sub(wanted)) < absDuration(starts[j].Sub(wanted))
+	})
+	if len(starts) > suggestionCount {
+		starts = starts[:suggestionCount]
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+
+	suggestions := make([]string, 0, len(starts))
+	for _, start := range starts {
+		suggestions = append(suggestions, start.Format("15:04"))
+	}
+	return suggestions
+}
+
+// Availability is the free start times of a master on one date
+type Availability struct {
+	// Date is YYYY-MM-DD and Slots are HH:MM, both in TimeZone, the master's zone
+	Date     string
+	TimeZone string
+	Duration time.Duration
+	Slots    []string
+}
+
+// AvailableSlots lists the start times on date at which the master can take all of serviceIDs
+// in a row, each taking as long as the master takes for it. Without services the default duration
+// of one hour is used. A zero date means today in the master's zone. Times that have passed or are
+// within the minimum lead time are left out. Returns ErrServiceNotOffered if the master cannot be
+// booked for one of the services.
+func (s *Service) AvailableSlots(ctx context.Context, masterID int, date time.Time, serviceIDs []int) (*Availability, error) {
+	master, err := s.store.GetMasterByID(ctx, masterID)
+	if err != nil {
+		return nil, notFound(err, ErrMasterNotFound)
+	}
+	loc, err := location(master)
+	if err != nil {
+		return nil, err
+	}
+	if date.IsZero() {
+		date = s.today(loc)
+	}
+
+	duration := defaultDuration
+	if len(serviceIDs) > 0 {
+		duration = 0
+		for _, serviceID := range serviceIDs {
+			service, err := s.offeredService(ctx, master.ID, serviceID)
+			if err != nil {
+				return nil, err
+			}
+			duration += serviceDuration(service)
+		}
+	}
+
+	schedule, err := s.loadDay(ctx, master, date)
+	if err != nil {
+		return nil, err
+	}
+
+	availability := &Availability{
+		Date:     schedule.date.Format("2006-01-02"),
+		TimeZone: loc.String(),
+		Duration: duration,
+		Slots:    []string{},
+	}
+	for _, start := range schedule.free(duration) {
+		availability.Slots = append(availability.Slots, start.Format("15:04"))
+	}
+	return availability, nil
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// serviceDuration is how long the master is busy with a service
+func serviceDuration(service *models.Service) time.Duration {
+	if service.DurationMinutes <= 0 {
+		return defaultDuration
+	}
+	return time.Duration(service.DurationMinutes) * time.Minute
+}
@@ -0,0 +1,190 @@
+package booking
+
+import (
+	"beep-backend/internal/models"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var testDate = time.Date(2030, time.January, 7, 0, 0, 0, 0, time.UTC)
+
+// at is testDate at the given clock time; hours past 24 fall on the next day
+func at(hour, minute int) time.Time {
+	return testDate.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+}
+
+// span is [from, to) on testDate, in whole hours
+func span(from, to int) models.TimeRange {
+	return models.TimeRange{Start: at(from, 0), End: at(to, 0)}
+}
+
+func clocks(starts []time.Time) []string {
+	out := []string{}
+	for _, start := range starts {
+		out = append(out, start.Format("15:04"))
+	}
+	return out
+}
+
+func TestSubtract(t *testing.T) {
+	tests := []struct {
+		name    string
+		windows []models.TimeRange
+		cut     models.TimeRange
+		want    []models.TimeRange
+	}{
+		{name: "cut inside splits the window", windows: []models.TimeRange{span(9, 18)}, cut: span(13, 14), want: []models.TimeRange{span(9, 13), span(14, 18)}},
+		{name: "cut outside keeps the window", windows: []models.TimeRange{span(9, 12)}, cut: span(13, 14), want: []models.TimeRange{span(9, 12)}},
+		{name: "touching cut keeps the window", windows: []models.TimeRange{span(9, 12)}, cut: span(12, 13), want: []models.TimeRange{span(9, 12)}},
+		{name: "cut over the start trims it", windows: []models.TimeRange{span(9, 18)}, cut: span(8, 10), want: []models.TimeRange{span(10, 18)}},
+		{name: "cut over the end trims it", windows: []models.TimeRange{span(9, 18)}, cut: span(17, 19), want: []models.TimeRange{span(9, 17)}},
+		{name: "cut covering the window removes it", windows: []models.TimeRange{span(9, 12), span(14, 18)}, cut: span(8, 13), want: []models.TimeRange{span(14, 18)}},
+		{name: "cut across two windows", windows: []models.TimeRange{span(9, 12), span(13, 18)}, cut: span(11, 14), want: []models.TimeRange{span(9, 11), span(14, 18)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := subtract(tt.windows, tt.cut); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("subtract() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name    string
+		windows []models.TimeRange
+		want    []models.TimeRange
+	}{
+		{name: "nothing to merge", windows: nil, want: nil},
+		{name: "disjoint windows are sorted", windows: []models.TimeRange{span(14, 18), span(9, 12)}, want: []models.TimeRange{span(9, 12), span(14, 18)}},
+		{name: "overlapping windows join", windows: []models.TimeRange{span(11, 15), span(9, 12)}, want: []models.TimeRange{span(9, 15)}},
+		{name: "touching windows join", windows: []models.TimeRange{span(9, 12), span(12, 14)}, want: []models.TimeRange{span(9, 14)}},
+		{name: "contained window is absorbed", windows: []models.TimeRange{span(9, 18), span(10, 11)}, want: []models.TimeRange{span(9, 18)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := merge(tt.windows); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("merge() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDayFree(t *testing.T) {
+	tests := []struct {
+		name     string
+		day      day
+		duration time.Duration
+		want     []string
+	}{
+		{
+			name:     "whole window on the grid",
+			day:      day{windows: []models.TimeRange{span(9, 12)}, step: time.Hour},
+			duration: time.Hour,
+			want:     []string{"09:00", "10:00", "11:00"},
+		},
+		{
+			name:     "the whole duration must fit into the window",
+			day:      day{windows: []models.TimeRange{span(9, 12)}, step: time.Hour},
+			duration: 2 * time.Hour,
+			want:     []string{"09:00", "10:00"},
+		},
+		{
+			name:     "booked time is skipped",
+			day:      day{windows: []models.TimeRange{span(9, 12)}, step: time.Hour, booked: []models.TimeRange{span(10, 11)}},
+			duration: time.Hour,
+			want:     []string{"09:00", "11:00"},
+		},
+		{
+			name: "slots overlapping a booking that starts later are skipped",
+			day: day{
+				windows: []models.TimeRange{span(9, 12)},
+				step:    30 * time.Minute,
+				booked:  []models.TimeRange{{Start: at(10, 0), End: at(10, 30)}},
+			},
+			duration: 90 * time.Minute,
+			want:     []string{"10:30"},
+		},
+		{
+			name:     "times before the earliest start are left out",
+			day:      day{windows: []models.TimeRange{span(9, 12)}, step: time.Hour, earliest: at(10, 0)},
+			duration: time.Hour,
+			want:     []string{"10:00", "11:00"},
+		},
+		{
+			name:     "each window is its own grid",
+			day:      day{windows: []models.TimeRange{span(9, 11), {Start: at(13, 30), End: at(15, 30)}}, step: time.Hour},
+			duration: time.Hour,
+			want:     []string{"09:00", "10:00", "13:30", "14:30"},
+		},
+		{
+			name: "a booking from the day before blocks the morning",
+			day: day{
+				windows: []models.TimeRange{span(24, 27)},
+				step:    time.Hour,
+				booked:  []models.TimeRange{span(23, 25)},
+			},
+			duration: time.Hour,
+			want:     []string{"01:00", "02:00"},
+		},
+		{name: "day off", day: day{step: time.Hour}, duration: time.Hour, want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := clocks(tt.day.free(tt.duration)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("free() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDayRelease(t *testing.T) {
+	tests := []struct {
+		name   string
+		booked []models.TimeRange
+		slot   models.TimeRange
+		want   []models.TimeRange
+	}{
+		{name: "drops the slot", booked: []models.TimeRange{span(9, 10), span(11, 12), span(14, 15)}, slot: span(11, 12), want: []models.TimeRange{span(9, 10), span(14, 15)}},
+		{name: "keeps overlapping intervals that differ", booked: []models.TimeRange{span(9, 11)}, slot: span(9, 10), want: []models.TimeRange{span(9, 11)}},
+		{name: "unknown slot changes nothing", booked: []models.TimeRange{span(9, 10)}, slot: span(12, 13), want: []models.TimeRange{span(9, 10)}},
+		{name: "nothing booked", booked: []models.TimeRange{}, slot: span(9, 10), want: []models.TimeRange{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &day{booked: tt.booked}
+			d.release(tt.slot)
+			if !reflect.DeepEqual(d.booked, tt.want) {
+				t.Errorf("booked = %v, want %v", d.booked, tt.want)
+			}
+		})
+	}
+}
+
+func TestDayCheck(t *testing.T) {
+	d := &day{windows: []models.TimeRange{span(9, 12)}, step: time.Hour, booked: []models.TimeRange{span(10, 11)}}
+	tests := []struct {
+		name      string
+		start     time.Time
+		wantError bool
+	}{
+		{name: "free time", start: at(9, 0)},
+		{name: "booked time", start: at(10, 0), wantError: true},
+		{name: "runs into a booking", start: at(9, 30), wantError: true},
+		{name: "outside working hours", start: at(11, 30), wantError: true},
+		{name: "before working hours", start: at(8, 0), wantError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := d.check(tt.start, time.Hour)
+			if (err != nil) != tt.wantError {
+				t.Fatalf("check() = %v, want error: %v", err, tt.wantError)
+			}
+			if slotErr, ok := err.(*SlotUnavailableError); ok && len(slotErr.Suggestions) == 0 {
+				t.Errorf("no suggestions for %v", tt.start)
+			}
+		})
+	}
+}
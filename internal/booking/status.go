@@ -0,0 +1,208 @@
+package booking
+
+import (
+	"beep-backend/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Actor is who changes an appointment
+type Actor struct {
+	UserID int
+	// Role is how the user takes part in the appointment: models.RoleCustomer, RoleMaster or RoleAdmin
+	Role string
+}
+
+var (
+	ErrUnknownStatus = errors.New("unknown appointment status")
+	// ErrStatusForbidden is returned when the transition exists but the actor may not make it
+	ErrStatusForbidden = errors.New("you are not allowed to make this status change")
+)
+
+// TransitionError is returned when an appointment cannot go from its status to the requested one
+type TransitionError struct {
+	From, To string
+	// Allowed lists the statuses the actor could move the appointment to instead
+	Allowed []string
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("cannot change appointment status from %s to %s", e.From, e.To)
+}
+
+// transitions lists, for every status, the statuses it can move to and the roles allowed to make each move.
+// completed, cancelled, no_show and rejected are final.
+var transitions = map[string]map[string][]string{
+	models.AppointmentPending: {
+		models.AppointmentConfirmed: {models.RoleMaster, models.RoleAdmin},
+		models.AppointmentRejected:  {models.RoleMaster, models.RoleAdmin},
+		models.AppointmentCancelled: {models.RoleCustomer, models.RoleMaster, models.RoleAdmin},
+	},
+	models.AppointmentConfirmed: {
+		models.AppointmentInProgress: {models.RoleMaster, models.RoleAdmin},
+		models.AppointmentNoShow:     {models.RoleMaster, models.RoleAdmin},
+		models.AppointmentCancelled:  {models.RoleCustomer, models.RoleMaster, models.RoleAdmin},
+	},
+	models.AppointmentInProgress: {
+		models.AppointmentCompleted: {models.RoleMaster, models.RoleAdmin},
+	},
+}
+
+var knownStatuses = map[string]bool{
+	models.AppointmentPending:    true,
+	models.AppointmentConfirmed:  true,
+	models.AppointmentInProgress: true,
+	models.AppointmentCompleted:  true,
+	models.AppointmentCancelled:  true,
+	models.AppointmentNoShow:     true,
+	models.AppointmentRejected:   true,
+}
+
+// Transition checks that role may move an appointment from one status to another.
+// Returns ErrUnknownStatus, a *TransitionError or ErrStatusForbidden.
+func Transition(from, to, role string) error {
+	if !knownStatuses[to] {
+		return ErrUnknownStatus
+	}
+	roles, ok := transitions[from][to]
+	if !ok {
+		return &TransitionError{From: from, To: to, Allowed: NextStatuses(from, role)}
+	}
+	if !containsRole(roles, role) {
+		return ErrStatusForbidden
+	}
+	return nil
+}
+
+// NextStatuses lists the statuses role may move an appointment in status to
+func NextStatuses(status, role string) []string {
+	next := []string{}
+	for to, roles := range transitions[status] {
+		if containsRole(roles, role) {
+			next = append(next, to)
+		}
+	}
+	sort.Strings(next)
+	return next
+}
+
+// userRef is the actor ID of a history event, nil for masters without a user account
+func userRef(userID int) *int {
+	if userID <= 0 {
+		return nil
+	}
+	return &userID
+}
+
+func containsRole(roles []string, role string) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// ChangeStatus moves the appointment to status on behalf of actor, records the change in its history
+// and notifies the other side. note is kept in the history and added to the notification.
+// Returns the errors of Transition, or repository.ErrStatusChanged if someone changed the status meanwhile.
+func (s *Service) ChangeStatus(ctx context.Context, appointment *models.Appointment, actor Actor, status, note string) (*models.AppointmentEvent, error) {
+	if err := Transition(appointment.Status, status, actor.Role); err != nil {
+		return nil, err
+	}
+
+	event := &models.AppointmentEvent{
+		FromStatus: appointment.Status,
+		ToStatus:   status,
+		ActorID:    userRef(actor.UserID),
+		ActorRole:  actor.Role,
+		Note:       note,
+	}
+	notifications, err := s.statusNotifications(ctx, appointment, actor, status, note)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.store.ChangeAppointmentStatus(ctx, appointment.ID, event, notifications); err != nil {
+		return nil, err
+	}
+	appointment.Status = status
+	return event, nil
+}
+
+// statusNotifications tells the customer about changes made by the master or an admin,
+// and the master about cancellations by the customer
+func (s *Service) statusNotifications(ctx context.Context, appointment *models.Appointment, actor Actor, status, note string) ([]models.Notification, error) {
+	master, err := s.store.GetMasterByID(ctx, appointment.MasterID)
+	if err != nil {
+		return nil, notFound(err, ErrMasterNotFound)
+	}
+	loc, err := location(master)
+	if err != nil {
+		return nil, err
+	}
+	services := s.appointmentServices(ctx, appointment.ID, appointment.ServiceID)
+	date := time.Date(appointment.Date.Year(), appointment.Date.Month(), appointment.Date.Day(), 0, 0, 0, 0, loc)
+	start, err := atClock(date, appointment.Time)
+	if err != nil {
+		return nil, err
+	}
+	reason := ""
+	if note != "" {
+		reason = " Причина: " + note + "."
+	}
+
+	if actor.Role == models.RoleCustomer {
+		if status != models.AppointmentCancelled || master.UserID <= 0 {
+			return nil, nil
+		}
+		customerName := "Клиент"
+		if customer, err := s.store.GetUserByID(ctx, appointment.UserID); err == nil {
+			customerName = "Клиент " + customer.Name
+		}
+		return []models.Notification{{
+			UserID: master.UserID,
+			Type:   "appointment_status_changed",
+			Title:  "Запись отменена клиентом",
+			Message: fmt.Sprintf("%s отменил запись на %s. Дата: %s, Время: %s.%s",
+				customerName, services, start.Format("02.01.2006"), start.Format("15:04"), reason),
+		}}, nil
+	}
+
+	if customer, err := s.store.GetUserByID(ctx, appointment.UserID); err == nil {
+		start = start.In(userLocation(customer, loc))
+	}
+	var title, message string
+	switch status {
+	case models.AppointmentConfirmed:
+		title = "Запись подтверждена"
+		message = fmt.Sprintf("Ваша запись к мастеру %s на %s подтверждена. Дата: %s, Время: %s",
+			master.Name, services, start.Format("02.01.2006"), start.Format("15:04"))
+	case models.AppointmentRejected:
+		title = "Запись отклонена"
+		message = fmt.Sprintf("Мастер %s не может принять вашу запись на %s. Дата: %s, Время: %s",
+			master.Name, services, start.Format("02.01.2006"), start.Format("15:04"))
+	case models.AppointmentCompleted:
+		title = "Услуга выполнена"
+		message = fmt.Sprintf("Мастер %s выполнил %s. Дата: %s",
+			master.Name, services, start.Format("02.01.2006"))
+	case models.AppointmentCancelled:
+		title = "Запись отменена"
+		message = fmt.Sprintf("Ваша запись к мастеру %s на %s отменена. Дата: %s, Время: %s",
+			master.Name, services, start.Format("02.01.2006"), start.Format("15:04"))
+	case models.AppointmentNoShow:
+		title = "Пропущенная запись"
+		message = fmt.Sprintf("Мастер %s отметил, что вы не пришли на запись на %s. Дата: %s, Время: %s",
+			master.Name, services, start.Format("02.01.2006"), start.Format("15:04"))
+	default:
+		return nil, nil
+	}
+	return []models.Notification{{
+		UserID:  appointment.UserID,
+		Type:    "appointment_status_changed",
+		Title:   title,
+		Message: message + "." + reason,
+	}}, nil
+}
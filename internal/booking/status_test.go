@@ -0,0 +1,102 @@
+package booking
+
+import (
+	"beep-backend/internal/models"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestTransition(t *testing.T) {
+	tests := []struct {
+		name        string
+		from, to    string
+		role        string
+		wantErr     error
+		wantAllowed []string
+	}{
+		{name: "master confirms", from: models.AppointmentPending, to: models.AppointmentConfirmed, role: models.RoleMaster},
+		{name: "admin confirms", from: models.AppointmentPending, to: models.AppointmentConfirmed, role: models.RoleAdmin},
+		{name: "customer cancels pending", from: models.AppointmentPending, to: models.AppointmentCancelled, role: models.RoleCustomer},
+		{name: "customer cancels confirmed", from: models.AppointmentConfirmed, to: models.AppointmentCancelled, role: models.RoleCustomer},
+		{name: "master starts", from: models.AppointmentConfirmed, to: models.AppointmentInProgress, role: models.RoleMaster},
+		{name: "master completes", from: models.AppointmentInProgress, to: models.AppointmentCompleted, role: models.RoleMaster},
+		{name: "master marks no-show", from: models.AppointmentConfirmed, to: models.AppointmentNoShow, role: models.RoleMaster},
+		{
+			name: "customer cannot confirm", from: models.AppointmentPending, to: models.AppointmentConfirmed,
+			role: models.RoleCustomer, wantErr: ErrStatusForbidden,
+		},
+		{
+			name: "customer cannot reject", from: models.AppointmentPending, to: models.AppointmentRejected,
+			role: models.RoleCustomer, wantErr: ErrStatusForbidden,
+		},
+		{
+			name: "unknown status", from: models.AppointmentPending, to: "archived",
+			role: models.RoleAdmin, wantErr: ErrUnknownStatus,
+		},
+		{
+			name: "skipping a step", from: models.AppointmentPending, to: models.AppointmentCompleted,
+			role: models.RoleMaster, wantErr: &TransitionError{},
+			wantAllowed: []string{models.AppointmentCancelled, models.AppointmentConfirmed, models.AppointmentRejected},
+		},
+		{
+			name: "cancelling an appointment in progress", from: models.AppointmentInProgress, to: models.AppointmentCancelled,
+			role: models.RoleCustomer, wantErr: &TransitionError{}, wantAllowed: []string{},
+		},
+		{
+			name: "final status", from: models.AppointmentCompleted, to: models.AppointmentPending,
+			role: models.RoleAdmin, wantErr: &TransitionError{}, wantAllowed: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Transition(tt.from, tt.to, tt.role)
+			var transitionErr *TransitionError
+			switch {
+			case tt.wantErr == nil:
+				if err != nil {
+					t.Fatalf("Transition() = %v, want nil", err)
+				}
+			case errors.As(tt.wantErr, &transitionErr):
+				if !errors.As(err, &transitionErr) {
+					t.Fatalf("Transition() = %v, want a *TransitionError", err)
+				}
+				if transitionErr.From != tt.from || transitionErr.To != tt.to {
+					t.Errorf("TransitionError is for %s -> %s", transitionErr.From, transitionErr.To)
+				}
+				if !reflect.DeepEqual(transitionErr.Allowed, tt.wantAllowed) {
+					t.Errorf("Allowed = %v, want %v", transitionErr.Allowed, tt.wantAllowed)
+				}
+			default:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Transition() = %v, want %v", err, tt.wantErr)
+				}
+			}
+		})
+	}
+}
+
+func TestNextStatuses(t *testing.T) {
+	tests := []struct {
+		status string
+		role   string
+		want   []string
+	}{
+		{models.AppointmentPending, models.RoleMaster, []string{models.AppointmentCancelled, models.AppointmentConfirmed, models.AppointmentRejected}},
+		{models.AppointmentPending, models.RoleCustomer, []string{models.AppointmentCancelled}},
+		{models.AppointmentConfirmed, models.RoleAdmin, []string{models.AppointmentCancelled, models.AppointmentInProgress, models.AppointmentNoShow}},
+		{models.AppointmentConfirmed, models.RoleCustomer, []string{models.AppointmentCancelled}},
+		{models.AppointmentInProgress, models.RoleMaster, []string{models.AppointmentCompleted}},
+		{models.AppointmentInProgress, models.RoleCustomer, []string{}},
+		{models.AppointmentCompleted, models.RoleAdmin, []string{}},
+		{models.AppointmentCancelled, models.RoleMaster, []string{}},
+		{"archived", models.RoleAdmin, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.status+"/"+tt.role, func(t *testing.T) {
+			if got := NextStatuses(tt.status, tt.role); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NextStatuses(%s, %s) = %v, want %v", tt.status, tt.role, got, tt.want)
+			}
+		})
+	}
+}
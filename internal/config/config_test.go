@@ -0,0 +1,190 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const strongSecret = "0123456789abcdef0123456789abcdef"
+
+// clearEnv unsets the variables these tests rely on, whatever the machine running them has set
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, key := range []string{"APP_ENV", "JWT_SECRET", "AUTH_DEV_MODE", "CONFIG_FILE", "PORT", "ACCESS_TOKEN_TTL", "TRUSTED_PROXIES", "FEATURE_REGISTRATION", "RATE_LIMIT_LOGIN", "OTP_LENGTH"} {
+		t.Setenv(key, "")
+	}
+}
+
+func TestValidateJWTSecret(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     string
+		secret  string
+		devMode bool
+		wantErr string
+	}{
+		{name: "production with a strong secret", env: EnvProduction, secret: strongSecret},
+		{name: "production with the default secret", env: EnvProduction, secret: DefaultJWTSecret, wantErr: "must be changed from the default"},
+		{name: "production with a short secret", env: EnvProduction, secret: strongSecret[:31], wantErr: "at least 32 characters"},
+		{name: "staging with the default secret", env: EnvStaging, secret: DefaultJWTSecret, wantErr: "must be changed from the default"},
+		{name: "staging with a short secret", env: EnvStaging, secret: "your-secret-key", wantErr: "at least 32 characters"},
+		{name: "development with the default secret", env: EnvDevelopment, secret: DefaultJWTSecret},
+		{name: "development with a short secret", env: EnvDevelopment, secret: "your-secret-key"},
+		{name: "empty secret", env: EnvDevelopment, secret: "", wantErr: "auth.jwt_secret is required"},
+		{name: "dev mode in production", env: EnvProduction, secret: strongSecret, devMode: true, wantErr: "auth.dev_mode"},
+		{name: "dev mode in staging", env: EnvStaging, secret: strongSecret, devMode: true},
+		{name: "unknown environment", env: "prod", secret: strongSecret, wantErr: "env must be"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			cfg.Env = tt.env
+			cfg.Auth.JWTSecret = tt.secret
+			cfg.Auth.DevMode = tt.devMode
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDefaultRefusesToStart(t *testing.T) {
+	cfg := Default()
+	if !cfg.IsProduction() {
+		t.Fatalf("default env = %q, want %q", cfg.Env, EnvProduction)
+	}
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("default configuration accepted the placeholder secret")
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		check   func(t *testing.T, cfg *Config)
+		wantErr string
+	}{
+		{
+			name: "nothing set keeps the defaults",
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Env != EnvProduction || cfg.Server.Port != "8080" || cfg.Auth.JWTSecret != DefaultJWTSecret {
+					t.Errorf("got env %q, port %q, secret %q", cfg.Env, cfg.Server.Port, cfg.Auth.JWTSecret)
+				}
+			},
+		},
+		{
+			name: "overrides",
+			env: map[string]string{
+				"APP_ENV":              EnvDevelopment,
+				"JWT_SECRET":           "from-env",
+				"PORT":                 "9090",
+				"ACCESS_TOKEN_TTL":     "5m",
+				"TRUSTED_PROXIES":      "10.0.0.1, 10.1.0.0/16,",
+				"FEATURE_REGISTRATION": "false",
+				"RATE_LIMIT_LOGIN":     "ip=1/1m",
+			},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Env != EnvDevelopment || cfg.Auth.JWTSecret != "from-env" || cfg.Server.Port != "9090" {
+					t.Errorf("got env %q, secret %q, port %q", cfg.Env, cfg.Auth.JWTSecret, cfg.Server.Port)
+				}
+				if cfg.Auth.AccessTokenTTL != 5*time.Minute {
+					t.Errorf("access token TTL = %s", cfg.Auth.AccessTokenTTL)
+				}
+				if len(cfg.Server.TrustedProxies) != 2 || cfg.Server.TrustedProxies[1] != "10.1.0.0/16" {
+					t.Errorf("trusted proxies = %q", cfg.Server.TrustedProxies)
+				}
+				if cfg.Features.Registration {
+					t.Error("registration still enabled")
+				}
+				if cfg.RateLimit.Policies["login"] != "ip=1/1m" {
+					t.Errorf("rate limit policies = %v", cfg.RateLimit.Policies)
+				}
+			},
+		},
+		{
+			name: "empty values are ignored",
+			env:  map[string]string{"APP_ENV": "", "JWT_SECRET": ""},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Env != EnvProduction || cfg.Auth.JWTSecret != DefaultJWTSecret {
+					t.Errorf("got env %q, secret %q", cfg.Env, cfg.Auth.JWTSecret)
+				}
+			},
+		},
+		{name: "invalid duration", env: map[string]string{"ACCESS_TOKEN_TTL": "15"}, wantErr: `ACCESS_TOKEN_TTL: invalid duration "15"`},
+		{name: "invalid integer", env: map[string]string{"OTP_LENGTH": "six"}, wantErr: `OTP_LENGTH: invalid integer "six"`},
+		{name: "invalid boolean", env: map[string]string{"AUTH_DEV_MODE": "maybe"}, wantErr: `AUTH_DEV_MODE: invalid boolean "maybe"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			cfg := Default()
+			err := cfg.applyEnv()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("applyEnv() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, cfg)
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("env: development\nserver:\n  port: \"7070\"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("file, then environment", func(t *testing.T) {
+		clearEnv(t)
+		t.Setenv("PORT", "9090")
+		cfg, err := Load(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Env != EnvDevelopment || cfg.Server.Port != "9090" {
+			t.Errorf("got env %q, port %q", cfg.Env, cfg.Server.Port)
+		}
+	})
+
+	t.Run("environment overrides the file's development env", func(t *testing.T) {
+		clearEnv(t)
+		t.Setenv("APP_ENV", EnvProduction)
+		if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "auth.jwt_secret") {
+			t.Fatalf("Load() error = %v, want the default secret refused", err)
+		}
+		t.Setenv("JWT_SECRET", strongSecret)
+		if _, err := Load(path); err != nil {
+			t.Fatalf("Load() with a strong secret: %v", err)
+		}
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		clearEnv(t)
+		bad := filepath.Join(t.TempDir(), "config.yaml")
+		if err := os.WriteFile(bad, []byte("env: development\nauth:\n  jwt_secrets: typo\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(bad); err == nil {
+			t.Fatal("Load() accepted an unknown field")
+		}
+	})
+}
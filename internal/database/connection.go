@@ -3,15 +3,31 @@ package database
 import (
 	"database/sql"
 	"log"
+	"time"
 	_ "github.com/lib/pq"
 )
 
-func Connect(databaseURL string) (*sql.DB, error) {
+// PoolSettings limits the connections kept by sql.DB. Zero values keep the database/sql defaults.
+type PoolSettings struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+func Connect(databaseURL string, pool PoolSettings) (*sql.DB, error) {
 	db, err := sql.Open("postgres", databaseURL)
 	if err != nil {
 		return nil, err
 	}
 
+	db.SetMaxOpenConns(pool.MaxOpenConns)
+	if pool.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(pool.MaxIdleConns)
+	}
+	db.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
+
 	if err := db.Ping(); err != nil {
 		return nil, err
 	}
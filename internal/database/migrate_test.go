@@ -0,0 +1,144 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func migrationFS(files map[string]string) fstest.MapFS {
+	fsys := fstest.MapFS{}
+	for name, content := range files {
+		fsys["migrations/"+name] = &fstest.MapFile{Data: []byte(content)}
+	}
+	return fsys
+}
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := migrationFS(map[string]string{
+		"0002_add_index.up.sql":      "CREATE INDEX idx ON t(id);",
+		"0002_add_index.down.sql":    "DROP INDEX idx;",
+		"0001_create_table.up.sql":   "CREATE TABLE t (id INT);",
+		"0001_create_table.down.sql": "DROP TABLE t;",
+		"0003_no_down_script.up.sql": "ALTER TABLE t ADD COLUMN name TEXT;",
+		"README.md":                  "not a migration",
+	})
+
+	migrations, err := loadMigrations(fsys, "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) != 3 {
+		t.Fatalf("loaded %d migrations, want 3", len(migrations))
+	}
+	for i, want := range []string{"create_table", "add_index", "no_down_script"} {
+		if migrations[i].Version != i+1 || migrations[i].Name != want {
+			t.Errorf("migration %d = %04d_%s, want %04d_%s", i, migrations[i].Version, migrations[i].Name, i+1, want)
+		}
+	}
+	if migrations[0].Up != "CREATE TABLE t (id INT);" || migrations[0].Down != "DROP TABLE t;" {
+		t.Errorf("scripts = %q / %q", migrations[0].Up, migrations[0].Down)
+	}
+	if migrations[0].Checksum == "" || migrations[0].Checksum == migrations[1].Checksum {
+		t.Errorf("checksums %q and %q", migrations[0].Checksum, migrations[1].Checksum)
+	}
+
+	// A migration without a down script loads but cannot be reverted
+	var m Migrator
+	if err := m.revert(context.Background(), nil, migrations[2]); err == nil || !strings.Contains(err.Error(), "no down script") {
+		t.Errorf("revert without down script: %v", err)
+	}
+}
+
+func TestLoadMigrationsErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name:  "duplicate version",
+			files: map[string]string{"0001_create_table.up.sql": "SELECT 1;", "0001_create_index.up.sql": "SELECT 2;"},
+			want:  "is used by both",
+		},
+		{
+			name:  "missing up script",
+			files: map[string]string{"0001_create_table.up.sql": "SELECT 1;", "0002_add_index.down.sql": "SELECT 2;"},
+			want:  "0002_add_index has no up script",
+		},
+		{
+			name:  "missing name",
+			files: map[string]string{"0001.up.sql": "SELECT 1;"},
+			want:  "expected NNNN_name.up.sql",
+		},
+		{
+			name:  "invalid version",
+			files: map[string]string{"v1_create_table.up.sql": "SELECT 1;"},
+			want:  "invalid version",
+		},
+		{
+			name:  "zero version",
+			files: map[string]string{"0000_create_table.up.sql": "SELECT 1;"},
+			want:  "invalid version",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadMigrations(migrationFS(tt.files), "migrations")
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("loadMigrations() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := loadMigrations(fstest.MapFS{}, "migrations"); err == nil {
+		t.Error("missing directory accepted")
+	}
+}
+
+func TestPending(t *testing.T) {
+	migrations, err := loadMigrations(migrationFS(map[string]string{
+		"0001_create_table.up.sql": "CREATE TABLE t (id INT);",
+		"0002_add_index.up.sql":    "CREATE INDEX idx ON t(id);",
+		"0003_add_column.up.sql":   "ALTER TABLE t ADD COLUMN name TEXT;",
+	}), "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := pending(migrations, map[int]appliedMigration{
+		1: {checksum: migrations[0].Checksum},
+		3: {checksum: migrations[2].Checksum},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Version != 2 {
+		t.Errorf("pending = %+v, want only 0002", got)
+	}
+
+	// An applied script edited afterwards stops the run before anything is applied
+	_, err = pending(migrations, map[int]appliedMigration{
+		1: {checksum: migrations[0].Checksum},
+		2: {checksum: "checksum of the script as it was applied"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "0002_add_index was modified after it was applied") {
+		t.Errorf("pending() error = %v, want modified migration", err)
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := loadMigrations(migrationFiles, "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			t.Errorf("migration %04d_%s: versions are not contiguous", migration.Version, migration.Name)
+		}
+		if migration.Down == "" {
+			t.Errorf("migration %04d_%s has no down script", migration.Version, migration.Name)
+		}
+	}
+}
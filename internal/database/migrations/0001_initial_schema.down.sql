@@ -0,0 +1,18 @@
+DROP TABLE IF EXISTS master_certificates;
+DROP TABLE IF EXISTS notifications;
+DROP TABLE IF EXISTS guarantees;
+DROP TABLE IF EXISTS user_cars;
+DROP TABLE IF EXISTS favorite_masters;
+DROP TABLE IF EXISTS user_subscriptions;
+DROP TABLE IF EXISTS master_payment_info;
+DROP TABLE IF EXISTS master_works;
+DROP TABLE IF EXISTS reviews;
+DROP TABLE IF EXISTS appointments;
+DROP TABLE IF EXISTS master_schedule;
+DROP TABLE IF EXISTS masters;
+DROP TABLE IF EXISTS pricing_rules;
+DROP TABLE IF EXISTS price_zones;
+DROP TABLE IF EXISTS cars;
+DROP TABLE IF EXISTS services;
+DROP TABLE IF EXISTS categories;
+DROP TABLE IF EXISTS users;
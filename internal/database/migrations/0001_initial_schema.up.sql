@@ -0,0 +1,204 @@
+-- Baseline schema. Written to be idempotent so that databases created by the
+-- old boot-time migrations can be adopted without changes.
+
+CREATE TABLE IF NOT EXISTS users (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    email VARCHAR(255) UNIQUE NOT NULL,
+    phone VARCHAR(20),
+    photo_url VARCHAR(255),
+    password_hash VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS photo_url VARCHAR(255);
+
+CREATE TABLE IF NOT EXISTS categories (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    description TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS services (
+    id SERIAL PRIMARY KEY,
+    category_id INTEGER REFERENCES categories(id),
+    name VARCHAR(200) NOT NULL,
+    description TEXT,
+    base_price DECIMAL(10,2),
+    min_price DECIMAL(10,2),
+    max_price DECIMAL(10,2),
+    duration_minutes INTEGER,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS cars (
+    id SERIAL PRIMARY KEY,
+    brand VARCHAR(50) NOT NULL,
+    model VARCHAR(50) NOT NULL,
+    year INTEGER,
+    type VARCHAR(50),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS price_zones (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    multiplier DECIMAL(5,2) DEFAULT 1.0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS pricing_rules (
+    id SERIAL PRIMARY KEY,
+    service_id INTEGER REFERENCES services(id),
+    car_type VARCHAR(50),
+    car_age_min INTEGER,
+    car_age_max INTEGER,
+    multiplier DECIMAL(5,2) DEFAULT 1.0,
+    fixed_addition DECIMAL(10,2) DEFAULT 0.0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS masters (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE UNIQUE,
+    name VARCHAR(100) NOT NULL,
+    email VARCHAR(255) UNIQUE NOT NULL,
+    phone VARCHAR(20) NOT NULL,
+    specialization VARCHAR(100),
+    rating DECIMAL(3,2) DEFAULT 0.0,
+    photo_url TEXT,
+    location_lat DECIMAL(10,8),
+    location_lng DECIMAL(11,8),
+    address TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE masters ADD COLUMN IF NOT EXISTS user_id INTEGER REFERENCES users(id) ON DELETE CASCADE;
+
+CREATE TABLE IF NOT EXISTS master_schedule (
+    id SERIAL PRIMARY KEY,
+    master_id INTEGER REFERENCES masters(id) ON DELETE CASCADE,
+    day_of_week INTEGER NOT NULL CHECK (day_of_week BETWEEN 0 AND 6),
+    start_time TIME NOT NULL,
+    end_time TIME NOT NULL,
+    is_active BOOLEAN DEFAULT true,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS appointments (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id),
+    master_id INTEGER REFERENCES masters(id),
+    service_id INTEGER REFERENCES services(id),
+    date DATE NOT NULL,
+    time TIME NOT NULL,
+    status VARCHAR(20) DEFAULT 'pending' CHECK (status IN ('pending', 'confirmed', 'cancelled', 'completed')),
+    comment TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS reviews (
+    id SERIAL PRIMARY KEY,
+    master_id INTEGER REFERENCES masters(id) ON DELETE CASCADE,
+    user_id INTEGER REFERENCES users(id),
+    rating INTEGER NOT NULL CHECK (rating BETWEEN 1 AND 5),
+    comment TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS master_works (
+    id SERIAL PRIMARY KEY,
+    master_id INTEGER REFERENCES masters(id) ON DELETE CASCADE,
+    title VARCHAR(255) NOT NULL,
+    work_date DATE NOT NULL,
+    customer_name VARCHAR(255) NOT NULL,
+    amount DECIMAL(10, 2),
+    photo_urls TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Early builds created photo_urls as TEXT[]
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_name = 'master_works' AND column_name = 'photo_urls' AND data_type = 'ARRAY'
+    ) THEN
+        ALTER TABLE master_works ALTER COLUMN photo_urls TYPE TEXT;
+    END IF;
+END $$;
+
+CREATE TABLE IF NOT EXISTS master_payment_info (
+    id SERIAL PRIMARY KEY,
+    master_id INTEGER REFERENCES masters(id) ON DELETE CASCADE UNIQUE,
+    kaspi_card VARCHAR(20),
+    freedom_card VARCHAR(20),
+    halyk_card VARCHAR(20),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS user_subscriptions (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE UNIQUE,
+    plan VARCHAR(20) DEFAULT 'basic' CHECK (plan IN ('basic', 'premium', 'trial')),
+    trial_start_date TIMESTAMP,
+    trial_end_date TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE user_subscriptions ADD COLUMN IF NOT EXISTS trial_start_date TIMESTAMP;
+ALTER TABLE user_subscriptions ADD COLUMN IF NOT EXISTS trial_end_date TIMESTAMP;
+
+CREATE TABLE IF NOT EXISTS favorite_masters (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    master_id INTEGER REFERENCES masters(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(user_id, master_id)
+);
+
+CREATE TABLE IF NOT EXISTS user_cars (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    year INTEGER,
+    comment TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS guarantees (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    appointment_id INTEGER REFERENCES appointments(id) ON DELETE CASCADE,
+    service_name VARCHAR(255) NOT NULL,
+    master_name VARCHAR(255),
+    service_date DATE NOT NULL,
+    expiry_date DATE NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS notifications (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    type VARCHAR(50) NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    message TEXT,
+    related_id INTEGER,
+    is_read BOOLEAN DEFAULT false,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS master_certificates (
+    id SERIAL PRIMARY KEY,
+    master_id INTEGER REFERENCES masters(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    photo_url VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,5 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'customer'
+    CHECK (role IN ('customer', 'master', 'admin'));
+
+UPDATE users SET role = 'master'
+WHERE role = 'customer' AND id IN (SELECT user_id FROM masters WHERE user_id IS NOT NULL);
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS otp_codes;
+DROP INDEX IF EXISTS idx_users_phone_digits;
+
+-- Fails while phone-only users exist; remove or complete them first
+ALTER TABLE users ALTER COLUMN email SET NOT NULL;
+ALTER TABLE users ALTER COLUMN password_hash SET NOT NULL;
@@ -0,0 +1,2 @@
+ALTER TABLE appointments DROP CONSTRAINT IF EXISTS appointments_no_overlap;
+ALTER TABLE appointments DROP COLUMN IF EXISTS slot;
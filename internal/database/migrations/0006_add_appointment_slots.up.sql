@@ -0,0 +1,25 @@
+-- Each appointment occupies [date + time, date + time + service duration) of its master.
+-- The exclusion constraint makes overlapping active bookings impossible even under concurrent requests.
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+
+ALTER TABLE appointments ADD COLUMN IF NOT EXISTS slot TSRANGE;
+
+-- Existing double bookings cannot satisfy the constraint: only the earliest of overlapping
+-- active appointments gets a slot, the rest keep NULL and are still treated as busy by the API
+WITH ranges AS (
+    SELECT a.id, a.master_id, a.status,
+           tsrange(a.date + a.time, a.date + a.time + make_interval(mins => COALESCE(NULLIF(s.duration_minutes, 0), 60)), '[)') AS slot
+    FROM appointments a
+    LEFT JOIN services s ON s.id = a.service_id
+)
+UPDATE appointments a SET slot = r.slot
+FROM ranges r
+WHERE a.id = r.id AND a.slot IS NULL
+  AND (r.status = 'cancelled' OR NOT EXISTS (
+      SELECT 1 FROM ranges earlier
+      WHERE earlier.master_id = r.master_id AND earlier.id < r.id
+        AND earlier.status <> 'cancelled' AND earlier.slot && r.slot
+  ));
+
+ALTER TABLE appointments ADD CONSTRAINT appointments_no_overlap
+    EXCLUDE USING gist (master_id WITH =, slot WITH &&) WHERE (status <> 'cancelled');
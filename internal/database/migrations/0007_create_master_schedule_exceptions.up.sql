@@ -0,0 +1,21 @@
+-- One-off changes to a master's weekly schedule for the dates start_date..end_date.
+-- day_off and vacation close whole days, custom_hours replace the weekly windows
+-- (several rows give several windows) and break blocks start_time..end_time on each day.
+CREATE TABLE IF NOT EXISTS master_schedule_exceptions (
+    id SERIAL PRIMARY KEY,
+    master_id INTEGER NOT NULL REFERENCES masters(id) ON DELETE CASCADE,
+    type VARCHAR(20) NOT NULL CHECK (type IN ('day_off', 'vacation', 'custom_hours', 'break')),
+    start_date DATE NOT NULL,
+    end_date DATE NOT NULL,
+    start_time TIME,
+    end_time TIME,
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CHECK (end_date >= start_date),
+    CHECK (
+        (type IN ('day_off', 'vacation') AND start_time IS NULL AND end_time IS NULL)
+        OR (type IN ('custom_hours', 'break') AND start_time < end_time)
+    )
+);
+
+CREATE INDEX IF NOT EXISTS idx_master_schedule_exceptions_dates ON master_schedule_exceptions(master_id, start_date, end_date);
@@ -0,0 +1,18 @@
+ALTER TABLE appointments DROP CONSTRAINT IF EXISTS appointments_no_overlap;
+ALTER TABLE appointments ADD COLUMN slot_local TSRANGE;
+
+UPDATE appointments a
+SET slot_local = tsrange(lower(a.slot) AT TIME ZONE m.time_zone, upper(a.slot) AT TIME ZONE m.time_zone, '[)')
+FROM masters m
+WHERE m.id = a.master_id AND a.slot IS NOT NULL;
+
+ALTER TABLE appointments DROP COLUMN slot;
+ALTER TABLE appointments RENAME COLUMN slot_local TO slot;
+
+ALTER TABLE appointments
+    ADD CONSTRAINT appointments_no_overlap
+    EXCLUDE USING gist (master_id WITH =, slot WITH &&)
+    WHERE (status <> 'cancelled');
+
+ALTER TABLE users DROP COLUMN IF EXISTS time_zone;
+ALTER TABLE masters DROP COLUMN IF EXISTS time_zone;
@@ -0,0 +1,20 @@
+-- IANA time zones of masters (schedule and appointment times) and users (how times are shown to them)
+ALTER TABLE masters ADD COLUMN IF NOT EXISTS time_zone VARCHAR(64) NOT NULL DEFAULT 'Asia/Almaty';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS time_zone VARCHAR(64) NOT NULL DEFAULT 'Asia/Almaty';
+
+-- Slots become absolute timestamps. date and time stay as the wall-clock time in the master's zone.
+ALTER TABLE appointments DROP CONSTRAINT IF EXISTS appointments_no_overlap;
+ALTER TABLE appointments ADD COLUMN slot_at TSTZRANGE;
+
+UPDATE appointments a
+SET slot_at = tstzrange(lower(a.slot) AT TIME ZONE m.time_zone, upper(a.slot) AT TIME ZONE m.time_zone, '[)')
+FROM masters m
+WHERE m.id = a.master_id AND a.slot IS NOT NULL;
+
+ALTER TABLE appointments DROP COLUMN slot;
+ALTER TABLE appointments RENAME COLUMN slot_at TO slot;
+
+ALTER TABLE appointments
+    ADD CONSTRAINT appointments_no_overlap
+    EXCLUDE USING gist (master_id WITH =, slot WITH &&)
+    WHERE (status <> 'cancelled');
@@ -0,0 +1,15 @@
+DROP TABLE IF EXISTS appointment_events;
+
+-- Statuses unknown to the old schema are folded into the nearest old one
+UPDATE appointments SET status = 'confirmed' WHERE status = 'in_progress';
+UPDATE appointments SET status = 'cancelled' WHERE status IN ('no_show', 'rejected');
+
+ALTER TABLE appointments DROP CONSTRAINT IF EXISTS appointments_no_overlap;
+ALTER TABLE appointments
+    ADD CONSTRAINT appointments_no_overlap
+    EXCLUDE USING gist (master_id WITH =, slot WITH &&)
+    WHERE (status <> 'cancelled');
+
+ALTER TABLE appointments DROP CONSTRAINT IF EXISTS appointments_status_check;
+ALTER TABLE appointments ADD CONSTRAINT appointments_status_check
+    CHECK (status IN ('pending', 'confirmed', 'cancelled', 'completed'));
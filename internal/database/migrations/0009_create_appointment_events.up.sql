@@ -0,0 +1,39 @@
+-- Appointment statuses follow a state machine: pending -> confirmed -> in_progress -> completed,
+-- pending may be rejected by the master, pending and confirmed may be cancelled and a confirmed
+-- appointment the customer did not come to becomes no_show.
+ALTER TABLE appointments DROP CONSTRAINT IF EXISTS appointments_status_check;
+ALTER TABLE appointments ADD CONSTRAINT appointments_status_check
+    CHECK (status IN ('pending', 'confirmed', 'in_progress', 'completed', 'cancelled', 'no_show', 'rejected'));
+
+-- Rejected appointments free their time like cancelled ones
+ALTER TABLE appointments DROP CONSTRAINT IF EXISTS appointments_no_overlap;
+ALTER TABLE appointments
+    ADD CONSTRAINT appointments_no_overlap
+    EXCLUDE USING gist (master_id WITH =, slot WITH &&)
+    WHERE (status NOT IN ('cancelled', 'rejected'));
+
+-- History of an appointment: its creation, every status change and every move to another time.
+-- actor_id is the user who made the change, NULL for the system.
+CREATE TABLE IF NOT EXISTS appointment_events (
+    id SERIAL PRIMARY KEY,
+    appointment_id INTEGER NOT NULL REFERENCES appointments(id) ON DELETE CASCADE,
+    type VARCHAR(20) NOT NULL CHECK (type IN ('created', 'status_changed', 'rescheduled')),
+    from_status VARCHAR(20),
+    to_status VARCHAR(20) NOT NULL,
+    actor_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    actor_role VARCHAR(20) NOT NULL CHECK (actor_role IN ('customer', 'master', 'admin', 'system')),
+    note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_appointment_events_appointment ON appointment_events(appointment_id, created_at);
+
+-- Existing appointments start their history with what is known: creation and the current status
+INSERT INTO appointment_events (appointment_id, type, from_status, to_status, actor_id, actor_role, created_at)
+SELECT id, 'created', NULL, 'pending', user_id, 'customer', created_at
+FROM appointments;
+
+INSERT INTO appointment_events (appointment_id, type, from_status, to_status, actor_role, note, created_at)
+SELECT id, 'status_changed', 'pending', status, 'system', 'Recorded before history was kept', updated_at
+FROM appointments
+WHERE status <> 'pending';
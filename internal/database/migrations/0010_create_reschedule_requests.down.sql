@@ -0,0 +1,6 @@
+DELETE FROM appointment_events WHERE type IN ('reschedule_requested', 'reschedule_declined');
+ALTER TABLE appointment_events DROP CONSTRAINT IF EXISTS appointment_events_type_check;
+ALTER TABLE appointment_events ADD CONSTRAINT appointment_events_type_check
+    CHECK (type IN ('created', 'status_changed', 'rescheduled'));
+
+DROP TABLE IF EXISTS reschedule_requests;
@@ -0,0 +1,27 @@
+-- A customer's proposal to move an appointment. While pending, the proposed slot is held: nobody else
+-- can book it and other proposals cannot overlap it. The appointment keeps its own slot until the master accepts.
+CREATE TABLE IF NOT EXISTS reschedule_requests (
+    id SERIAL PRIMARY KEY,
+    appointment_id INTEGER NOT NULL REFERENCES appointments(id) ON DELETE CASCADE,
+    master_id INTEGER NOT NULL REFERENCES masters(id) ON DELETE CASCADE,
+    requested_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    date DATE NOT NULL,
+    time TIME NOT NULL,
+    slot TSTZRANGE NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'accepted', 'declined', 'cancelled', 'expired')),
+    comment TEXT NOT NULL DEFAULT '',
+    response TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    resolved_at TIMESTAMP,
+    CONSTRAINT reschedule_requests_no_overlap
+        EXCLUDE USING gist (master_id WITH =, slot WITH &&) WHERE (status = 'pending')
+);
+
+-- An appointment has at most one open proposal
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reschedule_requests_pending ON reschedule_requests(appointment_id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_reschedule_requests_master ON reschedule_requests(master_id, status, date);
+
+ALTER TABLE appointment_events DROP CONSTRAINT IF EXISTS appointment_events_type_check;
+ALTER TABLE appointment_events ADD CONSTRAINT appointment_events_type_check
+    CHECK (type IN ('created', 'status_changed', 'rescheduled', 'reschedule_requested', 'reschedule_declined'));
@@ -0,0 +1,20 @@
+DELETE FROM pricing_rules
+WHERE service_id IS NULL AND description IN (
+    'Надбавка за старый автомобиль (+20%)',
+    'Скидка за новый автомобиль (-10%)',
+    'Надбавка за премиум автомобиль (+50%)',
+    'Надбавка за люксовый автомобиль (+100%)'
+);
+
+ALTER TABLE price_zones ALTER COLUMN multiplier DROP NOT NULL;
+
+DROP INDEX IF EXISTS idx_pricing_rules_service;
+
+ALTER TABLE pricing_rules DROP CONSTRAINT IF EXISTS pricing_rules_service_id_fkey;
+ALTER TABLE pricing_rules ADD CONSTRAINT pricing_rules_service_id_fkey
+    FOREIGN KEY (service_id) REFERENCES services(id);
+
+ALTER TABLE pricing_rules ALTER COLUMN fixed_addition DROP NOT NULL;
+ALTER TABLE pricing_rules ALTER COLUMN multiplier DROP NOT NULL;
+ALTER TABLE pricing_rules DROP COLUMN IF EXISTS position;
+ALTER TABLE pricing_rules DROP COLUMN IF EXISTS description;
@@ -0,0 +1,30 @@
+-- Prices are calculated from pricing_rules instead of adjustments hard-coded in the API.
+-- NULL service_id, car_type or age bound means "any"; age bounds are inclusive.
+-- Every matching rule applies in position order: the price is multiplied by multiplier
+-- and fixed_addition is added. description is shown in the price breakdown.
+ALTER TABLE pricing_rules ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT '';
+ALTER TABLE pricing_rules ADD COLUMN IF NOT EXISTS position INTEGER NOT NULL DEFAULT 0;
+UPDATE pricing_rules SET multiplier = 1.0 WHERE multiplier IS NULL;
+UPDATE pricing_rules SET fixed_addition = 0.0 WHERE fixed_addition IS NULL;
+ALTER TABLE pricing_rules ALTER COLUMN multiplier SET NOT NULL;
+ALTER TABLE pricing_rules ALTER COLUMN fixed_addition SET NOT NULL;
+
+ALTER TABLE pricing_rules DROP CONSTRAINT IF EXISTS pricing_rules_service_id_fkey;
+ALTER TABLE pricing_rules ADD CONSTRAINT pricing_rules_service_id_fkey
+    FOREIGN KEY (service_id) REFERENCES services(id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_pricing_rules_service ON pricing_rules(service_id, position);
+
+UPDATE price_zones SET multiplier = 1.0 WHERE multiplier IS NULL;
+ALTER TABLE price_zones ALTER COLUMN multiplier SET NOT NULL;
+
+-- The adjustments the API used to apply, for installations without rules of their own
+INSERT INTO pricing_rules (service_id, car_type, car_age_min, car_age_max, multiplier, fixed_addition, description, position)
+SELECT v.service_id, v.car_type, v.car_age_min, v.car_age_max, v.multiplier, 0, v.description, v.position
+FROM (VALUES
+    (NULL::integer, NULL::varchar, 11, NULL::integer, 1.2, 'Надбавка за старый автомобиль (+20%)', 10),
+    (NULL, NULL, NULL, 2, 0.9, 'Скидка за новый автомобиль (-10%)', 20),
+    (NULL, 'Premium', NULL, NULL, 1.5, 'Надбавка за премиум автомобиль (+50%)', 30),
+    (NULL, 'Luxury', NULL, NULL, 2.0, 'Надбавка за люксовый автомобиль (+100%)', 40)
+) AS v(service_id, car_type, car_age_min, car_age_max, multiplier, description, position)
+WHERE NOT EXISTS (SELECT 1 FROM pricing_rules);
@@ -0,0 +1,21 @@
+-- A master's price list: the catalog services the master offers, at the master's own price and duration.
+-- Masters can only be booked for services listed here with is_available set.
+CREATE TABLE IF NOT EXISTS master_services (
+    id SERIAL PRIMARY KEY,
+    master_id INTEGER NOT NULL REFERENCES masters(id) ON DELETE CASCADE,
+    service_id INTEGER NOT NULL REFERENCES services(id) ON DELETE CASCADE,
+    base_price DECIMAL(10,2) NOT NULL CHECK (base_price >= 0),
+    duration_minutes INTEGER NOT NULL CHECK (duration_minutes > 0),
+    is_available BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (master_id, service_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_master_services_service ON master_services(service_id) WHERE is_available;
+
+-- Existing masters keep offering the whole catalog at catalog prices
+INSERT INTO master_services (master_id, service_id, base_price, duration_minutes)
+SELECT m.id, s.id, COALESCE(s.base_price, 0), COALESCE(NULLIF(s.duration_minutes, 0), 60)
+FROM masters m CROSS JOIN services s
+ON CONFLICT (master_id, service_id) DO NOTHING;
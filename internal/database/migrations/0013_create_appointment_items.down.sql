@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS bundle_discounts;
+DROP TABLE IF EXISTS appointment_items;
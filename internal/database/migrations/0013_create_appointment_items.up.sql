@@ -0,0 +1,43 @@
+-- The services booked in one appointment, in the order the master performs them.
+-- price and duration_minutes are the master's at booking time; appointments.service_id
+-- stays the first item's service for clients that show a single service.
+CREATE TABLE IF NOT EXISTS appointment_items (
+    id SERIAL PRIMARY KEY,
+    appointment_id INTEGER NOT NULL REFERENCES appointments(id) ON DELETE CASCADE,
+    service_id INTEGER NOT NULL REFERENCES services(id),
+    position INTEGER NOT NULL DEFAULT 0,
+    price DECIMAL(10,2) NOT NULL DEFAULT 0,
+    duration_minutes INTEGER NOT NULL CHECK (duration_minutes > 0),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (appointment_id, service_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_appointment_items_appointment ON appointment_items(appointment_id, position);
+
+-- Existing appointments have the one service they were booked for
+INSERT INTO appointment_items (appointment_id, service_id, position, price, duration_minutes)
+SELECT a.id, a.service_id, 0,
+       COALESCE(ms.base_price, s.base_price, 0),
+       COALESCE(ms.duration_minutes, NULLIF(s.duration_minutes, 0), 60)
+FROM appointments a
+JOIN services s ON s.id = a.service_id
+LEFT JOIN master_services ms ON ms.master_id = a.master_id AND ms.service_id = a.service_id
+ON CONFLICT (appointment_id, service_id) DO NOTHING;
+
+-- Discounts for booking several services at once. A basket of at least min_services services
+-- gets percent off its total; when several tiers apply the largest percent wins.
+CREATE TABLE IF NOT EXISTS bundle_discounts (
+    id SERIAL PRIMARY KEY,
+    min_services INTEGER NOT NULL CHECK (min_services >= 2),
+    percent DECIMAL(5,2) NOT NULL CHECK (percent > 0 AND percent < 100),
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO bundle_discounts (min_services, percent, description)
+SELECT v.min_services, v.percent, v.description
+FROM (VALUES
+    (2, 5.0, 'Скидка за 2 услуги (-5%)'),
+    (3, 10.0, 'Скидка за 3 и более услуги (-10%)')
+) AS v(min_services, percent, description)
+WHERE NOT EXISTS (SELECT 1 FROM bundle_discounts);
@@ -0,0 +1,3 @@
+ALTER TABLE appointments DROP COLUMN IF EXISTS price;
+ALTER TABLE appointments DROP COLUMN IF EXISTS quote_id;
+DROP TABLE IF EXISTS price_quotes;
@@ -0,0 +1,20 @@
+-- Prices shown to customers, kept so the price can be honoured at booking until expires_at.
+-- items holds the priced services and price_details the basket breakdown, both as the API returned them.
+CREATE TABLE IF NOT EXISTS price_quotes (
+    id SERIAL PRIMARY KEY,
+    car_id INTEGER NOT NULL REFERENCES cars(id) ON DELETE CASCADE,
+    master_id INTEGER REFERENCES masters(id) ON DELETE CASCADE,
+    zone_id INTEGER REFERENCES price_zones(id) ON DELETE SET NULL,
+    items JSONB NOT NULL,
+    price_details JSONB NOT NULL,
+    subtotal DECIMAL(10,2) NOT NULL,
+    discount DECIMAL(10,2) NOT NULL DEFAULT 0,
+    total DECIMAL(10,2) NOT NULL,
+    duration_minutes INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- price is what the customer pays, locked from quote_id when the appointment was booked with a quote
+ALTER TABLE appointments ADD COLUMN IF NOT EXISTS quote_id INTEGER REFERENCES price_quotes(id) ON DELETE SET NULL;
+ALTER TABLE appointments ADD COLUMN IF NOT EXISTS price DECIMAL(10,2);
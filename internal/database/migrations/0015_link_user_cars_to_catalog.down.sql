@@ -0,0 +1,2 @@
+ALTER TABLE price_quotes DROP COLUMN IF EXISTS user_car_id;
+ALTER TABLE user_cars DROP COLUMN IF EXISTS car_id;
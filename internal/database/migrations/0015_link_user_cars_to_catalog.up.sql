@@ -0,0 +1,12 @@
+-- car_id links a car from the user's garage to the catalog car it is, so it can be priced.
+-- Existing cars are linked where their name is exactly the catalog brand and model.
+ALTER TABLE user_cars ADD COLUMN IF NOT EXISTS car_id INTEGER REFERENCES cars(id) ON DELETE SET NULL;
+
+UPDATE user_cars uc SET car_id = (
+    SELECT c.id FROM cars c
+    WHERE LOWER(c.brand || ' ' || c.model) = LOWER(TRIM(uc.name))
+    ORDER BY c.id LIMIT 1
+)
+WHERE uc.car_id IS NULL;
+
+ALTER TABLE price_quotes ADD COLUMN IF NOT EXISTS user_car_id INTEGER REFERENCES user_cars(id) ON DELETE SET NULL;
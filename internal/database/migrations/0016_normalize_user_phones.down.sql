@@ -0,0 +1,3 @@
+-- The original notation of the numbers is not kept, so only the index is restored
+DROP INDEX IF EXISTS idx_users_phone;
+CREATE INDEX IF NOT EXISTS idx_users_phone_digits ON users ((regexp_replace(phone, '[^0-9]', '', 'g')));
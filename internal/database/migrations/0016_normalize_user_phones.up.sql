@@ -0,0 +1,9 @@
+-- Phone login looks users up by the number normalized to +7XXXXXXXXXX, as new phones are stored.
+-- Bring existing numbers written as 8XXXXXXXXXX, 7XXXXXXXXXX or without the country code to that form;
+-- anything that is not a valid number is left as it is.
+UPDATE users
+SET phone = '+7' || RIGHT(regexp_replace(phone, '[^0-9]', '', 'g'), 10)
+WHERE regexp_replace(phone, '[^0-9]', '', 'g') ~ '^([78][0-9]{10}|[0-9]{10})$';
+
+DROP INDEX IF EXISTS idx_users_phone_digits;
+CREATE INDEX IF NOT EXISTS idx_users_phone ON users(phone);
@@ -0,0 +1,15 @@
+ALTER TABLE otp_codes
+    ALTER COLUMN expires_at TYPE TIMESTAMP,
+    ALTER COLUMN consumed_at TYPE TIMESTAMP,
+    ALTER COLUMN created_at TYPE TIMESTAMP;
+
+ALTER TABLE auth_tokens
+    ALTER COLUMN expires_at TYPE TIMESTAMP,
+    ALTER COLUMN used_at TYPE TIMESTAMP,
+    ALTER COLUMN created_at TYPE TIMESTAMP;
+
+ALTER TABLE refresh_tokens
+    ALTER COLUMN expires_at TYPE TIMESTAMP,
+    ALTER COLUMN rotated_at TYPE TIMESTAMP,
+    ALTER COLUMN revoked_at TYPE TIMESTAMP,
+    ALTER COLUMN created_at TYPE TIMESTAMP;
@@ -0,0 +1,19 @@
+-- Expiry and rate-limit checks compare these columns with the application's clock. As TIMESTAMP they
+-- hold wall-clock times of whichever zone wrote them (the server for expires_at, the database for NOW()),
+-- which is off by the UTC offset whenever the two zones are not UTC. TIMESTAMPTZ stores instants.
+-- Existing values are read in the session time zone, the zone NOW() wrote them in.
+ALTER TABLE refresh_tokens
+    ALTER COLUMN expires_at TYPE TIMESTAMPTZ,
+    ALTER COLUMN rotated_at TYPE TIMESTAMPTZ,
+    ALTER COLUMN revoked_at TYPE TIMESTAMPTZ,
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ;
+
+ALTER TABLE auth_tokens
+    ALTER COLUMN expires_at TYPE TIMESTAMPTZ,
+    ALTER COLUMN used_at TYPE TIMESTAMPTZ,
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ;
+
+ALTER TABLE otp_codes
+    ALTER COLUMN expires_at TYPE TIMESTAMPTZ,
+    ALTER COLUMN consumed_at TYPE TIMESTAMPTZ,
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ;
@@ -0,0 +1,9 @@
+DELETE FROM appointment_events WHERE type = 'deleted';
+ALTER TABLE appointment_events DROP CONSTRAINT IF EXISTS appointment_events_type_check;
+ALTER TABLE appointment_events ADD CONSTRAINT appointment_events_type_check
+    CHECK (type IN ('created', 'status_changed', 'rescheduled', 'reschedule_requested', 'reschedule_declined'));
+
+-- The history of deleted appointments cannot be kept under the foreign key
+DELETE FROM appointment_events e WHERE NOT EXISTS (SELECT 1 FROM appointments a WHERE a.id = e.appointment_id);
+ALTER TABLE appointment_events ADD CONSTRAINT appointment_events_appointment_id_fkey
+    FOREIGN KEY (appointment_id) REFERENCES appointments(id) ON DELETE CASCADE;
@@ -0,0 +1,7 @@
+-- Deleting an appointment no longer erases its history: events keep the id of the deleted
+-- appointment, and the deletion itself is recorded as the last event.
+ALTER TABLE appointment_events DROP CONSTRAINT IF EXISTS appointment_events_appointment_id_fkey;
+
+ALTER TABLE appointment_events DROP CONSTRAINT IF EXISTS appointment_events_type_check;
+ALTER TABLE appointment_events ADD CONSTRAINT appointment_events_type_check
+    CHECK (type IN ('created', 'status_changed', 'rescheduled', 'reschedule_requested', 'reschedule_declined', 'deleted'));
@@ -0,0 +1,4 @@
+ALTER TABLE appointments DROP COLUMN IF EXISTS user_car_id;
+ALTER TABLE appointments DROP COLUMN IF EXISTS car_id;
+DROP INDEX IF EXISTS idx_price_quotes_expires_at;
+ALTER TABLE price_quotes DROP COLUMN IF EXISTS user_id;
@@ -0,0 +1,15 @@
+-- A quote belongs to the customer it was made for and can only be booked by them, for the quoted car.
+-- Quotes made before they had an owner cannot be booked any more and are dropped, except those
+-- already booked, which take the owner of their appointment.
+DELETE FROM price_quotes q WHERE NOT EXISTS (SELECT 1 FROM appointments a WHERE a.quote_id = q.id);
+
+ALTER TABLE price_quotes ADD COLUMN IF NOT EXISTS user_id INTEGER REFERENCES users(id) ON DELETE CASCADE;
+UPDATE price_quotes q SET user_id = a.user_id FROM appointments a WHERE a.quote_id = q.id AND q.user_id IS NULL;
+ALTER TABLE price_quotes ALTER COLUMN user_id SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_price_quotes_expires_at ON price_quotes(expires_at);
+
+-- The car an appointment is for: a catalog car, and the customer's own car if they picked one
+ALTER TABLE appointments ADD COLUMN IF NOT EXISTS car_id INTEGER REFERENCES cars(id) ON DELETE SET NULL;
+ALTER TABLE appointments ADD COLUMN IF NOT EXISTS user_car_id INTEGER REFERENCES user_cars(id) ON DELETE SET NULL;
+UPDATE appointments a SET car_id = q.car_id, user_car_id = q.user_car_id FROM price_quotes q WHERE q.id = a.quote_id;
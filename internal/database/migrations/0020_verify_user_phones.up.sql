@@ -0,0 +1,12 @@
+-- Phone login signs in only the account that proved it owns the number with a code.
+-- Users created by phone login (no password) have done so; the oldest of them keeps the number.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS phone_verified_at TIMESTAMPTZ;
+
+UPDATE users u SET phone_verified_at = u.created_at
+WHERE u.password_hash IS NULL AND u.phone IS NOT NULL
+  AND NOT EXISTS (
+      SELECT 1 FROM users o
+      WHERE o.phone = u.phone AND o.password_hash IS NULL AND o.id < u.id
+  );
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_verified_phone ON users(phone) WHERE phone_verified_at IS NOT NULL;
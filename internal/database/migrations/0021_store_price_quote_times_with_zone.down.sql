@@ -0,0 +1,3 @@
+ALTER TABLE price_quotes
+    ALTER COLUMN created_at TYPE TIMESTAMP,
+    ALTER COLUMN expires_at TYPE TIMESTAMP USING expires_at AT TIME ZONE 'UTC';
@@ -0,0 +1,6 @@
+-- expires_at was written as UTC wall-clock time and compared with NOW() converted to UTC.
+-- TIMESTAMPTZ stores the instant, so it compares with NOW() directly whatever the session time zone.
+-- created_at was written by NOW() and is read in the session time zone, like the auth tables in 0017.
+ALTER TABLE price_quotes
+    ALTER COLUMN expires_at TYPE TIMESTAMPTZ USING expires_at AT TIME ZONE 'UTC',
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ;
@@ -0,0 +1,444 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"beep-backend/internal/auth"
+	"beep-backend/internal/mail"
+	"beep-backend/internal/models"
+	"beep-backend/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// issueAuthResponse starts a new session for the user and writes the token pair returned by Login and Register
+func (h *Handlers) issueAuthResponse(c *gin.Context, status int, user *models.User) {
+	sessionID, err := auth.NewSessionID()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start session"})
+		return
+	}
+
+	role := user.Role
+	if role == "" {
+		role = models.RoleCustomer
+	}
+	tokens, err := h.issueTokenPair(c, user.ID, role, sessionID, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue token"})
+		return
+	}
+
+	tokens["user"] = gin.H{
+		"id":         user.ID,
+		"name":       user.Name,
+		"email":      user.Email,
+		"phone":      user.Phone,
+		"role":       role,
+		"created_at": user.CreatedAt,
+	}
+	c.JSON(status, tokens)
+}
+
+// issueTokenPair issues an access token and a refresh token for the session.
+// When rotate is set the refresh token replaces it; otherwise a new session is stored.
+func (h *Handlers) issueTokenPair(c *gin.Context, userID int, role, sessionID string, rotate *models.RefreshToken) (gin.H, error) {
+	refreshToken, refreshHash, err := auth.NewOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	stored := &models.RefreshToken{
+		UserID:    userID,
+		FamilyID:  sessionID,
+		TokenHash: refreshHash,
+		Device:    deviceName(c),
+		IPAddress: c.ClientIP(),
+		ExpiresAt: time.Now().Add(h.refreshTTL),
+	}
+	if rotate != nil {
+		err = h.repo.RotateRefreshToken(c.Request.Context(), rotate.ID, stored)
+	} else {
+		err = h.repo.CreateRefreshToken(c.Request.Context(), stored)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, expiresAt, err := h.tokens.Issue(userID, role, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return gin.H{
+		"token":              accessToken,
+		"token_type":         "Bearer",
+		"expires_at":         expiresAt,
+		"refresh_token":      refreshToken,
+		"refresh_expires_at": stored.ExpiresAt,
+	}, nil
+}
+
+// deviceName describes the client for the sessions list, preferring an explicit X-Device-Name header
+func deviceName(c *gin.Context) string {
+	if device := c.GetHeader("X-Device-Name"); device != "" {
+		return device
+	}
+	return c.Request.UserAgent()
+}
+
+// userRole returns the role stored for the user
+func (h *Handlers) userRole(ctx context.Context, userID int) string {
+	user, err := h.repo.GetUserByID(ctx, userID)
+	if err != nil || user.Role == "" {
+		return models.RoleCustomer
+	}
+	return user.Role
+}
+
+// RefreshToken exchanges a refresh token for a new token pair.
+// Presenting a token that was already rotated revokes the whole session.
+func (h *Handlers) RefreshToken(c *gin.Context) {
+	type Request struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+
+	var req Request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	stored, err := h.repo.GetRefreshTokenByHash(c.Request.Context(), auth.HashToken(req.RefreshToken))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid refresh token"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if stored.RevokedAt != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has been revoked"})
+		return
+	}
+	if stored.RotatedAt != nil {
+		h.revokeReusedSession(c.Request.Context(), stored)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token reuse detected, session revoked"})
+		return
+	}
+	if time.Now().After(stored.ExpiresAt) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token expired"})
+		return
+	}
+
+	tokens, err := h.issueTokenPair(c, stored.UserID, h.userRole(c.Request.Context(), stored.UserID), stored.FamilyID, stored)
+	if err != nil {
+		if errors.Is(err, repository.ErrRefreshTokenReused) {
+			h.revokeReusedSession(c.Request.Context(), stored)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token reuse detected, session revoked"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+func (h *Handlers) revokeReusedSession(ctx context.Context, token *models.RefreshToken) {
+	log.Printf("Warning: Refresh token reuse detected for user %d, session %s", token.UserID, token.FamilyID)
+	if err := h.repo.RevokeRefreshTokenFamily(ctx, token.UserID, token.FamilyID); err != nil {
+		log.Printf("Warning: Failed to revoke session %s: %v", token.FamilyID, err)
+	}
+}
+
+// Logout revokes the session of the given refresh token, or of the current access token if none is given
+func (h *Handlers) Logout(c *gin.Context) {
+	type Request struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	var req Request
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	var userID int
+	var sessionID string
+	if req.RefreshToken != "" {
+		stored, err := h.repo.GetRefreshTokenByHash(c.Request.Context(), auth.HashToken(req.RefreshToken))
+		if err != nil {
+			if err == sql.ErrNoRows {
+				// Unknown tokens are already logged out
+				c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		userID, sessionID = stored.UserID, stored.FamilyID
+	} else {
+		var err error
+		userID, err = h.getUserIDFromContext(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		sessionID = c.GetString(ctxSessionIDKey)
+		if sessionID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "refresh_token is required"})
+			return
+		}
+	}
+
+	if err := h.repo.RevokeRefreshTokenFamily(c.Request.Context(), userID, sessionID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// LogoutAll revokes every session of the current user
+func (h *Handlers) LogoutAll(c *gin.Context) {
+	userID, err := h.getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if err := h.repo.RevokeUserRefreshTokens(c.Request.Context(), userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out from all sessions"})
+}
+
+// GetUserSessions lists active sessions of the current user
+func (h *Handlers) GetUserSessions(c *gin.Context) {
+	userID, err := h.getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	sessions, err := h.repo.GetUserSessions(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	currentSessionID := c.GetString(ctxSessionIDKey)
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentSessionID
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+// sendEmailVerification emails the user a single-use link confirming their address
+func (h *Handlers) sendEmailVerification(c *gin.Context, user *models.User) error {
+	token, err := h.createAuthToken(c.Request.Context(), user.ID, models.AuthTokenEmailVerification, h.emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/verify-email?token=%s", h.appBaseURL, url.QueryEscape(token))
+	return h.mailer.Send(c.Request.Context(), mail.Message{
+		To:      user.Email,
+		Subject: "Подтверждение email в BEEP",
+		Body: fmt.Sprintf("Здравствуйте, %s!\n\nЧтобы подтвердить адрес электронной почты, перейдите по ссылке:\n%s\n\nСсылка действительна до %s.",
+			user.Name, link, time.Now().Add(h.emailVerificationTTL).Format("02.01.2006 15:04")),
+	})
+}
+
+// createAuthToken stores a new single-use token for the user and returns its plaintext
+func (h *Handlers) createAuthToken(ctx context.Context, userID int, purpose string, ttl time.Duration) (string, error) {
+	token, tokenHash, err := auth.NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	if err := h.repo.CreateAuthToken(ctx, userID, purpose, tokenHash, time.Now().Add(ttl)); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ForgotPassword emails a password reset link. The response does not reveal whether the email is registered.
+func (h *Handlers) ForgotPassword(c *gin.Context) {
+	type Request struct {
+		Email string `json:"email" binding:"required"`
+	}
+
+	var req Request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := gin.H{"message": "If the email is registered, a password reset link has been sent"}
+
+	user, err := h.repo.GetUserByEmail(c.Request.Context(), req.Email)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("Warning: Failed to look up user for password reset: %v", err)
+		}
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	token, err := h.createAuthToken(c.Request.Context(), user.ID, models.AuthTokenPasswordReset, h.passwordResetTTL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create reset token"})
+		return
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", h.appBaseURL, url.QueryEscape(token))
+	err = h.mailer.Send(c.Request.Context(), mail.Message{
+		To:      user.Email,
+		Subject: "Восстановление пароля BEEP",
+		Body: fmt.Sprintf("Здравствуйте, %s!\n\nЧтобы задать новый пароль, перейдите по ссылке:\n%s\n\nСсылка действительна до %s. Если вы не запрашивали восстановление, просто проигнорируйте это письмо.",
+			user.Name, link, time.Now().Add(h.passwordResetTTL).Format("02.01.2006 15:04")),
+	})
+	if err != nil {
+		log.Printf("Warning: Failed to send password reset email to user %d: %v", user.ID, err)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ResetPassword sets a new password using a reset token and signs the user out of every session
+func (h *Handlers) ResetPassword(c *gin.Context) {
+	type Request struct {
+		Token    string `json:"token" binding:"required"`
+		Password string `json:"password" binding:"required,min=8,max=72"`
+	}
+
+	var req Request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Hash before consuming the token so a rejected password does not use it up
+	passwordHash, ok := hashPassword(c, req.Password)
+	if !ok {
+		return
+	}
+
+	userID, err := h.repo.ConsumeAuthToken(c.Request.Context(), models.AuthTokenPasswordReset, auth.HashToken(req.Token))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.repo.UpdateUserPassword(c.Request.Context(), userID, passwordHash); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.repo.RevokeUserRefreshTokens(c.Request.Context(), userID); err != nil {
+		log.Printf("Warning: Failed to revoke sessions after password reset for user %d: %v", userID, err)
+	}
+
+	// Following the emailed link proves ownership of the address
+	if err := h.repo.MarkEmailVerified(c.Request.Context(), userID); err != nil {
+		log.Printf("Warning: Failed to mark email verified for user %d: %v", userID, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset"})
+}
+
+// VerifyEmail confirms the user's email address using a verification token
+func (h *Handlers) VerifyEmail(c *gin.Context) {
+	type Request struct {
+		Token string `json:"token" binding:"required"`
+	}
+
+	var req Request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, err := h.repo.ConsumeAuthToken(c.Request.Context(), models.AuthTokenEmailVerification, auth.HashToken(req.Token))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification token"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.repo.MarkEmailVerified(c.Request.Context(), userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+}
+
+// ResendEmailVerification sends a fresh verification link to the current user
+func (h *Handlers) ResendEmailVerification(c *gin.Context) {
+	userID, err := h.getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	user, err := h.repo.GetUserByID(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if user.Email == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No email address on the profile"})
+		return
+	}
+
+	if user.EmailVerifiedAt != nil {
+		c.JSON(http.StatusOK, gin.H{"message": "Email is already verified"})
+		return
+	}
+
+	if err := h.sendEmailVerification(c, user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Verification email sent"})
+}
+
+// hashPassword hashes a new password, writing 400 for one bcrypt cannot hash.
+// The binding's max=72 counts characters, so a long non-ASCII password is caught here.
+func hashPassword(c *gin.Context, password string) (string, bool) {
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		if errors.Is(err, auth.ErrPasswordTooLong) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return "", false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return "", false
+	}
+	return hash, true
+}
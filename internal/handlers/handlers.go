@@ -1,9 +1,15 @@
 package handlers
 
 import (
+	"beep-backend/internal/auth"
+	"beep-backend/internal/booking"
+	"beep-backend/internal/mail"
 	"beep-backend/internal/models"
+	"beep-backend/internal/pricing"
 	"beep-backend/internal/repository"
+	"beep-backend/internal/sms"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -16,56 +22,82 @@ import (
 )
 
 type Handlers struct {
-	repo *repository.Repository
+	repo                 repository.Store
+	booking              *booking.Service
+	pricing              *pricing.Service
+	tokens               *auth.TokenManager
+	mailer               mail.Mailer
+	refreshTTL           time.Duration
+	passwordResetTTL     time.Duration
+	emailVerificationTTL time.Duration
+	appBaseURL           string
+	sms                  sms.Sender
+	otp                  OTPSettings
+	uploads              UploadSettings
+	devAuth              bool
 }
 
-func New(repo *repository.Repository) *Handlers {
-	return &Handlers{repo: repo}
+// Options carries the collaborators Handlers needs besides the repository
+type Options struct {
+	Tokens               *auth.TokenManager
+	Mailer               mail.Mailer
+	RefreshTokenTTL      time.Duration
+	PasswordResetTTL     time.Duration
+	EmailVerificationTTL time.Duration
+	// AppBaseURL is used to build links in emails
+	AppBaseURL string
+	SMS        sms.Sender
+	OTP        OTPSettings
+	Uploads    UploadSettings
+	// DevAuth accepts legacy "mock-jwt-token-<email>" tokens
+	DevAuth bool
+	Booking booking.Settings
+	Pricing pricing.Settings
 }
 
-// getUserIDFromContext extracts user ID from JWT token in Authorization header
-func (h *Handlers) getUserIDFromContext(c *gin.Context) (int, error) {
-	authHeader := c.GetHeader("Authorization")
-	if authHeader == "" {
-		return 0, fmt.Errorf("no authorization header")
-	}
-
-	// Remove "Bearer " prefix if present
-	token := strings.TrimPrefix(authHeader, "Bearer ")
-	token = strings.TrimSpace(token)
+// UploadSettings controls where uploaded photos are stored and how large they may be
+type UploadSettings struct {
+	Dir string
+	// URLPath is the public path Dir is served under
+	URLPath  string
+	MaxBytes int64
+}
 
-	// Extract email from mock token (format: "mock-jwt-token-email@example.com" or just "email@example.com")
-	var email string
-	if strings.Contains(token, "mock-jwt-token-") {
-		parts := strings.Split(token, "mock-jwt-token-")
-		if len(parts) != 2 {
-			return 0, fmt.Errorf("invalid token format: expected 'mock-jwt-token-email', got: %s", token)
-		}
-		email = parts[1]
-	} else {
-		// Try to use token directly as email (for backward compatibility)
-		email = token
+func New(repo repository.Store, opts Options) *Handlers {
+	opts.Uploads.URLPath = strings.TrimRight(opts.Uploads.URLPath, "/")
+	return &Handlers{
+		repo:                 repo,
+		booking:              booking.NewService(repo, opts.Booking),
+		pricing:              pricing.NewService(repo, opts.Pricing),
+		tokens:               opts.Tokens,
+		mailer:               opts.Mailer,
+		refreshTTL:           opts.RefreshTokenTTL,
+		passwordResetTTL:     opts.PasswordResetTTL,
+		emailVerificationTTL: opts.EmailVerificationTTL,
+		appBaseURL:           strings.TrimRight(opts.AppBaseURL, "/"),
+		sms:                  opts.SMS,
+		otp:                  opts.OTP,
+		uploads:              opts.Uploads,
+		devAuth:              opts.DevAuth,
 	}
+}
 
-	if email == "" {
-		return 0, fmt.Errorf("email not found in token: %s", token)
+// getUserIDFromContext returns the ID of the user attached to the request by the Authenticate middleware
+func (h *Handlers) getUserIDFromContext(c *gin.Context) (int, error) {
+	if userID := c.GetInt(ctxUserIDKey); userID > 0 {
+		return userID, nil
 	}
-
-	// Get user by email
-	user, err := h.repo.GetUserByEmail(email)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return 0, fmt.Errorf("user not found with email: %s. Please register first", email)
+	if value, ok := c.Get(ctxAuthErrorKey); ok {
+		if err, ok := value.(error); ok {
+			return 0, err
 		}
-		return 0, fmt.Errorf("database error: %v", err)
 	}
-
-	return user.ID, nil
+	return 0, fmt.Errorf("no authorization header")
 }
 
 // Categories
 func (h *Handlers) GetCategories(c *gin.Context) {
-	categories, err := h.repo.GetAllCategories()
+	categories, err := h.repo.GetAllCategories(c.Request.Context())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -86,7 +118,7 @@ func (h *Handlers) GetCategoryByID(c *gin.Context) {
 		return
 	}
 
-	category, err := h.repo.GetCategoryByID(id)
+	category, err := h.repo.GetCategoryByID(c.Request.Context(), id)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
@@ -111,9 +143,9 @@ func (h *Handlers) GetServices(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category_id"})
 			return
 		}
-		services, err = h.repo.GetServicesByCategory(id)
+		services, err = h.repo.GetServicesByCategory(c.Request.Context(), id)
 	} else {
-		services, err = h.repo.GetAllServices()
+		services, err = h.repo.GetAllServices(c.Request.Context())
 	}
 
 	if err != nil {
@@ -130,7 +162,7 @@ func (h *Handlers) GetServiceByID(c *gin.Context) {
 		return
 	}
 
-	service, err := h.repo.GetServiceByID(id)
+	service, err := h.repo.GetServiceByID(c.Request.Context(), id)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Service not found"})
@@ -144,7 +176,7 @@ func (h *Handlers) GetServiceByID(c *gin.Context) {
 
 // Cars
 func (h *Handlers) GetCars(c *gin.Context) {
-	cars, err := h.repo.GetAllCars()
+	cars, err := h.repo.GetAllCars(c.Request.Context())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -159,7 +191,7 @@ func (h *Handlers) GetCarByID(c *gin.Context) {
 		return
 	}
 
-	car, err := h.repo.GetCarByID(id)
+	car, err := h.repo.GetCarByID(c.Request.Context(), id)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Car not found"})
@@ -175,7 +207,9 @@ func (h *Handlers) GetCarByID(c *gin.Context) {
 func (h *Handlers) CalculatePrice(c *gin.Context) {
 	type Request struct {
 		ServiceID int `json:"service_id" binding:"required"`
-		CarID     int `json:"car_id" binding:"required"`
+		CarID     int `json:"car_id"`
+		UserCarID int `json:"user_car_id"`
+		MasterID  int `json:"master_id"`
 		ZoneID    int `json:"zone_id"`
 	}
 
@@ -184,57 +218,239 @@ func (h *Handlers) CalculatePrice(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	userID, ok := h.pricedCarOwner(c, req.CarID, req.UserCarID)
+	if !ok {
+		return
+	}
+
+	// Only a quote can be booked at a fixed price, so a single price is not stored
+	result, err := h.pricing.Calculate(c.Request.Context(), pricing.Request{
+		ServiceID: req.ServiceID,
+		CarID:     req.CarID,
+		UserCarID: req.UserCarID,
+		UserID:    userID,
+		MasterID:  req.MasterID,
+		ZoneID:    req.ZoneID,
+	})
+	if err != nil {
+		pricingError(c, err)
+		return
+	}
 
-	result, err := h.repo.CalculatePrice(req.ServiceID, req.CarID)
+	c.JSON(http.StatusOK, result)
+}
+
+// QuotePrice prices a basket of services of one master for one car, with the bundle discount the basket qualifies for.
+// The quote is stored for the signed-in user; booking the master with its id keeps the quoted prices until it expires.
+func (h *Handlers) QuotePrice(c *gin.Context) {
+	type Request struct {
+		CarID      int   `json:"car_id"`
+		UserCarID  int   `json:"user_car_id"`
+		ServiceIDs []int `json:"service_ids" binding:"required"`
+		MasterID   int   `json:"master_id"`
+		ZoneID     int   `json:"zone_id"`
+	}
+
+	var req Request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, ok := h.pricedCarOwner(c, req.CarID, req.UserCarID); !ok {
+		return
+	}
+	// The quote belongs to the caller whichever car is priced
+	userID, err := h.getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	quote, err := h.pricing.Quote(c.Request.Context(), pricing.QuoteRequest{
+		CarID:      req.CarID,
+		UserCarID:  req.UserCarID,
+		UserID:     userID,
+		ServiceIDs: req.ServiceIDs,
+		MasterID:   req.MasterID,
+		ZoneID:     req.ZoneID,
+	})
+	if err != nil {
+		pricingError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, quote)
+}
+
+// GetPriceQuote returns a stored quote of the signed-in user with its breakdown, including expired ones.
+// Quotes of other users are reported as not found.
+func (h *Handlers) GetPriceQuote(c *gin.Context) {
+	userID, err := h.getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	quoteID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid quote ID"})
+		return
+	}
+
+	quote, err := h.repo.GetPriceQuote(c.Request.Context(), quoteID)
+	if err == nil && quote.UserID != userID {
+		err = sql.ErrNoRows
+	}
 	if err != nil {
 		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Service or car not found"})
+			c.JSON(http.StatusNotFound, gin.H{"error": "Price quote not found"})
 			return
 		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	c.JSON(http.StatusOK, quote)
+}
 
-	c.JSON(http.StatusOK, result)
+// pricedCarOwner checks that a pricing request names exactly one of a catalog car and a user car.
+// For a user car it returns the signed-in user, whose garage the car must be in.
+// Writes the error response and returns false otherwise.
+func (h *Handlers) pricedCarOwner(c *gin.Context, carID, userCarID int) (int, bool) {
+	if (carID == 0) == (userCarID == 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Specify either car_id or user_car_id"})
+		return 0, false
+	}
+	if userCarID == 0 {
+		return 0, true
+	}
+	userID, err := h.getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return 0, false
+	}
+	return userID, true
+}
+
+// pricingError writes the response for an error of the pricing service
+func pricingError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, pricing.ErrEmptyBasket), errors.Is(err, pricing.ErrDuplicateService),
+		errors.Is(err, pricing.ErrUserCarNotLinked), errors.Is(err, pricing.ErrMasterRequired):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, pricing.ErrServiceNotFound), errors.Is(err, pricing.ErrCarNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Service or car not found"})
+	case errors.Is(err, pricing.ErrZoneNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Price zone not found"})
+	case errors.Is(err, pricing.ErrServiceNotOffered):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
 }
 
 // Masters
+
+// GetMasters lists masters. ?master_id= narrows the list to one master. With ?service_id= only masters
+// who can be booked for the service are listed, each with their offer of it; adding ?car_id= (and
+// optionally ?zone_id=) also returns each master's price for that car.
 func (h *Handlers) GetMasters(c *gin.Context) {
-	masters, err := h.repo.GetAllMasters()
+	var filter struct {
+		MasterID  int `form:"master_id"`
+		ServiceID int `form:"service_id"`
+		CarID     int `form:"car_id"`
+		ZoneID    int `form:"zone_id"`
+	}
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if filter.CarID != 0 && filter.ServiceID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "car_id requires service_id"})
+		return
+	}
+
+	masters, err := h.repo.GetAllMasters(c.Request.Context())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	var offers map[int]models.MasterService
+	if filter.ServiceID != 0 {
+		serviceOffers, err := h.repo.GetServiceOffers(c.Request.Context(), filter.ServiceID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		offers = make(map[int]models.MasterService, len(serviceOffers))
+		for _, offer := range serviceOffers {
+			offers[offer.MasterID] = offer
+		}
+	}
+
 	// Get user ID from context (optional - for checking favorites)
 	userID, _ := h.getUserIDFromContext(c)
 
 	// Enhance masters with verification status and favorite status
 	type MasterResponse struct {
 		*models.Master `json:",inline"`
-		IsVerified     bool `json:"is_verified"`
-		ReviewCount    int  `json:"review_count"`
-		WorkCount      int  `json:"work_count"`
-		IsFavorite     bool `json:"is_favorite"`
+		IsVerified     bool                           `json:"is_verified"`
+		ReviewCount    int                            `json:"review_count"`
+		WorkCount      int                            `json:"work_count"`
+		IsFavorite     bool                           `json:"is_favorite"`
+		Service        *models.MasterService          `json:"service,omitempty"`
+		Quote          *models.CalculatePriceResponse `json:"quote,omitempty"`
 	}
 
-	var result []MasterResponse
+	result := []MasterResponse{}
 	for _, master := range masters {
+		if filter.MasterID != 0 && master.ID != filter.MasterID {
+			continue
+		}
+		var offer *models.MasterService
+		if offers != nil {
+			o, ok := offers[master.ID]
+			if !ok {
+				continue
+			}
+			offer = &o
+		}
+
 		// Create a copy to avoid pointer issues
 		masterCopy := master
-		isVerified, reviewCount, workCount, _ := h.repo.CheckMasterVerificationStatus(master.ID)
+		isVerified, reviewCount, workCount, _ := h.repo.CheckMasterVerificationStatus(c.Request.Context(), master.ID)
 		isFavorite := false
 		if userID > 0 {
-			isFavorite, _ = h.repo.IsFavoriteMaster(userID, master.ID)
+			isFavorite, _ = h.repo.IsFavoriteMaster(c.Request.Context(), userID, master.ID)
 		}
 
-		result = append(result, MasterResponse{
+		response := MasterResponse{
 			Master:      &masterCopy, // Use copy, not original
 			IsVerified:  isVerified,
 			ReviewCount: reviewCount,
 			WorkCount:   workCount,
 			IsFavorite:  isFavorite,
-		})
+			Service:     offer,
+		}
+		if filter.CarID != 0 {
+			response.Quote, err = h.pricing.Calculate(c.Request.Context(), pricing.Request{
+				ServiceID: filter.ServiceID,
+				CarID:     filter.CarID,
+				MasterID:  master.ID,
+				ZoneID:    filter.ZoneID,
+			})
+			if err != nil {
+				switch {
+				case errors.Is(err, pricing.ErrCarNotFound):
+					c.JSON(http.StatusNotFound, gin.H{"error": "Car not found"})
+				case errors.Is(err, pricing.ErrZoneNotFound):
+					c.JSON(http.StatusNotFound, gin.H{"error": "Price zone not found"})
+				default:
+					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				}
+				return
+			}
+		}
+		result = append(result, response)
 	}
 
 	c.JSON(http.StatusOK, result)
@@ -247,7 +463,7 @@ func (h *Handlers) GetMasterByID(c *gin.Context) {
 		return
 	}
 
-	master, err := h.repo.GetMasterByID(id)
+	master, err := h.repo.GetMasterByID(c.Request.Context(), id)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Master not found"})
@@ -266,7 +482,7 @@ func (h *Handlers) GetMasterSchedule(c *gin.Context) {
 		return
 	}
 
-	schedules, err := h.repo.GetMasterSchedule(id)
+	schedules, err := h.repo.GetMasterSchedule(c.Request.Context(), id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -282,7 +498,7 @@ func (h *Handlers) GetMasterScheduleByUser(c *gin.Context) {
 		return
 	}
 
-	master, err := h.repo.GetMasterByUserID(userID)
+	master, err := h.repo.GetMasterByUserID(c.Request.Context(), userID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			c.JSON(http.StatusOK, []models.MasterSchedule{})
@@ -292,7 +508,7 @@ func (h *Handlers) GetMasterScheduleByUser(c *gin.Context) {
 		return
 	}
 
-	schedules, err := h.repo.GetMasterSchedule(master.ID)
+	schedules, err := h.repo.GetMasterSchedule(c.Request.Context(), master.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -313,7 +529,7 @@ func (h *Handlers) UpdateMasterSchedule(c *gin.Context) {
 		return
 	}
 
-	master, err := h.repo.GetMasterByUserID(userID)
+	master, err := h.repo.GetMasterByUserID(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Master not found"})
 		return
@@ -329,7 +545,8 @@ func (h *Handlers) UpdateMasterSchedule(c *gin.Context) {
 		return
 	}
 
-	// Validate schedules
+	// Validate schedules. A day may have several windows, e.g. before and after lunch, but they must not overlap.
+	windows := make(map[int][]models.TimeRange)
 	for _, schedule := range req.Schedules {
 		if schedule.DayOfWeek < 0 || schedule.DayOfWeek > 6 {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid day_of_week. Must be between 0 and 6"})
@@ -339,9 +556,26 @@ func (h *Handlers) UpdateMasterSchedule(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Start time and end time are required"})
 			return
 		}
+		start, startErr := parseClock(schedule.StartTime)
+		end, endErr := parseClock(schedule.EndTime)
+		if startErr != nil || endErr != nil || !start.Before(end) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Start time and end time must be HH:MM with start before end"})
+			return
+		}
+		if !schedule.IsActive {
+			continue
+		}
+		window := models.TimeRange{Start: start, End: end}
+		for _, other := range windows[schedule.DayOfWeek] {
+			if window.Overlaps(other) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Working hours of one day must not overlap"})
+				return
+			}
+		}
+		windows[schedule.DayOfWeek] = append(windows[schedule.DayOfWeek], window)
 	}
 
-	if err := h.repo.UpdateMasterSchedule(master.ID, req.Schedules); err != nil {
+	if err := h.repo.UpdateMasterSchedule(c.Request.Context(), master.ID, req.Schedules); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -357,31 +591,69 @@ func (h *Handlers) GetAvailableSlots(c *gin.Context) {
 		return
 	}
 
-	dateStr := c.Query("date")
-	if dateStr == "" {
-		dateStr = time.Now().Format("2006-01-02")
+	// Without a date the master's today is used
+	var date time.Time
+	if dateStr := c.Query("date"); dateStr != "" {
+		date, err = time.Parse("2006-01-02", dateStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD"})
+			return
+		}
 	}
 
-	date, err := time.Parse("2006-01-02", dateStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD"})
-		return
+	// service_id may be repeated or comma-separated to book several services in a row
+	var serviceIDs []int
+	for _, value := range c.QueryArray("service_id") {
+		for _, part := range strings.Split(value, ",") {
+			if strings.TrimSpace(part) == "" {
+				continue
+			}
+			serviceID, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid service ID"})
+				return
+			}
+			serviceIDs = append(serviceIDs, serviceID)
+		}
 	}
 
-	slots, err := h.repo.GetAvailableSlots(masterID, date)
+	availability, err := h.booking.AvailableSlots(c.Request.Context(), masterID, date, serviceIDs)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		switch {
+		case errors.Is(err, booking.ErrMasterNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Master not found"})
+		case errors.Is(err, booking.ErrServiceNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Service not found"})
+		case errors.Is(err, booking.ErrCarNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Car not found"})
+		case errors.Is(err, booking.ErrServiceNotOffered):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"date": dateStr, "slots": slots})
+	c.JSON(http.StatusOK, gin.H{
+		"date":             availability.Date,
+		"time_zone":        availability.TimeZone,
+		"slots":            availability.Slots,
+		"duration_minutes": int(availability.Duration / time.Minute),
+	})
 }
 
 // Appointments
 func (h *Handlers) CreateAppointment(c *gin.Context) {
 	type Request struct {
-		MasterID  int    `json:"master_id" binding:"required"`
-		ServiceID int    `json:"service_id" binding:"required"`
+		MasterID int `json:"master_id" binding:"required"`
+		// Either service_id for a single service or service_ids for several, performed in that order
+		ServiceID  int   `json:"service_id"`
+		ServiceIDs []int `json:"service_ids"`
+		// QuoteID books at the quoted prices; the services default to the quote's
+		QuoteID int `json:"quote_id"`
+		// Optionally the car, either a catalog car_id or user_car_id from the customer's garage
+		CarID     int    `json:"car_id"`
+		UserCarID int    `json:"user_car_id"`
 		Date      string `json:"date" binding:"required"`
 		Time      string `json:"time" binding:"required"`
 		Comment   string `json:"comment"`
@@ -392,6 +664,21 @@ func (h *Handlers) CreateAppointment(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	serviceIDs := req.ServiceIDs
+	switch {
+	case req.ServiceID != 0 && len(serviceIDs) > 0:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Use either service_id or service_ids"})
+		return
+	case req.ServiceID != 0:
+		serviceIDs = []int{req.ServiceID}
+	case len(serviceIDs) == 0 && req.QuoteID == 0:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "service_id, service_ids or quote_id is required"})
+		return
+	}
+	if req.CarID != 0 && req.UserCarID != 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Use either car_id or user_car_id"})
+		return
+	}
 
 	// Parse date
 	date, err := time.Parse("2006-01-02", req.Date)
@@ -406,60 +693,43 @@ func (h *Handlers) CreateAppointment(c *gin.Context) {
 		return
 	}
 
-	appointment, err := h.repo.CreateAppointment(userID, req.MasterID, req.ServiceID, date, req.Time, req.Comment)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	// Automatically create 14-day guarantee for the appointment
-	// Get service and master info
-	service, err := h.repo.GetServiceByID(req.ServiceID)
-	if err != nil {
-		log.Printf("Warning: Failed to get service for guarantee: %v", err)
-		service = nil
-	}
-
-	master, err := h.repo.GetMasterByID(req.MasterID)
-	if err != nil {
-		log.Printf("Warning: Failed to get master for guarantee: %v", err)
-		master = nil
-	}
-
-	// Create guarantee if we have both service and master
-	if service != nil && master != nil {
-		_, err := h.repo.CreateGuarantee(userID, appointment.ID, service.Name, master.Name, date)
-		if err != nil {
-			log.Printf("Warning: Failed to create guarantee: %v", err)
-		}
-	}
-
-	// Create notifications for user and master
-	user, err := h.repo.GetUserByID(userID)
+	appointment, err := h.booking.Book(c.Request.Context(), booking.Request{
+		UserID:     userID,
+		MasterID:   req.MasterID,
+		ServiceIDs: serviceIDs,
+		QuoteID:    req.QuoteID,
+		CarID:      req.CarID,
+		UserCarID:  req.UserCarID,
+		Date:       date,
+		Time:       req.Time,
+		Comment:    req.Comment,
+	})
 	if err != nil {
-		log.Printf("Warning: Failed to get user for notification: %v", err)
-	}
-
-	// Create notification for user about appointment status
-	if user != nil && master != nil && service != nil {
-		userNotifTitle := "Запись создана"
-		userNotifMsg := fmt.Sprintf("Вы записаны к мастеру %s на услугу %s. Дата: %s, Время: %s. Статус: Ожидание подтверждения",
-			master.Name, service.Name, date.Format("02.01.2006"), req.Time)
-		_, err := h.repo.CreateNotification(userID, "appointment_created", userNotifTitle, userNotifMsg, appointment.ID)
-		if err != nil {
-			log.Printf("Warning: Failed to create user notification: %v", err)
-		}
-	}
-
-	// Create notification for master about new appointment
-	if master != nil && master.UserID > 0 && user != nil && service != nil {
-		masterNotifTitle := "Новая запись"
-		masterNotifMsg := fmt.Sprintf("Клиент %s записался к вам на услугу %s. Дата: %s, Время: %s. Телефон: %s",
-			user.Name, service.Name, date.Format("02.01.2006"), req.Time, user.Phone)
-		_, err := h.repo.CreateNotification(master.UserID, "new_appointment", masterNotifTitle, masterNotifMsg, appointment.ID)
-		if err != nil {
-			log.Printf("Warning: Failed to create master notification: %v", err)
+		var slotErr *booking.SlotUnavailableError
+		switch {
+		case errors.As(err, &slotErr):
+			c.JSON(http.StatusConflict, gin.H{"error": slotErr.Reason, "suggested_slots": slotErr.Suggestions})
+		case errors.Is(err, booking.ErrInvalidTime), errors.Is(err, booking.ErrNoServices), errors.Is(err, booking.ErrDuplicateService),
+			errors.Is(err, booking.ErrQuoteMismatch):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, booking.ErrQuoteNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Price quote not found"})
+		case errors.Is(err, booking.ErrQuoteExpired):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, booking.ErrMasterNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Master not found"})
+		case errors.Is(err, booking.ErrServiceNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Service not found"})
+		case errors.Is(err, booking.ErrCarNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Car not found"})
+		case errors.Is(err, booking.ErrServiceNotOffered):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, booking.ErrUserNotFound):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create appointment: " + err.Error()})
 		}
+		return
 	}
 
 	c.JSON(http.StatusCreated, appointment)
@@ -472,7 +742,7 @@ func (h *Handlers) GetUserAppointments(c *gin.Context) {
 		return
 	}
 
-	appointments, err := h.repo.GetUserAppointments(userID)
+	appointments, err := h.repo.GetUserAppointments(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -480,35 +750,28 @@ func (h *Handlers) GetUserAppointments(c *gin.Context) {
 	c.JSON(http.StatusOK, appointments)
 }
 
+// GetAppointmentByID returns an appointment with the services booked in it to its customer or master
+// (checked by RequireAppointmentAccess)
 func (h *Handlers) GetAppointmentByID(c *gin.Context) {
-	id, err := strconv.Atoi(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
-		return
-	}
+	appointment := c.MustGet(ctxAppointmentKey).(*models.Appointment)
 
-	appointment, err := h.repo.GetAppointmentByID(id)
+	items, err := h.repo.GetAppointmentItems(c.Request.Context(), appointment.ID)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Appointment not found"})
-			return
-		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	appointment.Items = items
 	c.JSON(http.StatusOK, appointment)
 }
 
+// UpdateAppointment updates comment and status. Status changes follow booking.Transition,
+// and only the customer may change the comment.
 func (h *Handlers) UpdateAppointment(c *gin.Context) {
-	id, err := strconv.Atoi(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
-		return
-	}
-
 	type Request struct {
-		Comment string `json:"comment"`
-		Status  string `json:"status"`
+		Comment *string `json:"comment"`
+		Status  string  `json:"status"`
+		// Note explains the status change, e.g. why an appointment is rejected
+		Note string `json:"note"`
 	}
 
 	var req Request
@@ -517,101 +780,94 @@ func (h *Handlers) UpdateAppointment(c *gin.Context) {
 		return
 	}
 
-	// Get old appointment to check status change
-	oldAppointment, err := h.repo.GetAppointmentByID(id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
+	appointment := c.MustGet(ctxAppointmentKey).(*models.Appointment)
+	actor := c.GetString(ctxAppointmentActorKey)
 
-	if err := h.repo.UpdateAppointment(id, req.Comment, req.Status); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if req.Comment != nil && *req.Comment != appointment.Comment && actor == models.RoleMaster {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the customer can change the appointment comment"})
 		return
 	}
-
-	// Create notifications if status changed
-	if req.Status != "" && req.Status != oldAppointment.Status {
-		// Get appointment details for notification
-		appointments, err := h.repo.GetUserAppointments(oldAppointment.UserID)
-		var appointmentDetails *models.AppointmentWithDetails
-		if err == nil {
-			for _, apt := range appointments {
-				if apt.ID == id {
-					appointmentDetails = &apt
-					break
-				}
-			}
-		}
-
-		// Create notification for user about status change
-		var userNotifTitle, userNotifMsg string
-		masterName := "мастеру"
-		serviceName := "услугу"
-
-		if appointmentDetails != nil {
-			masterName = appointmentDetails.MasterName
-			serviceName = appointmentDetails.ServiceName
+	if req.Status != "" && req.Status != appointment.Status {
+		// Check the status first so a rejected change leaves the comment untouched
+		if err := booking.Transition(appointment.Status, req.Status, actor); err != nil {
+			h.statusChangeError(c, err)
+			return
 		}
+	}
 
-		switch req.Status {
-		case "confirmed":
-			userNotifTitle = "Запись подтверждена"
-			userNotifMsg = fmt.Sprintf("Ваша запись к мастеру %s на услугу %s подтверждена. Дата: %s, Время: %s",
-				masterName, serviceName,
-				oldAppointment.Date.Format("02.01.2006"), oldAppointment.Time)
-		case "completed":
-			userNotifTitle = "Услуга выполнена"
-			userNotifMsg = fmt.Sprintf("Услуга %s у мастера %s выполнена. Дата: %s",
-				serviceName, masterName,
-				oldAppointment.Date.Format("02.01.2006"))
-		case "cancelled":
-			userNotifTitle = "Запись отменена"
-			userNotifMsg = fmt.Sprintf("Ваша запись к мастеру %s на услугу %s отменена. Дата: %s, Время: %s",
-				masterName, serviceName,
-				oldAppointment.Date.Format("02.01.2006"), oldAppointment.Time)
+	if req.Comment != nil && *req.Comment != appointment.Comment {
+		if err := h.repo.UpdateAppointmentComment(c.Request.Context(), appointment.ID, *req.Comment); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
 		}
+	}
 
-		if userNotifTitle != "" {
-			_, err := h.repo.CreateNotification(oldAppointment.UserID, "appointment_status_changed", userNotifTitle, userNotifMsg, id)
-			if err != nil {
-				log.Printf("Warning: Failed to create user notification: %v", err)
-			}
+	if req.Status != "" && req.Status != appointment.Status {
+		if !h.changeAppointmentStatus(c, appointment, req.Status, req.Note) {
+			return
 		}
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Appointment updated successfully"})
 }
 
-func (h *Handlers) DeleteAppointment(c *gin.Context) {
-	id, err := strconv.Atoi(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
-		return
-	}
-
+// changeAppointmentStatus moves the appointment to status on behalf of the caller, or writes the error response
+func (h *Handlers) changeAppointmentStatus(c *gin.Context, appointment *models.Appointment, status, note string) bool {
 	userID, err := h.getUserIDFromContext(c)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-		return
+		return false
 	}
 
-	// Verify that the appointment belongs to the current user
-	appointment, err := h.repo.GetAppointmentByID(id)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Appointment not found"})
-			return
-		}
+	actor := booking.Actor{UserID: userID, Role: c.GetString(ctxAppointmentActorKey)}
+	if _, err := h.booking.ChangeStatus(c.Request.Context(), appointment, actor, status, note); err != nil {
+		h.statusChangeError(c, err)
+		return false
+	}
+	return true
+}
+
+// statusChangeError writes the response for an error of booking.ChangeStatus
+func (h *Handlers) statusChangeError(c *gin.Context, err error) {
+	var transitionErr *booking.TransitionError
+	switch {
+	case errors.Is(err, booking.ErrUnknownStatus):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.As(err, &transitionErr):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "allowed_statuses": transitionErr.Allowed})
+	case errors.Is(err, booking.ErrStatusForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, repository.ErrStatusChanged):
+		c.JSON(http.StatusConflict, gin.H{"error": "The appointment was changed by someone else, reload it and try again"})
+	default:
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// DeleteAppointment deletes a cancelled or rejected appointment; only its customer or an admin may do so
+// (checked by RequireAppointmentAccess). Appointments still in progress must be cancelled first so the
+// master is notified. The appointment's history is kept, ending with the deletion.
+func (h *Handlers) DeleteAppointment(c *gin.Context) {
+	appointment := c.MustGet(ctxAppointmentKey).(*models.Appointment)
+	if appointment.Status != models.AppointmentCancelled && appointment.Status != models.AppointmentRejected {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only cancelled or rejected appointments can be deleted, cancel it first"})
 		return
 	}
 
-	if appointment.UserID != userID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have permission to delete this appointment"})
+	userID, err := h.getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
 
-	if err := h.repo.DeleteAppointment(id); err != nil {
+	event := &models.AppointmentEvent{
+		Type:       models.AppointmentEventDeleted,
+		FromStatus: appointment.Status,
+		ToStatus:   appointment.Status,
+		ActorID:    &userID,
+		ActorRole:  c.GetString(ctxAppointmentActorKey),
+	}
+	if err := h.repo.DeleteAppointment(c.Request.Context(), appointment.ID, event); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -619,19 +875,49 @@ func (h *Handlers) DeleteAppointment(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Appointment deleted successfully"})
 }
 
+// CancelAppointment cancels a pending or confirmed appointment on behalf of its customer or master
+// (checked by RequireAppointmentAccess). An optional {"reason": "..."} body is kept in the history.
 func (h *Handlers) CancelAppointment(c *gin.Context) {
-	id, err := strconv.Atoi(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+	type Request struct {
+		Reason string `json:"reason"`
+	}
+
+	var req Request
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	appointment := c.MustGet(ctxAppointmentKey).(*models.Appointment)
+	if !h.changeAppointmentStatus(c, appointment, models.AppointmentCancelled, req.Reason) {
 		return
 	}
 
-	if err := h.repo.CancelAppointment(id); err != nil {
+	c.JSON(http.StatusOK, gin.H{"message": "Appointment cancelled successfully"})
+}
+
+// GetAppointmentHistory returns the status changes and moves of an appointment, oldest first,
+// with the statuses the caller may move it to next
+func (h *Handlers) GetAppointmentHistory(c *gin.Context) {
+	appointment := c.MustGet(ctxAppointmentKey).(*models.Appointment)
+
+	events, err := h.repo.GetAppointmentEvents(c.Request.Context(), appointment.ID)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if events == nil {
+		events = []models.AppointmentEvent{}
+	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Appointment cancelled successfully"})
+	c.JSON(http.StatusOK, gin.H{
+		"appointment_id":   appointment.ID,
+		"status":           appointment.Status,
+		"allowed_statuses": booking.NextStatuses(appointment.Status, c.GetString(ctxAppointmentActorKey)),
+		"events":           events,
+	})
 }
 
 // Auth handlers
@@ -647,11 +933,10 @@ func (h *Handlers) Login(c *gin.Context) {
 		return
 	}
 
-	// TODO: Implement actual authentication with JWT
-	// For MVP: Simple authentication
-	user, err := h.repo.GetUserByEmail(req.Email)
+	user, err := h.repo.GetUserByEmail(c.Request.Context(), req.Email)
 	if err != nil {
 		if err == sql.ErrNoRows {
+			auth.SimulatePasswordCheck(req.Password)
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
 			return
 		}
@@ -659,18 +944,22 @@ func (h *Handlers) Login(c *gin.Context) {
 		return
 	}
 
-	// For MVP: Simple password check (in production use bcrypt)
-	// For now, just return user (without sensitive data)
-	c.JSON(http.StatusOK, gin.H{
-		"token": "mock-jwt-token-" + user.Email,
-		"user": gin.H{
-			"id":         user.ID,
-			"name":       user.Name,
-			"email":      user.Email,
-			"phone":      user.Phone,
-			"created_at": user.CreatedAt,
-		},
-	})
+	needsRehash, err := auth.CheckPassword(user.PasswordHash, req.Password)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+		return
+	}
+
+	// Upgrade legacy "hashed-" rows and outdated bcrypt costs now that we know the plaintext
+	if needsRehash {
+		if hash, err := auth.HashPassword(req.Password); err != nil {
+			log.Printf("Warning: Failed to rehash password for user %d: %v", user.ID, err)
+		} else if err := h.repo.UpdateUserPassword(c.Request.Context(), user.ID, hash); err != nil {
+			log.Printf("Warning: Failed to store rehashed password for user %d: %v", user.ID, err)
+		}
+	}
+
+	h.issueAuthResponse(c, http.StatusOK, user)
 }
 
 func (h *Handlers) Register(c *gin.Context) {
@@ -678,7 +967,7 @@ func (h *Handlers) Register(c *gin.Context) {
 		Name     string `json:"name" binding:"required"`
 		Email    string `json:"email" binding:"required"`
 		Phone    string `json:"phone"`
-		Password string `json:"password" binding:"required"`
+		Password string `json:"password" binding:"required,max=72"`
 	}
 
 	var req Request
@@ -687,23 +976,22 @@ func (h *Handlers) Register(c *gin.Context) {
 		return
 	}
 
-	// TODO: Implement actual password hashing with bcrypt
-	user, err := h.repo.CreateUser(req.Name, req.Email, req.Phone, "hashed-"+req.Password)
+	passwordHash, ok := hashPassword(c, req.Password)
+	if !ok {
+		return
+	}
+
+	user, err := h.repo.CreateUser(c.Request.Context(), req.Name, req.Email, storedPhone(req.Phone), passwordHash)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"token": "mock-jwt-token-" + user.Email,
-		"user": gin.H{
-			"id":         user.ID,
-			"name":       user.Name,
-			"email":      user.Email,
-			"phone":      user.Phone,
-			"created_at": user.CreatedAt,
-		},
-	})
+	if err := h.sendEmailVerification(c, user); err != nil {
+		log.Printf("Warning: Failed to send verification email to user %d: %v", user.ID, err)
+	}
+
+	h.issueAuthResponse(c, http.StatusCreated, user)
 }
 
 // GetUserProfile gets user profile
@@ -714,7 +1002,7 @@ func (h *Handlers) GetUserProfile(c *gin.Context) {
 		return
 	}
 
-	user, err := h.repo.GetUserByID(userID)
+	user, err := h.repo.GetUserByID(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -725,9 +1013,10 @@ func (h *Handlers) GetUserProfile(c *gin.Context) {
 
 func (h *Handlers) UpdateUserProfile(c *gin.Context) {
 	type Request struct {
-		Name  *string `json:"name"`
-		Email *string `json:"email"`
-		Phone *string `json:"phone"`
+		Name     *string `json:"name"`
+		Email    *string `json:"email"`
+		Phone    *string `json:"phone"`
+		TimeZone *string `json:"time_zone"`
 	}
 
 	var req Request
@@ -735,6 +1024,10 @@ func (h *Handlers) UpdateUserProfile(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if req.TimeZone != nil && !validTimeZone(*req.TimeZone) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid time_zone. Use an IANA name like Asia/Almaty"})
+		return
+	}
 
 	userID, err := h.getUserIDFromContext(c)
 	if err != nil {
@@ -743,7 +1036,7 @@ func (h *Handlers) UpdateUserProfile(c *gin.Context) {
 	}
 
 	// Get current user data
-	currentUser, err := h.repo.GetUserByID(userID)
+	currentUser, err := h.repo.GetUserByID(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "User not found"})
 		return
@@ -761,14 +1054,20 @@ func (h *Handlers) UpdateUserProfile(c *gin.Context) {
 		email = *req.Email
 	}
 	if req.Phone != nil {
-		phone = *req.Phone
+		phone = storedPhone(*req.Phone)
 	}
 
 	// Update user profile
-	if err := h.repo.UpdateUserProfile(userID, name, email, phone); err != nil {
+	if err := h.repo.UpdateUserProfile(c.Request.Context(), userID, name, email, phone); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if req.TimeZone != nil {
+		if err := h.repo.UpdateUserTimeZone(c.Request.Context(), userID, *req.TimeZone); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Profile updated successfully"})
 }
@@ -794,16 +1093,16 @@ func (h *Handlers) UploadProfilePhoto(c *gin.Context) {
 		return
 	}
 
-	// Validate file size (max 5MB)
-	if file.Size > 5*1024*1024 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "File too large (max 5MB)"})
+	// Validate file size
+	if file.Size > h.uploads.MaxBytes {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("File too large (max %dMB)", h.uploads.MaxBytes>>20)})
 		return
 	}
 
 	// Generate unique filename
 	ext := filepath.Ext(file.Filename)
 	filename := fmt.Sprintf("user_%d_%d%s", userID, time.Now().Unix(), ext)
-	filepath := filepath.Join("static", "uploads", filename)
+	filepath := filepath.Join(h.uploads.Dir, filename)
 
 	// Save file
 	if err := c.SaveUploadedFile(file, filepath); err != nil {
@@ -812,8 +1111,8 @@ func (h *Handlers) UploadProfilePhoto(c *gin.Context) {
 	}
 
 	// Update user photo URL in database
-	photoURL := "/static/uploads/" + filename
-	if err := h.repo.UpdateUserPhoto(userID, photoURL); err != nil {
+	photoURL := h.uploads.URLPath + "/" + filename
+	if err := h.repo.UpdateUserPhoto(c.Request.Context(), userID, photoURL); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update photo URL"})
 		return
 	}
@@ -833,7 +1132,7 @@ func (h *Handlers) UploadMasterPhoto(c *gin.Context) {
 	}
 
 	// Get master profile
-	master, err := h.repo.GetMasterByUserID(userID)
+	master, err := h.repo.GetMasterByUserID(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Master profile not found"})
 		return
@@ -852,16 +1151,16 @@ func (h *Handlers) UploadMasterPhoto(c *gin.Context) {
 		return
 	}
 
-	// Validate file size (max 5MB)
-	if file.Size > 5*1024*1024 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "File too large (max 5MB)"})
+	// Validate file size
+	if file.Size > h.uploads.MaxBytes {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("File too large (max %dMB)", h.uploads.MaxBytes>>20)})
 		return
 	}
 
 	// Generate unique filename
 	ext := filepath.Ext(file.Filename)
 	filename := fmt.Sprintf("master_%d_%d%s", master.ID, time.Now().Unix(), ext)
-	filepath := filepath.Join("static", "uploads", filename)
+	filepath := filepath.Join(h.uploads.Dir, filename)
 
 	// Save file
 	if err := c.SaveUploadedFile(file, filepath); err != nil {
@@ -870,8 +1169,8 @@ func (h *Handlers) UploadMasterPhoto(c *gin.Context) {
 	}
 
 	// Update master photo URL in database
-	photoURL := "/static/uploads/" + filename
-	if err := h.repo.UpdateMasterPhoto(master.ID, photoURL); err != nil {
+	photoURL := h.uploads.URLPath + "/" + filename
+	if err := h.repo.UpdateMasterPhoto(c.Request.Context(), master.ID, photoURL); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update photo URL"})
 		return
 	}
@@ -907,16 +1206,16 @@ func (h *Handlers) UploadWorkPhoto(c *gin.Context) {
 		return
 	}
 
-	// Validate file size (max 5MB)
-	if file.Size > 5*1024*1024 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "File too large (max 5MB)"})
+	// Validate file size
+	if file.Size > h.uploads.MaxBytes {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("File too large (max %dMB)", h.uploads.MaxBytes>>20)})
 		return
 	}
 
 	// Generate unique filename
 	ext := filepath.Ext(file.Filename)
 	filename := fmt.Sprintf("work_%d%s", time.Now().Unix(), ext)
-	filepath := filepath.Join("static", "uploads", filename)
+	filepath := filepath.Join(h.uploads.Dir, filename)
 
 	// Save file
 	if err := c.SaveUploadedFile(file, filepath); err != nil {
@@ -924,7 +1223,7 @@ func (h *Handlers) UploadWorkPhoto(c *gin.Context) {
 		return
 	}
 
-	photoURL := "/static/uploads/" + filename
+	photoURL := h.uploads.URLPath + "/" + filename
 
 	log.Printf("Work photo uploaded successfully: %s", photoURL)
 
@@ -944,7 +1243,7 @@ func (h *Handlers) GetMasterProfile(c *gin.Context) {
 		return
 	}
 
-	master, err := h.repo.GetMasterByUserID(userID)
+	master, err := h.repo.GetMasterByUserID(c.Request.Context(), userID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Master profile not found"})
@@ -965,6 +1264,7 @@ func (h *Handlers) CreateMasterProfile(c *gin.Context) {
 		Phone          string `json:"phone" binding:"required"`
 		Specialization string `json:"specialization"`
 		Address        string `json:"address"`
+		TimeZone       string `json:"time_zone"`
 	}
 
 	var req Request
@@ -972,6 +1272,10 @@ func (h *Handlers) CreateMasterProfile(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if req.TimeZone != "" && !validTimeZone(req.TimeZone) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid time_zone. Use an IANA name like Asia/Almaty"})
+		return
+	}
 
 	userID, err := h.getUserIDFromContext(c)
 	if err != nil {
@@ -982,11 +1286,18 @@ func (h *Handlers) CreateMasterProfile(c *gin.Context) {
 	// Generate unique email for master profile to avoid conflicts
 	masterEmail := fmt.Sprintf("master_%d_%s", userID, req.Email)
 
-	master, err := h.repo.CreateMaster(userID, req.Name, masterEmail, req.Phone, req.Specialization, req.Address)
+	master, err := h.repo.CreateMaster(c.Request.Context(), userID, req.Name, masterEmail, req.Phone, req.Specialization, req.Address)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if req.TimeZone != "" {
+		if err := h.repo.UpdateMasterTimeZone(c.Request.Context(), master.ID, req.TimeZone); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		master.TimeZone = req.TimeZone
+	}
 
 	c.JSON(http.StatusCreated, master)
 }
@@ -999,7 +1310,7 @@ func (h *Handlers) DeleteMasterProfile(c *gin.Context) {
 		return
 	}
 
-	if err := h.repo.DeleteMasterProfile(userID); err != nil {
+	if err := h.repo.DeleteMasterProfile(c.Request.Context(), userID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -1015,6 +1326,7 @@ func (h *Handlers) UpdateMasterProfile(c *gin.Context) {
 		Phone          *string `json:"phone"`
 		Specialization *string `json:"specialization"`
 		Address        *string `json:"address"`
+		TimeZone       *string `json:"time_zone"`
 	}
 
 	var req Request
@@ -1023,6 +1335,10 @@ func (h *Handlers) UpdateMasterProfile(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if req.TimeZone != nil && !validTimeZone(*req.TimeZone) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid time_zone. Use an IANA name like Asia/Almaty"})
+		return
+	}
 
 	userID, err := h.getUserIDFromContext(c)
 	if err != nil {
@@ -1032,7 +1348,7 @@ func (h *Handlers) UpdateMasterProfile(c *gin.Context) {
 	}
 
 	// Get current master data
-	currentMaster, err := h.repo.GetMasterByUserID(userID)
+	currentMaster, err := h.repo.GetMasterByUserID(c.Request.Context(), userID)
 	if err != nil {
 		log.Printf("Master not found for user %d: %v", userID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Master not found"})
@@ -1070,11 +1386,19 @@ func (h *Handlers) UpdateMasterProfile(c *gin.Context) {
 	}
 
 	// Update master profile
-	if err := h.repo.UpdateMaster(currentMaster.ID, name, email, phone, specialization, address); err != nil {
+	if err := h.repo.UpdateMaster(c.Request.Context(), currentMaster.ID, name, email, phone, specialization, address); err != nil {
 		log.Printf("Error updating master profile: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if req.TimeZone != nil {
+		if err := h.repo.UpdateMasterTimeZone(c.Request.Context(), currentMaster.ID, *req.TimeZone); err != nil {
+			log.Printf("Error updating master time zone: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Updating time zone to: %s", *req.TimeZone)
+	}
 
 	log.Printf("Master profile updated successfully")
 	c.JSON(http.StatusOK, gin.H{"message": "Master profile updated successfully"})
@@ -1090,13 +1414,13 @@ func (h *Handlers) GetMasterWorks(c *gin.Context) {
 		return
 	}
 
-	master, err := h.repo.GetMasterByUserID(userID)
+	master, err := h.repo.GetMasterByUserID(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Master not found"})
 		return
 	}
 
-	works, err := h.repo.GetMasterWorks(master.ID)
+	works, err := h.repo.GetMasterWorks(c.Request.Context(), master.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -1113,7 +1437,7 @@ func (h *Handlers) GetMasterWorksPublic(c *gin.Context) {
 		return
 	}
 
-	works, err := h.repo.GetMasterWorks(masterID)
+	works, err := h.repo.GetMasterWorks(c.Request.Context(), masterID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -1144,7 +1468,7 @@ func (h *Handlers) CreateMasterWork(c *gin.Context) {
 		return
 	}
 
-	master, err := h.repo.GetMasterByUserID(userID)
+	master, err := h.repo.GetMasterByUserID(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Master not found"})
 		return
@@ -1156,7 +1480,7 @@ func (h *Handlers) CreateMasterWork(c *gin.Context) {
 		return
 	}
 
-	work, err := h.repo.CreateMasterWork(master.ID, req.Title, workDate, req.CustomerName, req.Amount, req.PhotoURLs)
+	work, err := h.repo.CreateMasterWork(c.Request.Context(), master.ID, req.Title, workDate, req.CustomerName, req.Amount, req.PhotoURLs)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -1181,7 +1505,7 @@ func (h *Handlers) GetMasterWork(c *gin.Context) {
 		return
 	}
 
-	master, err := h.repo.GetMasterByUserID(userID)
+	master, err := h.repo.GetMasterByUserID(c.Request.Context(), userID)
 	if err != nil {
 		log.Printf("Master not found for user %d", userID)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Master not found"})
@@ -1190,7 +1514,7 @@ func (h *Handlers) GetMasterWork(c *gin.Context) {
 
 	log.Printf("Getting work %d for master %d (user %d)", workID, master.ID, userID)
 
-	work, err := h.repo.GetMasterWork(workID, master.ID)
+	work, err := h.repo.GetMasterWork(c.Request.Context(), workID, master.ID)
 	if err != nil {
 		log.Printf("Error getting work %d: %v", workID, err)
 		if err == sql.ErrNoRows {
@@ -1233,7 +1557,7 @@ func (h *Handlers) UpdateMasterWork(c *gin.Context) {
 		return
 	}
 
-	master, err := h.repo.GetMasterByUserID(userID)
+	master, err := h.repo.GetMasterByUserID(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Master not found"})
 		return
@@ -1245,7 +1569,7 @@ func (h *Handlers) UpdateMasterWork(c *gin.Context) {
 		return
 	}
 
-	if err := h.repo.UpdateMasterWork(workID, master.ID, req.Title, workDate, req.CustomerName, req.Amount, req.PhotoURLs); err != nil {
+	if err := h.repo.UpdateMasterWork(c.Request.Context(), workID, master.ID, req.Title, workDate, req.CustomerName, req.Amount, req.PhotoURLs); err != nil {
 		if err == sql.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Work not found or does not belong to this master"})
 			return
@@ -1272,13 +1596,13 @@ func (h *Handlers) DeleteMasterWork(c *gin.Context) {
 	}
 
 	// Verify that the work belongs to the current master
-	master, err := h.repo.GetMasterByUserID(userID)
+	master, err := h.repo.GetMasterByUserID(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Master not found"})
 		return
 	}
 
-	if err := h.repo.DeleteMasterWork(workID, master.ID); err != nil {
+	if err := h.repo.DeleteMasterWork(c.Request.Context(), workID, master.ID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -1296,13 +1620,13 @@ func (h *Handlers) GetMasterPaymentInfo(c *gin.Context) {
 		return
 	}
 
-	master, err := h.repo.GetMasterByUserID(userID)
+	master, err := h.repo.GetMasterByUserID(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Master not found"})
 		return
 	}
 
-	info, err := h.repo.GetMasterPaymentInfo(master.ID)
+	info, err := h.repo.GetMasterPaymentInfo(c.Request.Context(), master.ID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Payment info not found"})
@@ -1335,13 +1659,13 @@ func (h *Handlers) UpdateMasterPaymentInfo(c *gin.Context) {
 		return
 	}
 
-	master, err := h.repo.GetMasterByUserID(userID)
+	master, err := h.repo.GetMasterByUserID(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Master not found"})
 		return
 	}
 
-	if err := h.repo.UpdateMasterPaymentInfo(master.ID, req.KaspiCard, req.FreedomCard, req.HalykCard); err != nil {
+	if err := h.repo.UpdateMasterPaymentInfo(c.Request.Context(), master.ID, req.KaspiCard, req.FreedomCard, req.HalykCard); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -1372,7 +1696,7 @@ func (h *Handlers) GetMasterReviews(c *gin.Context) {
 			return
 		}
 
-		master, err := h.repo.GetMasterByUserID(userID)
+		master, err := h.repo.GetMasterByUserID(c.Request.Context(), userID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Master not found"})
 			return
@@ -1380,7 +1704,7 @@ func (h *Handlers) GetMasterReviews(c *gin.Context) {
 		masterID = master.ID
 	}
 
-	reviews, err := h.repo.GetMasterReviews(masterID)
+	reviews, err := h.repo.GetMasterReviews(c.Request.Context(), masterID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -1409,7 +1733,7 @@ func (h *Handlers) CreateReview(c *gin.Context) {
 		return
 	}
 
-	review, err := h.repo.CreateReview(req.MasterID, userID, req.Rating, req.Comment)
+	review, err := h.repo.CreateReview(c.Request.Context(), req.MasterID, userID, req.Rating, req.Comment)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -1433,14 +1757,14 @@ func (h *Handlers) DeleteReview(c *gin.Context) {
 	}
 
 	// Get master by user ID
-	master, err := h.repo.GetMasterByUserID(userID)
+	master, err := h.repo.GetMasterByUserID(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Master profile not found"})
 		return
 	}
 
 	// Delete review
-	err = h.repo.DeleteReview(reviewID, master.ID)
+	err = h.repo.DeleteReview(c.Request.Context(), reviewID, master.ID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Review not found or access denied"})
@@ -1476,7 +1800,7 @@ func (h *Handlers) GetMasterCertificates(c *gin.Context) {
 			return
 		}
 
-		master, err := h.repo.GetMasterByUserID(userID)
+		master, err := h.repo.GetMasterByUserID(c.Request.Context(), userID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Master not found"})
 			return
@@ -1484,7 +1808,7 @@ func (h *Handlers) GetMasterCertificates(c *gin.Context) {
 		masterID = master.ID
 	}
 
-	certificates, err := h.repo.GetMasterCertificates(masterID)
+	certificates, err := h.repo.GetMasterCertificates(c.Request.Context(), masterID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -1507,7 +1831,7 @@ func (h *Handlers) CreateMasterCertificate(c *gin.Context) {
 		return
 	}
 
-	master, err := h.repo.GetMasterByUserID(userID)
+	master, err := h.repo.GetMasterByUserID(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Master profile not found"})
 		return
@@ -1526,16 +1850,16 @@ func (h *Handlers) CreateMasterCertificate(c *gin.Context) {
 		return
 	}
 
-	// Validate file size (max 5MB)
-	if file.Size > 5*1024*1024 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "File too large (max 5MB)"})
+	// Validate file size
+	if file.Size > h.uploads.MaxBytes {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("File too large (max %dMB)", h.uploads.MaxBytes>>20)})
 		return
 	}
 
 	// Generate unique filename
 	ext := filepath.Ext(file.Filename)
 	filename := fmt.Sprintf("certificate_%d_%d%s", master.ID, time.Now().Unix(), ext)
-	filepath := filepath.Join("static", "uploads", filename)
+	filepath := filepath.Join(h.uploads.Dir, filename)
 
 	// Save file
 	if err := c.SaveUploadedFile(file, filepath); err != nil {
@@ -1544,8 +1868,8 @@ func (h *Handlers) CreateMasterCertificate(c *gin.Context) {
 	}
 
 	// Save certificate to database
-	photoURL := "/static/uploads/" + filename
-	certificate, err := h.repo.CreateMasterCertificate(master.ID, name, photoURL)
+	photoURL := h.uploads.URLPath + "/" + filename
+	certificate, err := h.repo.CreateMasterCertificate(c.Request.Context(), master.ID, name, photoURL)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -1568,13 +1892,13 @@ func (h *Handlers) DeleteMasterCertificate(c *gin.Context) {
 		return
 	}
 
-	master, err := h.repo.GetMasterByUserID(userID)
+	master, err := h.repo.GetMasterByUserID(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Master profile not found"})
 		return
 	}
 
-	if err := h.repo.DeleteMasterCertificate(certificateID, master.ID); err != nil {
+	if err := h.repo.DeleteMasterCertificate(c.Request.Context(), certificateID, master.ID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -1592,7 +1916,7 @@ func (h *Handlers) GetMasterVerificationStatus(c *gin.Context) {
 		return
 	}
 
-	isVerified, reviewCount, workCount, err := h.repo.CheckMasterVerificationStatus(masterID)
+	isVerified, reviewCount, workCount, err := h.repo.CheckMasterVerificationStatus(c.Request.Context(), masterID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -1616,7 +1940,7 @@ func (h *Handlers) GetUserSubscription(c *gin.Context) {
 		return
 	}
 
-	subscription, err := h.repo.GetUserSubscription(userID)
+	subscription, err := h.repo.GetUserSubscription(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -1657,7 +1981,7 @@ func (h *Handlers) UpdateUserSubscription(c *gin.Context) {
 	}
 
 	// Check current subscription to allow upgrade from trial to premium
-	currentSub, err := h.repo.GetUserSubscription(userID)
+	currentSub, err := h.repo.GetUserSubscription(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить информацию о подписке: " + err.Error()})
 		return
@@ -1670,7 +1994,7 @@ func (h *Handlers) UpdateUserSubscription(c *gin.Context) {
 		// Downgrade from trial to basic is also fine
 	}
 
-	if err := h.repo.UpdateUserSubscription(userID, req.Plan); err != nil {
+	if err := h.repo.UpdateUserSubscription(c.Request.Context(), userID, req.Plan); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось обновить подписку: " + err.Error()})
 		return
 	}
@@ -1686,7 +2010,7 @@ func (h *Handlers) StartTrial(c *gin.Context) {
 		return
 	}
 
-	subscription, err := h.repo.StartTrial(userID)
+	subscription, err := h.repo.StartTrial(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -1730,7 +2054,7 @@ func (h *Handlers) AddFavoriteMaster(c *gin.Context) {
 		return
 	}
 
-	if err := h.repo.AddFavoriteMaster(userID, req.MasterID); err != nil {
+	if err := h.repo.AddFavoriteMaster(c.Request.Context(), userID, req.MasterID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add favorite: " + err.Error()})
 		return
 	}
@@ -1767,7 +2091,7 @@ func (h *Handlers) RemoveFavoriteMaster(c *gin.Context) {
 		return
 	}
 
-	if err := h.repo.RemoveFavoriteMaster(userID, masterID); err != nil {
+	if err := h.repo.RemoveFavoriteMaster(c.Request.Context(), userID, masterID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove favorite: " + err.Error()})
 		return
 	}
@@ -1783,7 +2107,7 @@ func (h *Handlers) GetFavoriteMasters(c *gin.Context) {
 		return
 	}
 
-	masters, err := h.repo.GetFavoriteMasters(userID)
+	masters, err := h.repo.GetFavoriteMasters(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -1802,7 +2126,7 @@ func (h *Handlers) GetUserCars(c *gin.Context) {
 		return
 	}
 
-	cars, err := h.repo.GetUserCars(userID)
+	cars, err := h.repo.GetUserCars(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -1814,7 +2138,8 @@ func (h *Handlers) GetUserCars(c *gin.Context) {
 // CreateUserCar creates a new car for current user
 func (h *Handlers) CreateUserCar(c *gin.Context) {
 	type Request struct {
-		Name    string `json:"name" binding:"required"`
+		CarID   *int   `json:"car_id"`
+		Name    string `json:"name"`
 		Year    int    `json:"year"`
 		Comment string `json:"comment"`
 	}
@@ -1831,7 +2156,12 @@ func (h *Handlers) CreateUserCar(c *gin.Context) {
 		return
 	}
 
-	car, err := h.repo.CreateUserCar(userID, req.Name, req.Year, req.Comment)
+	name, ok := h.userCarName(c, req.CarID, req.Name)
+	if !ok {
+		return
+	}
+
+	car, err := h.repo.CreateUserCar(c.Request.Context(), userID, req.CarID, name, req.Year, req.Comment)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -1849,7 +2179,8 @@ func (h *Handlers) UpdateUserCar(c *gin.Context) {
 	}
 
 	type Request struct {
-		Name    string `json:"name" binding:"required"`
+		CarID   *int   `json:"car_id"`
+		Name    string `json:"name"`
 		Year    int    `json:"year"`
 		Comment string `json:"comment"`
 	}
@@ -1866,7 +2197,12 @@ func (h *Handlers) UpdateUserCar(c *gin.Context) {
 		return
 	}
 
-	if err := h.repo.UpdateUserCar(carID, userID, req.Name, req.Year, req.Comment); err != nil {
+	name, ok := h.userCarName(c, req.CarID, req.Name)
+	if !ok {
+		return
+	}
+
+	if err := h.repo.UpdateUserCar(c.Request.Context(), carID, userID, req.CarID, name, req.Year, req.Comment); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -1874,6 +2210,33 @@ func (h *Handlers) UpdateUserCar(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Car updated successfully"})
 }
 
+// userCarName checks the catalog car a user car is linked to and returns the car's name,
+// which defaults to the catalog brand and model. Writes the error response and returns false
+// if the catalog car does not exist or there is neither a name nor a catalog car.
+func (h *Handlers) userCarName(c *gin.Context, catalogCarID *int, name string) (string, bool) {
+	name = strings.TrimSpace(name)
+	if catalogCarID == nil {
+		if name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "name or car_id is required"})
+			return "", false
+		}
+		return name, true
+	}
+	car, err := h.repo.GetCarByID(c.Request.Context(), *catalogCarID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Car not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return "", false
+	}
+	if name == "" {
+		name = car.Brand + " " + car.Model
+	}
+	return name, true
+}
+
 // DeleteUserCar deletes a car
 func (h *Handlers) DeleteUserCar(c *gin.Context) {
 	carID, err := strconv.Atoi(c.Param("id"))
@@ -1888,7 +2251,7 @@ func (h *Handlers) DeleteUserCar(c *gin.Context) {
 		return
 	}
 
-	if err := h.repo.DeleteUserCar(carID, userID); err != nil {
+	if err := h.repo.DeleteUserCar(c.Request.Context(), carID, userID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -1906,7 +2269,7 @@ func (h *Handlers) GetUserGuarantees(c *gin.Context) {
 		return
 	}
 
-	guarantees, err := h.repo.GetUserGuarantees(userID)
+	guarantees, err := h.repo.GetUserGuarantees(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -1925,7 +2288,7 @@ func (h *Handlers) GetUserNotifications(c *gin.Context) {
 		return
 	}
 
-	notifications, err := h.repo.GetUserNotifications(userID)
+	notifications, err := h.repo.GetUserNotifications(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -1942,13 +2305,13 @@ func (h *Handlers) GetMasterNotifications(c *gin.Context) {
 		return
 	}
 
-	master, err := h.repo.GetMasterByUserID(userID)
+	master, err := h.repo.GetMasterByUserID(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Master not found"})
 		return
 	}
 
-	appointments, err := h.repo.GetMasterAppointmentsForNotifications(master.ID)
+	appointments, err := h.repo.GetMasterAppointmentsForNotifications(c.Request.Context(), master.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -1971,10 +2334,47 @@ func (h *Handlers) MarkNotificationRead(c *gin.Context) {
 		return
 	}
 
-	if err := h.repo.MarkNotificationRead(notificationID, userID); err != nil {
+	if err := h.repo.MarkNotificationRead(c.Request.Context(), notificationID, userID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Notification marked as read"})
 }
+
+// Admin Handlers
+
+// UpdateUserRole changes the role of a user
+func (h *Handlers) UpdateUserRole(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	type Request struct {
+		Role string `json:"role" binding:"required"`
+	}
+
+	var req Request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Role != models.RoleCustomer && req.Role != models.RoleMaster && req.Role != models.RoleAdmin {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role. Must be 'customer', 'master' or 'admin'"})
+		return
+	}
+
+	if err := h.repo.UpdateUserRole(c.Request.Context(), userID, req.Role); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role updated successfully"})
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"beep-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetMasterServices lists the services the authenticated master offers, including unavailable ones
+func (h *Handlers) GetMasterServices(c *gin.Context) {
+	master, ok := h.currentMaster(c)
+	if !ok {
+		return
+	}
+
+	offers, err := h.repo.GetMasterServices(c.Request.Context(), master.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if offers == nil {
+		offers = []models.MasterService{}
+	}
+	c.JSON(http.StatusOK, offers)
+}
+
+// SaveMasterService adds a catalog service to the master's price list or changes the master's offer.
+// base_price and duration_minutes default to the current offer, or to the catalog for a new one;
+// the price must stay within the catalog's min_price..max_price.
+func (h *Handlers) SaveMasterService(c *gin.Context) {
+	master, ok := h.currentMaster(c)
+	if !ok {
+		return
+	}
+	serviceID, err := strconv.Atoi(c.Param("service_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid service ID"})
+		return
+	}
+
+	type Request struct {
+		BasePrice       *float64 `json:"base_price"`
+		DurationMinutes *int     `json:"duration_minutes"`
+		IsAvailable     *bool    `json:"is_available"`
+	}
+
+	var req Request
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	service, err := h.repo.GetServiceByID(c.Request.Context(), serviceID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Service not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	offer := &models.MasterService{
+		MasterID:        master.ID,
+		ServiceID:       service.ID,
+		BasePrice:       service.BasePrice,
+		DurationMinutes: service.DurationMinutes,
+		IsAvailable:     true,
+	}
+	existing, err := h.repo.GetMasterService(c.Request.Context(), master.ID, service.ID)
+	switch {
+	case err == nil:
+		offer.BasePrice, offer.DurationMinutes, offer.IsAvailable = existing.BasePrice, existing.DurationMinutes, existing.IsAvailable
+	case err != sql.ErrNoRows:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if req.BasePrice != nil {
+		offer.BasePrice = *req.BasePrice
+	}
+	if req.DurationMinutes != nil {
+		offer.DurationMinutes = *req.DurationMinutes
+	}
+	if req.IsAvailable != nil {
+		offer.IsAvailable = *req.IsAvailable
+	}
+	if offer.DurationMinutes <= 0 && req.DurationMinutes == nil {
+		offer.DurationMinutes = 60
+	}
+
+	if offer.BasePrice < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "base_price must not be negative"})
+		return
+	}
+	if (service.MinPrice > 0 && offer.BasePrice < service.MinPrice) || (service.MaxPrice > 0 && offer.BasePrice > service.MaxPrice) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":     "base_price must be within the service's price range",
+			"min_price": service.MinPrice,
+			"max_price": service.MaxPrice,
+		})
+		return
+	}
+	if offer.DurationMinutes <= 0 || offer.DurationMinutes > 24*60 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "duration_minutes must be between 1 and 1440"})
+		return
+	}
+
+	if err := h.repo.SaveMasterService(c.Request.Context(), offer); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, offer)
+}
+
+// DeleteMasterService removes a service from the master's price list. Existing appointments are kept.
+func (h *Handlers) DeleteMasterService(c *gin.Context) {
+	master, ok := h.currentMaster(c)
+	if !ok {
+		return
+	}
+	serviceID, err := strconv.Atoi(c.Param("service_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid service ID"})
+		return
+	}
+
+	if err := h.repo.DeleteMasterService(c.Request.Context(), master.ID, serviceID); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Service is not in your price list"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Service removed from price list"})
+}
+
+// GetMasterServicesPublic lists the services a master can be booked for, at the master's prices
+func (h *Handlers) GetMasterServicesPublic(c *gin.Context) {
+	masterID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid master ID"})
+		return
+	}
+
+	offers, err := h.repo.GetMasterServices(c.Request.Context(), masterID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	available := []models.MasterService{}
+	for _, offer := range offers {
+		if offer.IsAvailable {
+			available = append(available, offer)
+		}
+	}
+	c.JSON(http.StatusOK, available)
+}
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"beep-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	ctxUserIDKey    = "userID"
+	ctxUserRoleKey  = "userRole"
+	ctxSessionIDKey = "sessionID"
+	ctxAuthErrorKey = "authError"
+
+	ctxAppointmentKey      = "appointment"
+	ctxAppointmentActorKey = "appointmentActor"
+
+	mockTokenPrefix = "mock-jwt-token-"
+)
+
+// Authenticate validates the bearer token, if any, and stores the caller's user ID and role in the context.
+// Requests without a valid token pass through unauthenticated so public endpoints keep working;
+// protected groups add RequireAuth on top.
+func (h *Handlers) Authenticate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		if authHeader == "" {
+			c.Next()
+			return
+		}
+
+		token := strings.TrimSpace(strings.TrimPrefix(authHeader, "Bearer "))
+
+		if h.devAuth && isMockToken(token) {
+			if err := h.authenticateMockToken(c, token); err != nil {
+				c.Set(ctxAuthErrorKey, err)
+			}
+			c.Next()
+			return
+		}
+
+		claims, err := h.tokens.Parse(token)
+		if err != nil {
+			c.Set(ctxAuthErrorKey, err)
+			c.Next()
+			return
+		}
+
+		c.Set(ctxUserIDKey, claims.UserID)
+		c.Set(ctxUserRoleKey, claims.Role)
+		c.Set(ctxSessionIDKey, claims.SessionID)
+		c.Next()
+	}
+}
+
+// RequireAuth rejects requests that Authenticate could not attach a user to
+func (h *Handlers) RequireAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, err := h.getUserIDFromContext(c); err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: " + err.Error()})
+			return
+		}
+		c.Next()
+	}
+}
+
+// isMockToken reports whether the token uses the legacy development format ("mock-jwt-token-<email>" or a bare email)
+func isMockToken(token string) bool {
+	return strings.HasPrefix(token, mockTokenPrefix) || (strings.Contains(token, "@") && strings.Count(token, ".") < 2)
+}
+
+// authenticateMockToken resolves a legacy development token to a user. Only reachable when dev auth is enabled.
+func (h *Handlers) authenticateMockToken(c *gin.Context, token string) error {
+	email := strings.TrimPrefix(token, mockTokenPrefix)
+	if email == "" {
+		return fmt.Errorf("email not found in token: %s", token)
+	}
+
+	user, err := h.repo.GetUserByEmail(c.Request.Context(), email)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("user not found with email: %s. Please register first", email)
+		}
+		return fmt.Errorf("database error: %v", err)
+	}
+
+	c.Set(ctxUserIDKey, user.ID)
+	c.Set(ctxUserRoleKey, h.userRole(c.Request.Context(), user.ID))
+	return nil
+}
+
+// CurrentUserID returns the ID of the user authenticated for the request, if any.
+// Intended for middleware outside this package, such as rate limiting.
+func CurrentUserID(c *gin.Context) (int, bool) {
+	userID := c.GetInt(ctxUserIDKey)
+	return userID, userID > 0
+}
+
+// RequireRole allows only callers with one of the given roles; admins are always allowed.
+// The stored role is checked rather than the token's claim, so promotions apply without
+// logging in again and demotions take effect before the token expires.
+func (h *Handlers) RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := h.getUserIDFromContext(c)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: " + err.Error()})
+			return
+		}
+
+		role := h.userRole(c.Request.Context(), userID)
+		if !roleAllowed(role, roles) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+			return
+		}
+		c.Set(ctxUserRoleKey, role)
+
+		c.Next()
+	}
+}
+
+func roleAllowed(role string, roles []string) bool {
+	if role == models.RoleAdmin {
+		return true
+	}
+	for _, allowed := range roles {
+		if role == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireAppointmentAccess loads the appointment from the :id route parameter and allows the request only if
+// the caller takes part in it as one of the given actors (models.RoleCustomer for the customer who booked it,
+// models.RoleMaster for the assigned master, models.RoleAdmin for administrators).
+// The appointment and the caller's actor role are stored in the context for the handler.
+func (h *Handlers) RequireAppointmentAccess(actors ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+			return
+		}
+
+		userID, err := h.getUserIDFromContext(c)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: " + err.Error()})
+			return
+		}
+
+		appointment, err := h.repo.GetAppointmentByID(c.Request.Context(), id)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Appointment not found"})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		actor := h.appointmentActor(c, userID, appointment)
+		if actor == "" || !containsString(actors, actor) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "You don't have permission to access this appointment"})
+			return
+		}
+
+		c.Set(ctxAppointmentKey, appointment)
+		c.Set(ctxAppointmentActorKey, actor)
+		c.Next()
+	}
+}
+
+// appointmentActor returns how the user takes part in the appointment, or "" if they don't.
+// Admin rights come from the stored role, not the token's claim.
+func (h *Handlers) appointmentActor(c *gin.Context, userID int, appointment *models.Appointment) string {
+	if h.userRole(c.Request.Context(), userID) == models.RoleAdmin {
+		return models.RoleAdmin
+	}
+	if master, err := h.repo.GetMasterByUserID(c.Request.Context(), userID); err == nil && master.ID == appointment.MasterID {
+		return models.RoleMaster
+	}
+	if appointment.UserID == userID {
+		return models.RoleCustomer
+	}
+	return ""
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
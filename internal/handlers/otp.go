@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"beep-backend/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OTPSettings controls phone login with one-time SMS codes
+type OTPSettings struct {
+	TTL            time.Duration
+	ResendInterval time.Duration
+	MaxPerHour     int
+	MaxAttempts    int
+	Length         int
+}
+
+// defaultOTPUserName is given to users created by their first phone login when no name is supplied
+const defaultOTPUserName = "Клиент"
+
+// normalizePhone converts a Kazakhstan phone number in any common notation to +7XXXXXXXXXX.
+// Returns false if the input is not a valid number.
+func normalizePhone(phone string) (string, bool) {
+	var digits strings.Builder
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			digits.WriteRune(r)
+		}
+	}
+
+	number := digits.String()
+	switch {
+	case len(number) == 11 && number[0] == '8':
+		number = "7" + number[1:]
+	case len(number) == 10:
+		number = "7" + number
+	}
+
+	if len(number) != 11 || number[0] != '7' {
+		return "", false
+	}
+	return "+" + number, true
+}
+
+// storedPhone is the phone as users are stored with it: normalized when it is a valid number,
+// otherwise as given, so phone login finds the user whatever notation they signed up with
+func storedPhone(phone string) string {
+	if normalized, ok := normalizePhone(phone); ok {
+		return normalized
+	}
+	return strings.TrimSpace(phone)
+}
+
+// hashOTPCode binds the code to the phone so a leaked hash cannot be replayed for another number
+func hashOTPCode(phone, code string) string {
+	return auth.HashToken(phone + ":" + code)
+}
+
+// OTPRequest sends a one-time login code to a phone number
+func (h *Handlers) OTPRequest(c *gin.Context) {
+	type Request struct {
+		Phone string `json:"phone" binding:"required"`
+	}
+
+	var req Request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	phone, ok := normalizePhone(req.Phone)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid phone number"})
+		return
+	}
+
+	now := time.Now()
+	sentLastHour, lastSentAt, err := h.repo.CountRecentOTPCodes(c.Request.Context(), phone, now.Add(-time.Hour))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if lastSentAt != nil {
+		if wait := lastSentAt.Add(h.otp.ResendInterval).Sub(now); wait > 0 {
+			otpTooManyRequests(c, "Code was sent recently, please wait before requesting a new one", wait)
+			return
+		}
+	}
+	if sentLastHour >= h.otp.MaxPerHour {
+		otpTooManyRequests(c, "Too many codes requested for this phone number", time.Hour)
+		return
+	}
+
+	code, err := auth.NewNumericCode(h.otp.Length)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate code"})
+		return
+	}
+
+	expiresAt := now.Add(h.otp.TTL)
+	if err := h.repo.CreateOTPCode(c.Request.Context(), phone, hashOTPCode(phone, code), c.ClientIP(), expiresAt); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	text := fmt.Sprintf("BEEP: код для входа %s. Никому его не сообщайте.", code)
+	if err := h.sms.Send(c.Request.Context(), phone, text); err != nil {
+		log.Printf("Warning: Failed to send login code to %s: %v", phone, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to send SMS"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Code sent",
+		"phone":        phone,
+		"expires_at":   expiresAt,
+		"resend_after": int(h.otp.ResendInterval.Seconds()),
+	})
+}
+
+// OTPVerify checks a one-time code and signs in the user who verified the phone, creating an account
+// if nobody has. Verifying while signed in confirms the phone on the caller's profile.
+func (h *Handlers) OTPVerify(c *gin.Context) {
+	type Request struct {
+		Phone string `json:"phone" binding:"required"`
+		Code  string `json:"code" binding:"required"`
+		Name  string `json:"name"`
+	}
+
+	var req Request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	phone, ok := normalizePhone(req.Phone)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid phone number"})
+		return
+	}
+
+	otp, err := h.repo.GetActiveOTPCode(c.Request.Context(), phone)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired code"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Count the attempt before comparing so concurrent guesses cannot exceed the limit
+	attempts, err := h.repo.RegisterOTPAttempt(c.Request.Context(), otp.ID, h.otp.MaxAttempts)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many attempts, request a new code"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if subtle.ConstantTimeCompare([]byte(otp.CodeHash), []byte(hashOTPCode(phone, strings.TrimSpace(req.Code)))) != 1 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":              "Invalid or expired code",
+			"attempts_remaining": h.otp.MaxAttempts - attempts,
+		})
+		return
+	}
+
+	if err := h.repo.ConsumeOTPCode(c.Request.Context(), otp.ID); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired code"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// A signed-in caller proves the phone on their profile is theirs; phone login then finds their account
+	if userID, err := h.getUserIDFromContext(c); err == nil {
+		current, err := h.repo.GetUserByID(c.Request.Context(), userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if current.Phone == phone {
+			if err := h.repo.MarkPhoneVerified(c.Request.Context(), current.ID, phone); err != nil && err != sql.ErrNoRows {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+	}
+
+	// Only an account that verified the phone is signed in; a number typed into a profile proves nothing
+	user, err := h.repo.GetUserByVerifiedPhone(c.Request.Context(), phone)
+	if err == nil {
+		h.issueAuthResponse(c, http.StatusOK, user)
+		return
+	}
+	if err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = defaultOTPUserName
+	}
+
+	user, err = h.repo.CreatePhoneUser(c.Request.Context(), name, phone)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		return
+	}
+
+	h.issueAuthResponse(c, http.StatusCreated, user)
+}
+
+func otpTooManyRequests(c *gin.Context, message string, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	c.Header("Retry-After", fmt.Sprint(seconds))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": message, "retry_after": seconds})
+}
@@ -0,0 +1,346 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"beep-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// pricingRuleRequest is the body of pricing rule create and update requests.
+// Omitted service_id, car_type and age bounds match any service, car type and age;
+// multiplier defaults to 1.
+type pricingRuleRequest struct {
+	ServiceID     *int     `json:"service_id"`
+	CarType       string   `json:"car_type"`
+	CarAgeMin     *int     `json:"car_age_min"`
+	CarAgeMax     *int     `json:"car_age_max"`
+	Multiplier    *float64 `json:"multiplier"`
+	FixedAddition float64  `json:"fixed_addition"`
+	Description   string   `json:"description"`
+	Position      int      `json:"position"`
+}
+
+// rule validates the request and converts it to a pricing rule
+func (r pricingRuleRequest) rule() (*models.PricingRule, error) {
+	rule := &models.PricingRule{
+		ServiceID:     r.ServiceID,
+		CarType:       strings.TrimSpace(r.CarType),
+		CarAgeMin:     r.CarAgeMin,
+		CarAgeMax:     r.CarAgeMax,
+		Multiplier:    1,
+		FixedAddition: r.FixedAddition,
+		Description:   strings.TrimSpace(r.Description),
+		Position:      r.Position,
+	}
+	if r.Multiplier != nil {
+		rule.Multiplier = *r.Multiplier
+	}
+	if rule.Multiplier <= 0 {
+		return nil, errors.New("multiplier must be greater than 0")
+	}
+	if (rule.CarAgeMin != nil && *rule.CarAgeMin < 0) || (rule.CarAgeMax != nil && *rule.CarAgeMax < 0) {
+		return nil, errors.New("car_age_min and car_age_max must not be negative")
+	}
+	if rule.CarAgeMin != nil && rule.CarAgeMax != nil && *rule.CarAgeMin > *rule.CarAgeMax {
+		return nil, errors.New("car_age_min must not be greater than car_age_max")
+	}
+	return rule, nil
+}
+
+// bindPricingRule reads and validates a pricing rule from the request body, or writes the error response
+func (h *Handlers) bindPricingRule(c *gin.Context) (*models.PricingRule, bool) {
+	var req pricingRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	rule, err := req.rule()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	if rule.ServiceID != nil {
+		if _, err := h.repo.GetServiceByID(c.Request.Context(), *rule.ServiceID); err != nil {
+			if err == sql.ErrNoRows {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Service not found"})
+				return nil, false
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return nil, false
+		}
+	}
+	return rule, true
+}
+
+// GetPricingRules lists the pricing rules in the order they apply.
+// ?service_id= limits the list to the rules that can apply to that service.
+func (h *Handlers) GetPricingRules(c *gin.Context) {
+	serviceID := 0
+	if value := c.Query("service_id"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid service ID"})
+			return
+		}
+		serviceID = parsed
+	}
+
+	rules, err := h.repo.GetPricingRules(c.Request.Context(), serviceID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if rules == nil {
+		rules = []models.PricingRule{}
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+func (h *Handlers) CreatePricingRule(c *gin.Context) {
+	rule, ok := h.bindPricingRule(c)
+	if !ok {
+		return
+	}
+
+	if err := h.repo.CreatePricingRule(c.Request.Context(), rule); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, rule)
+}
+
+func (h *Handlers) UpdatePricingRule(c *gin.Context) {
+	ruleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule ID"})
+		return
+	}
+	rule, ok := h.bindPricingRule(c)
+	if !ok {
+		return
+	}
+	rule.ID = ruleID
+
+	if err := h.repo.UpdatePricingRule(c.Request.Context(), rule); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Pricing rule not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, rule)
+}
+
+func (h *Handlers) DeletePricingRule(c *gin.Context) {
+	ruleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule ID"})
+		return
+	}
+
+	if err := h.repo.DeletePricingRule(c.Request.Context(), ruleID); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Pricing rule not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Pricing rule deleted successfully"})
+}
+
+// priceZoneRequest is the body of price zone create and update requests
+type priceZoneRequest struct {
+	Name       string  `json:"name" binding:"required"`
+	Multiplier float64 `json:"multiplier" binding:"required"`
+}
+
+// bindPriceZone reads and validates a price zone from the request body, or writes the error response
+func bindPriceZone(c *gin.Context) (*models.PriceZone, bool) {
+	var req priceZoneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name must not be empty"})
+		return nil, false
+	}
+	if req.Multiplier <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "multiplier must be greater than 0"})
+		return nil, false
+	}
+	return &models.PriceZone{Name: name, Multiplier: req.Multiplier}, true
+}
+
+// GetPriceZones lists the price zones a price can be calculated for
+func (h *Handlers) GetPriceZones(c *gin.Context) {
+	zones, err := h.repo.GetPriceZones(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if zones == nil {
+		zones = []models.PriceZone{}
+	}
+	c.JSON(http.StatusOK, zones)
+}
+
+func (h *Handlers) CreatePriceZone(c *gin.Context) {
+	zone, ok := bindPriceZone(c)
+	if !ok {
+		return
+	}
+
+	if err := h.repo.CreatePriceZone(c.Request.Context(), zone); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, zone)
+}
+
+func (h *Handlers) UpdatePriceZone(c *gin.Context) {
+	zoneID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid zone ID"})
+		return
+	}
+	zone, ok := bindPriceZone(c)
+	if !ok {
+		return
+	}
+	zone.ID = zoneID
+
+	if err := h.repo.UpdatePriceZone(c.Request.Context(), zone); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Price zone not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, zone)
+}
+
+func (h *Handlers) DeletePriceZone(c *gin.Context) {
+	zoneID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid zone ID"})
+		return
+	}
+
+	if err := h.repo.DeletePriceZone(c.Request.Context(), zoneID); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Price zone not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Price zone deleted successfully"})
+}
+
+// bundleDiscountRequest is the body of bundle discount create and update requests
+type bundleDiscountRequest struct {
+	MinServices int     `json:"min_services" binding:"required"`
+	Percent     float64 `json:"percent" binding:"required"`
+	Description string  `json:"description"`
+}
+
+// bindBundleDiscount reads and validates a bundle discount from the request body, or writes the error response
+func bindBundleDiscount(c *gin.Context) (*models.BundleDiscount, bool) {
+	var req bundleDiscountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if req.MinServices < 2 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "min_services must be at least 2"})
+		return nil, false
+	}
+	if req.Percent <= 0 || req.Percent >= 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "percent must be between 0 and 100"})
+		return nil, false
+	}
+	return &models.BundleDiscount{
+		MinServices: req.MinServices,
+		Percent:     req.Percent,
+		Description: strings.TrimSpace(req.Description),
+	}, true
+}
+
+// GetBundleDiscounts lists the discounts for booking several services at once
+func (h *Handlers) GetBundleDiscounts(c *gin.Context) {
+	discounts, err := h.repo.GetBundleDiscounts(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if discounts == nil {
+		discounts = []models.BundleDiscount{}
+	}
+	c.JSON(http.StatusOK, discounts)
+}
+
+func (h *Handlers) CreateBundleDiscount(c *gin.Context) {
+	discount, ok := bindBundleDiscount(c)
+	if !ok {
+		return
+	}
+
+	if err := h.repo.CreateBundleDiscount(c.Request.Context(), discount); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, discount)
+}
+
+func (h *Handlers) UpdateBundleDiscount(c *gin.Context) {
+	discountID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid discount ID"})
+		return
+	}
+	discount, ok := bindBundleDiscount(c)
+	if !ok {
+		return
+	}
+	discount.ID = discountID
+
+	if err := h.repo.UpdateBundleDiscount(c.Request.Context(), discount); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Bundle discount not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, discount)
+}
+
+func (h *Handlers) DeleteBundleDiscount(c *gin.Context) {
+	discountID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid discount ID"})
+		return
+	}
+
+	if err := h.repo.DeleteBundleDiscount(c.Request.Context(), discountID); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Bundle discount not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Bundle discount deleted successfully"})
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"beep-backend/internal/booking"
+	"beep-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestReschedule proposes a new date and time for an appointment. The time is held for the
+// appointment until the master accepts or declines; the appointment keeps its time meanwhile.
+func (h *Handlers) RequestReschedule(c *gin.Context) {
+	type Request struct {
+		Date    string `json:"date" binding:"required"`
+		Time    string `json:"time" binding:"required"`
+		Comment string `json:"comment"`
+	}
+
+	var req Request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	date, err := time.Parse("2006-01-02", req.Date)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD"})
+		return
+	}
+
+	userID, err := h.getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	appointment := c.MustGet(ctxAppointmentKey).(*models.Appointment)
+	actor := booking.Actor{UserID: userID, Role: c.GetString(ctxAppointmentActorKey)}
+	request, err := h.booking.RequestReschedule(c.Request.Context(), appointment.ID, actor, booking.RescheduleProposal{
+		Date:    date,
+		Time:    req.Time,
+		Comment: req.Comment,
+	})
+	if err != nil {
+		var slotErr *booking.SlotUnavailableError
+		switch {
+		case errors.As(err, &slotErr):
+			c.JSON(http.StatusConflict, gin.H{"error": slotErr.Reason, "suggested_slots": slotErr.Suggestions})
+		case errors.Is(err, booking.ErrNotReschedulable):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, booking.ErrInvalidTime), errors.Is(err, booking.ErrSameTime):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, request)
+}
+
+// GetAppointmentRescheduleRequests lists the reschedule requests of an appointment, newest first
+func (h *Handlers) GetAppointmentRescheduleRequests(c *gin.Context) {
+	appointment := c.MustGet(ctxAppointmentKey).(*models.Appointment)
+
+	requests, err := h.repo.GetAppointmentRescheduleRequests(c.Request.Context(), appointment.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if requests == nil {
+		requests = []models.RescheduleRequest{}
+	}
+	c.JSON(http.StatusOK, requests)
+}
+
+// GetMasterRescheduleRequests lists the reschedule requests addressed to the master.
+// ?status= filters them (pending by default, "all" for every status).
+func (h *Handlers) GetMasterRescheduleRequests(c *gin.Context) {
+	master, ok := h.currentMaster(c)
+	if !ok {
+		return
+	}
+
+	status := c.DefaultQuery("status", models.ReschedulePending)
+	switch status {
+	case "all":
+		status = ""
+	case models.ReschedulePending, models.RescheduleAccepted, models.RescheduleDeclined,
+		models.RescheduleCancelled, models.RescheduleExpired:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status. Must be pending, accepted, declined, cancelled, expired or all"})
+		return
+	}
+
+	requests, err := h.repo.GetMasterRescheduleRequests(c.Request.Context(), master.ID, status)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if requests == nil {
+		requests = []models.RescheduleRequest{}
+	}
+	c.JSON(http.StatusOK, requests)
+}
+
+// AcceptRescheduleRequest moves the appointment to the proposed time and releases its old time
+func (h *Handlers) AcceptRescheduleRequest(c *gin.Context) {
+	master, ok := h.currentMaster(c)
+	if !ok {
+		return
+	}
+	requestID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request ID"})
+		return
+	}
+
+	request, err := h.booking.AcceptReschedule(c.Request.Context(), master, requestID)
+	if err != nil {
+		rescheduleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, request)
+}
+
+// DeclineRescheduleRequest releases the proposed time; the appointment keeps its time.
+// An optional {"reason": "..."} body is sent to the customer.
+func (h *Handlers) DeclineRescheduleRequest(c *gin.Context) {
+	master, ok := h.currentMaster(c)
+	if !ok {
+		return
+	}
+	requestID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request ID"})
+		return
+	}
+
+	type Request struct {
+		Reason string `json:"reason"`
+	}
+
+	var req Request
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	request, err := h.booking.DeclineReschedule(c.Request.Context(), master, requestID, req.Reason)
+	if err != nil {
+		rescheduleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, request)
+}
+
+// rescheduleError writes the response for an error of accepting or declining a reschedule request
+func rescheduleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, booking.ErrRescheduleNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reschedule request not found"})
+	case errors.Is(err, booking.ErrRescheduleClosed), errors.Is(err, booking.ErrRescheduleExpired):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,294 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"beep-backend/internal/booking"
+	"beep-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// scheduleExceptionRequest is the body of exception create and update requests.
+// EndDate defaults to StartDate; times are required for custom hours and breaks only.
+type scheduleExceptionRequest struct {
+	Type      string `json:"type" binding:"required"`
+	StartDate string `json:"start_date" binding:"required"`
+	EndDate   string `json:"end_date"`
+	StartTime string `json:"start_time"`
+	EndTime   string `json:"end_time"`
+	Reason    string `json:"reason"`
+}
+
+// exception validates the request and converts it to a schedule exception of masterID
+func (r scheduleExceptionRequest) exception(masterID int) (*models.ScheduleException, error) {
+	exception := &models.ScheduleException{MasterID: masterID, Type: r.Type, Reason: r.Reason}
+
+	var err error
+	if exception.StartDate, err = time.Parse("2006-01-02", r.StartDate); err != nil {
+		return nil, errors.New("Invalid start_date format. Use YYYY-MM-DD")
+	}
+	exception.EndDate = exception.StartDate
+	if r.EndDate != "" {
+		if exception.EndDate, err = time.Parse("2006-01-02", r.EndDate); err != nil {
+			return nil, errors.New("Invalid end_date format. Use YYYY-MM-DD")
+		}
+	}
+	if exception.EndDate.Before(exception.StartDate) {
+		return nil, errors.New("end_date must not be before start_date")
+	}
+
+	switch r.Type {
+	case models.ExceptionDayOff, models.ExceptionVacation:
+		if r.StartTime != "" || r.EndTime != "" {
+			return nil, errors.New("Day offs and vacations cover whole days, omit start_time and end_time")
+		}
+	case models.ExceptionCustomHours, models.ExceptionBreak:
+		start, startErr := parseClock(r.StartTime)
+		end, endErr := parseClock(r.EndTime)
+		if startErr != nil || endErr != nil {
+			return nil, errors.New("start_time and end_time are required in HH:MM format")
+		}
+		if !start.Before(end) {
+			return nil, errors.New("start_time must be before end_time")
+		}
+		exception.StartTime, exception.EndTime = start.Format("15:04"), end.Format("15:04")
+	default:
+		return nil, errors.New("Invalid type. Must be day_off, vacation, custom_hours or break")
+	}
+	return exception, nil
+}
+
+// parseClock parses an HH:MM or HH:MM:SS time of day
+func parseClock(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if parsed, err := time.Parse("15:04:05", value); err == nil {
+		return parsed, nil
+	}
+	return time.Parse("15:04", value)
+}
+
+// validTimeZone reports whether name is a known IANA time zone. Empty and "Local" are rejected,
+// they would mean the server's zone.
+func validTimeZone(name string) bool {
+	if name == "" || name == "Local" {
+		return false
+	}
+	_, err := time.LoadLocation(name)
+	return err == nil
+}
+
+// currentMaster returns the master profile of the authenticated user, or writes the error response
+func (h *Handlers) currentMaster(c *gin.Context) (*models.Master, bool) {
+	userID, err := h.getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, false
+	}
+
+	master, err := h.repo.GetMasterByUserID(c.Request.Context(), userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Master not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get master profile"})
+		return nil, false
+	}
+	return master, true
+}
+
+// currentException loads the exception named by the :id parameter if it belongs to master
+func (h *Handlers) currentException(c *gin.Context, master *models.Master) (*models.ScheduleException, bool) {
+	exceptionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid exception ID"})
+		return nil, false
+	}
+
+	exception, err := h.repo.GetScheduleException(c.Request.Context(), exceptionID, master.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Schedule exception not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return exception, true
+}
+
+// GetScheduleExceptions lists the master's schedule exceptions between from and to
+// (YYYY-MM-DD, by default today and one year ahead)
+func (h *Handlers) GetScheduleExceptions(c *gin.Context) {
+	master, ok := h.currentMaster(c)
+	if !ok {
+		return
+	}
+
+	from := time.Now()
+	if value := c.Query("from"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date. Use YYYY-MM-DD"})
+			return
+		}
+		from = parsed
+	}
+	to := from.AddDate(1, 0, 0)
+	if value := c.Query("to"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date. Use YYYY-MM-DD"})
+			return
+		}
+		to = parsed
+	}
+
+	exceptions, err := h.repo.GetScheduleExceptions(c.Request.Context(), master.ID, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if exceptions == nil {
+		exceptions = []models.ScheduleException{}
+	}
+	c.JSON(http.StatusOK, exceptions)
+}
+
+// CreateScheduleException adds a day off, vacation, custom hours or a break.
+// Existing appointments are kept; see GetAffectedAppointments and ResolveAffectedAppointments.
+func (h *Handlers) CreateScheduleException(c *gin.Context) {
+	master, ok := h.currentMaster(c)
+	if !ok {
+		return
+	}
+
+	var req scheduleExceptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	exception, err := req.exception(master.ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.repo.CreateScheduleException(c.Request.Context(), exception); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, exception)
+}
+
+func (h *Handlers) UpdateScheduleException(c *gin.Context) {
+	master, ok := h.currentMaster(c)
+	if !ok {
+		return
+	}
+	existing, ok := h.currentException(c, master)
+	if !ok {
+		return
+	}
+
+	var req scheduleExceptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	exception, err := req.exception(master.ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	exception.ID = existing.ID
+
+	if err := h.repo.UpdateScheduleException(c.Request.Context(), exception); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Schedule exception not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, exception)
+}
+
+func (h *Handlers) DeleteScheduleException(c *gin.Context) {
+	master, ok := h.currentMaster(c)
+	if !ok {
+		return
+	}
+	exception, ok := h.currentException(c, master)
+	if !ok {
+		return
+	}
+
+	if err := h.repo.DeleteScheduleException(c.Request.Context(), exception.ID, master.ID); err != nil && err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Schedule exception deleted successfully"})
+}
+
+// GetAffectedAppointments lists upcoming appointments in the exception's dates that are outside working hours
+func (h *Handlers) GetAffectedAppointments(c *gin.Context) {
+	master, ok := h.currentMaster(c)
+	if !ok {
+		return
+	}
+	exception, ok := h.currentException(c, master)
+	if !ok {
+		return
+	}
+
+	affected, err := h.booking.AffectedAppointments(c.Request.Context(), master.ID, exception.StartDate, exception.EndDate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if affected == nil {
+		affected = []models.ScheduledAppointment{}
+	}
+	c.JSON(http.StatusOK, affected)
+}
+
+// ResolveAffectedAppointments cancels or reschedules all affected appointments and notifies the customers
+func (h *Handlers) ResolveAffectedAppointments(c *gin.Context) {
+	master, ok := h.currentMaster(c)
+	if !ok {
+		return
+	}
+	exception, ok := h.currentException(c, master)
+	if !ok {
+		return
+	}
+
+	type Request struct {
+		Action string `json:"action" binding:"required"`
+	}
+
+	var req Request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resolutions, err := h.booking.ResolveAffected(c.Request.Context(), master.ID, exception.StartDate, exception.EndDate, req.Action, exception.Reason)
+	if err != nil {
+		if errors.Is(err, booking.ErrInvalidResolveAction) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		// Appointments resolved before the failure stay resolved
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "resolved": resolutions})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"resolved": resolutions})
+}
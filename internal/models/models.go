@@ -4,16 +4,36 @@ import "time"
 
 // User represents a user in the system
 type User struct {
-	ID           int       `json:"id" db:"id"`
-	Name         string    `json:"name" db:"name"`
-	Email        string    `json:"email" db:"email"`
-	Phone        string    `json:"phone" db:"phone"`
-	PhotoURL     string    `json:"photo_url" db:"photo_url"`
-	PasswordHash string    `json:"-" db:"password_hash"`
-	CreatedAt    time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+	ID              int        `json:"id" db:"id"`
+	Name            string     `json:"name" db:"name"`
+	Email           string     `json:"email" db:"email"`
+	Phone           string     `json:"phone" db:"phone"`
+	PhotoURL        string     `json:"photo_url" db:"photo_url"`
+	PasswordHash    string     `json:"-" db:"password_hash"`
+	Role            string     `json:"role" db:"role"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at" db:"email_verified_at"`
+	PhoneVerifiedAt *time.Time `json:"phone_verified_at" db:"phone_verified_at"`
+	TimeZone        string     `json:"time_zone" db:"time_zone"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at" db:"updated_at"`
 }
 
+// DefaultTimeZone is the zone of users and masters who have not chosen one
+const DefaultTimeZone = "Asia/Almaty"
+
+// User roles
+const (
+	RoleCustomer = "customer"
+	RoleMaster   = "master"
+	RoleAdmin    = "admin"
+)
+
+// Purposes of single-use auth tokens
+const (
+	AuthTokenPasswordReset     = "password_reset"
+	AuthTokenEmailVerification = "email_verification"
+)
+
 // Category represents a service category
 type Category struct {
 	ID          int       `json:"id" db:"id"`
@@ -44,22 +64,38 @@ type Car struct {
 	Type  string `json:"type" db:"type"`
 }
 
-// PriceZone represents a price zone
+// PriceZone represents a price zone. Prices calculated for the zone are multiplied by Multiplier.
 type PriceZone struct {
-	ID         int     `json:"id" db:"id"`
-	Name       string  `json:"name" db:"name"`
-	Multiplier float64 `json:"multiplier" db:"multiplier"`
+	ID         int       `json:"id" db:"id"`
+	Name       string    `json:"name" db:"name"`
+	Multiplier float64   `json:"multiplier" db:"multiplier"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
 }
 
-// PricingRule represents a pricing rule
+// PricingRule represents a pricing rule. A nil ServiceID, empty CarType or nil age bound matches
+// any service, car type or age; the age bounds are inclusive. Every matching rule is applied in
+// Position order: the price is multiplied by Multiplier and FixedAddition is added.
 type PricingRule struct {
-	ID            int     `json:"id" db:"id"`
-	ServiceID     int     `json:"service_id" db:"service_id"`
-	CarType       string  `json:"car_type" db:"car_type"`
-	CarAgeMin     int     `json:"car_age_min" db:"car_age_min"`
-	CarAgeMax     int     `json:"car_age_max" db:"car_age_max"`
-	Multiplier    float64 `json:"multiplier" db:"multiplier"`
-	FixedAddition float64 `json:"fixed_addition" db:"fixed_addition"`
+	ID            int       `json:"id" db:"id"`
+	ServiceID     *int      `json:"service_id" db:"service_id"`
+	CarType       string    `json:"car_type" db:"car_type"`
+	CarAgeMin     *int      `json:"car_age_min" db:"car_age_min"`
+	CarAgeMax     *int      `json:"car_age_max" db:"car_age_max"`
+	Multiplier    float64   `json:"multiplier" db:"multiplier"`
+	FixedAddition float64   `json:"fixed_addition" db:"fixed_addition"`
+	Description   string    `json:"description" db:"description"`
+	Position      int       `json:"position" db:"position"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+}
+
+// BundleDiscount takes Percent off the total of a basket of at least MinServices services.
+// When several discounts apply the largest one wins.
+type BundleDiscount struct {
+	ID          int       `json:"id" db:"id"`
+	MinServices int       `json:"min_services" db:"min_services"`
+	Percent     float64   `json:"percent" db:"percent"`
+	Description string    `json:"description" db:"description"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
 }
 
 // Master represents a service master
@@ -75,6 +111,7 @@ type Master struct {
 	LocationLat    float64   `json:"location_lat" db:"location_lat"`
 	LocationLng    float64   `json:"location_lng" db:"location_lng"`
 	Address        string    `json:"address" db:"address"`
+	TimeZone       string    `json:"time_zone" db:"time_zone"`
 	CreatedAt      time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
 }
@@ -90,6 +127,44 @@ type MasterSchedule struct {
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 }
 
+// MasterService is a catalog service as a master offers it, at the master's own price and duration.
+// Customers can only book a master for services the master offers and has marked available.
+type MasterService struct {
+	ID              int       `json:"id" db:"id"`
+	MasterID        int       `json:"master_id" db:"master_id"`
+	ServiceID       int       `json:"service_id" db:"service_id"`
+	CategoryID      int       `json:"category_id" db:"category_id"`
+	ServiceName     string    `json:"service_name" db:"service_name"`
+	BasePrice       float64   `json:"base_price" db:"base_price"`
+	DurationMinutes int       `json:"duration_minutes" db:"duration_minutes"`
+	IsAvailable     bool      `json:"is_available" db:"is_available"`
+	CreatedAt       time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Schedule exception types
+const (
+	ExceptionDayOff      = "day_off"
+	ExceptionVacation    = "vacation"
+	ExceptionCustomHours = "custom_hours"
+	ExceptionBreak       = "break"
+)
+
+// ScheduleException changes a master's weekly schedule from StartDate to EndDate inclusive.
+// Day offs and vacations close whole days, custom hours replace the weekly windows and
+// breaks block StartTime..EndTime on each day.
+type ScheduleException struct {
+	ID        int       `json:"id" db:"id"`
+	MasterID  int       `json:"master_id" db:"master_id"`
+	Type      string    `json:"type" db:"type"`
+	StartDate time.Time `json:"start_date" db:"start_date"`
+	EndDate   time.Time `json:"end_date" db:"end_date"`
+	StartTime string    `json:"start_time,omitempty" db:"start_time"`
+	EndTime   string    `json:"end_time,omitempty" db:"end_time"`
+	Reason    string    `json:"reason" db:"reason"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
 // Appointment represents a booking
 type Appointment struct {
 	ID        int       `json:"id" db:"id"`
@@ -102,6 +177,103 @@ type Appointment struct {
 	Comment   string    `json:"comment" db:"comment"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	// QuoteID is the price quote the appointment was booked with; Price is then its total, locked at booking
+	QuoteID *int     `json:"quote_id,omitempty" db:"quote_id"`
+	Price   *float64 `json:"price,omitempty" db:"price"`
+	// CarID is the catalog car the appointment is for and UserCarID the customer's own car, if given
+	CarID     *int `json:"car_id,omitempty" db:"car_id"`
+	UserCarID *int `json:"user_car_id,omitempty" db:"user_car_id"`
+	// Items are the booked services in order; ServiceID is the first of them.
+	// Only filled where an appointment is returned on its own.
+	Items []AppointmentItem `json:"items,omitempty"`
+}
+
+// AppointmentItem is one service booked in an appointment, at the master's price and duration
+// at booking time
+type AppointmentItem struct {
+	ID              int     `json:"id" db:"id"`
+	AppointmentID   int     `json:"appointment_id" db:"appointment_id"`
+	ServiceID       int     `json:"service_id" db:"service_id"`
+	ServiceName     string  `json:"service_name" db:"service_name"`
+	Position        int     `json:"position" db:"position"`
+	Price           float64 `json:"price" db:"price"`
+	DurationMinutes int     `json:"duration_minutes" db:"duration_minutes"`
+}
+
+// Appointment statuses. An appointment moves pending -> confirmed -> in_progress -> completed;
+// see booking.Transition for the other branches and who may take each step.
+const (
+	AppointmentPending    = "pending"
+	AppointmentConfirmed  = "confirmed"
+	AppointmentInProgress = "in_progress"
+	AppointmentCompleted  = "completed"
+	AppointmentCancelled  = "cancelled"
+	AppointmentNoShow     = "no_show"
+	AppointmentRejected   = "rejected"
+)
+
+// HoldsSlot reports whether an appointment in status keeps its master busy.
+// Mirrors the WHERE clause of appointments_no_overlap.
+func HoldsSlot(status string) bool {
+	return status != AppointmentCancelled && status != AppointmentRejected
+}
+
+// Types of appointment history events
+const (
+	AppointmentEventCreated       = "created"
+	AppointmentEventStatusChanged = "status_changed"
+	AppointmentEventRescheduled   = "rescheduled"
+	// The customer proposed another time; see RescheduleRequest
+	AppointmentEventRescheduleRequested = "reschedule_requested"
+	AppointmentEventRescheduleDeclined  = "reschedule_declined"
+	// The appointment was deleted; its history is kept
+	AppointmentEventDeleted = "deleted"
+)
+
+// ActorSystem is the actor role of changes nobody made by hand
+const ActorSystem = "system"
+
+// AppointmentEvent is one entry of an appointment's history
+type AppointmentEvent struct {
+	ID            int    `json:"id" db:"id"`
+	AppointmentID int    `json:"appointment_id" db:"appointment_id"`
+	Type          string `json:"type" db:"type"`
+	// FromStatus is empty for AppointmentEventCreated
+	FromStatus string `json:"from_status" db:"from_status"`
+	ToStatus   string `json:"to_status" db:"to_status"`
+	// ActorID is the user who made the change, nil for the system
+	ActorID   *int      `json:"actor_id" db:"actor_id"`
+	ActorRole string    `json:"actor_role" db:"actor_role"`
+	Note      string    `json:"note" db:"note"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// Reschedule request statuses
+const (
+	ReschedulePending   = "pending"
+	RescheduleAccepted  = "accepted"
+	RescheduleDeclined  = "declined"
+	RescheduleCancelled = "cancelled"
+	RescheduleExpired   = "expired"
+)
+
+// RescheduleRequest is a customer's proposal to move an appointment to another time.
+// While pending, Slot is held for the appointment; the master accepts or declines it.
+type RescheduleRequest struct {
+	ID            int `json:"id" db:"id"`
+	AppointmentID int `json:"appointment_id" db:"appointment_id"`
+	MasterID      int `json:"master_id" db:"master_id"`
+	RequestedBy   int `json:"requested_by" db:"requested_by"`
+	// Date and Time are the proposed start in the master's zone
+	Date    time.Time `json:"date" db:"date"`
+	Time    string    `json:"time" db:"time"`
+	Slot    TimeRange `json:"slot" db:"slot"`
+	Status  string    `json:"status" db:"status"`
+	Comment string    `json:"comment" db:"comment"`
+	// Response is the master's reason for declining
+	Response   string     `json:"response" db:"response"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	ResolvedAt *time.Time `json:"resolved_at" db:"resolved_at"`
 }
 
 // AppointmentWithDetails represents an appointment with related details
@@ -111,6 +283,40 @@ type AppointmentWithDetails struct {
 	MasterName  string `json:"master_name" db:"master_name"`
 }
 
+// Booking is a new appointment together with the records created alongside it.
+// The repository stores all of them or none.
+type Booking struct {
+	Appointment Appointment
+	// Slot is when the master is busy; Appointment.Date and Time are its start in the master's zone
+	Slot          TimeRange
+	Guarantee     *Guarantee
+	Notifications []Notification
+	// Event starts the appointment's history, nil to record none
+	Event *AppointmentEvent
+}
+
+// TimeRange is the half-open interval [Start, End)
+type TimeRange struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// Overlaps reports whether the two ranges share any instant
+func (r TimeRange) Overlaps(other TimeRange) bool {
+	return r.Start.Before(other.End) && other.Start.Before(r.End)
+}
+
+// Contains reports whether other lies entirely within r
+func (r TimeRange) Contains(other TimeRange) bool {
+	return !other.Start.Before(r.Start) && !other.End.After(r.End)
+}
+
+// ScheduledAppointment is an active appointment with the time it occupies
+type ScheduledAppointment struct {
+	Appointment
+	Slot TimeRange `json:"slot"`
+}
+
 // Review represents a review of a master
 type Review struct {
 	ID        int       `json:"id" db:"id"`
@@ -125,6 +331,7 @@ type Review struct {
 type CalculatePriceResponse struct {
 	ServiceID    int           `json:"service_id"`
 	ServiceName  string        `json:"service_name"`
+	MasterID     int           `json:"master_id,omitempty"`
 	CarBrand     string        `json:"car_brand"`
 	CarModel     string        `json:"car_model"`
 	CarYear      int           `json:"car_year"`
@@ -134,7 +341,45 @@ type CalculatePriceResponse struct {
 	FinalPrice   float64       `json:"final_price"`
 	MinPrice     float64       `json:"min_price"`
 	MaxPrice     float64       `json:"max_price"`
+	Zone         *PriceZone    `json:"zone,omitempty"`
 	PriceDetails []PriceDetail `json:"price_details"`
+	// DurationMinutes is how long the service takes, the master's duration if a master is given
+	DurationMinutes int `json:"duration_minutes"`
+}
+
+// PriceQuote is the price of a basket of services for one car. Items are priced one by one;
+// the bundle discount, if any, is taken off their Subtotal. A stored quote can be booked
+// at its prices until ExpiresAt, only by the user it was made for.
+type PriceQuote struct {
+	ID              int                      `json:"id,omitempty"`
+	UserID          int                      `json:"user_id"`
+	CarID           int                      `json:"car_id"`
+	UserCarID       int                      `json:"user_car_id,omitempty"`
+	MasterID        int                      `json:"master_id,omitempty"`
+	Zone            *PriceZone               `json:"zone,omitempty"`
+	Items           []CalculatePriceResponse `json:"items"`
+	Subtotal        float64                  `json:"subtotal"`
+	Discount        float64                  `json:"discount"`
+	Total           float64                  `json:"total"`
+	DurationMinutes int                      `json:"duration_minutes"`
+	PriceDetails    []PriceDetail            `json:"price_details"`
+	ExpiresAt       time.Time                `json:"expires_at"`
+	CreatedAt       time.Time                `json:"created_at"`
+}
+
+// Expired reports whether the quote can no longer be booked at now
+func (q PriceQuote) Expired(now time.Time) bool {
+	return !now.Before(q.ExpiresAt)
+}
+
+// Item is the quoted price of serviceID, or nil if the quote does not include it
+func (q PriceQuote) Item(serviceID int) *CalculatePriceResponse {
+	for i := range q.Items {
+		if q.Items[i].ServiceID == serviceID {
+			return &q.Items[i]
+		}
+	}
+	return nil
 }
 
 // PriceDetail represents a price calculation step
@@ -193,13 +438,18 @@ type FavoriteMaster struct {
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 }
 
-// UserCar represents a user's car
+// UserCar represents a user's car.
+// CarID is the catalog car it is, if the user picked one; Brand, Model and Type are read from it.
 type UserCar struct {
 	ID        int       `json:"id" db:"id"`
 	UserID    int       `json:"user_id" db:"user_id"`
+	CarID     *int      `json:"car_id" db:"car_id"`
 	Name      string    `json:"name" db:"name"`
 	Year      int       `json:"year" db:"year"`
 	Comment   string    `json:"comment" db:"comment"`
+	Brand     string    `json:"brand,omitempty" db:"brand"`
+	Model     string    `json:"model,omitempty" db:"model"`
+	Type      string    `json:"type,omitempty" db:"type"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
 }
@@ -254,3 +504,39 @@ type MasterCertificate struct {
 	PhotoURL  string    `json:"photo_url" db:"photo_url"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 }
+
+// RefreshToken represents a stored refresh token; tokens of one login session share a FamilyID
+type RefreshToken struct {
+	ID        int        `json:"id" db:"id"`
+	UserID    int        `json:"user_id" db:"user_id"`
+	FamilyID  string     `json:"family_id" db:"family_id"`
+	TokenHash string     `json:"-" db:"token_hash"`
+	Device    string     `json:"device" db:"device"`
+	IPAddress string     `json:"ip_address" db:"ip_address"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	RotatedAt *time.Time `json:"rotated_at" db:"rotated_at"`
+	RevokedAt *time.Time `json:"revoked_at" db:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+// Session represents an active login session of a user
+type Session struct {
+	ID         string    `json:"id"`
+	Device     string    `json:"device"`
+	IPAddress  string    `json:"ip_address"`
+	StartedAt  time.Time `json:"started_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+// OTPCode represents a one-time login code sent by SMS
+type OTPCode struct {
+	ID         int        `json:"id" db:"id"`
+	Phone      string     `json:"phone" db:"phone"`
+	CodeHash   string     `json:"-" db:"code_hash"`
+	ExpiresAt  time.Time  `json:"expires_at" db:"expires_at"`
+	Attempts   int        `json:"attempts" db:"attempts"`
+	ConsumedAt *time.Time `json:"consumed_at" db:"consumed_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
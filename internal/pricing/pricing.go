@@ -0,0 +1,383 @@
+// Package pricing calculates service prices from the pricing rules, price zones and
+// bundle discounts administrators maintain.
+package pricing
+
+import (
+	"beep-backend/internal/models"
+	"beep-backend/internal/repository"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultQuoteTTL is how long a quote can be booked at its prices unless Settings say otherwise
+const DefaultQuoteTTL = 24 * time.Hour
+
+var (
+	ErrServiceNotFound = errors.New("service not found")
+	ErrCarNotFound     = errors.New("car not found")
+	ErrZoneNotFound    = errors.New("price zone not found")
+	// ErrServiceNotOffered is returned when the master does not offer the service or has it unavailable
+	ErrServiceNotOffered = errors.New("the master does not offer this service")
+	ErrEmptyBasket       = errors.New("at least one service is required")
+	ErrDuplicateService  = errors.New("each service can be in the basket only once")
+	// ErrMasterRequired is returned for a quote without a master; only the master's own prices can be booked
+	ErrMasterRequired = errors.New("master_id is required to quote a price that can be booked")
+	// ErrUserCarNotLinked is returned for a user car that is not linked to a catalog car and so cannot be priced
+	ErrUserCarNotLinked = errors.New("the car is not linked to a catalog car")
+)
+
+// Service calculates prices and keeps the quotes given to customers
+type Service struct {
+	store    repository.Store
+	quoteTTL time.Duration
+	now      func() time.Time
+}
+
+// Settings tunes pricing
+type Settings struct {
+	// QuoteTTL is how long a quote can be booked at its prices, DefaultQuoteTTL if zero
+	QuoteTTL time.Duration
+	// Now is the clock car ages and quote expiry are computed from, time.Now if nil
+	Now func() time.Time
+}
+
+func NewService(store repository.Store, settings Settings) *Service {
+	if settings.QuoteTTL <= 0 {
+		settings.QuoteTTL = DefaultQuoteTTL
+	}
+	if settings.Now == nil {
+		settings.Now = time.Now
+	}
+	return &Service{
+		store:    store,
+		quoteTTL: settings.QuoteTTL,
+		now:      settings.Now,
+	}
+}
+
+// Request describes what to price. With a MasterID the master's own price of the service is the base
+// price, otherwise the catalog price. ZoneID 0 means no price zone.
+// The car is either the catalog car CarID or UserCarID, a car from the garage of UserID.
+type Request struct {
+	ServiceID int
+	CarID     int
+	UserCarID int
+	UserID    int
+	MasterID  int
+	ZoneID    int
+}
+
+// Calculate prices a service for a car in a zone, at a master's rate if one is given.
+// Returns ErrServiceNotFound, ErrCarNotFound or ErrZoneNotFound for unknown IDs,
+// ErrUserCarNotLinked for a user car that cannot be priced
+// and ErrServiceNotOffered if the master cannot be booked for the service.
+func (s *Service) Calculate(ctx context.Context, req Request) (*models.CalculatePriceResponse, error) {
+	car, zone, err := s.carAndZone(ctx, carRef{req.CarID, req.UserCarID, req.UserID}, req.ZoneID)
+	if err != nil {
+		return nil, err
+	}
+	return s.price(ctx, req.ServiceID, req.MasterID, car, zone)
+}
+
+// QuoteRequest describes a basket of services to price together for one car,
+// given like in Request. The quote is made for UserID, who alone can read and book it.
+type QuoteRequest struct {
+	CarID      int
+	UserCarID  int
+	UserID     int
+	ServiceIDs []int
+	MasterID   int
+	ZoneID     int
+}
+
+// Quote prices each service of the basket like Calculate and takes the best bundle discount
+// the basket qualifies for off the subtotal. The quote is stored and can be booked at its prices
+// until it expires with the quoted master. Returns ErrMasterRequired without a master, ErrEmptyBasket
+// or ErrDuplicateService for a malformed basket and otherwise the errors of Calculate.
+func (s *Service) Quote(ctx context.Context, req QuoteRequest) (*models.PriceQuote, error) {
+	if req.MasterID == 0 {
+		return nil, ErrMasterRequired
+	}
+	if len(req.ServiceIDs) == 0 {
+		return nil, ErrEmptyBasket
+	}
+	seen := make(map[int]bool, len(req.ServiceIDs))
+	for _, serviceID := range req.ServiceIDs {
+		if seen[serviceID] {
+			return nil, ErrDuplicateService
+		}
+		seen[serviceID] = true
+	}
+
+	car, zone, err := s.carAndZone(ctx, carRef{req.CarID, req.UserCarID, req.UserID}, req.ZoneID)
+	if err != nil {
+		return nil, err
+	}
+	quote := &models.PriceQuote{
+		UserID:    req.UserID,
+		CarID:     car.ID,
+		UserCarID: req.UserCarID,
+		MasterID:  req.MasterID,
+		Zone:      zone,
+		Items:     make([]models.CalculatePriceResponse, 0, len(req.ServiceIDs)),
+	}
+	for _, serviceID := range req.ServiceIDs {
+		item, err := s.price(ctx, serviceID, req.MasterID, car, zone)
+		if err != nil {
+			return nil, err
+		}
+		quote.Items = append(quote.Items, *item)
+		quote.Subtotal += item.FinalPrice
+		quote.DurationMinutes += item.DurationMinutes
+	}
+
+	discounts, err := s.store.GetBundleDiscounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	quote.PriceDetails = []models.PriceDetail{{
+		Description: fmt.Sprintf("Стоимость услуг (%d)", len(quote.Items)),
+		Amount:      quote.Subtotal,
+	}}
+	if discount := BestBundleDiscount(discounts, len(quote.Items)); discount != nil {
+		quote.Discount = quote.Subtotal * discount.Percent / 100
+		description := discount.Description
+		if description == "" {
+			description = fmt.Sprintf("Скидка за комплекс услуг (-%g%%)", discount.Percent)
+		}
+		quote.PriceDetails = append(quote.PriceDetails, models.PriceDetail{
+			Description: description,
+			Amount:      -quote.Discount,
+			Multiplier:  1 - discount.Percent/100,
+			IsAddition:  true,
+		})
+	}
+	quote.Total = quote.Subtotal - quote.Discount
+
+	quote.ExpiresAt = s.now().Add(s.quoteTTL)
+	if err := s.store.CreatePriceQuote(ctx, quote); err != nil {
+		return nil, err
+	}
+	return quote, nil
+}
+
+// BestBundleDiscount is the discount with the largest percent among those a basket of count
+// services qualifies for, or nil if there is none
+func BestBundleDiscount(discounts []models.BundleDiscount, count int) *models.BundleDiscount {
+	var best *models.BundleDiscount
+	for i := range discounts {
+		discount := &discounts[i]
+		if count < discount.MinServices {
+			continue
+		}
+		if best == nil || discount.Percent > best.Percent {
+			best = discount
+		}
+	}
+	return best
+}
+
+// carRef names the car to price: a catalog car, or a car in a user's garage if userCarID is set
+type carRef struct {
+	carID     int
+	userCarID int
+	userID    int
+}
+
+// carAndZone loads the car and, unless zoneID is 0, the price zone.
+// Returns ErrCarNotFound or ErrZoneNotFound for unknown IDs.
+func (s *Service) carAndZone(ctx context.Context, ref carRef, zoneID int) (*models.Car, *models.PriceZone, error) {
+	car, err := s.car(ctx, ref)
+	if err != nil {
+		return nil, nil, err
+	}
+	if zoneID == 0 {
+		return car, nil, nil
+	}
+	zone, err := s.store.GetPriceZone(ctx, zoneID)
+	if err != nil {
+		return nil, nil, notFound(err, ErrZoneNotFound)
+	}
+	return car, zone, nil
+}
+
+// car loads the catalog car to price. A user car is priced as the catalog car it is linked to,
+// of the user car's year if it has one. Returns ErrCarNotFound if the car does not exist or is
+// not in the user's garage and ErrUserCarNotLinked if the user car has no catalog car.
+func (s *Service) car(ctx context.Context, ref carRef) (*models.Car, error) {
+	if ref.userCarID == 0 {
+		car, err := s.store.GetCarByID(ctx, ref.carID)
+		return car, notFound(err, ErrCarNotFound)
+	}
+	userCar, err := s.store.GetUserCar(ctx, ref.userCarID, ref.userID)
+	if err != nil {
+		return nil, notFound(err, ErrCarNotFound)
+	}
+	if userCar.CarID == nil {
+		return nil, ErrUserCarNotLinked
+	}
+	car, err := s.store.GetCarByID(ctx, *userCar.CarID)
+	if err != nil {
+		return nil, notFound(err, ErrCarNotFound)
+	}
+	if userCar.Year > 0 {
+		car.Year = userCar.Year
+	}
+	return car, nil
+}
+
+// price prices one service for the car, at the master's rate unless masterID is 0
+func (s *Service) price(ctx context.Context, serviceID, masterID int, car *models.Car, zone *models.PriceZone) (*models.CalculatePriceResponse, error) {
+	service, err := s.store.GetServiceByID(ctx, serviceID)
+	if err != nil {
+		return nil, notFound(err, ErrServiceNotFound)
+	}
+	if masterID != 0 {
+		offer, err := s.store.GetMasterService(ctx, masterID, service.ID)
+		if err != nil {
+			return nil, notFound(err, ErrServiceNotOffered)
+		}
+		if !offer.IsAvailable {
+			return nil, ErrServiceNotOffered
+		}
+		service.BasePrice = offer.BasePrice
+		service.DurationMinutes = offer.DurationMinutes
+	}
+	rules, err := s.store.GetPricingRules(ctx, service.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := Price(*service, *car, CarAge(car.Year, s.now()), rules, zone)
+	result.MasterID = masterID
+	return result, nil
+}
+
+// Price applies the rules matching the service and car, in the given order, then the zone multiplier,
+// and clamps the result to the service's minimum and maximum price where those are set.
+// Every step is listed in PriceDetails; rule amounts are relative to the base price.
+func Price(service models.Service, car models.Car, carAge int, rules []models.PricingRule, zone *models.PriceZone) *models.CalculatePriceResponse {
+	price := service.BasePrice
+	details := []models.PriceDetail{{
+		Description: "Базовая цена услуги",
+		Amount:      service.BasePrice,
+		IsAddition:  false,
+	}}
+
+	for _, rule := range rules {
+		if !Matches(rule, service.ID, car.Type, carAge) {
+			continue
+		}
+		price = price*rule.Multiplier + rule.FixedAddition
+		detail := models.PriceDetail{
+			Description: rule.Description,
+			Amount:      service.BasePrice*(rule.Multiplier-1) + rule.FixedAddition,
+			IsAddition:  true,
+		}
+		if rule.Multiplier != 1 {
+			detail.Multiplier = rule.Multiplier
+		}
+		if detail.Description == "" {
+			detail.Description = ruleDescription(rule)
+		}
+		details = append(details, detail)
+	}
+
+	if zone != nil && zone.Multiplier != 1 {
+		details = append(details, models.PriceDetail{
+			Description: fmt.Sprintf("Ценовая зона «%s» (×%g)", zone.Name, zone.Multiplier),
+			Amount:      price * (zone.Multiplier - 1),
+			Multiplier:  zone.Multiplier,
+			IsAddition:  true,
+		})
+		price *= zone.Multiplier
+	}
+
+	if service.MinPrice > 0 && price < service.MinPrice {
+		details = append(details, models.PriceDetail{
+			Description: "Минимальная цена услуги",
+			Amount:      service.MinPrice - price,
+			IsAddition:  true,
+		})
+		price = service.MinPrice
+	}
+	if service.MaxPrice > 0 && price > service.MaxPrice {
+		details = append(details, models.PriceDetail{
+			Description: "Максимальная цена услуги",
+			Amount:      service.MaxPrice - price,
+			IsAddition:  true,
+		})
+		price = service.MaxPrice
+	}
+
+	return &models.CalculatePriceResponse{
+		ServiceID:    service.ID,
+		ServiceName:  service.Name,
+		CarBrand:     car.Brand,
+		CarModel:     car.Model,
+		CarYear:      car.Year,
+		CarType:      car.Type,
+		CarAge:       carAge,
+		BasePrice:    service.BasePrice,
+		FinalPrice:   price,
+		MinPrice:     service.MinPrice,
+		MaxPrice:     service.MaxPrice,
+		Zone:         zone,
+		PriceDetails: details,
+
+		DurationMinutes: service.DurationMinutes,
+	}
+}
+
+// CarAge is how many years old a car built in year is at now, counting by calendar year.
+// A car of an unknown or future year is 0 years old.
+func CarAge(year int, now time.Time) int {
+	if year <= 0 || year > now.Year() {
+		return 0
+	}
+	return now.Year() - year
+}
+
+// Matches reports whether a rule applies to the service and a car of carType aged carAge years.
+// Car types are compared case-insensitively.
+func Matches(rule models.PricingRule, serviceID int, carType string, carAge int) bool {
+	if rule.ServiceID != nil && *rule.ServiceID != serviceID {
+		return false
+	}
+	if rule.CarType != "" && !strings.EqualFold(rule.CarType, carType) {
+		return false
+	}
+	if rule.CarAgeMin != nil && carAge < *rule.CarAgeMin {
+		return false
+	}
+	if rule.CarAgeMax != nil && carAge > *rule.CarAgeMax {
+		return false
+	}
+	return true
+}
+
+// ruleDescription describes a rule that has no description of its own
+func ruleDescription(rule models.PricingRule) string {
+	var parts []string
+	if rule.Multiplier != 1 {
+		parts = append(parts, fmt.Sprintf("%+.0f%%", (rule.Multiplier-1)*100))
+	}
+	if rule.FixedAddition != 0 {
+		parts = append(parts, fmt.Sprintf("%+.2f руб.", rule.FixedAddition))
+	}
+	if len(parts) == 0 {
+		return "Корректировка цены"
+	}
+	return "Корректировка цены (" + strings.Join(parts, ", ") + ")"
+}
+
+func notFound(err, notFoundErr error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return notFoundErr
+	}
+	return err
+}
@@ -0,0 +1,196 @@
+package pricing
+
+import (
+	"beep-backend/internal/models"
+	"math"
+	"testing"
+	"time"
+)
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func TestPrice(t *testing.T) {
+	service := models.Service{ID: 1, Name: "Мойка", BasePrice: 100}
+	car := models.Car{ID: 1, Brand: "Kia", Model: "Rio", Year: 2018, Type: "Economy"}
+
+	tests := []struct {
+		name    string
+		service models.Service
+		rules   []models.PricingRule
+		zone    *models.PriceZone
+		want    float64
+		details int
+	}{
+		{name: "base price without rules", service: service, want: 100, details: 1},
+		{
+			name:    "multiplier rule",
+			service: service,
+			rules:   []models.PricingRule{{Multiplier: 1.5}},
+			want:    150,
+			details: 2,
+		},
+		{
+			name:    "multiplier and fixed addition",
+			service: service,
+			rules:   []models.PricingRule{{Multiplier: 1.2, FixedAddition: 10}},
+			want:    130,
+			details: 2,
+		},
+		{
+			name:    "rules apply in order",
+			service: service,
+			rules:   []models.PricingRule{{Multiplier: 1, FixedAddition: 50}, {Multiplier: 2}},
+			want:    300,
+			details: 3,
+		},
+		{
+			name:    "rule for another car type is skipped",
+			service: service,
+			rules:   []models.PricingRule{{Multiplier: 2, CarType: "Premium"}},
+			want:    100,
+			details: 1,
+		},
+		{
+			name:    "rule for another service is skipped",
+			service: service,
+			rules:   []models.PricingRule{{Multiplier: 2, ServiceID: intPtr(2)}},
+			want:    100,
+			details: 1,
+		},
+		{
+			name:    "zone applies after rules",
+			service: service,
+			rules:   []models.PricingRule{{Multiplier: 1.5}},
+			zone:    &models.PriceZone{Name: "Центр", Multiplier: 1.2},
+			want:    180,
+			details: 3,
+		},
+		{
+			name:    "zone of multiplier one adds no step",
+			service: service,
+			zone:    &models.PriceZone{Name: "Город", Multiplier: 1},
+			want:    100,
+			details: 1,
+		},
+		{
+			name:    "raised to the minimum price",
+			service: models.Service{ID: 1, BasePrice: 100, MinPrice: 80},
+			zone:    &models.PriceZone{Name: "Окраина", Multiplier: 0.5},
+			want:    80,
+			details: 3,
+		},
+		{
+			name:    "capped at the maximum price",
+			service: models.Service{ID: 1, BasePrice: 100, MaxPrice: 150},
+			rules:   []models.PricingRule{{Multiplier: 2}},
+			want:    150,
+			details: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Price(tt.service, car, 8, tt.rules, tt.zone)
+			if math.Abs(got.FinalPrice-tt.want) > 1e-9 {
+				t.Errorf("FinalPrice = %v, want %v", got.FinalPrice, tt.want)
+			}
+			if len(got.PriceDetails) != tt.details {
+				t.Errorf("got %d price details, want %d: %+v", len(got.PriceDetails), tt.details, got.PriceDetails)
+			}
+			if got.BasePrice != tt.service.BasePrice || got.CarAge != 8 || got.CarType != car.Type {
+				t.Errorf("response does not describe the priced service and car: %+v", got)
+			}
+		})
+	}
+}
+
+func TestCarAge(t *testing.T) {
+	now := time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		year int
+		now  time.Time
+		want int
+	}{
+		{name: "older car", year: 2018, now: now, want: 8},
+		{name: "this year's car", year: 2026, now: now, want: 0},
+		{name: "unknown year", year: 0, now: now, want: 0},
+		{name: "future year", year: 2027, now: now, want: 0},
+		{name: "counts by calendar year", year: 2025, now: time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC), want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CarAge(tt.year, tt.now); got != tt.want {
+				t.Errorf("CarAge(%d) = %d, want %d", tt.year, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		name      string
+		rule      models.PricingRule
+		serviceID int
+		carType   string
+		carAge    int
+		want      bool
+	}{
+		{name: "rule without conditions", rule: models.PricingRule{}, serviceID: 1, carType: "Economy", carAge: 3, want: true},
+		{name: "same service", rule: models.PricingRule{ServiceID: intPtr(1)}, serviceID: 1, want: true},
+		{name: "other service", rule: models.PricingRule{ServiceID: intPtr(2)}, serviceID: 1, want: false},
+		{name: "car type ignores case", rule: models.PricingRule{CarType: "premium"}, carType: "Premium", want: true},
+		{name: "other car type", rule: models.PricingRule{CarType: "Premium"}, carType: "Economy", want: false},
+		{name: "at minimum age", rule: models.PricingRule{CarAgeMin: intPtr(10)}, carAge: 10, want: true},
+		{name: "below minimum age", rule: models.PricingRule{CarAgeMin: intPtr(10)}, carAge: 9, want: false},
+		{name: "at maximum age", rule: models.PricingRule{CarAgeMax: intPtr(3)}, carAge: 3, want: true},
+		{name: "above maximum age", rule: models.PricingRule{CarAgeMax: intPtr(3)}, carAge: 4, want: false},
+		{
+			name:      "all conditions met",
+			rule:      models.PricingRule{ServiceID: intPtr(1), CarType: "SUV", CarAgeMin: intPtr(5), CarAgeMax: intPtr(10)},
+			serviceID: 1,
+			carType:   "SUV",
+			carAge:    7,
+			want:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Matches(tt.rule, tt.serviceID, tt.carType, tt.carAge); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBestBundleDiscount(t *testing.T) {
+	discounts := []models.BundleDiscount{
+		{ID: 1, MinServices: 3, Percent: 10},
+		{ID: 2, MinServices: 2, Percent: 5},
+		{ID: 3, MinServices: 5, Percent: 15},
+	}
+	tests := []struct {
+		name      string
+		discounts []models.BundleDiscount
+		count     int
+		wantID    int
+	}{
+		{name: "no discounts", count: 3},
+		{name: "basket too small", discounts: discounts, count: 1},
+		{name: "only the smallest qualifies", discounts: discounts, count: 2, wantID: 2},
+		{name: "largest qualifying percent", discounts: discounts, count: 4, wantID: 1},
+		{name: "all qualify", discounts: discounts, count: 5, wantID: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := BestBundleDiscount(tt.discounts, tt.count)
+			switch {
+			case tt.wantID == 0 && got != nil:
+				t.Errorf("got discount %d, want none", got.ID)
+			case tt.wantID != 0 && (got == nil || got.ID != tt.wantID):
+				t.Errorf("got %+v, want discount %d", got, tt.wantID)
+			}
+		})
+	}
+}
@@ -0,0 +1,813 @@
+package memory
+
+import (
+	"beep-backend/internal/models"
+	"beep-backend/internal/repository"
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Appointments
+
+// CreateBooking stores the appointment with its guarantee and notifications under one lock,
+// so readers never see part of a booking
+func (s *Store) CreateBooking(ctx context.Context, booking *models.Booking) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[booking.Appointment.UserID]; !ok {
+		return fmt.Errorf("failed to create appointment: user %d does not exist", booking.Appointment.UserID)
+	}
+	if _, ok := s.masters[booking.Appointment.MasterID]; !ok {
+		return fmt.Errorf("failed to create appointment: master %d does not exist", booking.Appointment.MasterID)
+	}
+	if _, ok := s.services[booking.Appointment.ServiceID]; !ok {
+		return fmt.Errorf("failed to create appointment: service %d does not exist", booking.Appointment.ServiceID)
+	}
+	for _, item := range booking.Appointment.Items {
+		if _, ok := s.services[item.ServiceID]; !ok {
+			return fmt.Errorf("failed to create appointment item: service %d does not exist", item.ServiceID)
+		}
+	}
+
+	appointment := &booking.Appointment
+	appointment.Date = dateOnly(appointment.Date)
+	if models.HoldsSlot(appointment.Status) && s.overlapsBooking(appointment.MasterID, 0, booking.Slot) {
+		return repository.ErrSlotTaken
+	}
+
+	now := time.Now()
+	appointment.ID = s.nextID()
+	appointment.CreatedAt = now
+	appointment.UpdatedAt = now
+	stored := *appointment
+	stored.Items = nil
+	s.appointments[appointment.ID] = stored
+	s.appointmentSlots[appointment.ID] = booking.Slot
+
+	for i := range appointment.Items {
+		item := &appointment.Items[i]
+		item.ID = s.nextID()
+		item.AppointmentID = appointment.ID
+		item.Position = i
+		item.ServiceName = s.services[item.ServiceID].Name
+		s.appointmentItems[item.ID] = *item
+	}
+
+	if g := booking.Guarantee; g != nil {
+		g.ID = s.nextID()
+		g.AppointmentID = appointment.ID
+		g.ServiceDate = dateOnly(g.ServiceDate)
+		g.ExpiryDate = dateOnly(g.ExpiryDate)
+		g.CreatedAt = now
+		s.guarantees[g.ID] = *g
+	}
+
+	if booking.Event != nil {
+		s.addAppointmentEvent(appointment.ID, booking.Event)
+	}
+	s.addNotifications(appointment.ID, booking.Notifications)
+	return nil
+}
+
+func (s *Store) addAppointmentEvent(appointmentID int, event *models.AppointmentEvent) {
+	event.ID = s.nextID()
+	event.AppointmentID = appointmentID
+	event.CreatedAt = time.Now()
+	s.appointmentEvents[event.ID] = *event
+}
+
+func (s *Store) addNotifications(relatedID int, notifications []models.Notification) {
+	for i := range notifications {
+		n := &notifications[i]
+		n.ID = s.nextID()
+		n.RelatedID = relatedID
+		n.IsRead = false
+		n.CreatedAt = time.Now()
+		s.notifications[n.ID] = *n
+	}
+}
+
+func (s *Store) GetBookedIntervals(ctx context.Context, masterID int, from, to time.Time) ([]models.TimeRange, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.bookedIntervals(masterID, models.TimeRange{Start: from, End: to}), nil
+}
+
+// bookedIntervals are the slots of the master's active appointments and pending reschedule
+// requests that overlap window, whatever date they are filed under
+func (s *Store) bookedIntervals(masterID int, window models.TimeRange) []models.TimeRange {
+	var intervals []models.TimeRange
+	for _, appointment := range s.appointments {
+		if appointment.MasterID != masterID || !models.HoldsSlot(appointment.Status) {
+			continue
+		}
+		if interval, ok := s.appointmentSlot(appointment); ok && interval.Overlaps(window) {
+			intervals = append(intervals, interval)
+		}
+	}
+	for _, request := range s.reschedules {
+		if request.MasterID == masterID && request.Status == models.ReschedulePending && request.Slot.Overlaps(window) {
+			intervals = append(intervals, request.Slot)
+		}
+	}
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i].Start.Before(intervals[j].Start) })
+	return intervals
+}
+
+// appointmentSlot is the stored slot of an appointment. Appointments without one occupy their
+// date and time in the master's zone for as long as their service lasts.
+func (s *Store) appointmentSlot(appointment models.Appointment) (models.TimeRange, bool) {
+	if slot, ok := s.appointmentSlots[appointment.ID]; ok {
+		return slot, true
+	}
+
+	minutes := s.services[appointment.ServiceID].DurationMinutes
+	if minutes <= 0 {
+		minutes = 60
+	}
+	loc, err := time.LoadLocation(s.masters[appointment.MasterID].TimeZone)
+	if err != nil {
+		return models.TimeRange{}, false
+	}
+	clock, err := time.Parse("15:04", normalizeClock(appointment.Time))
+	if err != nil {
+		return models.TimeRange{}, false
+	}
+	start := time.Date(appointment.Date.Year(), appointment.Date.Month(), appointment.Date.Day(), clock.Hour(), clock.Minute(), 0, 0, loc)
+	return models.TimeRange{Start: start, End: start.Add(time.Duration(minutes) * time.Minute)}, true
+}
+
+// overlapsBooking mirrors appointments_no_overlap and the reschedule holds: whether slot overlaps
+// an active appointment of the master or a slot held by a pending reschedule request, ignoring
+// the appointment exceptID and its requests
+func (s *Store) overlapsBooking(masterID, exceptID int, slot models.TimeRange) bool {
+	for _, other := range s.appointments {
+		if other.ID == exceptID || other.MasterID != masterID || !models.HoldsSlot(other.Status) {
+			continue
+		}
+		if booked, ok := s.appointmentSlot(other); ok && booked.Overlaps(slot) {
+			return true
+		}
+	}
+	for _, request := range s.reschedules {
+		if request.AppointmentID != exceptID && request.MasterID == masterID &&
+			request.Status == models.ReschedulePending && request.Slot.Overlaps(slot) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) GetMasterAppointmentsBetween(ctx context.Context, masterID int, from, to time.Time) ([]models.ScheduledAppointment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	from, to = dateOnly(from), dateOnly(to)
+	var appointments []models.ScheduledAppointment
+	for _, appointment := range sortedValues(s.appointments) {
+		if appointment.MasterID != masterID || !upcoming(appointment.Status) ||
+			appointment.Date.Before(from) || appointment.Date.After(to) {
+			continue
+		}
+		if slot, ok := s.appointmentSlot(appointment); ok {
+			appointments = append(appointments, models.ScheduledAppointment{Appointment: appointment, Slot: slot})
+		}
+	}
+	sort.SliceStable(appointments, func(i, j int) bool { return appointments[i].Slot.Start.Before(appointments[j].Slot.Start) })
+	return appointments, nil
+}
+
+// upcoming reports whether an appointment in status has not started yet and can still be moved
+func upcoming(status string) bool {
+	return status == models.AppointmentPending || status == models.AppointmentConfirmed
+}
+
+func (s *Store) ChangeAppointmentStatus(ctx context.Context, appointmentID int, event *models.AppointmentEvent, notifications []models.Notification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	appointment, ok := s.appointments[appointmentID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if appointment.Status != event.FromStatus {
+		return repository.ErrStatusChanged
+	}
+	s.updateAppointment(appointmentID, func(appointment *models.Appointment) { appointment.Status = event.ToStatus })
+	event.Type = models.AppointmentEventStatusChanged
+	s.addAppointmentEvent(appointmentID, event)
+	if !upcoming(event.ToStatus) {
+		s.closeReschedules(appointmentID, models.RescheduleCancelled)
+	}
+	s.addNotifications(appointmentID, notifications)
+	return nil
+}
+
+func (s *Store) MoveBooking(ctx context.Context, appointmentID int, slot models.TimeRange, event *models.AppointmentEvent, notifications []models.Notification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.moveBooking(appointmentID, slot, event); err != nil {
+		return err
+	}
+	s.addNotifications(appointmentID, notifications)
+	return nil
+}
+
+func (s *Store) moveBooking(appointmentID int, slot models.TimeRange, event *models.AppointmentEvent) error {
+	appointment, ok := s.appointments[appointmentID]
+	if !ok || !upcoming(appointment.Status) {
+		return sql.ErrNoRows
+	}
+	if s.overlapsBooking(appointment.MasterID, appointmentID, slot) {
+		return repository.ErrSlotTaken
+	}
+
+	newDate := dateOnly(slot.Start)
+	s.updateAppointment(appointmentID, func(appointment *models.Appointment) {
+		appointment.Date = newDate
+		appointment.Time = slot.Start.Format("15:04")
+	})
+	s.appointmentSlots[appointmentID] = slot
+
+	for id, g := range s.guarantees {
+		if g.AppointmentID == appointmentID {
+			g.ExpiryDate = g.ExpiryDate.Add(newDate.Sub(g.ServiceDate))
+			g.ServiceDate = newDate
+			s.guarantees[id] = g
+		}
+	}
+
+	event.Type = models.AppointmentEventRescheduled
+	event.FromStatus, event.ToStatus = appointment.Status, appointment.Status
+	s.addAppointmentEvent(appointmentID, event)
+	return nil
+}
+
+func (s *Store) GetAppointmentByID(ctx context.Context, appointmentID int) (*models.Appointment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	appointment, ok := s.appointments[appointmentID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &appointment, nil
+}
+
+func (s *Store) GetScheduledAppointment(ctx context.Context, appointmentID int) (*models.ScheduledAppointment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	appointment, ok := s.appointments[appointmentID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	slot, ok := s.appointmentSlot(appointment)
+	if !ok {
+		return nil, fmt.Errorf("appointment %d has no valid slot", appointmentID)
+	}
+	return &models.ScheduledAppointment{Appointment: appointment, Slot: slot}, nil
+}
+
+func (s *Store) GetAppointmentEvents(ctx context.Context, appointmentID int) ([]models.AppointmentEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var events []models.AppointmentEvent
+	for _, event := range sortedValues(s.appointmentEvents) {
+		if event.AppointmentID == appointmentID {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (s *Store) GetAppointmentItems(ctx context.Context, appointmentID int) ([]models.AppointmentItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var items []models.AppointmentItem
+	for _, item := range sortedValues(s.appointmentItems) {
+		if item.AppointmentID == appointmentID {
+			item.ServiceName = s.services[item.ServiceID].Name
+			items = append(items, item)
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Position < items[j].Position })
+	return items, nil
+}
+
+// newestAppointmentsFirst orders appointments by date and time descending
+func newestAppointmentsFirst(appointments []models.AppointmentWithDetails) {
+	sort.SliceStable(appointments, func(i, j int) bool {
+		if !appointments[i].Date.Equal(appointments[j].Date) {
+			return appointments[i].Date.After(appointments[j].Date)
+		}
+		return appointments[i].Time > appointments[j].Time
+	})
+}
+
+func (s *Store) GetUserAppointments(ctx context.Context, userID int) ([]models.AppointmentWithDetails, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var appointments []models.AppointmentWithDetails
+	for _, appointment := range sortedValues(s.appointments) {
+		if appointment.UserID != userID {
+			continue
+		}
+		appointments = append(appointments, models.AppointmentWithDetails{
+			Appointment: appointment,
+			ServiceName: s.services[appointment.ServiceID].Name,
+			MasterName:  s.masters[appointment.MasterID].Name,
+		})
+	}
+	newestAppointmentsFirst(appointments)
+	return appointments, nil
+}
+
+// GetMasterAppointmentsForNotifications returns the master's appointments with the customer's
+// name in MasterName and their contacts and cars prepended to Comment, like the PostgreSQL repository
+func (s *Store) GetMasterAppointmentsForNotifications(ctx context.Context, masterID int) ([]models.AppointmentWithDetails, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var appointments []models.AppointmentWithDetails
+	for _, appointment := range sortedValues(s.appointments) {
+		service, serviceOK := s.services[appointment.ServiceID]
+		customer, customerOK := s.users[appointment.UserID]
+		if appointment.MasterID != masterID || !serviceOK || !customerOK {
+			continue
+		}
+
+		for _, car := range s.carsOrPlaceholder(appointment.UserID) {
+			details := models.AppointmentWithDetails{
+				Appointment: appointment,
+				ServiceName: service.Name,
+				MasterName:  customer.Name,
+			}
+
+			contactParts := []string{
+				"Телефон: " + customer.Phone,
+				"Email: " + customer.Email,
+			}
+			carInfo := "Машина: " + car.Name
+			if car.Year != 0 {
+				carInfo += fmt.Sprintf(" (%d)", car.Year)
+			}
+			contactParts = append(contactParts, carInfo)
+
+			infoText := strings.Join(contactParts, "\n")
+			if details.Comment != "" {
+				details.Comment = infoText + "\n\nКомментарий клиента: " + details.Comment
+			} else {
+				details.Comment = infoText
+			}
+			appointments = append(appointments, details)
+		}
+	}
+	newestAppointmentsFirst(appointments)
+	return appointments, nil
+}
+
+// carsOrPlaceholder returns the user's cars, or a single unnamed car, matching the
+// LEFT JOIN on user_cars in the PostgreSQL repository
+func (s *Store) carsOrPlaceholder(userID int) []models.UserCar {
+	var cars []models.UserCar
+	for _, car := range sortedValues(s.userCars) {
+		if car.UserID == userID {
+			cars = append(cars, car)
+		}
+	}
+	if len(cars) == 0 {
+		cars = append(cars, models.UserCar{Name: "Не указана"})
+	}
+	return cars
+}
+
+func (s *Store) UpdateAppointmentComment(ctx context.Context, appointmentID int, comment string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.updateAppointment(appointmentID, func(appointment *models.Appointment) { appointment.Comment = comment })
+	return nil
+}
+
+func (s *Store) updateAppointment(appointmentID int, fn func(appointment *models.Appointment)) {
+	appointment, ok := s.appointments[appointmentID]
+	if !ok {
+		return
+	}
+	fn(&appointment)
+	appointment.UpdatedAt = time.Now()
+	s.appointments[appointmentID] = appointment
+}
+
+func (s *Store) DeleteAppointment(ctx context.Context, appointmentID int, event *models.AppointmentEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.addAppointmentEvent(appointmentID, event)
+	delete(s.appointments, appointmentID)
+	delete(s.appointmentSlots, appointmentID)
+	for id, item := range s.appointmentItems {
+		if item.AppointmentID == appointmentID {
+			delete(s.appointmentItems, id)
+		}
+	}
+	for id, request := range s.reschedules {
+		if request.AppointmentID == appointmentID {
+			delete(s.reschedules, id)
+		}
+	}
+	return nil
+}
+
+// Reschedule requests
+
+func (s *Store) CreateRescheduleRequest(ctx context.Context, request *models.RescheduleRequest, event *models.AppointmentEvent, notifications []models.Notification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.appointments[request.AppointmentID]; !ok {
+		return fmt.Errorf("failed to create reschedule request: appointment %d does not exist", request.AppointmentID)
+	}
+	s.closeReschedules(request.AppointmentID, models.RescheduleCancelled)
+	if s.overlapsBooking(request.MasterID, request.AppointmentID, request.Slot) {
+		return repository.ErrSlotTaken
+	}
+
+	request.ID = s.nextID()
+	request.Date = dateOnly(request.Date)
+	request.Status = models.ReschedulePending
+	request.Response = ""
+	request.CreatedAt = time.Now()
+	request.ResolvedAt = nil
+	s.reschedules[request.ID] = *request
+
+	event.Type = models.AppointmentEventRescheduleRequested
+	s.addAppointmentEvent(request.AppointmentID, event)
+	s.addNotifications(request.AppointmentID, notifications)
+	return nil
+}
+
+// closeReschedules moves the appointment's pending requests to status
+func (s *Store) closeReschedules(appointmentID int, status string) {
+	now := time.Now()
+	for id, request := range s.reschedules {
+		if request.AppointmentID == appointmentID && request.Status == models.ReschedulePending {
+			request.Status = status
+			request.ResolvedAt = &now
+			s.reschedules[id] = request
+		}
+	}
+}
+
+func (s *Store) GetRescheduleRequest(ctx context.Context, requestID int) (*models.RescheduleRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	request, ok := s.reschedules[requestID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &request, nil
+}
+
+func (s *Store) GetAppointmentRescheduleRequests(ctx context.Context, appointmentID int) ([]models.RescheduleRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var requests []models.RescheduleRequest
+	all := sortedValues(s.reschedules)
+	for i := len(all) - 1; i >= 0; i-- {
+		if all[i].AppointmentID == appointmentID {
+			requests = append(requests, all[i])
+		}
+	}
+	return requests, nil
+}
+
+func (s *Store) GetMasterRescheduleRequests(ctx context.Context, masterID int, status string) ([]models.RescheduleRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var requests []models.RescheduleRequest
+	for _, request := range sortedValues(s.reschedules) {
+		if request.MasterID == masterID && (status == "" || request.Status == status) {
+			requests = append(requests, request)
+		}
+	}
+	sort.SliceStable(requests, func(i, j int) bool { return requests[i].Slot.Start.Before(requests[j].Slot.Start) })
+	return requests, nil
+}
+
+func (s *Store) AcceptRescheduleRequest(ctx context.Context, requestID int, event *models.AppointmentEvent, notifications []models.Notification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	request, ok := s.reschedules[requestID]
+	if !ok || request.Status != models.ReschedulePending {
+		return sql.ErrNoRows
+	}
+	loc, err := time.LoadLocation(s.masters[request.MasterID].TimeZone)
+	if err != nil {
+		return fmt.Errorf("invalid master time zone: %w", err)
+	}
+
+	// The request stops holding its slot before the appointment moves into it
+	request.Status = models.RescheduleAccepted
+	s.reschedules[requestID] = request
+	slot := models.TimeRange{Start: request.Slot.Start.In(loc), End: request.Slot.End.In(loc)}
+	if err := s.moveBooking(request.AppointmentID, slot, event); err != nil {
+		request.Status = models.ReschedulePending
+		s.reschedules[requestID] = request
+		return err
+	}
+	now := time.Now()
+	request.ResolvedAt = &now
+	s.reschedules[requestID] = request
+	s.addNotifications(request.AppointmentID, notifications)
+	return nil
+}
+
+func (s *Store) CloseRescheduleRequest(ctx context.Context, requestID int, status, response string, event *models.AppointmentEvent, notifications []models.Notification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	request, ok := s.reschedules[requestID]
+	if !ok || request.Status != models.ReschedulePending {
+		return sql.ErrNoRows
+	}
+	now := time.Now()
+	request.Status = status
+	request.Response = response
+	request.ResolvedAt = &now
+	s.reschedules[requestID] = request
+
+	if event != nil {
+		s.addAppointmentEvent(request.AppointmentID, event)
+	}
+	s.addNotifications(request.AppointmentID, notifications)
+	return nil
+}
+
+// Notifications
+
+func (s *Store) GetUserNotifications(ctx context.Context, userID int) ([]models.Notification, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var notifications []models.Notification
+	all := sortedValues(s.notifications)
+	for i := len(all) - 1; i >= 0; i-- {
+		if all[i].UserID == userID {
+			notifications = append(notifications, all[i])
+		}
+	}
+	return notifications, nil
+}
+
+func (s *Store) CreateNotification(ctx context.Context, userID int, notificationType, title, message string, relatedID int) (*models.Notification, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	notification := models.Notification{
+		ID:        s.nextID(),
+		UserID:    userID,
+		Type:      notificationType,
+		Title:     title,
+		Message:   message,
+		RelatedID: relatedID,
+		CreatedAt: time.Now(),
+	}
+	s.notifications[notification.ID] = notification
+	return &notification, nil
+}
+
+func (s *Store) MarkNotificationRead(ctx context.Context, notificationID, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if notification, ok := s.notifications[notificationID]; ok && notification.UserID == userID {
+		notification.IsRead = true
+		s.notifications[notificationID] = notification
+	}
+	return nil
+}
+
+// Subscriptions
+
+func (s *Store) GetUserSubscription(ctx context.Context, userID int) (*models.Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sub, ok := s.subscriptions[userID]; ok {
+		return &sub, nil
+	}
+	// Users without a subscription get the basic plan, as in the PostgreSQL repository
+	sub := s.upsertSubscription(userID, func(sub *models.Subscription) { sub.Plan = "basic" })
+	return &sub, nil
+}
+
+func (s *Store) CreateUserSubscription(ctx context.Context, userID int, plan string) (*models.Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub := s.upsertSubscription(userID, func(sub *models.Subscription) { sub.Plan = plan })
+	return &sub, nil
+}
+
+func (s *Store) StartTrial(ctx context.Context, userID int) (*models.Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	trialStart := time.Now()
+	trialEnd := trialStart.AddDate(0, 0, 7) // 7 days trial
+	sub := s.upsertSubscription(userID, func(sub *models.Subscription) {
+		sub.Plan = "trial"
+		sub.TrialStartDate = &trialStart
+		sub.TrialEndDate = &trialEnd
+	})
+	return &sub, nil
+}
+
+func (s *Store) UpdateUserSubscription(ctx context.Context, userID int, plan string) error {
+	if plan != "basic" && plan != "premium" && plan != "trial" {
+		return fmt.Errorf("invalid plan: %s", plan)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.upsertSubscription(userID, func(sub *models.Subscription) { sub.Plan = plan })
+	return nil
+}
+
+func (s *Store) upsertSubscription(userID int, fn func(sub *models.Subscription)) models.Subscription {
+	now := time.Now()
+	sub, ok := s.subscriptions[userID]
+	if !ok {
+		sub = models.Subscription{ID: s.nextID(), UserID: userID, CreatedAt: now}
+	}
+	fn(&sub)
+	sub.UpdatedAt = now
+	s.subscriptions[userID] = sub
+	return sub
+}
+
+// User Cars
+
+func (s *Store) GetUserCars(ctx context.Context, userID int) ([]models.UserCar, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var cars []models.UserCar
+	all := sortedValues(s.userCars)
+	for i := len(all) - 1; i >= 0; i-- {
+		if all[i].UserID == userID {
+			cars = append(cars, s.withCatalogCar(all[i]))
+		}
+	}
+	return cars, nil
+}
+
+func (s *Store) GetUserCar(ctx context.Context, id, userID int) (*models.UserCar, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	car, ok := s.userCars[id]
+	if !ok || car.UserID != userID {
+		return nil, sql.ErrNoRows
+	}
+	car = s.withCatalogCar(car)
+	return &car, nil
+}
+
+func (s *Store) CreateUserCar(ctx context.Context, userID int, catalogCarID *int, name string, year int, comment string) (*models.UserCar, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	car := models.UserCar{
+		ID:        s.nextID(),
+		UserID:    userID,
+		CarID:     catalogCarID,
+		Name:      name,
+		Year:      year,
+		Comment:   comment,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	s.userCars[car.ID] = car
+	car = s.withCatalogCar(car)
+	return &car, nil
+}
+
+func (s *Store) UpdateUserCar(ctx context.Context, carID, userID int, catalogCarID *int, name string, year int, comment string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if car, ok := s.userCars[carID]; ok && car.UserID == userID {
+		car.CarID = catalogCarID
+		car.Name = name
+		car.Year = year
+		car.Comment = comment
+		car.UpdatedAt = time.Now()
+		s.userCars[carID] = car
+	}
+	return nil
+}
+
+func (s *Store) DeleteUserCar(ctx context.Context, carID, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if car, ok := s.userCars[carID]; ok && car.UserID == userID {
+		delete(s.userCars, carID)
+		for id, quote := range s.quotes {
+			if quote.UserCarID == carID {
+				quote.UserCarID = 0
+				s.quotes[id] = quote
+			}
+		}
+		for id, appointment := range s.appointments {
+			if appointment.UserCarID != nil && *appointment.UserCarID == carID {
+				appointment.UserCarID = nil
+				s.appointments[id] = appointment
+			}
+		}
+	}
+	return nil
+}
+
+// withCatalogCar fills in the brand, model and type of the catalog car a user car is linked to,
+// like the LEFT JOIN on cars in the PostgreSQL repository
+func (s *Store) withCatalogCar(car models.UserCar) models.UserCar {
+	if car.CarID != nil {
+		if catalog, ok := s.cars[*car.CarID]; ok {
+			car.Brand = catalog.Brand
+			car.Model = catalog.Model
+			car.Type = catalog.Type
+		}
+	}
+	return car
+}
+
+// Guarantees
+
+func (s *Store) GetUserGuarantees(ctx context.Context, userID int) ([]models.GuaranteeWithDetails, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	today := dateOnly(time.Now())
+	var guarantees []models.GuaranteeWithDetails
+	for _, g := range sortedValues(s.guarantees) {
+		if g.UserID != userID || g.ExpiryDate.Before(today) {
+			continue
+		}
+
+		appointment, ok := s.appointments[g.AppointmentID]
+		if !ok {
+			guarantees = append(guarantees, models.GuaranteeWithDetails{Guarantee: g})
+			continue
+		}
+		for _, car := range s.carsOrPlaceholder(appointment.UserID) {
+			guarantees = append(guarantees, models.GuaranteeWithDetails{
+				Guarantee:         g,
+				AppointmentDate:   appointment.Date.Format(time.RFC3339),
+				AppointmentTime:   appointment.Time,
+				AppointmentStatus: appointment.Status,
+				CarName:           car.Name,
+				CarYear:           car.Year,
+			})
+		}
+	}
+	sort.SliceStable(guarantees, func(i, j int) bool { return guarantees[i].ExpiryDate.Before(guarantees[j].ExpiryDate) })
+	return guarantees, nil
+}
+
+func (s *Store) CreateGuarantee(ctx context.Context, userID, appointmentID int, serviceName, masterName string, serviceDate time.Time) (*models.Guarantee, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g := models.Guarantee{
+		ID:            s.nextID(),
+		UserID:        userID,
+		AppointmentID: appointmentID,
+		ServiceName:   serviceName,
+		MasterName:    masterName,
+		ServiceDate:   dateOnly(serviceDate),
+		ExpiryDate:    dateOnly(serviceDate.AddDate(0, 0, 14)), // 14 days guarantee
+		CreatedAt:     time.Now(),
+	}
+	s.guarantees[g.ID] = g
+	return &g, nil
+}
@@ -0,0 +1,666 @@
+package memory
+
+import (
+	"beep-backend/internal/models"
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+)
+
+// Masters
+
+func (s *Store) GetAllMasters(ctx context.Context) ([]models.Master, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	masters := sortedValues(s.masters)
+	sort.SliceStable(masters, func(i, j int) bool { return masters[i].Rating > masters[j].Rating })
+	return masters, nil
+}
+
+func (s *Store) GetMasterByID(ctx context.Context, id int) (*models.Master, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	master, ok := s.masters[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &master, nil
+}
+
+func (s *Store) GetMasterByUserID(ctx context.Context, userID int) (*models.Master, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	master, ok := s.masterByUserID(userID)
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &master, nil
+}
+
+func (s *Store) masterByUserID(userID int) (models.Master, bool) {
+	for _, master := range sortedValues(s.masters) {
+		if master.UserID == userID {
+			return master, true
+		}
+	}
+	return models.Master{}, false
+}
+
+func (s *Store) CreateMaster(ctx context.Context, userID int, name, email, phone, specialization, address string) (*models.Master, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	master := models.Master{
+		ID:             s.nextID(),
+		UserID:         userID,
+		Name:           name,
+		Email:          email,
+		Phone:          phone,
+		Specialization: specialization,
+		Address:        address,
+		TimeZone:       models.DefaultTimeZone,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	s.masters[master.ID] = master
+
+	// Customers become masters once they have a profile; admins keep their role
+	s.updateUser(userID, func(user *models.User) {
+		if user.Role == models.RoleCustomer {
+			user.Role = models.RoleMaster
+		}
+	})
+	return &master, nil
+}
+
+func (s *Store) UpdateMaster(ctx context.Context, masterID int, name, email, phone, specialization, address string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.updateMaster(masterID, func(master *models.Master) {
+		master.Name = name
+		master.Email = email
+		master.Phone = phone
+		master.Specialization = specialization
+		master.Address = address
+	})
+	return nil
+}
+
+func (s *Store) UpdateMasterPhoto(ctx context.Context, masterID int, photoURL string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.updateMaster(masterID, func(master *models.Master) { master.PhotoURL = photoURL })
+	return nil
+}
+
+func (s *Store) UpdateMasterTimeZone(ctx context.Context, masterID int, timeZone string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.updateMaster(masterID, func(master *models.Master) { master.TimeZone = timeZone })
+	return nil
+}
+
+func (s *Store) updateMaster(masterID int, fn func(master *models.Master)) {
+	master, ok := s.masters[masterID]
+	if !ok {
+		return
+	}
+	fn(&master)
+	master.UpdatedAt = time.Now()
+	s.masters[masterID] = master
+}
+
+// DeleteMasterProfile removes the master of a user along with their appointments and
+// everything the database would delete by ON DELETE CASCADE
+func (s *Store) DeleteMasterProfile(ctx context.Context, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	master, ok := s.masterByUserID(userID)
+	if !ok {
+		return sql.ErrNoRows
+	}
+	masterID := master.ID
+
+	for id, appointment := range s.appointments {
+		if appointment.MasterID == masterID {
+			delete(s.appointments, id)
+		}
+	}
+	for id, work := range s.works {
+		if work.MasterID == masterID {
+			delete(s.works, id)
+		}
+	}
+	for id, cert := range s.certificates {
+		if cert.MasterID == masterID {
+			delete(s.certificates, id)
+		}
+	}
+	for id, review := range s.reviews {
+		if review.MasterID == masterID {
+			delete(s.reviews, id)
+		}
+	}
+	for id, favorite := range s.favorites {
+		if favorite.MasterID == masterID {
+			delete(s.favorites, id)
+		}
+	}
+	for id, offer := range s.offers {
+		if offer.MasterID == masterID {
+			delete(s.offers, id)
+		}
+	}
+	delete(s.paymentInfo, masterID)
+	delete(s.schedules, masterID)
+	for id, m := range s.masters {
+		if m.UserID == userID {
+			delete(s.masters, id)
+		}
+	}
+
+	s.updateUser(userID, func(user *models.User) {
+		if user.Role == models.RoleMaster {
+			user.Role = models.RoleCustomer
+		}
+	})
+	return nil
+}
+
+func (s *Store) CheckMasterVerificationStatus(ctx context.Context, masterID int) (bool, int, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	master, ok := s.masters[masterID]
+	if !ok {
+		return false, 0, 0, sql.ErrNoRows
+	}
+
+	reviewCount := 0
+	for _, review := range s.reviews {
+		if review.MasterID == masterID {
+			reviewCount++
+		}
+	}
+	workCount := 0
+	for _, work := range s.works {
+		if work.MasterID == masterID {
+			workCount++
+		}
+	}
+
+	// Same criteria as the PostgreSQL repository: 3+ reviews OR rating > 4 OR works > 2
+	isVerified := reviewCount >= 3 || master.Rating > 4.0 || workCount > 2
+	return isVerified, reviewCount, workCount, nil
+}
+
+// Schedule
+
+func (s *Store) GetMasterSchedule(ctx context.Context, masterID int) ([]models.MasterSchedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var schedules []models.MasterSchedule
+	schedules = append(schedules, s.schedules[masterID]...)
+	sort.SliceStable(schedules, func(i, j int) bool {
+		if schedules[i].DayOfWeek != schedules[j].DayOfWeek {
+			return schedules[i].DayOfWeek < schedules[j].DayOfWeek
+		}
+		return schedules[i].StartTime < schedules[j].StartTime
+	})
+	return schedules, nil
+}
+
+func (s *Store) UpdateMasterSchedule(ctx context.Context, masterID int, schedules []models.MasterSchedule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := make([]models.MasterSchedule, 0, len(schedules))
+	for _, schedule := range schedules {
+		schedule.ID = s.nextID()
+		schedule.MasterID = masterID
+		schedule.StartTime = normalizeClock(schedule.StartTime)
+		schedule.EndTime = normalizeClock(schedule.EndTime)
+		schedule.CreatedAt = time.Now()
+		stored = append(stored, schedule)
+	}
+	s.schedules[masterID] = stored
+	return nil
+}
+
+// Schedule exceptions
+
+func (s *Store) GetScheduleExceptions(ctx context.Context, masterID int, from, to time.Time) ([]models.ScheduleException, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	from, to = dateOnly(from), dateOnly(to)
+	var exceptions []models.ScheduleException
+	for _, exception := range sortedValues(s.exceptions) {
+		if exception.MasterID == masterID && !exception.StartDate.After(to) && !exception.EndDate.Before(from) {
+			exceptions = append(exceptions, exception)
+		}
+	}
+	sort.SliceStable(exceptions, func(i, j int) bool {
+		if !exceptions[i].StartDate.Equal(exceptions[j].StartDate) {
+			return exceptions[i].StartDate.Before(exceptions[j].StartDate)
+		}
+		return exceptions[i].StartTime < exceptions[j].StartTime
+	})
+	return exceptions, nil
+}
+
+func (s *Store) GetScheduleException(ctx context.Context, exceptionID, masterID int) (*models.ScheduleException, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	exception, ok := s.exceptions[exceptionID]
+	if !ok || exception.MasterID != masterID {
+		return nil, sql.ErrNoRows
+	}
+	return &exception, nil
+}
+
+func (s *Store) CreateScheduleException(ctx context.Context, exception *models.ScheduleException) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	exception.ID = s.nextID()
+	exception.CreatedAt = time.Now()
+	s.storeException(exception)
+	return nil
+}
+
+func (s *Store) UpdateScheduleException(ctx context.Context, exception *models.ScheduleException) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.exceptions[exception.ID]
+	if !ok || existing.MasterID != exception.MasterID {
+		return sql.ErrNoRows
+	}
+	exception.CreatedAt = existing.CreatedAt
+	s.storeException(exception)
+	return nil
+}
+
+func (s *Store) storeException(exception *models.ScheduleException) {
+	exception.StartDate = dateOnly(exception.StartDate)
+	exception.EndDate = dateOnly(exception.EndDate)
+	exception.StartTime = normalizeClock(exception.StartTime)
+	exception.EndTime = normalizeClock(exception.EndTime)
+	s.exceptions[exception.ID] = *exception
+}
+
+func (s *Store) DeleteScheduleException(ctx context.Context, exceptionID, masterID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	exception, ok := s.exceptions[exceptionID]
+	if !ok || exception.MasterID != masterID {
+		return sql.ErrNoRows
+	}
+	delete(s.exceptions, exceptionID)
+	return nil
+}
+
+// Master price lists
+
+func (s *Store) GetMasterServices(ctx context.Context, masterID int) ([]models.MasterService, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var offers []models.MasterService
+	for _, offer := range sortedValues(s.offers) {
+		if offer.MasterID == masterID {
+			offers = append(offers, s.withService(offer))
+		}
+	}
+	sort.SliceStable(offers, func(i, j int) bool {
+		if offers[i].CategoryID != offers[j].CategoryID {
+			return offers[i].CategoryID < offers[j].CategoryID
+		}
+		return offers[i].ServiceName < offers[j].ServiceName
+	})
+	return offers, nil
+}
+
+func (s *Store) GetMasterService(ctx context.Context, masterID, serviceID int) (*models.MasterService, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	offer, ok := s.masterService(masterID, serviceID)
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	offer = s.withService(offer)
+	return &offer, nil
+}
+
+func (s *Store) GetServiceOffers(ctx context.Context, serviceID int) ([]models.MasterService, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var offers []models.MasterService
+	for _, offer := range sortedValues(s.offers) {
+		if offer.ServiceID == serviceID && offer.IsAvailable {
+			offers = append(offers, s.withService(offer))
+		}
+	}
+	sort.SliceStable(offers, func(i, j int) bool { return offers[i].BasePrice < offers[j].BasePrice })
+	return offers, nil
+}
+
+func (s *Store) SaveMasterService(ctx context.Context, offer *models.MasterService) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if existing, ok := s.masterService(offer.MasterID, offer.ServiceID); ok {
+		offer.ID = existing.ID
+		offer.CreatedAt = existing.CreatedAt
+	} else {
+		offer.ID = s.nextID()
+		offer.CreatedAt = now
+	}
+	offer.UpdatedAt = now
+	*offer = s.withService(*offer)
+	s.offers[offer.ID] = *offer
+	return nil
+}
+
+func (s *Store) DeleteMasterService(ctx context.Context, masterID, serviceID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	offer, ok := s.masterService(masterID, serviceID)
+	if !ok {
+		return sql.ErrNoRows
+	}
+	delete(s.offers, offer.ID)
+	return nil
+}
+
+func (s *Store) masterService(masterID, serviceID int) (models.MasterService, bool) {
+	for _, offer := range s.offers {
+		if offer.MasterID == masterID && offer.ServiceID == serviceID {
+			return offer, true
+		}
+	}
+	return models.MasterService{}, false
+}
+
+// withService fills in the catalog fields the PostgreSQL repository joins from services
+func (s *Store) withService(offer models.MasterService) models.MasterService {
+	service := s.services[offer.ServiceID]
+	offer.ServiceName = service.Name
+	offer.CategoryID = service.CategoryID
+	return offer
+}
+
+// Works
+
+// firstPhoto mirrors the PostgreSQL repository, which keeps only the first photo of a work
+func firstPhoto(photoURLs []string) []string {
+	if len(photoURLs) == 0 || photoURLs[0] == "" {
+		return nil
+	}
+	return []string{photoURLs[0]}
+}
+
+func (s *Store) GetMasterWorks(ctx context.Context, masterID int) ([]models.MasterWork, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var works []models.MasterWork
+	for _, work := range sortedValues(s.works) {
+		if work.MasterID == masterID {
+			works = append(works, work)
+		}
+	}
+	sort.SliceStable(works, func(i, j int) bool { return works[i].WorkDate.After(works[j].WorkDate) })
+	return works, nil
+}
+
+func (s *Store) GetMasterWork(ctx context.Context, workID, masterID int) (*models.MasterWork, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	work, ok := s.works[workID]
+	if !ok || work.MasterID != masterID {
+		return nil, sql.ErrNoRows
+	}
+	return &work, nil
+}
+
+func (s *Store) CreateMasterWork(ctx context.Context, masterID int, title string, workDate time.Time, customerName string, amount float64, photoURLs []string) (*models.MasterWork, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	work := models.MasterWork{
+		ID:           s.nextID(),
+		MasterID:     masterID,
+		Title:        title,
+		WorkDate:     dateOnly(workDate),
+		CustomerName: customerName,
+		Amount:       amount,
+		PhotoURLs:    firstPhoto(photoURLs),
+		CreatedAt:    time.Now(),
+	}
+	s.works[work.ID] = work
+	return &work, nil
+}
+
+func (s *Store) UpdateMasterWork(ctx context.Context, workID, masterID int, title string, workDate time.Time, customerName string, amount float64, photoURLs []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	work, ok := s.works[workID]
+	if !ok || work.MasterID != masterID {
+		return sql.ErrNoRows
+	}
+	work.Title = title
+	work.WorkDate = dateOnly(workDate)
+	work.CustomerName = customerName
+	work.Amount = amount
+	work.PhotoURLs = firstPhoto(photoURLs)
+	s.works[workID] = work
+	return nil
+}
+
+func (s *Store) DeleteMasterWork(ctx context.Context, workID, masterID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	work, ok := s.works[workID]
+	if !ok || work.MasterID != masterID {
+		return sql.ErrNoRows
+	}
+	delete(s.works, workID)
+	return nil
+}
+
+// Payment Info
+
+func (s *Store) GetMasterPaymentInfo(ctx context.Context, masterID int) (*models.MasterPaymentInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, ok := s.paymentInfo[masterID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &info, nil
+}
+
+func (s *Store) UpdateMasterPaymentInfo(ctx context.Context, masterID int, kaspiCard, freedomCard, halykCard string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	info, ok := s.paymentInfo[masterID]
+	if !ok {
+		info = models.MasterPaymentInfo{ID: s.nextID(), MasterID: masterID, CreatedAt: now}
+	}
+	info.KaspiCard = kaspiCard
+	info.FreedomCard = freedomCard
+	info.HalykCard = halykCard
+	info.UpdatedAt = now
+	s.paymentInfo[masterID] = info
+	return nil
+}
+
+// Certificates
+
+func (s *Store) GetMasterCertificates(ctx context.Context, masterID int) ([]models.MasterCertificate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var certificates []models.MasterCertificate
+	all := sortedValues(s.certificates)
+	for i := len(all) - 1; i >= 0; i-- {
+		if all[i].MasterID == masterID {
+			certificates = append(certificates, all[i])
+		}
+	}
+	return certificates, nil
+}
+
+func (s *Store) CreateMasterCertificate(ctx context.Context, masterID int, name, photoURL string) (*models.MasterCertificate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cert := models.MasterCertificate{
+		ID:        s.nextID(),
+		MasterID:  masterID,
+		Name:      name,
+		PhotoURL:  photoURL,
+		CreatedAt: time.Now(),
+	}
+	s.certificates[cert.ID] = cert
+	return &cert, nil
+}
+
+func (s *Store) DeleteMasterCertificate(ctx context.Context, certificateID, masterID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if cert, ok := s.certificates[certificateID]; ok && cert.MasterID == masterID {
+		delete(s.certificates, certificateID)
+	}
+	return nil
+}
+
+// Reviews
+
+func (s *Store) GetMasterReviews(ctx context.Context, masterID int) ([]models.ReviewWithUser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var reviews []models.ReviewWithUser
+	all := sortedValues(s.reviews)
+	for i := len(all) - 1; i >= 0; i-- {
+		review := all[i]
+		user, ok := s.users[review.UserID]
+		if review.MasterID != masterID || !ok {
+			continue
+		}
+		reviews = append(reviews, models.ReviewWithUser{Review: review, UserName: user.Name})
+	}
+	return reviews, nil
+}
+
+func (s *Store) CreateReview(ctx context.Context, masterID, userID, rating int, comment string) (*models.Review, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	review := models.Review{
+		ID:        s.nextID(),
+		MasterID:  masterID,
+		UserID:    userID,
+		Rating:    rating,
+		Comment:   comment,
+		CreatedAt: time.Now(),
+	}
+	s.reviews[review.ID] = review
+	return &review, nil
+}
+
+func (s *Store) DeleteReview(ctx context.Context, reviewID, masterID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	review, ok := s.reviews[reviewID]
+	if !ok || review.MasterID != masterID {
+		return sql.ErrNoRows
+	}
+	delete(s.reviews, reviewID)
+	return nil
+}
+
+// Favorites
+
+func (s *Store) GetFavoriteMasters(ctx context.Context, userID int) ([]models.Master, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var masters []models.Master
+	all := sortedValues(s.favorites)
+	for i := len(all) - 1; i >= 0; i-- {
+		if all[i].UserID != userID {
+			continue
+		}
+		if master, ok := s.masters[all[i].MasterID]; ok {
+			masters = append(masters, master)
+		}
+	}
+	return masters, nil
+}
+
+func (s *Store) IsFavoriteMaster(ctx context.Context, userID, masterID int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.favoriteID(userID, masterID)
+	return ok, nil
+}
+
+func (s *Store) favoriteID(userID, masterID int) (int, bool) {
+	for id, favorite := range s.favorites {
+		if favorite.UserID == userID && favorite.MasterID == masterID {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+func (s *Store) AddFavoriteMaster(ctx context.Context, userID, masterID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.favoriteID(userID, masterID); ok {
+		return nil
+	}
+	id := s.nextID()
+	s.favorites[id] = models.FavoriteMaster{ID: id, UserID: userID, MasterID: masterID, CreatedAt: time.Now()}
+	return nil
+}
+
+func (s *Store) RemoveFavoriteMaster(ctx context.Context, userID, masterID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id, ok := s.favoriteID(userID, masterID); ok {
+		delete(s.favorites, id)
+	}
+	return nil
+}
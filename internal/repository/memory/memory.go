@@ -0,0 +1,508 @@
+// Package memory is an in-process implementation of repository.Store for handler tests.
+// It keeps the same contracts as the PostgreSQL repository, including sql.ErrNoRows for
+// missing rows, but has no persistence and no cross-method transactions.
+package memory
+
+import (
+	"beep-backend/internal/models"
+	"beep-backend/internal/repository"
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var _ repository.Store = (*Store)(nil)
+
+// Store holds all records in maps guarded by a single mutex
+type Store struct {
+	mu     sync.Mutex
+	lastID int
+
+	categories map[int]models.Category
+	services   map[int]models.Service
+	cars       map[int]models.Car
+	rules      map[int]models.PricingRule
+	zones      map[int]models.PriceZone
+	bundles    map[int]models.BundleDiscount
+	quotes     map[int]models.PriceQuote
+
+	users        map[int]models.User
+	masters      map[int]models.Master
+	schedules    map[int][]models.MasterSchedule
+	exceptions   map[int]models.ScheduleException
+	offers       map[int]models.MasterService
+	works        map[int]models.MasterWork
+	paymentInfo  map[int]models.MasterPaymentInfo
+	certificates map[int]models.MasterCertificate
+	reviews      map[int]models.Review
+	favorites    map[int]models.FavoriteMaster
+	appointments map[int]models.Appointment
+	// appointmentSlots holds appointments.slot
+	appointmentSlots  map[int]models.TimeRange
+	appointmentItems  map[int]models.AppointmentItem
+	appointmentEvents map[int]models.AppointmentEvent
+	reschedules       map[int]models.RescheduleRequest
+	notifications     map[int]models.Notification
+	subscriptions     map[int]models.Subscription
+	userCars          map[int]models.UserCar
+	guarantees        map[int]models.Guarantee
+
+	refreshTokens map[int]models.RefreshToken
+	authTokens    map[int]authToken
+	otpCodes      map[int]models.OTPCode
+}
+
+type authToken struct {
+	userID    int
+	purpose   string
+	tokenHash string
+	expiresAt time.Time
+	usedAt    *time.Time
+}
+
+func New() *Store {
+	s := &Store{
+		categories:        make(map[int]models.Category),
+		services:          make(map[int]models.Service),
+		cars:              make(map[int]models.Car),
+		rules:             make(map[int]models.PricingRule),
+		zones:             make(map[int]models.PriceZone),
+		bundles:           make(map[int]models.BundleDiscount),
+		quotes:            make(map[int]models.PriceQuote),
+		users:             make(map[int]models.User),
+		masters:           make(map[int]models.Master),
+		schedules:         make(map[int][]models.MasterSchedule),
+		exceptions:        make(map[int]models.ScheduleException),
+		offers:            make(map[int]models.MasterService),
+		works:             make(map[int]models.MasterWork),
+		paymentInfo:       make(map[int]models.MasterPaymentInfo),
+		certificates:      make(map[int]models.MasterCertificate),
+		reviews:           make(map[int]models.Review),
+		favorites:         make(map[int]models.FavoriteMaster),
+		appointments:      make(map[int]models.Appointment),
+		appointmentSlots:  make(map[int]models.TimeRange),
+		appointmentItems:  make(map[int]models.AppointmentItem),
+		appointmentEvents: make(map[int]models.AppointmentEvent),
+		reschedules:       make(map[int]models.RescheduleRequest),
+		notifications:     make(map[int]models.Notification),
+		subscriptions:     make(map[int]models.Subscription),
+		userCars:          make(map[int]models.UserCar),
+		guarantees:        make(map[int]models.Guarantee),
+		refreshTokens:     make(map[int]models.RefreshToken),
+		authTokens:        make(map[int]authToken),
+		otpCodes:          make(map[int]models.OTPCode),
+	}
+	s.addDefaultPricingRules()
+	s.addDefaultBundleDiscounts()
+	return s
+}
+
+// addDefaultPricingRules stores the rules migration 0011 seeds
+func (s *Store) addDefaultPricingRules() {
+	age := func(years int) *int { return &years }
+	defaults := []models.PricingRule{
+		{CarAgeMin: age(11), Multiplier: 1.2, Description: "Надбавка за старый автомобиль (+20%)", Position: 10},
+		{CarAgeMax: age(2), Multiplier: 0.9, Description: "Скидка за новый автомобиль (-10%)", Position: 20},
+		{CarType: "Premium", Multiplier: 1.5, Description: "Надбавка за премиум автомобиль (+50%)", Position: 30},
+		{CarType: "Luxury", Multiplier: 2.0, Description: "Надбавка за люксовый автомобиль (+100%)", Position: 40},
+	}
+	for _, rule := range defaults {
+		rule.ID = s.nextID()
+		rule.CreatedAt = time.Now()
+		s.rules[rule.ID] = rule
+	}
+}
+
+// addDefaultBundleDiscounts stores the discounts migration 0013 seeds
+func (s *Store) addDefaultBundleDiscounts() {
+	defaults := []models.BundleDiscount{
+		{MinServices: 2, Percent: 5, Description: "Скидка за 2 услуги (-5%)"},
+		{MinServices: 3, Percent: 10, Description: "Скидка за 3 и более услуги (-10%)"},
+	}
+	for _, discount := range defaults {
+		discount.ID = s.nextID()
+		discount.CreatedAt = time.Now()
+		s.bundles[discount.ID] = discount
+	}
+}
+
+// nextID returns a new ID; IDs are unique across all tables, which is enough for tests
+func (s *Store) nextID() int {
+	s.lastID++
+	return s.lastID
+}
+
+// sortedValues returns the values of m ordered by ID
+func sortedValues[T any](m map[int]T) []T {
+	ids := make([]int, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	values := make([]T, 0, len(ids))
+	for _, id := range ids {
+		values = append(values, m[id])
+	}
+	return values
+}
+
+func dateOnly(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Catalog fixtures. The catalog is read-only through the Store interface, so tests add entries here.
+
+// AddCategory stores a category and returns it with its new ID
+func (s *Store) AddCategory(category models.Category) models.Category {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	category.ID = s.nextID()
+	category.CreatedAt = time.Now()
+	s.categories[category.ID] = category
+	return category
+}
+
+// AddService stores a service and returns it with its new ID
+func (s *Store) AddService(service models.Service) models.Service {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	service.ID = s.nextID()
+	service.CreatedAt = time.Now()
+	s.services[service.ID] = service
+	return service
+}
+
+// AddCar stores a car model and returns it with its new ID
+func (s *Store) AddCar(car models.Car) models.Car {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	car.ID = s.nextID()
+	s.cars[car.ID] = car
+	return car
+}
+
+// Categories
+
+func (s *Store) GetAllCategories(ctx context.Context) ([]models.Category, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var categories []models.Category
+	categories = append(categories, sortedValues(s.categories)...)
+	sort.SliceStable(categories, func(i, j int) bool { return categories[i].Name < categories[j].Name })
+	return categories, nil
+}
+
+func (s *Store) GetCategoryByID(ctx context.Context, id int) (*models.Category, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	category, ok := s.categories[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &category, nil
+}
+
+// Services
+
+func (s *Store) GetAllServices(ctx context.Context) ([]models.Service, error) {
+	return s.findServices(func(models.Service) bool { return true }), nil
+}
+
+func (s *Store) GetServicesByCategory(ctx context.Context, categoryID int) ([]models.Service, error) {
+	return s.findServices(func(service models.Service) bool { return service.CategoryID == categoryID }), nil
+}
+
+func (s *Store) findServices(match func(models.Service) bool) []models.Service {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var services []models.Service
+	for _, service := range sortedValues(s.services) {
+		if match(service) {
+			services = append(services, service)
+		}
+	}
+	sort.SliceStable(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+	return services
+}
+
+func (s *Store) GetServiceByID(ctx context.Context, id int) (*models.Service, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	service, ok := s.services[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &service, nil
+}
+
+// Cars
+
+func (s *Store) GetAllCars(ctx context.Context) ([]models.Car, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var cars []models.Car
+	cars = append(cars, sortedValues(s.cars)...)
+	sort.SliceStable(cars, func(i, j int) bool {
+		if cars[i].Brand != cars[j].Brand {
+			return cars[i].Brand < cars[j].Brand
+		}
+		return cars[i].Model < cars[j].Model
+	})
+	return cars, nil
+}
+
+func (s *Store) GetCarByID(ctx context.Context, id int) (*models.Car, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	car, ok := s.cars[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &car, nil
+}
+
+// Pricing rules and zones
+
+func (s *Store) GetPricingRules(ctx context.Context, serviceID int) ([]models.PricingRule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var rules []models.PricingRule
+	for _, rule := range s.rules {
+		if serviceID == 0 || rule.ServiceID == nil || *rule.ServiceID == serviceID {
+			rules = append(rules, rule)
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].Position != rules[j].Position {
+			return rules[i].Position < rules[j].Position
+		}
+		return rules[i].ID < rules[j].ID
+	})
+	return rules, nil
+}
+
+func (s *Store) GetPricingRule(ctx context.Context, id int) (*models.PricingRule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rule, ok := s.rules[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &rule, nil
+}
+
+func (s *Store) CreatePricingRule(ctx context.Context, rule *models.PricingRule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rule.ID = s.nextID()
+	rule.CreatedAt = time.Now()
+	s.rules[rule.ID] = *rule
+	return nil
+}
+
+func (s *Store) UpdatePricingRule(ctx context.Context, rule *models.PricingRule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.rules[rule.ID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	rule.CreatedAt = existing.CreatedAt
+	s.rules[rule.ID] = *rule
+	return nil
+}
+
+func (s *Store) DeletePricingRule(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.rules[id]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(s.rules, id)
+	return nil
+}
+
+func (s *Store) GetPriceZones(ctx context.Context) ([]models.PriceZone, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	zones := sortedValues(s.zones)
+	sort.SliceStable(zones, func(i, j int) bool { return zones[i].Name < zones[j].Name })
+	return zones, nil
+}
+
+func (s *Store) GetPriceZone(ctx context.Context, id int) (*models.PriceZone, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	zone, ok := s.zones[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &zone, nil
+}
+
+func (s *Store) CreatePriceZone(ctx context.Context, zone *models.PriceZone) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	zone.ID = s.nextID()
+	zone.CreatedAt = time.Now()
+	s.zones[zone.ID] = *zone
+	return nil
+}
+
+func (s *Store) UpdatePriceZone(ctx context.Context, zone *models.PriceZone) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.zones[zone.ID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	zone.CreatedAt = existing.CreatedAt
+	s.zones[zone.ID] = *zone
+	return nil
+}
+
+func (s *Store) DeletePriceZone(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.zones[id]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(s.zones, id)
+	return nil
+}
+
+// Bundle discounts
+
+func (s *Store) GetBundleDiscounts(ctx context.Context) ([]models.BundleDiscount, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	discounts := sortedValues(s.bundles)
+	sort.SliceStable(discounts, func(i, j int) bool { return discounts[i].MinServices < discounts[j].MinServices })
+	return discounts, nil
+}
+
+func (s *Store) CreateBundleDiscount(ctx context.Context, discount *models.BundleDiscount) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	discount.ID = s.nextID()
+	discount.CreatedAt = time.Now()
+	s.bundles[discount.ID] = *discount
+	return nil
+}
+
+func (s *Store) UpdateBundleDiscount(ctx context.Context, discount *models.BundleDiscount) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.bundles[discount.ID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	discount.CreatedAt = existing.CreatedAt
+	s.bundles[discount.ID] = *discount
+	return nil
+}
+
+func (s *Store) DeleteBundleDiscount(ctx context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.bundles[id]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(s.bundles, id)
+	return nil
+}
+
+// Price quotes
+
+func (s *Store) CreatePriceQuote(ctx context.Context, quote *models.PriceQuote) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[quote.UserID]; !ok {
+		return fmt.Errorf("failed to create price quote: user %d does not exist", quote.UserID)
+	}
+	if _, ok := s.cars[quote.CarID]; !ok {
+		return fmt.Errorf("failed to create price quote: car %d does not exist", quote.CarID)
+	}
+	quote.ID = s.nextID()
+	quote.CreatedAt = time.Now()
+	s.quotes[quote.ID] = copyQuote(*quote)
+	return nil
+}
+
+func (s *Store) GetPriceQuote(ctx context.Context, id int) (*models.PriceQuote, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	quote, ok := s.quotes[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	quote = copyQuote(quote)
+	return &quote, nil
+}
+
+func (s *Store) PurgeExpiredPriceQuotes(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	booked := make(map[int]bool)
+	for _, appointment := range s.appointments {
+		if appointment.QuoteID != nil {
+			booked[*appointment.QuoteID] = true
+		}
+	}
+	now := time.Now()
+	var deleted int64
+	for id, quote := range s.quotes {
+		if quote.ExpiresAt.Before(now) && !booked[id] {
+			delete(s.quotes, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// copyQuote copies the slices of a quote, which the database round-trip would do
+func copyQuote(quote models.PriceQuote) models.PriceQuote {
+	quote.Items = append([]models.CalculatePriceResponse(nil), quote.Items...)
+	quote.PriceDetails = append([]models.PriceDetail(nil), quote.PriceDetails...)
+	return quote
+}
+
+// normalizeClock trims a schedule time to HH:MM like the TIME column round-trip does
+func normalizeClock(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) > 5 {
+		return value[:5]
+	}
+	return value
+}
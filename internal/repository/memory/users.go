@@ -0,0 +1,473 @@
+package memory
+
+import (
+	"beep-backend/internal/models"
+	"beep-backend/internal/repository"
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Users
+
+func (s *Store) GetUserByID(ctx context.Context, id int) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &user, nil
+}
+
+func (s *Store) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, user := range sortedValues(s.users) {
+		if email != "" && user.Email == email {
+			return &user, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (s *Store) GetUserByVerifiedPhone(ctx context.Context, phone string) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, user := range sortedValues(s.users) {
+		if user.Phone == phone && user.PhoneVerifiedAt != nil {
+			return &user, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (s *Store) CreateUser(ctx context.Context, name, email, phone, passwordHash string) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkEmailFree(email, 0); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	user := models.User{
+		ID:           s.nextID(),
+		Name:         name,
+		Email:        email,
+		Phone:        phone,
+		PasswordHash: passwordHash,
+		Role:         models.RoleCustomer,
+		TimeZone:     models.DefaultTimeZone,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	s.users[user.ID] = user
+	return &user, nil
+}
+
+func (s *Store) CreatePhoneUser(ctx context.Context, name, phone string) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkVerifiedPhoneFree(phone, 0); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	user := models.User{
+		ID:              s.nextID(),
+		Name:            name,
+		Phone:           phone,
+		PhoneVerifiedAt: &now,
+		Role:            models.RoleCustomer,
+		TimeZone:        models.DefaultTimeZone,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	s.users[user.ID] = user
+	return &user, nil
+}
+
+// checkEmailFree mirrors the unique index on users.email
+func (s *Store) checkEmailFree(email string, exceptUserID int) error {
+	if email == "" {
+		return nil
+	}
+	for _, user := range s.users {
+		if user.ID != exceptUserID && user.Email == email {
+			return fmt.Errorf("duplicate key value violates unique constraint: email %q", email)
+		}
+	}
+	return nil
+}
+
+// checkVerifiedPhoneFree mirrors the unique index on verified users.phone
+func (s *Store) checkVerifiedPhoneFree(phone string, exceptUserID int) error {
+	for _, user := range s.users {
+		if user.ID != exceptUserID && user.Phone == phone && user.PhoneVerifiedAt != nil {
+			return fmt.Errorf("duplicate key value violates unique constraint: verified phone %q", phone)
+		}
+	}
+	return nil
+}
+
+// updateUser applies fn to an existing user; missing users are ignored like an UPDATE matching no rows
+func (s *Store) updateUser(userID int, fn func(user *models.User)) bool {
+	user, ok := s.users[userID]
+	if !ok {
+		return false
+	}
+	fn(&user)
+	user.UpdatedAt = time.Now()
+	s.users[userID] = user
+	return true
+}
+
+func (s *Store) UpdateUserProfile(ctx context.Context, userID int, name, email, phone string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkEmailFree(email, userID); err != nil {
+		return err
+	}
+	s.updateUser(userID, func(user *models.User) {
+		user.Name = name
+		user.Email = email
+		if user.Phone != phone {
+			user.PhoneVerifiedAt = nil
+		}
+		user.Phone = phone
+	})
+	return nil
+}
+
+func (s *Store) UpdateUserRole(ctx context.Context, userID int, role string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.updateUser(userID, func(user *models.User) { user.Role = role }) {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (s *Store) UpdateUserPassword(ctx context.Context, userID int, passwordHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.updateUser(userID, func(user *models.User) { user.PasswordHash = passwordHash })
+	return nil
+}
+
+func (s *Store) UpdateUserPhoto(ctx context.Context, userID int, photoURL string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.updateUser(userID, func(user *models.User) { user.PhotoURL = photoURL })
+	return nil
+}
+
+func (s *Store) UpdateUserTimeZone(ctx context.Context, userID int, timeZone string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.updateUser(userID, func(user *models.User) { user.TimeZone = timeZone })
+	return nil
+}
+
+func (s *Store) MarkEmailVerified(ctx context.Context, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.updateUser(userID, func(user *models.User) {
+		if user.EmailVerifiedAt == nil {
+			now := time.Now()
+			user.EmailVerifiedAt = &now
+		}
+	})
+	return nil
+}
+
+func (s *Store) MarkPhoneVerified(ctx context.Context, userID int, phone string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok || user.Phone != phone {
+		return sql.ErrNoRows
+	}
+	for id, other := range s.users {
+		if id != userID && other.Phone == phone && other.PhoneVerifiedAt != nil {
+			s.updateUser(id, func(user *models.User) { user.PhoneVerifiedAt = nil })
+		}
+	}
+	s.updateUser(userID, func(user *models.User) {
+		now := time.Now()
+		user.PhoneVerifiedAt = &now
+	})
+	return nil
+}
+
+// Refresh Tokens
+
+func (s *Store) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.insertRefreshToken(token)
+	return nil
+}
+
+func (s *Store) insertRefreshToken(token *models.RefreshToken) {
+	token.ID = s.nextID()
+	token.CreatedAt = time.Now()
+	token.RotatedAt = nil
+	token.RevokedAt = nil
+	s.refreshTokens[token.ID] = *token
+}
+
+func (s *Store) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, token := range s.refreshTokens {
+		if token.TokenHash == tokenHash {
+			return &token, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (s *Store) RotateRefreshToken(ctx context.Context, oldTokenID int, next *models.RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, ok := s.refreshTokens[oldTokenID]
+	if !ok || old.RotatedAt != nil || old.RevokedAt != nil {
+		return repository.ErrRefreshTokenReused
+	}
+	now := time.Now()
+	old.RotatedAt = &now
+	s.refreshTokens[oldTokenID] = old
+
+	s.insertRefreshToken(next)
+	return nil
+}
+
+func (s *Store) RevokeRefreshTokenFamily(ctx context.Context, userID int, familyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.revokeRefreshTokens(func(token models.RefreshToken) bool {
+		return token.UserID == userID && token.FamilyID == familyID
+	})
+	return nil
+}
+
+func (s *Store) RevokeUserRefreshTokens(ctx context.Context, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.revokeRefreshTokens(func(token models.RefreshToken) bool { return token.UserID == userID })
+	return nil
+}
+
+func (s *Store) revokeRefreshTokens(match func(models.RefreshToken) bool) {
+	now := time.Now()
+	for id, token := range s.refreshTokens {
+		if token.RevokedAt == nil && match(token) {
+			token.RevokedAt = &now
+			s.refreshTokens[id] = token
+		}
+	}
+}
+
+func (s *Store) GetUserSessions(ctx context.Context, userID int) ([]models.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tokens := sortedValues(s.refreshTokens)
+	startedAt := make(map[string]time.Time)
+	for _, token := range tokens {
+		if token.UserID != userID {
+			continue
+		}
+		if started, ok := startedAt[token.FamilyID]; !ok || token.CreatedAt.Before(started) {
+			startedAt[token.FamilyID] = token.CreatedAt
+		}
+	}
+
+	now := time.Now()
+	sessions := []models.Session{}
+	for i := len(tokens) - 1; i >= 0; i-- {
+		token := tokens[i]
+		if token.UserID != userID || token.RotatedAt != nil || token.RevokedAt != nil || !token.ExpiresAt.After(now) {
+			continue
+		}
+		sessions = append(sessions, models.Session{
+			ID:         token.FamilyID,
+			Device:     token.Device,
+			IPAddress:  token.IPAddress,
+			StartedAt:  startedAt[token.FamilyID],
+			LastUsedAt: token.CreatedAt,
+			ExpiresAt:  token.ExpiresAt,
+		})
+	}
+	return sessions, nil
+}
+
+// Auth Tokens
+
+func (s *Store) CreateAuthToken(ctx context.Context, userID int, purpose, tokenHash string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, token := range s.authTokens {
+		if token.userID == userID && token.purpose == purpose && token.usedAt == nil {
+			token.usedAt = &now
+			s.authTokens[id] = token
+		}
+	}
+
+	s.authTokens[s.nextID()] = authToken{userID: userID, purpose: purpose, tokenHash: tokenHash, expiresAt: expiresAt}
+	return nil
+}
+
+func (s *Store) ConsumeAuthToken(ctx context.Context, purpose, tokenHash string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, token := range s.authTokens {
+		if token.tokenHash == tokenHash && token.purpose == purpose && token.usedAt == nil && token.expiresAt.After(now) {
+			token.usedAt = &now
+			s.authTokens[id] = token
+			return token.userID, nil
+		}
+	}
+	return 0, sql.ErrNoRows
+}
+
+// OTP Codes
+
+func (s *Store) CountRecentOTPCodes(ctx context.Context, phone string, since time.Time) (int, *time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	var lastSentAt *time.Time
+	for _, code := range s.otpCodes {
+		if code.Phone != phone || code.CreatedAt.Before(since) {
+			continue
+		}
+		count++
+		if lastSentAt == nil || code.CreatedAt.After(*lastSentAt) {
+			createdAt := code.CreatedAt
+			lastSentAt = &createdAt
+		}
+	}
+	return count, lastSentAt, nil
+}
+
+func (s *Store) CreateOTPCode(ctx context.Context, phone, codeHash, ipAddress string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, code := range s.otpCodes {
+		if code.Phone == phone && code.ConsumedAt == nil {
+			code.ConsumedAt = &now
+			s.otpCodes[id] = code
+		}
+	}
+
+	id := s.nextID()
+	s.otpCodes[id] = models.OTPCode{ID: id, Phone: phone, CodeHash: codeHash, ExpiresAt: expiresAt, CreatedAt: now}
+	return nil
+}
+
+func (s *Store) GetActiveOTPCode(ctx context.Context, phone string) (*models.OTPCode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	codes := sortedValues(s.otpCodes)
+	for i := len(codes) - 1; i >= 0; i-- {
+		code := codes[i]
+		if code.Phone == phone && code.ConsumedAt == nil && code.ExpiresAt.After(now) {
+			return &code, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (s *Store) RegisterOTPAttempt(ctx context.Context, codeID, maxAttempts int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	code, ok := s.otpCodes[codeID]
+	if !ok || code.Attempts >= maxAttempts || code.ConsumedAt != nil {
+		return 0, sql.ErrNoRows
+	}
+	code.Attempts++
+	s.otpCodes[codeID] = code
+	return code.Attempts, nil
+}
+
+func (s *Store) ConsumeOTPCode(ctx context.Context, codeID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	code, ok := s.otpCodes[codeID]
+	if !ok || code.ConsumedAt != nil {
+		return sql.ErrNoRows
+	}
+	now := time.Now()
+	code.ConsumedAt = &now
+	s.otpCodes[codeID] = code
+	return nil
+}
+
+// Maintenance
+
+func (s *Store) PurgeExpiredAuthData(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var deleted int64
+
+	familyExpiry := make(map[string]time.Time)
+	for _, token := range s.refreshTokens {
+		if token.ExpiresAt.After(familyExpiry[token.FamilyID]) {
+			familyExpiry[token.FamilyID] = token.ExpiresAt
+		}
+	}
+	for id, token := range s.refreshTokens {
+		if familyExpiry[token.FamilyID].Before(now) {
+			delete(s.refreshTokens, id)
+			deleted++
+		}
+	}
+
+	for id, token := range s.authTokens {
+		if token.expiresAt.Before(now) || token.usedAt != nil {
+			delete(s.authTokens, id)
+			deleted++
+		}
+	}
+
+	for id, code := range s.otpCodes {
+		if code.CreatedAt.Before(now.Add(-24 * time.Hour)) {
+			delete(s.otpCodes, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -2,13 +2,19 @@ package repository
 
 import (
 	"beep-backend/internal/models"
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"sort"
 	"strings"
 	"time"
+
+	"github.com/lib/pq"
 )
 
+// Repository is the PostgreSQL implementation of Store
 type Repository struct {
 	db *sql.DB
 }
@@ -18,8 +24,8 @@ func New(db *sql.DB) *Repository {
 }
 
 // Categories
-func (r *Repository) GetAllCategories() ([]models.Category, error) {
-	rows, err := r.db.Query("SELECT id, name, description, created_at FROM categories ORDER BY name")
+func (r *Repository) GetAllCategories(ctx context.Context) ([]models.Category, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT id, name, description, created_at FROM categories ORDER BY name")
 	if err != nil {
 		return nil, err
 	}
@@ -40,10 +46,10 @@ func (r *Repository) GetAllCategories() ([]models.Category, error) {
 	return categories, nil
 }
 
-func (r *Repository) GetCategoryByID(id int) (*models.Category, error) {
+func (r *Repository) GetCategoryByID(ctx context.Context, id int) (*models.Category, error) {
 	var cat models.Category
 	var description sql.NullString
-	err := r.db.QueryRow("SELECT id, name, description, created_at FROM categories WHERE id = $1", id).
+	err := r.db.QueryRowContext(ctx, "SELECT id, name, description, created_at FROM categories WHERE id = $1", id).
 		Scan(&cat.ID, &cat.Name, &description, &cat.CreatedAt)
 	if err != nil {
 		return nil, err
@@ -55,8 +61,8 @@ func (r *Repository) GetCategoryByID(id int) (*models.Category, error) {
 }
 
 // Services
-func (r *Repository) GetAllServices() ([]models.Service, error) {
-	rows, err := r.db.Query("SELECT id, category_id, name, description, base_price, min_price, max_price, duration_minutes, created_at FROM services ORDER BY name")
+func (r *Repository) GetAllServices(ctx context.Context) ([]models.Service, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT id, category_id, name, description, base_price, min_price, max_price, duration_minutes, created_at FROM services ORDER BY name")
 	if err != nil {
 		return nil, err
 	}
@@ -77,8 +83,8 @@ func (r *Repository) GetAllServices() ([]models.Service, error) {
 	return services, nil
 }
 
-func (r *Repository) GetServicesByCategory(categoryID int) ([]models.Service, error) {
-	rows, err := r.db.Query("SELECT id, category_id, name, description, base_price, min_price, max_price, duration_minutes, created_at FROM services WHERE category_id = $1 ORDER BY name", categoryID)
+func (r *Repository) GetServicesByCategory(ctx context.Context, categoryID int) ([]models.Service, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT id, category_id, name, description, base_price, min_price, max_price, duration_minutes, created_at FROM services WHERE category_id = $1 ORDER BY name", categoryID)
 	if err != nil {
 		return nil, err
 	}
@@ -99,10 +105,10 @@ func (r *Repository) GetServicesByCategory(categoryID int) ([]models.Service, er
 	return services, nil
 }
 
-func (r *Repository) GetServiceByID(id int) (*models.Service, error) {
+func (r *Repository) GetServiceByID(ctx context.Context, id int) (*models.Service, error) {
 	var srv models.Service
 	var description sql.NullString
-	err := r.db.QueryRow("SELECT id, category_id, name, description, base_price, min_price, max_price, duration_minutes, created_at FROM services WHERE id = $1", id).
+	err := r.db.QueryRowContext(ctx, "SELECT id, category_id, name, description, base_price, min_price, max_price, duration_minutes, created_at FROM services WHERE id = $1", id).
 		Scan(&srv.ID, &srv.CategoryID, &srv.Name, &description, &srv.BasePrice, &srv.MinPrice, &srv.MaxPrice, &srv.DurationMinutes, &srv.CreatedAt)
 	if err != nil {
 		return nil, err
@@ -114,8 +120,8 @@ func (r *Repository) GetServiceByID(id int) (*models.Service, error) {
 }
 
 // Cars
-func (r *Repository) GetAllCars() ([]models.Car, error) {
-	rows, err := r.db.Query("SELECT id, brand, model, year, type FROM cars ORDER BY brand, model")
+func (r *Repository) GetAllCars(ctx context.Context) ([]models.Car, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT id, brand, model, year, type FROM cars ORDER BY brand, model")
 	if err != nil {
 		return nil, err
 	}
@@ -132,9 +138,9 @@ func (r *Repository) GetAllCars() ([]models.Car, error) {
 	return cars, nil
 }
 
-func (r *Repository) GetCarByID(id int) (*models.Car, error) {
+func (r *Repository) GetCarByID(ctx context.Context, id int) (*models.Car, error) {
 	var car models.Car
-	err := r.db.QueryRow("SELECT id, brand, model, year, type FROM cars WHERE id = $1", id).
+	err := r.db.QueryRowContext(ctx, "SELECT id, brand, model, year, type FROM cars WHERE id = $1", id).
 		Scan(&car.ID, &car.Brand, &car.Model, &car.Year, &car.Type)
 	if err != nil {
 		return nil, err
@@ -142,113 +148,312 @@ func (r *Repository) GetCarByID(id int) (*models.Car, error) {
 	return &car, nil
 }
 
-// Pricing calculation
-func (r *Repository) CalculatePrice(serviceID, carID int) (*models.CalculatePriceResponse, error) {
-	var service models.Service
-	err := r.db.QueryRow("SELECT id, name, base_price, min_price, max_price FROM services WHERE id = $1", serviceID).
-		Scan(&service.ID, &service.Name, &service.BasePrice, &service.MinPrice, &service.MaxPrice)
+// Pricing rules and zones
+
+// GetPricingRules returns the rules that can apply to the service, including those for any service,
+// in the order they apply. serviceID 0 returns all rules.
+func (r *Repository) GetPricingRules(ctx context.Context, serviceID int) ([]models.PricingRule, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+pricingRuleColumns+`
+		FROM pricing_rules
+		WHERE $1 = 0 OR service_id = $1 OR service_id IS NULL
+		ORDER BY position, id
+	`, serviceID)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	var car models.Car
-	err = r.db.QueryRow("SELECT id, brand, model, year, type FROM cars WHERE id = $1", carID).
-		Scan(&car.ID, &car.Brand, &car.Model, &car.Year, &car.Type)
+	var rules []models.PricingRule
+	for rows.Next() {
+		rule, err := scanPricingRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, *rule)
+	}
+	return rules, rows.Err()
+}
+
+func (r *Repository) GetPricingRule(ctx context.Context, id int) (*models.PricingRule, error) {
+	row := r.db.QueryRowContext(ctx, "SELECT "+pricingRuleColumns+" FROM pricing_rules WHERE id = $1", id)
+	return scanPricingRule(row)
+}
+
+func (r *Repository) CreatePricingRule(ctx context.Context, rule *models.PricingRule) error {
+	row := r.db.QueryRowContext(ctx, `
+		INSERT INTO pricing_rules (service_id, car_type, car_age_min, car_age_max, multiplier, fixed_addition, description, position, created_at)
+		VALUES ($1, NULLIF($2, ''), $3, $4, $5, $6, $7, $8, NOW())
+		RETURNING `+pricingRuleColumns,
+		rule.ServiceID, rule.CarType, rule.CarAgeMin, rule.CarAgeMax, rule.Multiplier, rule.FixedAddition, rule.Description, rule.Position)
+	created, err := scanPricingRule(row)
+	if err != nil {
+		return err
+	}
+	*rule = *created
+	return nil
+}
+
+// UpdatePricingRule returns sql.ErrNoRows if the rule does not exist
+func (r *Repository) UpdatePricingRule(ctx context.Context, rule *models.PricingRule) error {
+	row := r.db.QueryRowContext(ctx, `
+		UPDATE pricing_rules
+		SET service_id = $2, car_type = NULLIF($3, ''), car_age_min = $4, car_age_max = $5,
+			multiplier = $6, fixed_addition = $7, description = $8, position = $9
+		WHERE id = $1
+		RETURNING `+pricingRuleColumns,
+		rule.ID, rule.ServiceID, rule.CarType, rule.CarAgeMin, rule.CarAgeMax, rule.Multiplier, rule.FixedAddition, rule.Description, rule.Position)
+	updated, err := scanPricingRule(row)
+	if err != nil {
+		return err
+	}
+	*rule = *updated
+	return nil
+}
+
+// DeletePricingRule returns sql.ErrNoRows if the rule does not exist
+func (r *Repository) DeletePricingRule(ctx context.Context, id int) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM pricing_rules WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+const pricingRuleColumns = `id, service_id, COALESCE(car_type, ''), car_age_min, car_age_max,
+	multiplier, fixed_addition, description, position, created_at`
+
+func scanPricingRule(row rowScanner) (*models.PricingRule, error) {
+	var rule models.PricingRule
+	var serviceID, ageMin, ageMax sql.NullInt64
+	err := row.Scan(&rule.ID, &serviceID, &rule.CarType, &ageMin, &ageMax,
+		&rule.Multiplier, &rule.FixedAddition, &rule.Description, &rule.Position, &rule.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
+	rule.ServiceID = nullableInt(serviceID)
+	rule.CarAgeMin = nullableInt(ageMin)
+	rule.CarAgeMax = nullableInt(ageMax)
+	return &rule, nil
+}
 
-	// Calculate car age
-	carAge := 2024 - car.Year
-	price := service.BasePrice
+func nullableInt(value sql.NullInt64) *int {
+	if !value.Valid {
+		return nil
+	}
+	v := int(value.Int64)
+	return &v
+}
 
-	// Create price details array
-	var priceDetails []models.PriceDetail
+func nullableFloat(value sql.NullFloat64) *float64 {
+	if !value.Valid {
+		return nil
+	}
+	return &value.Float64
+}
 
-	// Add base price detail
-	priceDetails = append(priceDetails, models.PriceDetail{
-		Description: "Базовая цена услуги",
-		Amount:      service.BasePrice,
-		IsAddition:  false,
-	})
+func (r *Repository) GetPriceZones(ctx context.Context) ([]models.PriceZone, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT id, name, multiplier, created_at FROM price_zones ORDER BY name, id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-	// Apply age-based multipliers
-	if carAge > 10 {
-		oldCarMultiplier := 1.2
-		oldCarAmount := service.BasePrice * (oldCarMultiplier - 1)
-		price *= oldCarMultiplier
-		priceDetails = append(priceDetails, models.PriceDetail{
-			Description: "Надбавка за старый автомобиль (+20%)",
-			Amount:      oldCarAmount,
-			Multiplier:  oldCarMultiplier,
-			IsAddition:  true,
-		})
-	} else if carAge < 3 {
-		newCarMultiplier := 0.9
-		newCarDiscount := service.BasePrice * (1 - newCarMultiplier)
-		price *= newCarMultiplier
-		priceDetails = append(priceDetails, models.PriceDetail{
-			Description: "Скидка за новый автомобиль (-10%)",
-			Amount:      -newCarDiscount,
-			Multiplier:  newCarMultiplier,
-			IsAddition:  true,
-		})
-	}
-
-	// Apply car type multipliers
-	if car.Type == "Premium" {
-		premiumMultiplier := 1.5
-		premiumAmount := service.BasePrice * (premiumMultiplier - 1)
-		price *= premiumMultiplier
-		priceDetails = append(priceDetails, models.PriceDetail{
-			Description: "Надбавка за премиум автомобиль (+50%)",
-			Amount:      premiumAmount,
-			Multiplier:  premiumMultiplier,
-			IsAddition:  true,
-		})
-	} else if car.Type == "Luxury" {
-		luxuryMultiplier := 2.0
-		luxuryAmount := service.BasePrice * (luxuryMultiplier - 1)
-		price *= luxuryMultiplier
-		priceDetails = append(priceDetails, models.PriceDetail{
-			Description: "Надбавка за люксовый автомобиль (+100%)",
-			Amount:      luxuryAmount,
-			Multiplier:  luxuryMultiplier,
-			IsAddition:  true,
-		})
-	}
-
-	response := &models.CalculatePriceResponse{
-		ServiceID:    service.ID,
-		ServiceName:  service.Name,
-		CarBrand:     car.Brand,
-		CarModel:     car.Model,
-		CarYear:      car.Year,
-		CarType:      car.Type,
-		CarAge:       carAge,
-		BasePrice:    service.BasePrice,
-		FinalPrice:   price,
-		MinPrice:     service.MinPrice,
-		MaxPrice:     service.MaxPrice,
-		PriceDetails: priceDetails,
-	}
-
-	return response, nil
+	var zones []models.PriceZone
+	for rows.Next() {
+		var zone models.PriceZone
+		if err := rows.Scan(&zone.ID, &zone.Name, &zone.Multiplier, &zone.CreatedAt); err != nil {
+			return nil, err
+		}
+		zones = append(zones, zone)
+	}
+	return zones, rows.Err()
+}
+
+func (r *Repository) GetPriceZone(ctx context.Context, id int) (*models.PriceZone, error) {
+	var zone models.PriceZone
+	err := r.db.QueryRowContext(ctx, "SELECT id, name, multiplier, created_at FROM price_zones WHERE id = $1", id).
+		Scan(&zone.ID, &zone.Name, &zone.Multiplier, &zone.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &zone, nil
+}
+
+func (r *Repository) CreatePriceZone(ctx context.Context, zone *models.PriceZone) error {
+	return r.db.QueryRowContext(ctx, `
+		INSERT INTO price_zones (name, multiplier, created_at)
+		VALUES ($1, $2, NOW())
+		RETURNING id, created_at
+	`, zone.Name, zone.Multiplier).Scan(&zone.ID, &zone.CreatedAt)
+}
+
+// UpdatePriceZone returns sql.ErrNoRows if the zone does not exist
+func (r *Repository) UpdatePriceZone(ctx context.Context, zone *models.PriceZone) error {
+	return r.db.QueryRowContext(ctx, `
+		UPDATE price_zones SET name = $2, multiplier = $3
+		WHERE id = $1
+		RETURNING created_at
+	`, zone.ID, zone.Name, zone.Multiplier).Scan(&zone.CreatedAt)
+}
+
+// DeletePriceZone returns sql.ErrNoRows if the zone does not exist
+func (r *Repository) DeletePriceZone(ctx context.Context, id int) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM price_zones WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetBundleDiscounts lists the bundle discounts from the smallest basket up
+func (r *Repository) GetBundleDiscounts(ctx context.Context) ([]models.BundleDiscount, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT id, min_services, percent, description, created_at FROM bundle_discounts ORDER BY min_services, id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var discounts []models.BundleDiscount
+	for rows.Next() {
+		var discount models.BundleDiscount
+		if err := rows.Scan(&discount.ID, &discount.MinServices, &discount.Percent, &discount.Description, &discount.CreatedAt); err != nil {
+			return nil, err
+		}
+		discounts = append(discounts, discount)
+	}
+	return discounts, rows.Err()
+}
+
+func (r *Repository) CreateBundleDiscount(ctx context.Context, discount *models.BundleDiscount) error {
+	return r.db.QueryRowContext(ctx, `
+		INSERT INTO bundle_discounts (min_services, percent, description, created_at)
+		VALUES ($1, $2, $3, NOW())
+		RETURNING id, created_at
+	`, discount.MinServices, discount.Percent, discount.Description).Scan(&discount.ID, &discount.CreatedAt)
+}
+
+// UpdateBundleDiscount returns sql.ErrNoRows if the discount does not exist
+func (r *Repository) UpdateBundleDiscount(ctx context.Context, discount *models.BundleDiscount) error {
+	return r.db.QueryRowContext(ctx, `
+		UPDATE bundle_discounts SET min_services = $2, percent = $3, description = $4
+		WHERE id = $1
+		RETURNING created_at
+	`, discount.ID, discount.MinServices, discount.Percent, discount.Description).Scan(&discount.CreatedAt)
+}
+
+// DeleteBundleDiscount returns sql.ErrNoRows if the discount does not exist
+func (r *Repository) DeleteBundleDiscount(ctx context.Context, id int) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM bundle_discounts WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// CreatePriceQuote stores a quote with its items and breakdown; quote.ExpiresAt must be set
+func (r *Repository) CreatePriceQuote(ctx context.Context, quote *models.PriceQuote) error {
+	items, err := json.Marshal(quote.Items)
+	if err != nil {
+		return fmt.Errorf("failed to encode quote items: %w", err)
+	}
+	details, err := json.Marshal(quote.PriceDetails)
+	if err != nil {
+		return fmt.Errorf("failed to encode quote details: %w", err)
+	}
+	var zoneID *int
+	if quote.Zone != nil {
+		zoneID = &quote.Zone.ID
+	}
+	var masterID, userCarID *int
+	if quote.MasterID != 0 {
+		masterID = &quote.MasterID
+	}
+	if quote.UserCarID != 0 {
+		userCarID = &quote.UserCarID
+	}
+	return r.db.QueryRowContext(ctx, `
+		INSERT INTO price_quotes (user_id, car_id, user_car_id, master_id, zone_id, items, price_details, subtotal, discount, total, duration_minutes, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, NOW())
+		RETURNING id, created_at
+	`, quote.UserID, quote.CarID, userCarID, masterID, zoneID, string(items), string(details), quote.Subtotal, quote.Discount, quote.Total, quote.DurationMinutes, quote.ExpiresAt).
+		Scan(&quote.ID, &quote.CreatedAt)
+}
+
+// GetPriceQuote returns a stored quote, expired or not
+func (r *Repository) GetPriceQuote(ctx context.Context, id int) (*models.PriceQuote, error) {
+	var quote models.PriceQuote
+	var userCarID, masterID, zoneID sql.NullInt64
+	var zoneName sql.NullString
+	var zoneMultiplier sql.NullFloat64
+	var zoneCreatedAt sql.NullTime
+	var items, details []byte
+	err := r.db.QueryRowContext(ctx, `
+		SELECT q.id, q.user_id, q.car_id, q.user_car_id, q.master_id, z.id, z.name, z.multiplier, z.created_at,
+		       q.items, q.price_details, q.subtotal, q.discount, q.total, q.duration_minutes, q.expires_at, q.created_at
+		FROM price_quotes q
+		LEFT JOIN price_zones z ON z.id = q.zone_id
+		WHERE q.id = $1
+	`, id).Scan(&quote.ID, &quote.UserID, &quote.CarID, &userCarID, &masterID, &zoneID, &zoneName, &zoneMultiplier, &zoneCreatedAt,
+		&items, &details, &quote.Subtotal, &quote.Discount, &quote.Total, &quote.DurationMinutes, &quote.ExpiresAt, &quote.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	quote.UserCarID = int(userCarID.Int64)
+	quote.MasterID = int(masterID.Int64)
+	if zoneID.Valid {
+		quote.Zone = &models.PriceZone{ID: int(zoneID.Int64), Name: zoneName.String, Multiplier: zoneMultiplier.Float64, CreatedAt: zoneCreatedAt.Time}
+	}
+	if err := json.Unmarshal(items, &quote.Items); err != nil {
+		return nil, fmt.Errorf("failed to decode quote items: %w", err)
+	}
+	if err := json.Unmarshal(details, &quote.PriceDetails); err != nil {
+		return nil, fmt.Errorf("failed to decode quote details: %w", err)
+	}
+	return &quote, nil
+}
+
+// PurgeExpiredPriceQuotes deletes expired quotes that no appointment was booked from
+func (r *Repository) PurgeExpiredPriceQuotes(ctx context.Context) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `
+		DELETE FROM price_quotes q
+		WHERE q.expires_at < NOW()
+		  AND NOT EXISTS (SELECT 1 FROM appointments a WHERE a.quote_id = q.id)
+	`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
 // Update user profile
-func (r *Repository) UpdateUserProfile(userID int, name, email, phone string) error {
-	_, err := r.db.Exec("UPDATE users SET name = $1, email = $2, phone = $3, updated_at = NOW() WHERE id = $4",
-		name, email, phone, userID)
+func (r *Repository) UpdateUserProfile(ctx context.Context, userID int, name, email, phone string) error {
+	// Phone-only users have no email; store NULL rather than '' to keep the unique index usable.
+	// A new phone has to be verified again before it can be used to log in.
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE users SET name = $1, email = NULLIF($2, ''), phone = $3,
+			phone_verified_at = CASE WHEN phone IS NOT DISTINCT FROM $3 THEN phone_verified_at END,
+			updated_at = NOW()
+		WHERE id = $4
+	`, name, email, phone, userID)
 	return err
 }
 
 // Masters
-func (r *Repository) GetAllMasters() ([]models.Master, error) {
+func (r *Repository) GetAllMasters(ctx context.Context) ([]models.Master, error) {
 	// Select all masters and deduplicate by id and email
-	rows, err := r.db.Query(`
+	rows, err := r.db.QueryContext(ctx, `
 		SELECT id, user_id, name, email, phone, specialization, rating, photo_url, 
-			location_lat, location_lng, address, created_at, updated_at 
+			location_lat, location_lng, address, time_zone, created_at, updated_at 
 		FROM masters 
 		ORDER BY id ASC
 	`)
@@ -273,7 +478,7 @@ func (r *Repository) GetAllMasters() ([]models.Master, error) {
 		var userID sql.NullInt64
 		if err := rows.Scan(&master.ID, &userID, &master.Name, &master.Email, &master.Phone,
 			&specialization, &rating, &photoURL, &locationLat, &locationLng,
-			&address, &master.CreatedAt, &master.UpdatedAt); err != nil {
+			&address, &master.TimeZone, &master.CreatedAt, &master.UpdatedAt); err != nil {
 			return nil, err
 		}
 		if userID.Valid {
@@ -394,19 +599,23 @@ func (r *Repository) GetAllMasters() ([]models.Master, error) {
 	return masters, nil
 }
 
-func (r *Repository) GetMasterByID(id int) (*models.Master, error) {
+func (r *Repository) GetMasterByID(ctx context.Context, id int) (*models.Master, error) {
 	var master models.Master
 	var specialization, photoURL, address sql.NullString
 	var rating sql.NullFloat64
 	var locationLat, locationLng sql.NullFloat64
 
-	err := r.db.QueryRow("SELECT id, name, email, phone, specialization, rating, photo_url, location_lat, location_lng, address, created_at, updated_at FROM masters WHERE id = $1", id).
-		Scan(&master.ID, &master.Name, &master.Email, &master.Phone,
+	var userID sql.NullInt64
+	err := r.db.QueryRowContext(ctx, "SELECT id, user_id, name, email, phone, specialization, rating, photo_url, location_lat, location_lng, address, time_zone, created_at, updated_at FROM masters WHERE id = $1", id).
+		Scan(&master.ID, &userID, &master.Name, &master.Email, &master.Phone,
 			&specialization, &rating, &photoURL, &locationLat, &locationLng,
-			&address, &master.CreatedAt, &master.UpdatedAt)
+			&address, &master.TimeZone, &master.CreatedAt, &master.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
+	if userID.Valid {
+		master.UserID = int(userID.Int64)
+	}
 
 	// Handle nullable fields
 	if specialization.Valid {
@@ -431,8 +640,8 @@ func (r *Repository) GetMasterByID(id int) (*models.Master, error) {
 	return &master, nil
 }
 
-func (r *Repository) GetMasterSchedule(masterID int) ([]models.MasterSchedule, error) {
-	rows, err := r.db.Query("SELECT id, master_id, day_of_week, start_time, end_time, is_active, created_at FROM master_schedule WHERE master_id = $1 ORDER BY day_of_week ASC", masterID)
+func (r *Repository) GetMasterSchedule(ctx context.Context, masterID int) ([]models.MasterSchedule, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT id, master_id, day_of_week, start_time, end_time, is_active, created_at FROM master_schedule WHERE master_id = $1 ORDER BY day_of_week ASC, start_time ASC", masterID)
 	if err != nil {
 		return nil, err
 	}
@@ -457,9 +666,9 @@ func (r *Repository) GetMasterSchedule(masterID int) ([]models.MasterSchedule, e
 }
 
 // UpdateMasterSchedule updates or creates schedule entries for a master
-func (r *Repository) UpdateMasterSchedule(masterID int, schedules []models.MasterSchedule) error {
+func (r *Repository) UpdateMasterSchedule(ctx context.Context, masterID int, schedules []models.MasterSchedule) error {
 	// Delete existing schedules for this master
-	_, err := r.db.Exec("DELETE FROM master_schedule WHERE master_id = $1", masterID)
+	_, err := r.db.ExecContext(ctx, "DELETE FROM master_schedule WHERE master_id = $1", masterID)
 	if err != nil {
 		return err
 	}
@@ -469,7 +678,7 @@ func (r *Repository) UpdateMasterSchedule(masterID int, schedules []models.Maste
 		startTime := strings.TrimSpace(schedule.StartTime)
 		endTime := strings.TrimSpace(schedule.EndTime)
 
-		_, err := r.db.Exec(`
+		_, err := r.db.ExecContext(ctx, `
 			INSERT INTO master_schedule (master_id, day_of_week, start_time, end_time, is_active, created_at)
 			VALUES ($1, $2, $3, $4, $5, NOW())
 		`, masterID, schedule.DayOfWeek, startTime, endTime, schedule.IsActive)
@@ -481,97 +690,521 @@ func (r *Repository) UpdateMasterSchedule(masterID int, schedules []models.Maste
 	return nil
 }
 
-// Get available time slots for a master on a specific date
-func (r *Repository) GetAvailableSlots(masterID int, date time.Time) ([]string, error) {
-	// Get master's schedule for that day of week
-	dayOfWeek := int(date.Weekday())
-	dayOfWeek = (dayOfWeek + 6) % 7 // PostgreSQL uses 0=Monday, 6=Sunday
+// GetScheduleExceptions returns the master's exceptions that cover any date from..to
+func (r *Repository) GetScheduleExceptions(ctx context.Context, masterID int, from, to time.Time) ([]models.ScheduleException, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+scheduleExceptionColumns+`
+		FROM master_schedule_exceptions
+		WHERE master_id = $1 AND start_date <= $3 AND end_date >= $2
+		ORDER BY start_date, start_time NULLS FIRST, id
+	`, masterID, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-	var startTimeDB, endTimeDB time.Time
-	err := r.db.QueryRow("SELECT start_time, end_time FROM master_schedule WHERE master_id = $1 AND day_of_week = $2 AND is_active = true", masterID, dayOfWeek).
-		Scan(&startTimeDB, &endTimeDB)
+	var exceptions []models.ScheduleException
+	for rows.Next() {
+		exception, err := scanScheduleException(rows)
+		if err != nil {
+			return nil, err
+		}
+		exceptions = append(exceptions, *exception)
+	}
+	return exceptions, rows.Err()
+}
 
-	// Default schedule if not found
-	var startTime, endTime string
-	if err == sql.ErrNoRows {
-		// No schedule found for this day, use default 08:00 - 19:00
-		startTime = "08:00"
-		endTime = "19:00"
-	} else if err != nil {
-		return nil, err
-	} else {
-		// Format time to HH:MM
-		startTime = startTimeDB.Format("15:04:05")[:5]
-		endTime = endTimeDB.Format("15:04:05")[:5]
+func (r *Repository) GetScheduleException(ctx context.Context, exceptionID, masterID int) (*models.ScheduleException, error) {
+	row := r.db.QueryRowContext(ctx, "SELECT "+scheduleExceptionColumns+" FROM master_schedule_exceptions WHERE id = $1 AND master_id = $2", exceptionID, masterID)
+	return scanScheduleException(row)
+}
+
+func (r *Repository) CreateScheduleException(ctx context.Context, exception *models.ScheduleException) error {
+	row := r.db.QueryRowContext(ctx, `
+		INSERT INTO master_schedule_exceptions (master_id, type, start_date, end_date, start_time, end_time, reason, created_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, '')::time, NULLIF($6, '')::time, $7, NOW())
+		RETURNING `+scheduleExceptionColumns,
+		exception.MasterID, exception.Type, exception.StartDate.Format("2006-01-02"), exception.EndDate.Format("2006-01-02"),
+		exception.StartTime, exception.EndTime, exception.Reason)
+	created, err := scanScheduleException(row)
+	if err != nil {
+		return err
 	}
+	*exception = *created
+	return nil
+}
 
-	// Get existing appointments for that date
-	existingAppointments, err := r.db.Query("SELECT time FROM appointments WHERE master_id = $1 AND date = $2 AND status != 'cancelled'", masterID, date.Format("2006-01-02"))
-	if err != nil && err != sql.ErrNoRows {
-		return nil, err
+// UpdateScheduleException returns sql.ErrNoRows if the exception does not belong to the master
+func (r *Repository) UpdateScheduleException(ctx context.Context, exception *models.ScheduleException) error {
+	row := r.db.QueryRowContext(ctx, `
+		UPDATE master_schedule_exceptions
+		SET type = $3, start_date = $4, end_date = $5, start_time = NULLIF($6, '')::time, end_time = NULLIF($7, '')::time, reason = $8
+		WHERE id = $1 AND master_id = $2
+		RETURNING `+scheduleExceptionColumns,
+		exception.ID, exception.MasterID, exception.Type, exception.StartDate.Format("2006-01-02"), exception.EndDate.Format("2006-01-02"),
+		exception.StartTime, exception.EndTime, exception.Reason)
+	updated, err := scanScheduleException(row)
+	if err != nil {
+		return err
 	}
+	*exception = *updated
+	return nil
+}
 
-	bookedTimes := make(map[string]bool)
-	if err == nil {
-		for existingAppointments.Next() {
-			var timeStr string
-			if err := existingAppointments.Scan(&timeStr); err != nil {
-				return nil, err
-			}
-			bookedTimes[timeStr] = true
-		}
+// DeleteScheduleException returns sql.ErrNoRows if the exception does not belong to the master
+func (r *Repository) DeleteScheduleException(ctx context.Context, exceptionID, masterID int) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM master_schedule_exceptions WHERE id = $1 AND master_id = $2", exceptionID, masterID)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return sql.ErrNoRows
 	}
+	return nil
+}
 
-	// Parse start and end times
-	startParts := strings.Split(startTime, ":")
-	endParts := strings.Split(endTime, ":")
-	if len(startParts) < 2 || len(endParts) < 2 {
-		return []string{}, nil
+const scheduleExceptionColumns = `id, master_id, type, start_date, end_date,
+	COALESCE(to_char(start_time, 'HH24:MI'), ''), COALESCE(to_char(end_time, 'HH24:MI'), ''), reason, created_at`
+
+// rowScanner is either *sql.Row or *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanScheduleException(row rowScanner) (*models.ScheduleException, error) {
+	var e models.ScheduleException
+	err := row.Scan(&e.ID, &e.MasterID, &e.Type, &e.StartDate, &e.EndDate, &e.StartTime, &e.EndTime, &e.Reason, &e.CreatedAt)
+	if err != nil {
+		return nil, err
 	}
+	return &e, nil
+}
 
-	var startHour, startMin int
-	var endHour, endMin int
-	fmt.Sscanf(startTime, "%d:%d", &startHour, &startMin)
-	fmt.Sscanf(endTime, "%d:%d", &endHour, &endMin)
+// Master price lists
 
-	// Generate available time slots (every hour from start to end)
-	slots := []string{}
+const masterServiceColumns = `ms.id, ms.master_id, ms.service_id, COALESCE(s.category_id, 0), s.name,
+	ms.base_price, ms.duration_minutes, ms.is_available, ms.created_at, ms.updated_at`
 
-	// Calculate total minutes from start to end
-	startTotalMinutes := startHour*60 + startMin
-	endTotalMinutes := endHour*60 + endMin
+// GetMasterServices returns the services the master offers, available or not
+func (r *Repository) GetMasterServices(ctx context.Context, masterID int) ([]models.MasterService, error) {
+	return r.queryMasterServices(ctx, `
+		SELECT `+masterServiceColumns+`
+		FROM master_services ms
+		JOIN services s ON s.id = ms.service_id
+		WHERE ms.master_id = $1
+		ORDER BY s.category_id, s.name, ms.id
+	`, masterID)
+}
+
+func (r *Repository) GetMasterService(ctx context.Context, masterID, serviceID int) (*models.MasterService, error) {
+	row := r.db.QueryRowContext(ctx, `
+		SELECT `+masterServiceColumns+`
+		FROM master_services ms
+		JOIN services s ON s.id = ms.service_id
+		WHERE ms.master_id = $1 AND ms.service_id = $2
+	`, masterID, serviceID)
+	return scanMasterService(row)
+}
+
+// GetServiceOffers returns the offers of the service by masters who have it available, cheapest first
+func (r *Repository) GetServiceOffers(ctx context.Context, serviceID int) ([]models.MasterService, error) {
+	return r.queryMasterServices(ctx, `
+		SELECT `+masterServiceColumns+`
+		FROM master_services ms
+		JOIN services s ON s.id = ms.service_id
+		WHERE ms.service_id = $1 AND ms.is_available
+		ORDER BY ms.base_price, ms.master_id
+	`, serviceID)
+}
+
+// SaveMasterService adds the service to the master's price list or updates the master's offer of it
+func (r *Repository) SaveMasterService(ctx context.Context, offer *models.MasterService) error {
+	row := r.db.QueryRowContext(ctx, `
+		WITH saved AS (
+			INSERT INTO master_services (master_id, service_id, base_price, duration_minutes, is_available, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
+			ON CONFLICT (master_id, service_id) DO UPDATE
+			SET base_price = EXCLUDED.base_price, duration_minutes = EXCLUDED.duration_minutes,
+				is_available = EXCLUDED.is_available, updated_at = NOW()
+			RETURNING *
+		)
+		SELECT `+masterServiceColumns+`
+		FROM saved ms
+		JOIN services s ON s.id = ms.service_id
+	`, offer.MasterID, offer.ServiceID, offer.BasePrice, offer.DurationMinutes, offer.IsAvailable)
+	saved, err := scanMasterService(row)
+	if err != nil {
+		return err
+	}
+	*offer = *saved
+	return nil
+}
+
+// DeleteMasterService returns sql.ErrNoRows if the master does not offer the service
+func (r *Repository) DeleteMasterService(ctx context.Context, masterID, serviceID int) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM master_services WHERE master_id = $1 AND service_id = $2", masterID, serviceID)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *Repository) queryMasterServices(ctx context.Context, query string, args ...interface{}) ([]models.MasterService, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-	for currentMin := startTotalMinutes; currentMin < endTotalMinutes; currentMin += 60 {
-		hour := currentMin / 60
-		min := currentMin % 60
-		slot := fmt.Sprintf("%02d:%02d", hour, min)
-		if !bookedTimes[slot] {
-			slots = append(slots, slot)
+	var offers []models.MasterService
+	for rows.Next() {
+		offer, err := scanMasterService(rows)
+		if err != nil {
+			return nil, err
 		}
+		offers = append(offers, *offer)
 	}
+	return offers, rows.Err()
+}
 
-	return slots, nil
+func scanMasterService(row rowScanner) (*models.MasterService, error) {
+	var o models.MasterService
+	err := row.Scan(&o.ID, &o.MasterID, &o.ServiceID, &o.CategoryID, &o.ServiceName,
+		&o.BasePrice, &o.DurationMinutes, &o.IsAvailable, &o.CreatedAt, &o.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &o, nil
 }
 
 // Appointments
-func (r *Repository) CreateAppointment(userID, masterID, serviceID int, date time.Time, timeStr, comment string) (*models.Appointment, error) {
-	var appointment models.Appointment
-	err := r.db.QueryRow(
-		`INSERT INTO appointments (user_id, master_id, service_id, date, time, status, comment) 
-		 VALUES ($1, $2, $3, $4, $5, 'pending', $6) 
+
+// ErrSlotTaken is returned when a booking overlaps another active appointment of the same master
+var ErrSlotTaken = errors.New("time slot is already booked")
+
+// exclusionViolation is the PostgreSQL error code raised by the appointments_no_overlap constraint
+const exclusionViolation = "23P01"
+
+// CreateBooking inserts the appointment, its guarantee and notifications in one transaction.
+// IDs and timestamps are filled in on success; the guarantee and notifications are linked to the new appointment.
+// Returns ErrSlotTaken if the master is already busy at that time.
+func (r *Repository) CreateBooking(ctx context.Context, booking *models.Booking) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	appointment := &booking.Appointment
+	if models.HoldsSlot(appointment.Status) {
+		if err := checkHolds(ctx, tx, appointment.MasterID, booking.Slot, 0); err != nil {
+			return err
+		}
+	}
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO appointments (user_id, master_id, service_id, date, time, status, comment, slot, quote_id, price, car_id, user_car_id) 
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, tstzrange($8, $9, '[)'), $10, $11, $12, $13) 
 		 RETURNING id, user_id, master_id, service_id, date, time, status, comment, created_at, updated_at`,
-		userID, masterID, serviceID, date.Format("2006-01-02"), timeStr, comment,
+		appointment.UserID, appointment.MasterID, appointment.ServiceID, appointment.Date.Format("2006-01-02"), appointment.Time, appointment.Status, appointment.Comment,
+		booking.Slot.Start, booking.Slot.End, appointment.QuoteID, appointment.Price, appointment.CarID, appointment.UserCarID,
 	).Scan(&appointment.ID, &appointment.UserID, &appointment.MasterID, &appointment.ServiceID, &appointment.Date, &appointment.Time, &appointment.Status, &appointment.Comment, &appointment.CreatedAt, &appointment.UpdatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == exclusionViolation {
+			return ErrSlotTaken
+		}
+		return fmt.Errorf("failed to create appointment: %w", err)
+	}
+
+	for i := range appointment.Items {
+		item := &appointment.Items[i]
+		item.AppointmentID = appointment.ID
+		item.Position = i
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO appointment_items (appointment_id, service_id, position, price, duration_minutes, created_at)
+			VALUES ($1, $2, $3, $4, $5, NOW())
+			RETURNING id
+		`, item.AppointmentID, item.ServiceID, item.Position, item.Price, item.DurationMinutes).Scan(&item.ID)
+		if err != nil {
+			return fmt.Errorf("failed to create appointment item: %w", err)
+		}
+	}
+
+	if g := booking.Guarantee; g != nil {
+		g.AppointmentID = appointment.ID
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO guarantees (user_id, appointment_id, service_name, master_name, service_date, expiry_date, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, NOW())
+			RETURNING id, created_at
+		`, g.UserID, g.AppointmentID, g.ServiceName, g.MasterName, g.ServiceDate, g.ExpiryDate).Scan(&g.ID, &g.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to create guarantee: %w", err)
+		}
+	}
+
+	if booking.Event != nil {
+		if err := insertAppointmentEvent(ctx, tx, appointment.ID, booking.Event); err != nil {
+			return err
+		}
+	}
+
+	if err := insertNotifications(ctx, tx, appointment.ID, booking.Notifications); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// insertNotifications stores notifications about the appointment relatedID within tx
+func insertNotifications(ctx context.Context, tx *sql.Tx, relatedID int, notifications []models.Notification) error {
+	for i := range notifications {
+		n := &notifications[i]
+		n.RelatedID = relatedID
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO notifications (user_id, type, title, message, related_id, is_read, created_at)
+			VALUES ($1, $2, $3, $4, $5, false, NOW())
+			RETURNING id, is_read, created_at
+		`, n.UserID, n.Type, n.Title, n.Message, n.RelatedID).Scan(&n.ID, &n.IsRead, &n.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to create notification: %w", err)
+		}
+	}
+	return nil
+}
+
+// checkHolds returns ErrSlotTaken if slot overlaps a pending reschedule request of the master, except those of
+// the appointment exceptAppointmentID. It locks the master's row first, so concurrent bookings and requests
+// of the master wait for this transaction; the exclusion constraints cover the rest.
+func checkHolds(ctx context.Context, tx *sql.Tx, masterID int, slot models.TimeRange, exceptAppointmentID int) error {
+	if _, err := tx.ExecContext(ctx, "SELECT 1 FROM masters WHERE id = $1 FOR UPDATE", masterID); err != nil {
+		return fmt.Errorf("failed to lock master: %w", err)
+	}
+	var held bool
+	err := tx.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM reschedule_requests
+			WHERE master_id = $1 AND status = 'pending' AND appointment_id <> $4 AND slot && tstzrange($2, $3, '[)')
+		)
+	`, masterID, slot.Start, slot.End, exceptAppointmentID).Scan(&held)
+	if err != nil {
+		return err
+	}
+	if held {
+		return ErrSlotTaken
+	}
+	return nil
+}
+
+// insertAppointmentEvent adds event to the history of the appointment appointmentID within tx
+func insertAppointmentEvent(ctx context.Context, tx *sql.Tx, appointmentID int, event *models.AppointmentEvent) error {
+	event.AppointmentID = appointmentID
+	err := tx.QueryRowContext(ctx, `
+		INSERT INTO appointment_events (appointment_id, type, from_status, to_status, actor_id, actor_role, note, created_at)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7, NOW())
+		RETURNING id, created_at
+	`, event.AppointmentID, event.Type, event.FromStatus, event.ToStatus, event.ActorID, event.ActorRole, event.Note).Scan(&event.ID, &event.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record appointment event: %w", err)
+	}
+	return nil
+}
+
+// activeAppointmentSQL matches appointments of a that keep their master busy, like models.HoldsSlot
+const activeAppointmentSQL = `a.status NOT IN ('cancelled', 'rejected')`
+
+// appointmentSlotSQL is the slot of appointment a, or for appointments without a stored slot its
+// date and time in the master's zone for as long as its service lasts. Needs services s and masters m joined.
+const appointmentSlotSQL = `COALESCE(a.slot, tstzrange(
+	(a.date + a.time) AT TIME ZONE m.time_zone,
+	(a.date + a.time + make_interval(mins => COALESCE(NULLIF(s.duration_minutes, 0), 60))) AT TIME ZONE m.time_zone,
+	'[)'))`
+
+// GetBookedIntervals returns the times a master is busy with active appointments or held for pending
+// reschedule requests that overlap from..to, including bookings that started the day before and run past
+// midnight. Appointments without a stored slot are assumed to last as long as their service.
+func (r *Repository) GetBookedIntervals(ctx context.Context, masterID int, from, to time.Time) ([]models.TimeRange, error) {
+	// The date filters only narrow the scan; a booking lasts less than a day, so it is dated
+	// no earlier than the day before from
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT lower(slot), upper(slot) FROM (
+			SELECT `+appointmentSlotSQL+` AS slot
+			FROM appointments a
+			JOIN masters m ON m.id = a.master_id
+			LEFT JOIN services s ON s.id = a.service_id
+			WHERE a.master_id = $1 AND a.date BETWEEN $2::date - 1 AND $3::date AND `+activeAppointmentSQL+`
+			UNION ALL
+			SELECT slot FROM reschedule_requests
+			WHERE master_id = $1 AND date BETWEEN $2::date - 1 AND $3::date AND status = 'pending'
+		) booked
+		WHERE slot && tstzrange($4, $5, '[)')
+		ORDER BY 1
+	`, masterID, from.Format("2006-01-02"), to.Format("2006-01-02"), from, to)
 	if err != nil {
 		return nil, err
 	}
-	return &appointment, nil
+	defer rows.Close()
+
+	var intervals []models.TimeRange
+	for rows.Next() {
+		var interval models.TimeRange
+		if err := rows.Scan(&interval.Start, &interval.End); err != nil {
+			return nil, err
+		}
+		intervals = append(intervals, interval)
+	}
+	return intervals, rows.Err()
+}
+
+// GetMasterAppointmentsBetween returns the master's upcoming (pending or confirmed) appointments dated from..to inclusive
+func (r *Repository) GetMasterAppointmentsBetween(ctx context.Context, masterID int, from, to time.Time) ([]models.ScheduledAppointment, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT a.id, a.user_id, a.master_id, a.service_id, a.date, a.time, a.status, a.comment, a.created_at, a.updated_at,
+		       lower(booked.slot), upper(booked.slot)
+		FROM appointments a
+		JOIN masters m ON m.id = a.master_id
+		LEFT JOIN services s ON s.id = a.service_id
+		CROSS JOIN LATERAL (SELECT `+appointmentSlotSQL+` AS slot) booked
+		WHERE a.master_id = $1 AND a.date BETWEEN $2 AND $3 AND a.status IN ('pending', 'confirmed')
+		ORDER BY a.date, a.time
+	`, masterID, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var appointments []models.ScheduledAppointment
+	for rows.Next() {
+		var a models.ScheduledAppointment
+		if err := rows.Scan(&a.ID, &a.UserID, &a.MasterID, &a.ServiceID, &a.Date, &a.Time, &a.Status, &a.Comment, &a.CreatedAt, &a.UpdatedAt,
+			&a.Slot.Start, &a.Slot.End); err != nil {
+			return nil, err
+		}
+		appointments = append(appointments, a)
+	}
+	return appointments, rows.Err()
 }
 
-func (r *Repository) GetUserAppointments(userID int) ([]models.AppointmentWithDetails, error) {
+// ErrStatusChanged is returned when an appointment is no longer in the status a change was made from
+var ErrStatusChanged = errors.New("appointment status has changed")
+
+// ChangeAppointmentStatus moves the appointment from event.FromStatus to event.ToStatus, records the event,
+// cancels its pending reschedule request if it is no longer pending or confirmed and stores the notifications,
+// all in one transaction. Returns ErrStatusChanged if the appointment
+// is no longer in event.FromStatus.
+func (r *Repository) ChangeAppointmentStatus(ctx context.Context, appointmentID int, event *models.AppointmentEvent, notifications []models.Notification) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE appointments SET status = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status = $2
+	`, appointmentID, event.FromStatus, event.ToStatus)
+	if err != nil {
+		return fmt.Errorf("failed to change appointment status: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		var exists bool
+		if err := tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM appointments WHERE id = $1)", appointmentID).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return sql.ErrNoRows
+		}
+		return ErrStatusChanged
+	}
+
+	event.Type = models.AppointmentEventStatusChanged
+	if err := insertAppointmentEvent(ctx, tx, appointmentID, event); err != nil {
+		return err
+	}
+	if event.ToStatus != models.AppointmentPending && event.ToStatus != models.AppointmentConfirmed {
+		// Once an appointment can no longer move, its open reschedule request stops holding a slot
+		_, err = tx.ExecContext(ctx, `
+			UPDATE reschedule_requests SET status = 'cancelled', resolved_at = CURRENT_TIMESTAMP
+			WHERE appointment_id = $1 AND status = 'pending'
+		`, appointmentID)
+		if err != nil {
+			return fmt.Errorf("failed to cancel reschedule requests: %w", err)
+		}
+	}
+	if err := insertNotifications(ctx, tx, appointmentID, notifications); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// MoveBooking moves an upcoming (pending or confirmed) appointment to slot, shifts its guarantee by the
+// same number of days, records event as a reschedule and stores the notifications, all in one transaction.
+// Returns ErrSlotTaken if slot is booked or held. slot.Start must be in the master's zone, it sets the appointment's date and time.
+func (r *Repository) MoveBooking(ctx context.Context, appointmentID int, slot models.TimeRange, event *models.AppointmentEvent, notifications []models.Notification) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := moveBooking(ctx, tx, appointmentID, slot, event); err != nil {
+		return err
+	}
+	if err := insertNotifications(ctx, tx, appointmentID, notifications); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// moveBooking is MoveBooking without the notifications within tx
+func moveBooking(ctx context.Context, tx *sql.Tx, appointmentID int, slot models.TimeRange, event *models.AppointmentEvent) error {
+	var masterID int
+	if err := tx.QueryRowContext(ctx, "SELECT master_id FROM appointments WHERE id = $1", appointmentID).Scan(&masterID); err != nil {
+		return err
+	}
+	if err := checkHolds(ctx, tx, masterID, slot, appointmentID); err != nil {
+		return err
+	}
+
+	var status string
+	err := tx.QueryRowContext(ctx, `
+		UPDATE appointments
+		SET date = $2, time = $3, slot = tstzrange($4, $5, '[)'), updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status IN ('pending', 'confirmed')
+		RETURNING status
+	`, appointmentID, slot.Start.Format("2006-01-02"), slot.Start.Format("15:04"), slot.Start, slot.End).Scan(&status)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == exclusionViolation {
+			return ErrSlotTaken
+		}
+		if err == sql.ErrNoRows {
+			return err
+		}
+		return fmt.Errorf("failed to move appointment: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE guarantees
+		SET expiry_date = expiry_date + ($2::date - service_date), service_date = $2
+		WHERE appointment_id = $1
+	`, appointmentID, slot.Start.Format("2006-01-02"))
+	if err != nil {
+		return fmt.Errorf("failed to move guarantee: %w", err)
+	}
+
+	event.Type = models.AppointmentEventRescheduled
+	event.FromStatus, event.ToStatus = status, status
+	return insertAppointmentEvent(ctx, tx, appointmentID, event)
+}
+
+func (r *Repository) GetUserAppointments(ctx context.Context, userID int) ([]models.AppointmentWithDetails, error) {
 	query := `
 		SELECT 
 			a.id, a.user_id, a.master_id, a.service_id, a.date, a.time, a.status, a.comment, a.created_at, a.updated_at,
+			a.quote_id, a.price, a.car_id, a.user_car_id,
 			s.name as service_name,
 			m.name as master_name
 		FROM appointments a
@@ -580,124 +1213,469 @@ func (r *Repository) GetUserAppointments(userID int) ([]models.AppointmentWithDe
 		WHERE a.user_id = $1 
 		ORDER BY a.date DESC, a.time DESC`
 
-	rows, err := r.db.Query(query, userID)
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var appointments []models.AppointmentWithDetails
+	for rows.Next() {
+		var appt models.AppointmentWithDetails
+		var quoteID, carID, userCarID sql.NullInt64
+		var price sql.NullFloat64
+		if err := rows.Scan(&appt.ID, &appt.UserID, &appt.MasterID, &appt.ServiceID, &appt.Date, &appt.Time, &appt.Status, &appt.Comment, &appt.CreatedAt, &appt.UpdatedAt,
+			&quoteID, &price, &carID, &userCarID, &appt.ServiceName, &appt.MasterName); err != nil {
+			return nil, err
+		}
+		appt.QuoteID = nullableInt(quoteID)
+		appt.Price = nullableFloat(price)
+		appt.CarID = nullableInt(carID)
+		appt.UserCarID = nullableInt(userCarID)
+		appointments = append(appointments, appt)
+	}
+	return appointments, nil
+}
+
+func (r *Repository) UpdateAppointmentComment(ctx context.Context, appointmentID int, comment string) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE appointments SET comment = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2", comment, appointmentID)
+	return err
+}
+
+// DeleteAppointment deletes an appointment and records event in its history, which outlives it
+func (r *Repository) DeleteAppointment(ctx context.Context, appointmentID int, event *models.AppointmentEvent) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := insertAppointmentEvent(ctx, tx, appointmentID, event); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM appointments WHERE id = $1", appointmentID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *Repository) GetAppointmentByID(ctx context.Context, appointmentID int) (*models.Appointment, error) {
+	var appt models.Appointment
+	var quoteID, carID, userCarID sql.NullInt64
+	var price sql.NullFloat64
+	err := r.db.QueryRowContext(ctx, "SELECT id, user_id, master_id, service_id, date, time, status, comment, created_at, updated_at, quote_id, price, car_id, user_car_id FROM appointments WHERE id = $1", appointmentID).
+		Scan(&appt.ID, &appt.UserID, &appt.MasterID, &appt.ServiceID, &appt.Date, &appt.Time, &appt.Status, &appt.Comment, &appt.CreatedAt, &appt.UpdatedAt, &quoteID, &price, &carID, &userCarID)
+	if err != nil {
+		return nil, err
+	}
+	appt.QuoteID = nullableInt(quoteID)
+	appt.Price = nullableFloat(price)
+	appt.CarID = nullableInt(carID)
+	appt.UserCarID = nullableInt(userCarID)
+	return &appt, nil
+}
+
+// GetScheduledAppointment returns an appointment with the time its master is busy with it
+func (r *Repository) GetScheduledAppointment(ctx context.Context, appointmentID int) (*models.ScheduledAppointment, error) {
+	var a models.ScheduledAppointment
+	err := r.db.QueryRowContext(ctx, `
+		SELECT a.id, a.user_id, a.master_id, a.service_id, a.date, a.time, a.status, a.comment, a.created_at, a.updated_at,
+		       lower(booked.slot), upper(booked.slot)
+		FROM appointments a
+		JOIN masters m ON m.id = a.master_id
+		LEFT JOIN services s ON s.id = a.service_id
+		CROSS JOIN LATERAL (SELECT `+appointmentSlotSQL+` AS slot) booked
+		WHERE a.id = $1
+	`, appointmentID).Scan(&a.ID, &a.UserID, &a.MasterID, &a.ServiceID, &a.Date, &a.Time, &a.Status, &a.Comment, &a.CreatedAt, &a.UpdatedAt,
+		&a.Slot.Start, &a.Slot.End)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// GetAppointmentEvents returns the history of an appointment, oldest first
+func (r *Repository) GetAppointmentEvents(ctx context.Context, appointmentID int) ([]models.AppointmentEvent, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, appointment_id, type, COALESCE(from_status, ''), to_status, actor_id, actor_role, note, created_at
+		FROM appointment_events
+		WHERE appointment_id = $1
+		ORDER BY created_at, id
+	`, appointmentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []models.AppointmentEvent
+	for rows.Next() {
+		var e models.AppointmentEvent
+		var actorID sql.NullInt64
+		if err := rows.Scan(&e.ID, &e.AppointmentID, &e.Type, &e.FromStatus, &e.ToStatus, &actorID, &e.ActorRole, &e.Note, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		if actorID.Valid {
+			id := int(actorID.Int64)
+			e.ActorID = &id
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// GetAppointmentItems lists the services booked in an appointment in the order they are performed
+func (r *Repository) GetAppointmentItems(ctx context.Context, appointmentID int) ([]models.AppointmentItem, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT i.id, i.appointment_id, i.service_id, s.name, i.position, i.price, i.duration_minutes
+		FROM appointment_items i
+		JOIN services s ON s.id = i.service_id
+		WHERE i.appointment_id = $1
+		ORDER BY i.position, i.id
+	`, appointmentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.AppointmentItem
+	for rows.Next() {
+		var item models.AppointmentItem
+		if err := rows.Scan(&item.ID, &item.AppointmentID, &item.ServiceID, &item.ServiceName, &item.Position, &item.Price, &item.DurationMinutes); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// Reschedule requests
+
+const rescheduleRequestColumns = `id, appointment_id, master_id, COALESCE(requested_by, 0), date, to_char(time, 'HH24:MI'),
+	lower(slot), upper(slot), status, comment, response, created_at, resolved_at`
+
+func scanRescheduleRequest(row rowScanner) (*models.RescheduleRequest, error) {
+	var req models.RescheduleRequest
+	var resolvedAt sql.NullTime
+	err := row.Scan(&req.ID, &req.AppointmentID, &req.MasterID, &req.RequestedBy, &req.Date, &req.Time,
+		&req.Slot.Start, &req.Slot.End, &req.Status, &req.Comment, &req.Response, &req.CreatedAt, &resolvedAt)
+	if err != nil {
+		return nil, err
+	}
+	if resolvedAt.Valid {
+		req.ResolvedAt = &resolvedAt.Time
+	}
+	return &req, nil
+}
+
+// CreateRescheduleRequest stores a pending request holding request.Slot, replacing the appointment's
+// previous pending request, records event and stores the notifications, all in one transaction.
+// Returns ErrSlotTaken if the slot overlaps another appointment or held slot of the master.
+func (r *Repository) CreateRescheduleRequest(ctx context.Context, request *models.RescheduleRequest, event *models.AppointmentEvent, notifications []models.Notification) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE reschedule_requests SET status = 'cancelled', resolved_at = CURRENT_TIMESTAMP
+		WHERE appointment_id = $1 AND status = 'pending'
+	`, request.AppointmentID)
+	if err != nil {
+		return fmt.Errorf("failed to cancel previous reschedule request: %w", err)
+	}
+
+	// Appointments are checked here, other held slots by checkHolds and reschedule_requests_no_overlap
+	if err := checkHolds(ctx, tx, request.MasterID, request.Slot, request.AppointmentID); err != nil {
+		return err
+	}
+	var booked bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM appointments a
+			JOIN masters m ON m.id = a.master_id
+			LEFT JOIN services s ON s.id = a.service_id
+			WHERE a.master_id = $1 AND a.id <> $2 AND `+activeAppointmentSQL+`
+			  AND `+appointmentSlotSQL+` && tstzrange($3, $4, '[)')
+		)
+	`, request.MasterID, request.AppointmentID, request.Slot.Start, request.Slot.End).Scan(&booked)
+	if err != nil {
+		return err
+	}
+	if booked {
+		return ErrSlotTaken
+	}
+
+	created, err := scanRescheduleRequest(tx.QueryRowContext(ctx, `
+		INSERT INTO reschedule_requests (appointment_id, master_id, requested_by, date, time, slot, status, comment)
+		VALUES ($1, $2, NULLIF($3, 0), $4, $5, tstzrange($6, $7, '[)'), 'pending', $8)
+		RETURNING `+rescheduleRequestColumns,
+		request.AppointmentID, request.MasterID, request.RequestedBy, request.Date.Format("2006-01-02"), request.Time,
+		request.Slot.Start, request.Slot.End, request.Comment))
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == exclusionViolation {
+			return ErrSlotTaken
+		}
+		return fmt.Errorf("failed to create reschedule request: %w", err)
+	}
+	*request = *created
+
+	event.Type = models.AppointmentEventRescheduleRequested
+	if err := insertAppointmentEvent(ctx, tx, request.AppointmentID, event); err != nil {
+		return err
+	}
+	if err := insertNotifications(ctx, tx, request.AppointmentID, notifications); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *Repository) GetRescheduleRequest(ctx context.Context, requestID int) (*models.RescheduleRequest, error) {
+	return scanRescheduleRequest(r.db.QueryRowContext(ctx,
+		"SELECT "+rescheduleRequestColumns+" FROM reschedule_requests WHERE id = $1", requestID))
+}
+
+// GetAppointmentRescheduleRequests returns the appointment's reschedule requests, newest first
+func (r *Repository) GetAppointmentRescheduleRequests(ctx context.Context, appointmentID int) ([]models.RescheduleRequest, error) {
+	return r.queryRescheduleRequests(ctx, `
+		SELECT `+rescheduleRequestColumns+` FROM reschedule_requests
+		WHERE appointment_id = $1
+		ORDER BY created_at DESC, id DESC
+	`, appointmentID)
+}
+
+// GetMasterRescheduleRequests returns the master's reschedule requests in status (all if empty),
+// ordered by the proposed time
+func (r *Repository) GetMasterRescheduleRequests(ctx context.Context, masterID int, status string) ([]models.RescheduleRequest, error) {
+	return r.queryRescheduleRequests(ctx, `
+		SELECT `+rescheduleRequestColumns+` FROM reschedule_requests
+		WHERE master_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY lower(slot), id
+	`, masterID, status)
+}
+
+func (r *Repository) queryRescheduleRequests(ctx context.Context, query string, args ...interface{}) ([]models.RescheduleRequest, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var appointments []models.AppointmentWithDetails
+	var requests []models.RescheduleRequest
 	for rows.Next() {
-		var appt models.AppointmentWithDetails
-		if err := rows.Scan(&appt.ID, &appt.UserID, &appt.MasterID, &appt.ServiceID, &appt.Date, &appt.Time, &appt.Status, &appt.Comment, &appt.CreatedAt, &appt.UpdatedAt, &appt.ServiceName, &appt.MasterName); err != nil {
+		req, err := scanRescheduleRequest(rows)
+		if err != nil {
 			return nil, err
 		}
-		appointments = append(appointments, appt)
+		requests = append(requests, *req)
 	}
-	return appointments, nil
+	return requests, rows.Err()
 }
 
-func (r *Repository) UpdateAppointment(appointmentID int, comment string, status string) error {
-	if status != "" {
-		_, err := r.db.Exec("UPDATE appointments SET comment = $1, status = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $3", comment, status, appointmentID)
+// AcceptRescheduleRequest marks a pending request accepted and moves its appointment to the held slot
+// like MoveBooking, releasing the old slot, all in one transaction. Returns sql.ErrNoRows if the request
+// is not pending or its appointment can no longer be moved.
+func (r *Repository) AcceptRescheduleRequest(ctx context.Context, requestID int, event *models.AppointmentEvent, notifications []models.Notification) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
 		return err
 	}
-	_, err := r.db.Exec("UPDATE appointments SET comment = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2", comment, appointmentID)
-	return err
-}
+	defer tx.Rollback()
 
-func (r *Repository) CancelAppointment(appointmentID int) error {
-	_, err := r.db.Exec("UPDATE appointments SET status = 'cancelled', updated_at = CURRENT_TIMESTAMP WHERE id = $1", appointmentID)
-	return err
-}
+	var appointmentID int
+	var slot models.TimeRange
+	err = tx.QueryRowContext(ctx, `
+		UPDATE reschedule_requests SET status = 'accepted', resolved_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status = 'pending'
+		RETURNING appointment_id, lower(slot), upper(slot)
+	`, requestID).Scan(&appointmentID, &slot.Start, &slot.End)
+	if err != nil {
+		return err
+	}
 
-func (r *Repository) DeleteAppointment(appointmentID int) error {
-	_, err := r.db.Exec("DELETE FROM appointments WHERE id = $1", appointmentID)
-	return err
+	// The appointment's date and time are the wall clock in the master's zone
+	var zone string
+	if err := tx.QueryRowContext(ctx, `
+		SELECT m.time_zone FROM appointments a JOIN masters m ON m.id = a.master_id WHERE a.id = $1
+	`, appointmentID).Scan(&zone); err != nil {
+		return err
+	}
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return fmt.Errorf("invalid master time zone %q: %w", zone, err)
+	}
+	slot.Start, slot.End = slot.Start.In(loc), slot.End.In(loc)
+
+	if err := moveBooking(ctx, tx, appointmentID, slot, event); err != nil {
+		return err
+	}
+	if err := insertNotifications(ctx, tx, appointmentID, notifications); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (r *Repository) GetAppointmentByID(appointmentID int) (*models.Appointment, error) {
-	var appt models.Appointment
-	err := r.db.QueryRow("SELECT id, user_id, master_id, service_id, date, time, status, comment, created_at, updated_at FROM appointments WHERE id = $1", appointmentID).
-		Scan(&appt.ID, &appt.UserID, &appt.MasterID, &appt.ServiceID, &appt.Date, &appt.Time, &appt.Status, &appt.Comment, &appt.CreatedAt, &appt.UpdatedAt)
+// CloseRescheduleRequest moves a pending request to status (declined, cancelled or expired), releasing its slot,
+// records event if not nil and stores the notifications, all in one transaction.
+// Returns sql.ErrNoRows if the request is not pending.
+func (r *Repository) CloseRescheduleRequest(ctx context.Context, requestID int, status, response string, event *models.AppointmentEvent, notifications []models.Notification) error {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	return &appt, nil
+	defer tx.Rollback()
+
+	var appointmentID int
+	err = tx.QueryRowContext(ctx, `
+		UPDATE reschedule_requests SET status = $2, response = $3, resolved_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status = 'pending'
+		RETURNING appointment_id
+	`, requestID, status, response).Scan(&appointmentID)
+	if err != nil {
+		return err
+	}
+
+	if event != nil {
+		if err := insertAppointmentEvent(ctx, tx, appointmentID, event); err != nil {
+			return err
+		}
+	}
+	if err := insertNotifications(ctx, tx, appointmentID, notifications); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 // Users
-func (r *Repository) GetUserByEmail(email string) (*models.User, error) {
+func (r *Repository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
 	var user models.User
 	var photoURL sql.NullString
-	err := r.db.QueryRow("SELECT id, name, email, phone, photo_url, password_hash, created_at, updated_at FROM users WHERE email = $1", email).
-		Scan(&user.ID, &user.Name, &user.Email, &user.Phone, &photoURL, &user.PasswordHash, &user.CreatedAt, &user.UpdatedAt)
+	var emailVerifiedAt sql.NullTime
+	var phoneVerifiedAt sql.NullTime
+	err := r.db.QueryRowContext(ctx, "SELECT id, name, COALESCE(email, ''), COALESCE(phone, ''), photo_url, COALESCE(password_hash, ''), role, email_verified_at, phone_verified_at, time_zone, created_at, updated_at FROM users WHERE email = $1", email).
+		Scan(&user.ID, &user.Name, &user.Email, &user.Phone, &photoURL, &user.PasswordHash, &user.Role, &emailVerifiedAt, &phoneVerifiedAt, &user.TimeZone, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
 	if photoURL.Valid {
 		user.PhotoURL = photoURL.String
 	}
+	if emailVerifiedAt.Valid {
+		user.EmailVerifiedAt = &emailVerifiedAt.Time
+	}
+	if phoneVerifiedAt.Valid {
+		user.PhoneVerifiedAt = &phoneVerifiedAt.Time
+	}
 	return &user, nil
 }
 
-func (r *Repository) CreateUser(name, email, phone, passwordHash string) (*models.User, error) {
+func (r *Repository) CreateUser(ctx context.Context, name, email, phone, passwordHash string) (*models.User, error) {
 	var user models.User
 	var photoURL sql.NullString
-	err := r.db.QueryRow(
+	var emailVerifiedAt sql.NullTime
+	var phoneVerifiedAt sql.NullTime
+	err := r.db.QueryRowContext(ctx,
 		`INSERT INTO users (name, email, phone, password_hash) 
 		 VALUES ($1, $2, $3, $4) 
-		 RETURNING id, name, email, phone, photo_url, password_hash, created_at, updated_at`,
+		 RETURNING id, name, COALESCE(email, ''), COALESCE(phone, ''), photo_url, COALESCE(password_hash, ''), role, email_verified_at, phone_verified_at, time_zone, created_at, updated_at`,
 		name, email, phone, passwordHash,
-	).Scan(&user.ID, &user.Name, &user.Email, &user.Phone, &photoURL, &user.PasswordHash, &user.CreatedAt, &user.UpdatedAt)
+	).Scan(&user.ID, &user.Name, &user.Email, &user.Phone, &photoURL, &user.PasswordHash, &user.Role, &emailVerifiedAt, &phoneVerifiedAt, &user.TimeZone, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
 	if photoURL.Valid {
 		user.PhotoURL = photoURL.String
 	}
+	if emailVerifiedAt.Valid {
+		user.EmailVerifiedAt = &emailVerifiedAt.Time
+	}
+	if phoneVerifiedAt.Valid {
+		user.PhoneVerifiedAt = &phoneVerifiedAt.Time
+	}
 	return &user, nil
 }
 
-func (r *Repository) GetUserByID(id int) (*models.User, error) {
+func (r *Repository) GetUserByID(ctx context.Context, id int) (*models.User, error) {
 	var user models.User
 	var photoURL sql.NullString
-	err := r.db.QueryRow("SELECT id, name, email, phone, photo_url, password_hash, created_at, updated_at FROM users WHERE id = $1", id).
-		Scan(&user.ID, &user.Name, &user.Email, &user.Phone, &photoURL, &user.PasswordHash, &user.CreatedAt, &user.UpdatedAt)
+	var emailVerifiedAt sql.NullTime
+	var phoneVerifiedAt sql.NullTime
+	err := r.db.QueryRowContext(ctx, "SELECT id, name, COALESCE(email, ''), COALESCE(phone, ''), photo_url, COALESCE(password_hash, ''), role, email_verified_at, phone_verified_at, time_zone, created_at, updated_at FROM users WHERE id = $1", id).
+		Scan(&user.ID, &user.Name, &user.Email, &user.Phone, &photoURL, &user.PasswordHash, &user.Role, &emailVerifiedAt, &phoneVerifiedAt, &user.TimeZone, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
 	if photoURL.Valid {
 		user.PhotoURL = photoURL.String
 	}
+	if emailVerifiedAt.Valid {
+		user.EmailVerifiedAt = &emailVerifiedAt.Time
+	}
+	if phoneVerifiedAt.Valid {
+		user.PhoneVerifiedAt = &phoneVerifiedAt.Time
+	}
 	return &user, nil
 }
 
+// UpdateUserRole changes the role of a user
+func (r *Repository) UpdateUserRole(ctx context.Context, userID int, role string) error {
+	result, err := r.db.ExecContext(ctx, "UPDATE users SET role = $1, updated_at = NOW() WHERE id = $2", role, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// UpdateUserPassword replaces the stored password hash
+func (r *Repository) UpdateUserPassword(ctx context.Context, userID int, passwordHash string) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE users SET password_hash = $1, updated_at = NOW() WHERE id = $2", passwordHash, userID)
+	return err
+}
+
 // Update user photo URL
-func (r *Repository) UpdateUserPhoto(userID int, photoURL string) error {
-	_, err := r.db.Exec("UPDATE users SET photo_url = $1, updated_at = NOW() WHERE id = $2", photoURL, userID)
+func (r *Repository) UpdateUserTimeZone(ctx context.Context, userID int, timeZone string) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE users SET time_zone = $1, updated_at = NOW() WHERE id = $2", timeZone, userID)
+	return err
+}
+
+func (r *Repository) UpdateUserPhoto(ctx context.Context, userID int, photoURL string) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE users SET photo_url = $1, updated_at = NOW() WHERE id = $2", photoURL, userID)
+	return err
+}
+
+func (r *Repository) UpdateMasterTimeZone(ctx context.Context, masterID int, timeZone string) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE masters SET time_zone = $1, updated_at = NOW() WHERE id = $2", timeZone, masterID)
 	return err
 }
 
 // Update master photo URL
-func (r *Repository) UpdateMasterPhoto(masterID int, photoURL string) error {
-	_, err := r.db.Exec("UPDATE masters SET photo_url = $1, updated_at = NOW() WHERE id = $2", photoURL, masterID)
+func (r *Repository) UpdateMasterPhoto(ctx context.Context, masterID int, photoURL string) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE masters SET photo_url = $1, updated_at = NOW() WHERE id = $2", photoURL, masterID)
 	return err
 }
 
 // Master Profile Methods
 
 // GetMasterByUserID gets master profile by user ID
-func (r *Repository) GetMasterByUserID(userID int) (*models.Master, error) {
+func (r *Repository) GetMasterByUserID(ctx context.Context, userID int) (*models.Master, error) {
 	var master models.Master
 	var specialization, photoURL, address sql.NullString
 	var rating sql.NullFloat64
 	var locationLat, locationLng sql.NullFloat64
 
-	err := r.db.QueryRow("SELECT id, user_id, name, email, phone, specialization, rating, photo_url, location_lat, location_lng, address, created_at, updated_at FROM masters WHERE user_id = $1", userID).
+	err := r.db.QueryRowContext(ctx, "SELECT id, user_id, name, email, phone, specialization, rating, photo_url, location_lat, location_lng, address, time_zone, created_at, updated_at FROM masters WHERE user_id = $1", userID).
 		Scan(&master.ID, &master.UserID, &master.Name, &master.Email, &master.Phone,
 			&specialization, &rating, &photoURL, &locationLat, &locationLng,
-			&address, &master.CreatedAt, &master.UpdatedAt)
+			&address, &master.TimeZone, &master.CreatedAt, &master.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -726,24 +1704,29 @@ func (r *Repository) GetMasterByUserID(userID int) (*models.Master, error) {
 }
 
 // CreateMaster creates a new master profile
-func (r *Repository) CreateMaster(userID int, name, email, phone, specialization, address string) (*models.Master, error) {
+func (r *Repository) CreateMaster(ctx context.Context, userID int, name, email, phone, specialization, address string) (*models.Master, error) {
 	var master models.Master
 	var specializationNull, photoURLNull, addressNull sql.NullString
 	var locationLatNull, locationLngNull sql.NullFloat64
 	var ratingNull sql.NullFloat64
 
-	err := r.db.QueryRow(`
+	err := r.db.QueryRowContext(ctx, `
 		INSERT INTO masters (user_id, name, email, phone, specialization, address, created_at, updated_at)
 		VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())
-		RETURNING id, user_id, name, email, phone, specialization, rating, photo_url, location_lat, location_lng, address, created_at, updated_at
+		RETURNING id, user_id, name, email, phone, specialization, rating, photo_url, location_lat, location_lng, address, time_zone, created_at, updated_at
 	`, userID, name, email, phone, specialization, address).
 		Scan(&master.ID, &master.UserID, &master.Name, &master.Email, &master.Phone,
 			&specializationNull, &ratingNull, &photoURLNull, &locationLatNull, &locationLngNull,
-			&addressNull, &master.CreatedAt, &master.UpdatedAt)
+			&addressNull, &master.TimeZone, &master.CreatedAt, &master.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
 
+	// Customers become masters once they have a profile; admins keep their role
+	if _, err := r.db.ExecContext(ctx, "UPDATE users SET role = 'master', updated_at = NOW() WHERE id = $1 AND role = 'customer'", userID); err != nil {
+		return nil, err
+	}
+
 	// Handle nullable fields
 	if specializationNull.Valid {
 		master.Specialization = specializationNull.String
@@ -768,8 +1751,8 @@ func (r *Repository) CreateMaster(userID int, name, email, phone, specialization
 }
 
 // UpdateMaster updates master profile
-func (r *Repository) UpdateMaster(masterID int, name, email, phone, specialization, address string) error {
-	_, err := r.db.Exec(`
+func (r *Repository) UpdateMaster(ctx context.Context, masterID int, name, email, phone, specialization, address string) error {
+	_, err := r.db.ExecContext(ctx, `
 		UPDATE masters SET name = $1, email = $2, phone = $3, specialization = $4, address = $5, updated_at = NOW()
 		WHERE id = $6
 	`, name, email, phone, specialization, address, masterID)
@@ -778,30 +1761,35 @@ func (r *Repository) UpdateMaster(masterID int, name, email, phone, specializati
 
 // DeleteMasterProfile deletes master profile by user ID
 // First deletes all related appointments, then the master profile
-func (r *Repository) DeleteMasterProfile(userID int) error {
+func (r *Repository) DeleteMasterProfile(ctx context.Context, userID int) error {
 	// Get master ID first
 	var masterID int
-	err := r.db.QueryRow("SELECT id FROM masters WHERE user_id = $1", userID).Scan(&masterID)
+	err := r.db.QueryRowContext(ctx, "SELECT id FROM masters WHERE user_id = $1", userID).Scan(&masterID)
 	if err != nil {
 		return err
 	}
 
 	// Delete all appointments for this master
-	_, err = r.db.Exec("DELETE FROM appointments WHERE master_id = $1", masterID)
+	_, err = r.db.ExecContext(ctx, "DELETE FROM appointments WHERE master_id = $1", masterID)
 	if err != nil {
 		return err
 	}
 
 	// Delete master profile (other related tables have ON DELETE CASCADE)
-	_, err = r.db.Exec("DELETE FROM masters WHERE user_id = $1", userID)
+	_, err = r.db.ExecContext(ctx, "DELETE FROM masters WHERE user_id = $1", userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, "UPDATE users SET role = 'customer', updated_at = NOW() WHERE id = $1 AND role = 'master'", userID)
 	return err
 }
 
 // Master Works Methods
 
 // GetMasterWorks gets all works for a master
-func (r *Repository) GetMasterWorks(masterID int) ([]models.MasterWork, error) {
-	rows, err := r.db.Query(`
+func (r *Repository) GetMasterWorks(ctx context.Context, masterID int) ([]models.MasterWork, error) {
+	rows, err := r.db.QueryContext(ctx, `
 		SELECT id, master_id, title, work_date, customer_name, amount, photo_urls, created_at
 		FROM master_works WHERE master_id = $1 ORDER BY work_date DESC
 	`, masterID)
@@ -828,7 +1816,7 @@ func (r *Repository) GetMasterWorks(masterID int) ([]models.MasterWork, error) {
 }
 
 // CreateMasterWork creates a new work entry
-func (r *Repository) CreateMasterWork(masterID int, title string, workDate time.Time, customerName string, amount float64, photoURLs []string) (*models.MasterWork, error) {
+func (r *Repository) CreateMasterWork(ctx context.Context, masterID int, title string, workDate time.Time, customerName string, amount float64, photoURLs []string) (*models.MasterWork, error) {
 	var work models.MasterWork
 	var photoURLsStr sql.NullString
 
@@ -837,7 +1825,7 @@ func (r *Repository) CreateMasterWork(masterID int, title string, workDate time.
 		photoURLsStr = sql.NullString{String: photoURLs[0], Valid: true} // Simplified for now
 	}
 
-	err := r.db.QueryRow(`
+	err := r.db.QueryRowContext(ctx, `
 		INSERT INTO master_works (master_id, title, work_date, customer_name, amount, photo_urls, created_at)
 		VALUES ($1, $2, $3, $4, $5, $6, NOW())
 		RETURNING id, master_id, title, work_date, customer_name, amount, photo_urls, created_at
@@ -855,11 +1843,11 @@ func (r *Repository) CreateMasterWork(masterID int, title string, workDate time.
 }
 
 // GetMasterWork gets a single work by ID
-func (r *Repository) GetMasterWork(workID, masterID int) (*models.MasterWork, error) {
+func (r *Repository) GetMasterWork(ctx context.Context, workID, masterID int) (*models.MasterWork, error) {
 	var work models.MasterWork
 	var photoURLs sql.NullString
 
-	err := r.db.QueryRow(`
+	err := r.db.QueryRowContext(ctx, `
 		SELECT id, master_id, title, work_date, customer_name, amount, photo_urls, created_at
 		FROM master_works WHERE id = $1 AND master_id = $2
 	`, workID, masterID).
@@ -877,14 +1865,14 @@ func (r *Repository) GetMasterWork(workID, masterID int) (*models.MasterWork, er
 }
 
 // UpdateMasterWork updates a work entry
-func (r *Repository) UpdateMasterWork(workID, masterID int, title string, workDate time.Time, customerName string, amount float64, photoURLs []string) error {
+func (r *Repository) UpdateMasterWork(ctx context.Context, workID, masterID int, title string, workDate time.Time, customerName string, amount float64, photoURLs []string) error {
 	var photoURLsStr sql.NullString
 
 	if len(photoURLs) > 0 {
 		photoURLsStr = sql.NullString{String: photoURLs[0], Valid: true}
 	}
 
-	result, err := r.db.Exec(`
+	result, err := r.db.ExecContext(ctx, `
 		UPDATE master_works SET title = $1, work_date = $2, customer_name = $3, amount = $4, photo_urls = $5
 		WHERE id = $6 AND master_id = $7
 	`, title, workDate, customerName, amount, photoURLsStr, workID, masterID)
@@ -905,8 +1893,8 @@ func (r *Repository) UpdateMasterWork(workID, masterID int, title string, workDa
 }
 
 // DeleteMasterWork deletes a work entry
-func (r *Repository) DeleteMasterWork(workID, masterID int) error {
-	result, err := r.db.Exec("DELETE FROM master_works WHERE id = $1 AND master_id = $2", workID, masterID)
+func (r *Repository) DeleteMasterWork(ctx context.Context, workID, masterID int) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM master_works WHERE id = $1 AND master_id = $2", workID, masterID)
 	if err != nil {
 		return err
 	}
@@ -926,11 +1914,11 @@ func (r *Repository) DeleteMasterWork(workID, masterID int) error {
 // Master Payment Info Methods
 
 // GetMasterPaymentInfo gets payment info for a master
-func (r *Repository) GetMasterPaymentInfo(masterID int) (*models.MasterPaymentInfo, error) {
+func (r *Repository) GetMasterPaymentInfo(ctx context.Context, masterID int) (*models.MasterPaymentInfo, error) {
 	var info models.MasterPaymentInfo
 	var kaspiCard, freedomCard, halykCard sql.NullString
 
-	err := r.db.QueryRow(`
+	err := r.db.QueryRowContext(ctx, `
 		SELECT id, master_id, kaspi_card, freedom_card, halyk_card, created_at, updated_at
 		FROM master_payment_info WHERE master_id = $1
 	`, masterID).
@@ -954,8 +1942,8 @@ func (r *Repository) GetMasterPaymentInfo(masterID int) (*models.MasterPaymentIn
 }
 
 // UpdateMasterPaymentInfo updates payment info for a master
-func (r *Repository) UpdateMasterPaymentInfo(masterID int, kaspiCard, freedomCard, halykCard string) error {
-	_, err := r.db.Exec(`
+func (r *Repository) UpdateMasterPaymentInfo(ctx context.Context, masterID int, kaspiCard, freedomCard, halykCard string) error {
+	_, err := r.db.ExecContext(ctx, `
 		INSERT INTO master_payment_info (master_id, kaspi_card, freedom_card, halyk_card, created_at, updated_at)
 		VALUES ($1, $2, $3, $4, NOW(), NOW())
 		ON CONFLICT (master_id) DO UPDATE SET
@@ -970,8 +1958,8 @@ func (r *Repository) UpdateMasterPaymentInfo(masterID int, kaspiCard, freedomCar
 // Reviews Methods
 
 // GetMasterReviews gets all reviews for a master
-func (r *Repository) GetMasterReviews(masterID int) ([]models.ReviewWithUser, error) {
-	rows, err := r.db.Query(`
+func (r *Repository) GetMasterReviews(ctx context.Context, masterID int) ([]models.ReviewWithUser, error) {
+	rows, err := r.db.QueryContext(ctx, `
 		SELECT r.id, r.master_id, r.user_id, r.rating, r.comment, r.created_at, u.name
 		FROM reviews r
 		JOIN users u ON r.user_id = u.id
@@ -995,9 +1983,9 @@ func (r *Repository) GetMasterReviews(masterID int) ([]models.ReviewWithUser, er
 }
 
 // CreateReview creates a new review
-func (r *Repository) CreateReview(masterID, userID, rating int, comment string) (*models.Review, error) {
+func (r *Repository) CreateReview(ctx context.Context, masterID, userID, rating int, comment string) (*models.Review, error) {
 	var review models.Review
-	err := r.db.QueryRow(`
+	err := r.db.QueryRowContext(ctx, `
 		INSERT INTO reviews (master_id, user_id, rating, comment, created_at)
 		VALUES ($1, $2, $3, $4, NOW())
 		RETURNING id, master_id, user_id, rating, comment, created_at
@@ -1010,8 +1998,8 @@ func (r *Repository) CreateReview(masterID, userID, rating int, comment string)
 }
 
 // DeleteReview deletes a review - only master who received the review can delete it
-func (r *Repository) DeleteReview(reviewID, masterID int) error {
-	result, err := r.db.Exec("DELETE FROM reviews WHERE id = $1 AND master_id = $2", reviewID, masterID)
+func (r *Repository) DeleteReview(ctx context.Context, reviewID, masterID int) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM reviews WHERE id = $1 AND master_id = $2", reviewID, masterID)
 	if err != nil {
 		return err
 	}
@@ -1031,24 +2019,24 @@ func (r *Repository) DeleteReview(reviewID, masterID int) error {
 // Master Verification Methods
 
 // CheckMasterVerificationStatus checks if a master is verified based on criteria
-func (r *Repository) CheckMasterVerificationStatus(masterID int) (bool, int, int, error) {
+func (r *Repository) CheckMasterVerificationStatus(ctx context.Context, masterID int) (bool, int, int, error) {
 	var reviewCount, workCount int
 	var rating float64
 
 	// Count reviews
-	err := r.db.QueryRow("SELECT COUNT(*) FROM reviews WHERE master_id = $1", masterID).Scan(&reviewCount)
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM reviews WHERE master_id = $1", masterID).Scan(&reviewCount)
 	if err != nil {
 		return false, 0, 0, err
 	}
 
 	// Count works
-	err = r.db.QueryRow("SELECT COUNT(*) FROM master_works WHERE master_id = $1", masterID).Scan(&workCount)
+	err = r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM master_works WHERE master_id = $1", masterID).Scan(&workCount)
 	if err != nil {
 		return false, 0, 0, err
 	}
 
 	// Get rating
-	err = r.db.QueryRow("SELECT rating FROM masters WHERE id = $1", masterID).Scan(&rating)
+	err = r.db.QueryRowContext(ctx, "SELECT rating FROM masters WHERE id = $1", masterID).Scan(&rating)
 	if err != nil {
 		return false, 0, 0, err
 	}
@@ -1062,18 +2050,18 @@ func (r *Repository) CheckMasterVerificationStatus(masterID int) (bool, int, int
 // Subscription Methods
 
 // GetUserSubscription gets subscription for a user
-func (r *Repository) GetUserSubscription(userID int) (*models.Subscription, error) {
+func (r *Repository) GetUserSubscription(ctx context.Context, userID int) (*models.Subscription, error) {
 	var sub models.Subscription
 	var trialStartDate, trialEndDate sql.NullTime
 
-	err := r.db.QueryRow(`
+	err := r.db.QueryRowContext(ctx, `
 		SELECT id, user_id, plan, trial_start_date, trial_end_date, created_at, updated_at
 		FROM user_subscriptions WHERE user_id = $1
 	`, userID).Scan(&sub.ID, &sub.UserID, &sub.Plan, &trialStartDate, &trialEndDate, &sub.CreatedAt, &sub.UpdatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			// Create basic subscription if doesn't exist
-			created, createErr := r.CreateUserSubscription(userID, "basic")
+			created, createErr := r.CreateUserSubscription(ctx, userID, "basic")
 			if createErr != nil {
 				// If creation fails, return a default subscription
 				return &models.Subscription{
@@ -1100,11 +2088,11 @@ func (r *Repository) GetUserSubscription(userID int) (*models.Subscription, erro
 }
 
 // CreateUserSubscription creates a subscription for a user
-func (r *Repository) CreateUserSubscription(userID int, plan string) (*models.Subscription, error) {
+func (r *Repository) CreateUserSubscription(ctx context.Context, userID int, plan string) (*models.Subscription, error) {
 	var sub models.Subscription
 	var trialStartDate, trialEndDate sql.NullTime
 
-	err := r.db.QueryRow(`
+	err := r.db.QueryRowContext(ctx, `
 		INSERT INTO user_subscriptions (user_id, plan, created_at, updated_at)
 		VALUES ($1, $2, NOW(), NOW())
 		ON CONFLICT (user_id) DO UPDATE SET plan = EXCLUDED.plan, updated_at = NOW()
@@ -1125,14 +2113,14 @@ func (r *Repository) CreateUserSubscription(userID int, plan string) (*models.Su
 }
 
 // StartTrial starts a trial period for a user (7 days)
-func (r *Repository) StartTrial(userID int) (*models.Subscription, error) {
+func (r *Repository) StartTrial(ctx context.Context, userID int) (*models.Subscription, error) {
 	trialStart := time.Now()
 	trialEnd := trialStart.AddDate(0, 0, 7) // 7 days trial
 
 	var sub models.Subscription
 	var trialStartDate, trialEndDate sql.NullTime
 
-	err := r.db.QueryRow(`
+	err := r.db.QueryRowContext(ctx, `
 		INSERT INTO user_subscriptions (user_id, plan, trial_start_date, trial_end_date, created_at, updated_at)
 		VALUES ($1, 'trial', $2, $3, NOW(), NOW())
 		ON CONFLICT (user_id) DO UPDATE SET 
@@ -1157,7 +2145,7 @@ func (r *Repository) StartTrial(userID int) (*models.Subscription, error) {
 }
 
 // UpdateUserSubscription updates subscription plan
-func (r *Repository) UpdateUserSubscription(userID int, plan string) error {
+func (r *Repository) UpdateUserSubscription(ctx context.Context, userID int, plan string) error {
 	// Validate plan
 	if plan != "basic" && plan != "premium" && plan != "trial" {
 		return fmt.Errorf("invalid plan: %s", plan)
@@ -1165,7 +2153,7 @@ func (r *Repository) UpdateUserSubscription(userID int, plan string) error {
 
 	// Update subscription plan
 	// Keep trial dates only if plan is 'trial', otherwise keep existing dates (don't null them)
-	_, err := r.db.Exec(`
+	_, err := r.db.ExecContext(ctx, `
 		INSERT INTO user_subscriptions (user_id, plan, created_at, updated_at)
 		VALUES ($1, $2, NOW(), NOW())
 		ON CONFLICT (user_id) DO UPDATE SET 
@@ -1189,8 +2177,8 @@ func (r *Repository) UpdateUserSubscription(userID int, plan string) error {
 // Favorite Masters Methods
 
 // AddFavoriteMaster adds a master to favorites
-func (r *Repository) AddFavoriteMaster(userID, masterID int) error {
-	_, err := r.db.Exec(`
+func (r *Repository) AddFavoriteMaster(ctx context.Context, userID, masterID int) error {
+	_, err := r.db.ExecContext(ctx, `
 		INSERT INTO favorite_masters (user_id, master_id, created_at)
 		VALUES ($1, $2, NOW())
 		ON CONFLICT (user_id, master_id) DO NOTHING
@@ -1199,16 +2187,16 @@ func (r *Repository) AddFavoriteMaster(userID, masterID int) error {
 }
 
 // RemoveFavoriteMaster removes a master from favorites
-func (r *Repository) RemoveFavoriteMaster(userID, masterID int) error {
-	_, err := r.db.Exec("DELETE FROM favorite_masters WHERE user_id = $1 AND master_id = $2", userID, masterID)
+func (r *Repository) RemoveFavoriteMaster(ctx context.Context, userID, masterID int) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM favorite_masters WHERE user_id = $1 AND master_id = $2", userID, masterID)
 	return err
 }
 
 // GetFavoriteMasters gets all favorite masters for a user
-func (r *Repository) GetFavoriteMasters(userID int) ([]models.Master, error) {
-	rows, err := r.db.Query(`
+func (r *Repository) GetFavoriteMasters(ctx context.Context, userID int) ([]models.Master, error) {
+	rows, err := r.db.QueryContext(ctx, `
 		SELECT m.id, m.user_id, m.name, m.email, m.phone, m.specialization, m.rating, m.photo_url,
-		       m.location_lat, m.location_lng, m.address, m.created_at, m.updated_at
+		       m.location_lat, m.location_lng, m.address, m.time_zone, m.created_at, m.updated_at
 		FROM favorite_masters fm
 		JOIN masters m ON fm.master_id = m.id
 		WHERE fm.user_id = $1
@@ -1224,7 +2212,7 @@ func (r *Repository) GetFavoriteMasters(userID int) ([]models.Master, error) {
 		var m models.Master
 		var photoURL sql.NullString
 		if err := rows.Scan(&m.ID, &m.UserID, &m.Name, &m.Email, &m.Phone, &m.Specialization,
-			&m.Rating, &photoURL, &m.LocationLat, &m.LocationLng, &m.Address, &m.CreatedAt, &m.UpdatedAt); err != nil {
+			&m.Rating, &photoURL, &m.LocationLat, &m.LocationLng, &m.Address, &m.TimeZone, &m.CreatedAt, &m.UpdatedAt); err != nil {
 			return nil, err
 		}
 		if photoURL.Valid {
@@ -1236,9 +2224,9 @@ func (r *Repository) GetFavoriteMasters(userID int) ([]models.Master, error) {
 }
 
 // IsFavoriteMaster checks if a master is in user's favorites
-func (r *Repository) IsFavoriteMaster(userID, masterID int) (bool, error) {
+func (r *Repository) IsFavoriteMaster(ctx context.Context, userID, masterID int) (bool, error) {
 	var count int
-	err := r.db.QueryRow(`
+	err := r.db.QueryRowContext(ctx, `
 		SELECT COUNT(*) FROM favorite_masters WHERE user_id = $1 AND master_id = $2
 	`, userID, masterID).Scan(&count)
 	if err != nil {
@@ -1249,11 +2237,26 @@ func (r *Repository) IsFavoriteMaster(userID, masterID int) (bool, error) {
 
 // User Cars Methods
 
+const userCarColumns = `uc.id, uc.user_id, uc.car_id, uc.name, uc.year, uc.comment,
+	COALESCE(c.brand, ''), COALESCE(c.model, ''), COALESCE(c.type, ''), uc.created_at, uc.updated_at`
+
+func scanUserCar(row rowScanner) (*models.UserCar, error) {
+	var car models.UserCar
+	var carID sql.NullInt64
+	if err := row.Scan(&car.ID, &car.UserID, &carID, &car.Name, &car.Year, &car.Comment,
+		&car.Brand, &car.Model, &car.Type, &car.CreatedAt, &car.UpdatedAt); err != nil {
+		return nil, err
+	}
+	car.CarID = nullableInt(carID)
+	return &car, nil
+}
+
 // GetUserCars gets all cars for a user
-func (r *Repository) GetUserCars(userID int) ([]models.UserCar, error) {
-	rows, err := r.db.Query(`
-		SELECT id, user_id, name, year, comment, created_at, updated_at
-		FROM user_cars WHERE user_id = $1 ORDER BY created_at DESC
+func (r *Repository) GetUserCars(ctx context.Context, userID int) ([]models.UserCar, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+userCarColumns+`
+		FROM user_cars uc LEFT JOIN cars c ON c.id = uc.car_id
+		WHERE uc.user_id = $1 ORDER BY uc.created_at DESC
 	`, userID)
 	if err != nil {
 		return nil, err
@@ -1262,50 +2265,58 @@ func (r *Repository) GetUserCars(userID int) ([]models.UserCar, error) {
 
 	var cars []models.UserCar
 	for rows.Next() {
-		var car models.UserCar
-		if err := rows.Scan(&car.ID, &car.UserID, &car.Name, &car.Year, &car.Comment, &car.CreatedAt, &car.UpdatedAt); err != nil {
+		car, err := scanUserCar(rows)
+		if err != nil {
 			return nil, err
 		}
-		cars = append(cars, car)
+		cars = append(cars, *car)
 	}
 	return cars, nil
 }
 
-// CreateUserCar creates a new car for a user
-func (r *Repository) CreateUserCar(userID int, name string, year int, comment string) (*models.UserCar, error) {
-	var car models.UserCar
-	err := r.db.QueryRow(`
-		INSERT INTO user_cars (user_id, name, year, comment, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, NOW(), NOW())
-		RETURNING id, user_id, name, year, comment, created_at, updated_at
-	`, userID, name, year, comment).
-		Scan(&car.ID, &car.UserID, &car.Name, &car.Year, &car.Comment, &car.CreatedAt, &car.UpdatedAt)
+// GetUserCar gets one of the user's cars; sql.ErrNoRows if the user has no such car
+func (r *Repository) GetUserCar(ctx context.Context, id, userID int) (*models.UserCar, error) {
+	return scanUserCar(r.db.QueryRowContext(ctx, `
+		SELECT `+userCarColumns+`
+		FROM user_cars uc LEFT JOIN cars c ON c.id = uc.car_id
+		WHERE uc.id = $1 AND uc.user_id = $2
+	`, id, userID))
+}
+
+// CreateUserCar creates a new car for a user, linked to the catalog car catalogCarID unless it is nil
+func (r *Repository) CreateUserCar(ctx context.Context, userID int, catalogCarID *int, name string, year int, comment string) (*models.UserCar, error) {
+	var id int
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO user_cars (user_id, car_id, name, year, comment, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
+		RETURNING id
+	`, userID, catalogCarID, name, year, comment).Scan(&id)
 	if err != nil {
 		return nil, err
 	}
-	return &car, nil
+	return r.GetUserCar(ctx, id, userID)
 }
 
 // UpdateUserCar updates a car
-func (r *Repository) UpdateUserCar(carID, userID int, name string, year int, comment string) error {
-	_, err := r.db.Exec(`
-		UPDATE user_cars SET name = $1, year = $2, comment = $3, updated_at = NOW()
-		WHERE id = $4 AND user_id = $5
-	`, name, year, comment, carID, userID)
+func (r *Repository) UpdateUserCar(ctx context.Context, carID, userID int, catalogCarID *int, name string, year int, comment string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE user_cars SET car_id = $1, name = $2, year = $3, comment = $4, updated_at = NOW()
+		WHERE id = $5 AND user_id = $6
+	`, catalogCarID, name, year, comment, carID, userID)
 	return err
 }
 
 // DeleteUserCar deletes a car
-func (r *Repository) DeleteUserCar(carID, userID int) error {
-	_, err := r.db.Exec("DELETE FROM user_cars WHERE id = $1 AND user_id = $2", carID, userID)
+func (r *Repository) DeleteUserCar(ctx context.Context, carID, userID int) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM user_cars WHERE id = $1 AND user_id = $2", carID, userID)
 	return err
 }
 
 // Guarantees Methods
 
 // GetUserGuarantees gets all active guarantees for a user (not expired) with appointment details
-func (r *Repository) GetUserGuarantees(userID int) ([]models.GuaranteeWithDetails, error) {
-	rows, err := r.db.Query(`
+func (r *Repository) GetUserGuarantees(ctx context.Context, userID int) ([]models.GuaranteeWithDetails, error) {
+	rows, err := r.db.QueryContext(ctx, `
 		SELECT 
 			g.id, g.user_id, g.appointment_id, g.service_name, g.master_name, 
 			g.service_date, g.expiry_date, g.created_at,
@@ -1359,10 +2370,10 @@ func (r *Repository) GetUserGuarantees(userID int) ([]models.GuaranteeWithDetail
 }
 
 // CreateGuarantee creates a guarantee for an appointment (14 days from service date)
-func (r *Repository) CreateGuarantee(userID, appointmentID int, serviceName, masterName string, serviceDate time.Time) (*models.Guarantee, error) {
+func (r *Repository) CreateGuarantee(ctx context.Context, userID, appointmentID int, serviceName, masterName string, serviceDate time.Time) (*models.Guarantee, error) {
 	expiryDate := serviceDate.AddDate(0, 0, 14) // 14 days guarantee
 	var g models.Guarantee
-	err := r.db.QueryRow(`
+	err := r.db.QueryRowContext(ctx, `
 		INSERT INTO guarantees (user_id, appointment_id, service_name, master_name, service_date, expiry_date, created_at)
 		VALUES ($1, $2, $3, $4, $5, $6, NOW())
 		RETURNING id, user_id, appointment_id, service_name, master_name, service_date, expiry_date, created_at
@@ -1377,8 +2388,8 @@ func (r *Repository) CreateGuarantee(userID, appointmentID int, serviceName, mas
 // Notifications Methods
 
 // GetUserNotifications gets all notifications for a user
-func (r *Repository) GetUserNotifications(userID int) ([]models.Notification, error) {
-	rows, err := r.db.Query(`
+func (r *Repository) GetUserNotifications(ctx context.Context, userID int) ([]models.Notification, error) {
+	rows, err := r.db.QueryContext(ctx, `
 		SELECT id, user_id, type, title, message, related_id, is_read, created_at
 		FROM notifications
 		WHERE user_id = $1
@@ -1401,9 +2412,9 @@ func (r *Repository) GetUserNotifications(userID int) ([]models.Notification, er
 }
 
 // CreateNotification creates a notification
-func (r *Repository) CreateNotification(userID int, notificationType, title, message string, relatedID int) (*models.Notification, error) {
+func (r *Repository) CreateNotification(ctx context.Context, userID int, notificationType, title, message string, relatedID int) (*models.Notification, error) {
 	var n models.Notification
-	err := r.db.QueryRow(`
+	err := r.db.QueryRowContext(ctx, `
 		INSERT INTO notifications (user_id, type, title, message, related_id, is_read, created_at)
 		VALUES ($1, $2, $3, $4, $5, false, NOW())
 		RETURNING id, user_id, type, title, message, related_id, is_read, created_at
@@ -1416,8 +2427,8 @@ func (r *Repository) CreateNotification(userID int, notificationType, title, mes
 }
 
 // MarkNotificationRead marks a notification as read
-func (r *Repository) MarkNotificationRead(notificationID, userID int) error {
-	_, err := r.db.Exec(`
+func (r *Repository) MarkNotificationRead(ctx context.Context, notificationID, userID int) error {
+	_, err := r.db.ExecContext(ctx, `
 		UPDATE notifications SET is_read = true
 		WHERE id = $1 AND user_id = $2
 	`, notificationID, userID)
@@ -1426,12 +2437,12 @@ func (r *Repository) MarkNotificationRead(notificationID, userID int) error {
 
 // GetMasterAppointmentsForNotifications gets appointments for a master (for notifications)
 // Returns appointments with customer information (name, phone, email) and car info
-func (r *Repository) GetMasterAppointmentsForNotifications(masterID int) ([]models.AppointmentWithDetails, error) {
-	rows, err := r.db.Query(`
+func (r *Repository) GetMasterAppointmentsForNotifications(ctx context.Context, masterID int) ([]models.AppointmentWithDetails, error) {
+	rows, err := r.db.QueryContext(ctx, `
 		SELECT 
 			a.id, a.user_id, a.master_id, a.service_id, a.date, a.time, a.status, a.comment,
 			a.created_at, a.updated_at, s.name as service_name, 
-			u.name as customer_name, u.email as customer_email, u.phone as customer_phone,
+			u.name as customer_name, COALESCE(u.email, '') as customer_email, COALESCE(u.phone, '') as customer_phone,
 			COALESCE(uc.name, 'Не указана') as car_name, uc.year as car_year
 		FROM appointments a
 		JOIN services s ON a.service_id = s.id
@@ -1490,8 +2501,8 @@ func (r *Repository) GetMasterAppointmentsForNotifications(masterID int) ([]mode
 // Master Certificates Methods
 
 // GetMasterCertificates gets all certificates for a master
-func (r *Repository) GetMasterCertificates(masterID int) ([]models.MasterCertificate, error) {
-	rows, err := r.db.Query(`
+func (r *Repository) GetMasterCertificates(ctx context.Context, masterID int) ([]models.MasterCertificate, error) {
+	rows, err := r.db.QueryContext(ctx, `
 		SELECT id, master_id, name, photo_url, created_at
 		FROM master_certificates
 		WHERE master_id = $1
@@ -1514,9 +2525,9 @@ func (r *Repository) GetMasterCertificates(masterID int) ([]models.MasterCertifi
 }
 
 // CreateMasterCertificate creates a new certificate for a master
-func (r *Repository) CreateMasterCertificate(masterID int, name, photoURL string) (*models.MasterCertificate, error) {
+func (r *Repository) CreateMasterCertificate(ctx context.Context, masterID int, name, photoURL string) (*models.MasterCertificate, error) {
 	var cert models.MasterCertificate
-	err := r.db.QueryRow(`
+	err := r.db.QueryRowContext(ctx, `
 		INSERT INTO master_certificates (master_id, name, photo_url, created_at)
 		VALUES ($1, $2, $3, NOW())
 		RETURNING id, master_id, name, photo_url, created_at
@@ -1529,10 +2540,373 @@ func (r *Repository) CreateMasterCertificate(masterID int, name, photoURL string
 }
 
 // DeleteMasterCertificate deletes a certificate
-func (r *Repository) DeleteMasterCertificate(certificateID, masterID int) error {
-	_, err := r.db.Exec(`
+func (r *Repository) DeleteMasterCertificate(ctx context.Context, certificateID, masterID int) error {
+	_, err := r.db.ExecContext(ctx, `
 		DELETE FROM master_certificates
 		WHERE id = $1 AND master_id = $2
 	`, certificateID, masterID)
 	return err
 }
+
+// Refresh Token Methods
+
+// ErrRefreshTokenReused is returned when a refresh token that was already rotated or revoked is presented again
+var ErrRefreshTokenReused = errors.New("refresh token reused")
+
+// CreateRefreshToken stores a refresh token
+func (r *Repository) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	return r.db.QueryRowContext(ctx, `
+		INSERT INTO refresh_tokens (user_id, family_id, token_hash, device, ip_address, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		RETURNING id, created_at
+	`, token.UserID, token.FamilyID, token.TokenHash, token.Device, token.IPAddress, token.ExpiresAt).
+		Scan(&token.ID, &token.CreatedAt)
+}
+
+// GetRefreshTokenByHash gets a refresh token by its hash
+func (r *Repository) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	var device, ipAddress sql.NullString
+	var rotatedAt, revokedAt sql.NullTime
+
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, user_id, family_id, token_hash, device, ip_address, expires_at, rotated_at, revoked_at, created_at
+		FROM refresh_tokens WHERE token_hash = $1
+	`, tokenHash).Scan(&token.ID, &token.UserID, &token.FamilyID, &token.TokenHash, &device, &ipAddress,
+		&token.ExpiresAt, &rotatedAt, &revokedAt, &token.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if device.Valid {
+		token.Device = device.String
+	}
+	if ipAddress.Valid {
+		token.IPAddress = ipAddress.String
+	}
+	if rotatedAt.Valid {
+		token.RotatedAt = &rotatedAt.Time
+	}
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Time
+	}
+	return &token, nil
+}
+
+// RotateRefreshToken marks the old token as rotated and stores its replacement in one transaction.
+// Returns ErrRefreshTokenReused if the old token was rotated or revoked concurrently.
+func (r *Repository) RotateRefreshToken(ctx context.Context, oldTokenID int, next *models.RefreshToken) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE refresh_tokens SET rotated_at = NOW()
+		WHERE id = $1 AND rotated_at IS NULL AND revoked_at IS NULL
+	`, oldTokenID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRefreshTokenReused
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO refresh_tokens (user_id, family_id, token_hash, device, ip_address, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		RETURNING id, created_at
+	`, next.UserID, next.FamilyID, next.TokenHash, next.Device, next.IPAddress, next.ExpiresAt).
+		Scan(&next.ID, &next.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RevokeRefreshTokenFamily revokes every token of a user's session
+func (r *Repository) RevokeRefreshTokenFamily(ctx context.Context, userID int, familyID string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE refresh_tokens SET revoked_at = NOW()
+		WHERE user_id = $1 AND family_id = $2 AND revoked_at IS NULL
+	`, userID, familyID)
+	return err
+}
+
+// RevokeUserRefreshTokens revokes every session of a user
+func (r *Repository) RevokeUserRefreshTokens(ctx context.Context, userID int) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE refresh_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL", userID)
+	return err
+}
+
+// GetUserSessions gets active sessions for a user, one per refresh token family
+func (r *Repository) GetUserSessions(ctx context.Context, userID int) ([]models.Session, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT rt.family_id, rt.device, rt.ip_address, f.started_at, rt.created_at, rt.expires_at
+		FROM refresh_tokens rt
+		JOIN (
+			SELECT family_id, MIN(created_at) AS started_at
+			FROM refresh_tokens WHERE user_id = $1
+			GROUP BY family_id
+		) f ON f.family_id = rt.family_id
+		WHERE rt.user_id = $1 AND rt.rotated_at IS NULL AND rt.revoked_at IS NULL AND rt.expires_at > NOW()
+		ORDER BY rt.created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []models.Session{}
+	for rows.Next() {
+		var s models.Session
+		var device, ipAddress sql.NullString
+		if err := rows.Scan(&s.ID, &device, &ipAddress, &s.StartedAt, &s.LastUsedAt, &s.ExpiresAt); err != nil {
+			return nil, err
+		}
+		if device.Valid {
+			s.Device = device.String
+		}
+		if ipAddress.Valid {
+			s.IPAddress = ipAddress.String
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, nil
+}
+
+// Auth Token Methods
+
+// CreateAuthToken stores a single-use token, invalidating earlier unused tokens of the same purpose
+func (r *Repository) CreateAuthToken(ctx context.Context, userID int, purpose, tokenHash string, expiresAt time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE auth_tokens SET used_at = NOW()
+		WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL
+	`, userID, purpose)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO auth_tokens (user_id, purpose, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, NOW())
+	`, userID, purpose, tokenHash, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ConsumeAuthToken marks an unused, unexpired token as used and returns its user ID.
+// Returns sql.ErrNoRows if the token is unknown, expired or already used.
+func (r *Repository) ConsumeAuthToken(ctx context.Context, purpose, tokenHash string) (int, error) {
+	var userID int
+	err := r.db.QueryRowContext(ctx, `
+		UPDATE auth_tokens SET used_at = NOW()
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id
+	`, tokenHash, purpose).Scan(&userID)
+	return userID, err
+}
+
+// MarkEmailVerified records that the user confirmed their email address
+func (r *Repository) MarkEmailVerified(ctx context.Context, userID int) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE users SET email_verified_at = COALESCE(email_verified_at, NOW()), updated_at = NOW() WHERE id = $1", userID)
+	return err
+}
+
+// OTP Methods
+
+// CountRecentOTPCodes counts codes sent to a phone since the given time and returns when the latest one was sent
+func (r *Repository) CountRecentOTPCodes(ctx context.Context, phone string, since time.Time) (int, *time.Time, error) {
+	var count int
+	var lastSentAt sql.NullTime
+	err := r.db.QueryRowContext(ctx, `
+		SELECT COUNT(*), MAX(created_at) FROM otp_codes WHERE phone = $1 AND created_at >= $2
+	`, phone, since).Scan(&count, &lastSentAt)
+	if err != nil {
+		return 0, nil, err
+	}
+	if lastSentAt.Valid {
+		return count, &lastSentAt.Time, nil
+	}
+	return count, nil, nil
+}
+
+// CreateOTPCode stores a new code for a phone, invalidating earlier unused ones
+func (r *Repository) CreateOTPCode(ctx context.Context, phone, codeHash, ipAddress string, expiresAt time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "UPDATE otp_codes SET consumed_at = NOW() WHERE phone = $1 AND consumed_at IS NULL", phone); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO otp_codes (phone, code_hash, ip_address, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, NOW())
+	`, phone, codeHash, ipAddress, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetActiveOTPCode gets the latest unused, unexpired code for a phone
+func (r *Repository) GetActiveOTPCode(ctx context.Context, phone string) (*models.OTPCode, error) {
+	var code models.OTPCode
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, phone, code_hash, expires_at, attempts, created_at
+		FROM otp_codes
+		WHERE phone = $1 AND consumed_at IS NULL AND expires_at > NOW()
+		ORDER BY created_at DESC LIMIT 1
+	`, phone).Scan(&code.ID, &code.Phone, &code.CodeHash, &code.ExpiresAt, &code.Attempts, &code.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+// RegisterOTPAttempt counts a verification attempt against a code.
+// Returns sql.ErrNoRows if the code has already used up maxAttempts.
+func (r *Repository) RegisterOTPAttempt(ctx context.Context, codeID, maxAttempts int) (int, error) {
+	var attempts int
+	err := r.db.QueryRowContext(ctx, `
+		UPDATE otp_codes SET attempts = attempts + 1
+		WHERE id = $1 AND attempts < $2 AND consumed_at IS NULL
+		RETURNING attempts
+	`, codeID, maxAttempts).Scan(&attempts)
+	return attempts, err
+}
+
+// ConsumeOTPCode marks a code as used. Returns sql.ErrNoRows if it was used concurrently.
+func (r *Repository) ConsumeOTPCode(ctx context.Context, codeID int) error {
+	result, err := r.db.ExecContext(ctx, "UPDATE otp_codes SET consumed_at = NOW() WHERE id = $1 AND consumed_at IS NULL", codeID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetUserByVerifiedPhone gets the user who confirmed the given phone, normalized to +7XXXXXXXXXX, with a login code
+func (r *Repository) GetUserByVerifiedPhone(ctx context.Context, phone string) (*models.User, error) {
+	var user models.User
+	var photoURL sql.NullString
+	var emailVerifiedAt sql.NullTime
+	var phoneVerifiedAt sql.NullTime
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, name, COALESCE(email, ''), COALESCE(phone, ''), photo_url, COALESCE(password_hash, ''), role, email_verified_at, phone_verified_at, time_zone, created_at, updated_at
+		FROM users WHERE phone = $1 AND phone_verified_at IS NOT NULL
+	`, phone).Scan(&user.ID, &user.Name, &user.Email, &user.Phone, &photoURL, &user.PasswordHash, &user.Role, &emailVerifiedAt, &phoneVerifiedAt, &user.TimeZone, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if photoURL.Valid {
+		user.PhotoURL = photoURL.String
+	}
+	if emailVerifiedAt.Valid {
+		user.EmailVerifiedAt = &emailVerifiedAt.Time
+	}
+	if phoneVerifiedAt.Valid {
+		user.PhoneVerifiedAt = &phoneVerifiedAt.Time
+	}
+	return &user, nil
+}
+
+// CreatePhoneUser creates a customer identified only by a phone number they have just verified
+func (r *Repository) CreatePhoneUser(ctx context.Context, name, phone string) (*models.User, error) {
+	var user models.User
+	var phoneVerifiedAt time.Time
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO users (name, phone, phone_verified_at, role, created_at, updated_at)
+		VALUES ($1, $2, NOW(), 'customer', NOW(), NOW())
+		RETURNING id, name, COALESCE(email, ''), phone, phone_verified_at, role, time_zone, created_at, updated_at
+	`, name, phone).Scan(&user.ID, &user.Name, &user.Email, &user.Phone, &phoneVerifiedAt, &user.Role, &user.TimeZone, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	user.PhoneVerifiedAt = &phoneVerifiedAt
+	return &user, nil
+}
+
+// MarkPhoneVerified records that the user confirmed their current phone with a login code.
+// Whoever verified the number before loses it, as numbers get reassigned.
+// Returns sql.ErrNoRows if the user's phone is no longer the given one.
+func (r *Repository) MarkPhoneVerified(ctx context.Context, userID int, phone string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET phone_verified_at = NULL, updated_at = NOW() WHERE phone = $1 AND id <> $2 AND phone_verified_at IS NOT NULL", phone, userID); err != nil {
+		return err
+	}
+	result, err := tx.ExecContext(ctx, "UPDATE users SET phone_verified_at = NOW(), updated_at = NOW() WHERE id = $1 AND phone = $2", userID, phone)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return tx.Commit()
+}
+
+// Maintenance Methods
+
+// PurgeExpiredAuthData deletes refresh tokens, single-use tokens and OTP codes that can no longer be used.
+// Refresh tokens are kept until their whole session has expired so reuse detection keeps working.
+// OTP codes are kept for a day because request limits count recent codes.
+func (r *Repository) PurgeExpiredAuthData(ctx context.Context) (int64, error) {
+	queries := []string{
+		`DELETE FROM refresh_tokens WHERE family_id IN (
+			SELECT family_id FROM refresh_tokens GROUP BY family_id HAVING MAX(expires_at) < NOW()
+		)`,
+		"DELETE FROM auth_tokens WHERE expires_at < NOW() OR used_at IS NOT NULL",
+		"DELETE FROM otp_codes WHERE created_at < NOW() - INTERVAL '1 day'",
+	}
+
+	var total int64
+	for _, query := range queries {
+		result, err := r.db.ExecContext(ctx, query)
+		if err != nil {
+			return total, err
+		}
+		deleted, err := result.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+		total += deleted
+	}
+	return total, nil
+}
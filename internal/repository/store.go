@@ -0,0 +1,209 @@
+package repository
+
+import (
+	"beep-backend/internal/models"
+	"context"
+	"time"
+)
+
+// Store is everything the HTTP handlers need from persistence. Repository implements it
+// on PostgreSQL; the memory package provides an in-process implementation for tests.
+type Store interface {
+	CatalogStore
+	PricingStore
+	UserStore
+	MasterStore
+	ReviewStore
+	AppointmentStore
+	RescheduleStore
+	NotificationStore
+	SubscriptionStore
+	FavoriteStore
+	UserCarStore
+	GuaranteeStore
+	AuthStore
+}
+
+var _ Store = (*Repository)(nil)
+
+// CatalogStore reads service categories, services and car models
+type CatalogStore interface {
+	GetAllCategories(ctx context.Context) ([]models.Category, error)
+	GetCategoryByID(ctx context.Context, id int) (*models.Category, error)
+	GetAllServices(ctx context.Context) ([]models.Service, error)
+	GetServicesByCategory(ctx context.Context, categoryID int) ([]models.Service, error)
+	GetServiceByID(ctx context.Context, id int) (*models.Service, error)
+	GetAllCars(ctx context.Context) ([]models.Car, error)
+	GetCarByID(ctx context.Context, id int) (*models.Car, error)
+}
+
+// PricingStore manages the pricing rules, price zones and bundle discounts service prices are calculated from,
+// and the price quotes given to customers
+type PricingStore interface {
+	GetPricingRules(ctx context.Context, serviceID int) ([]models.PricingRule, error)
+	GetPricingRule(ctx context.Context, id int) (*models.PricingRule, error)
+	CreatePricingRule(ctx context.Context, rule *models.PricingRule) error
+	UpdatePricingRule(ctx context.Context, rule *models.PricingRule) error
+	DeletePricingRule(ctx context.Context, id int) error
+	GetPriceZones(ctx context.Context) ([]models.PriceZone, error)
+	GetPriceZone(ctx context.Context, id int) (*models.PriceZone, error)
+	CreatePriceZone(ctx context.Context, zone *models.PriceZone) error
+	UpdatePriceZone(ctx context.Context, zone *models.PriceZone) error
+	DeletePriceZone(ctx context.Context, id int) error
+	GetBundleDiscounts(ctx context.Context) ([]models.BundleDiscount, error)
+	CreateBundleDiscount(ctx context.Context, discount *models.BundleDiscount) error
+	UpdateBundleDiscount(ctx context.Context, discount *models.BundleDiscount) error
+	DeleteBundleDiscount(ctx context.Context, id int) error
+	CreatePriceQuote(ctx context.Context, quote *models.PriceQuote) error
+	GetPriceQuote(ctx context.Context, id int) (*models.PriceQuote, error)
+	PurgeExpiredPriceQuotes(ctx context.Context) (int64, error)
+}
+
+// UserStore manages user accounts
+type UserStore interface {
+	GetUserByID(ctx context.Context, id int) (*models.User, error)
+	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
+	GetUserByVerifiedPhone(ctx context.Context, phone string) (*models.User, error)
+	CreateUser(ctx context.Context, name, email, phone, passwordHash string) (*models.User, error)
+	CreatePhoneUser(ctx context.Context, name, phone string) (*models.User, error)
+	UpdateUserProfile(ctx context.Context, userID int, name, email, phone string) error
+	UpdateUserRole(ctx context.Context, userID int, role string) error
+	UpdateUserPassword(ctx context.Context, userID int, passwordHash string) error
+	UpdateUserPhoto(ctx context.Context, userID int, photoURL string) error
+	UpdateUserTimeZone(ctx context.Context, userID int, timeZone string) error
+	MarkEmailVerified(ctx context.Context, userID int) error
+	MarkPhoneVerified(ctx context.Context, userID int, phone string) error
+}
+
+// MasterStore manages master profiles and everything a master publishes about themselves
+type MasterStore interface {
+	GetAllMasters(ctx context.Context) ([]models.Master, error)
+	GetMasterByID(ctx context.Context, id int) (*models.Master, error)
+	GetMasterByUserID(ctx context.Context, userID int) (*models.Master, error)
+	CreateMaster(ctx context.Context, userID int, name, email, phone, specialization, address string) (*models.Master, error)
+	UpdateMaster(ctx context.Context, masterID int, name, email, phone, specialization, address string) error
+	UpdateMasterPhoto(ctx context.Context, masterID int, photoURL string) error
+	UpdateMasterTimeZone(ctx context.Context, masterID int, timeZone string) error
+	DeleteMasterProfile(ctx context.Context, userID int) error
+	CheckMasterVerificationStatus(ctx context.Context, masterID int) (bool, int, int, error)
+
+	GetMasterSchedule(ctx context.Context, masterID int) ([]models.MasterSchedule, error)
+	UpdateMasterSchedule(ctx context.Context, masterID int, schedules []models.MasterSchedule) error
+	GetScheduleExceptions(ctx context.Context, masterID int, from, to time.Time) ([]models.ScheduleException, error)
+	GetScheduleException(ctx context.Context, exceptionID, masterID int) (*models.ScheduleException, error)
+	CreateScheduleException(ctx context.Context, exception *models.ScheduleException) error
+	UpdateScheduleException(ctx context.Context, exception *models.ScheduleException) error
+	DeleteScheduleException(ctx context.Context, exceptionID, masterID int) error
+
+	GetMasterServices(ctx context.Context, masterID int) ([]models.MasterService, error)
+	GetMasterService(ctx context.Context, masterID, serviceID int) (*models.MasterService, error)
+	GetServiceOffers(ctx context.Context, serviceID int) ([]models.MasterService, error)
+	SaveMasterService(ctx context.Context, offer *models.MasterService) error
+	DeleteMasterService(ctx context.Context, masterID, serviceID int) error
+
+	GetMasterWorks(ctx context.Context, masterID int) ([]models.MasterWork, error)
+	GetMasterWork(ctx context.Context, workID, masterID int) (*models.MasterWork, error)
+	CreateMasterWork(ctx context.Context, masterID int, title string, workDate time.Time, customerName string, amount float64, photoURLs []string) (*models.MasterWork, error)
+	UpdateMasterWork(ctx context.Context, workID, masterID int, title string, workDate time.Time, customerName string, amount float64, photoURLs []string) error
+	DeleteMasterWork(ctx context.Context, workID, masterID int) error
+
+	GetMasterPaymentInfo(ctx context.Context, masterID int) (*models.MasterPaymentInfo, error)
+	UpdateMasterPaymentInfo(ctx context.Context, masterID int, kaspiCard, freedomCard, halykCard string) error
+
+	GetMasterCertificates(ctx context.Context, masterID int) ([]models.MasterCertificate, error)
+	CreateMasterCertificate(ctx context.Context, masterID int, name, photoURL string) (*models.MasterCertificate, error)
+	DeleteMasterCertificate(ctx context.Context, certificateID, masterID int) error
+}
+
+// ReviewStore manages reviews of masters
+type ReviewStore interface {
+	GetMasterReviews(ctx context.Context, masterID int) ([]models.ReviewWithUser, error)
+	CreateReview(ctx context.Context, masterID, userID, rating int, comment string) (*models.Review, error)
+	DeleteReview(ctx context.Context, reviewID, masterID int) error
+}
+
+// AppointmentStore manages bookings and master availability
+type AppointmentStore interface {
+	GetBookedIntervals(ctx context.Context, masterID int, from, to time.Time) ([]models.TimeRange, error)
+	CreateBooking(ctx context.Context, booking *models.Booking) error
+	GetMasterAppointmentsBetween(ctx context.Context, masterID int, from, to time.Time) ([]models.ScheduledAppointment, error)
+	ChangeAppointmentStatus(ctx context.Context, appointmentID int, event *models.AppointmentEvent, notifications []models.Notification) error
+	MoveBooking(ctx context.Context, appointmentID int, slot models.TimeRange, event *models.AppointmentEvent, notifications []models.Notification) error
+	GetAppointmentByID(ctx context.Context, appointmentID int) (*models.Appointment, error)
+	GetScheduledAppointment(ctx context.Context, appointmentID int) (*models.ScheduledAppointment, error)
+	GetAppointmentEvents(ctx context.Context, appointmentID int) ([]models.AppointmentEvent, error)
+	GetAppointmentItems(ctx context.Context, appointmentID int) ([]models.AppointmentItem, error)
+	GetUserAppointments(ctx context.Context, userID int) ([]models.AppointmentWithDetails, error)
+	GetMasterAppointmentsForNotifications(ctx context.Context, masterID int) ([]models.AppointmentWithDetails, error)
+	UpdateAppointmentComment(ctx context.Context, appointmentID int, comment string) error
+	DeleteAppointment(ctx context.Context, appointmentID int, event *models.AppointmentEvent) error
+}
+
+// RescheduleStore manages customers' requests to move appointments and the slots they hold
+type RescheduleStore interface {
+	CreateRescheduleRequest(ctx context.Context, request *models.RescheduleRequest, event *models.AppointmentEvent, notifications []models.Notification) error
+	GetRescheduleRequest(ctx context.Context, requestID int) (*models.RescheduleRequest, error)
+	GetAppointmentRescheduleRequests(ctx context.Context, appointmentID int) ([]models.RescheduleRequest, error)
+	GetMasterRescheduleRequests(ctx context.Context, masterID int, status string) ([]models.RescheduleRequest, error)
+	AcceptRescheduleRequest(ctx context.Context, requestID int, event *models.AppointmentEvent, notifications []models.Notification) error
+	CloseRescheduleRequest(ctx context.Context, requestID int, status, response string, event *models.AppointmentEvent, notifications []models.Notification) error
+}
+
+// NotificationStore manages in-app notifications
+type NotificationStore interface {
+	GetUserNotifications(ctx context.Context, userID int) ([]models.Notification, error)
+	CreateNotification(ctx context.Context, userID int, notificationType, title, message string, relatedID int) (*models.Notification, error)
+	MarkNotificationRead(ctx context.Context, notificationID, userID int) error
+}
+
+// SubscriptionStore manages user subscription plans
+type SubscriptionStore interface {
+	GetUserSubscription(ctx context.Context, userID int) (*models.Subscription, error)
+	CreateUserSubscription(ctx context.Context, userID int, plan string) (*models.Subscription, error)
+	StartTrial(ctx context.Context, userID int) (*models.Subscription, error)
+	UpdateUserSubscription(ctx context.Context, userID int, plan string) error
+}
+
+// FavoriteStore manages users' favorite masters
+type FavoriteStore interface {
+	GetFavoriteMasters(ctx context.Context, userID int) ([]models.Master, error)
+	IsFavoriteMaster(ctx context.Context, userID, masterID int) (bool, error)
+	AddFavoriteMaster(ctx context.Context, userID, masterID int) error
+	RemoveFavoriteMaster(ctx context.Context, userID, masterID int) error
+}
+
+// UserCarStore manages the cars users keep in their profile
+type UserCarStore interface {
+	GetUserCars(ctx context.Context, userID int) ([]models.UserCar, error)
+	GetUserCar(ctx context.Context, id, userID int) (*models.UserCar, error)
+	CreateUserCar(ctx context.Context, userID int, catalogCarID *int, name string, year int, comment string) (*models.UserCar, error)
+	UpdateUserCar(ctx context.Context, carID, userID int, catalogCarID *int, name string, year int, comment string) error
+	DeleteUserCar(ctx context.Context, carID, userID int) error
+}
+
+// GuaranteeStore manages service guarantees
+type GuaranteeStore interface {
+	GetUserGuarantees(ctx context.Context, userID int) ([]models.GuaranteeWithDetails, error)
+	CreateGuarantee(ctx context.Context, userID, appointmentID int, serviceName, masterName string, serviceDate time.Time) (*models.Guarantee, error)
+}
+
+// AuthStore manages refresh tokens, single-use auth tokens and OTP codes
+type AuthStore interface {
+	CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error
+	GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error)
+	RotateRefreshToken(ctx context.Context, oldTokenID int, next *models.RefreshToken) error
+	RevokeRefreshTokenFamily(ctx context.Context, userID int, familyID string) error
+	RevokeUserRefreshTokens(ctx context.Context, userID int) error
+	GetUserSessions(ctx context.Context, userID int) ([]models.Session, error)
+
+	CreateAuthToken(ctx context.Context, userID int, purpose, tokenHash string, expiresAt time.Time) error
+	ConsumeAuthToken(ctx context.Context, purpose, tokenHash string) (int, error)
+
+	CountRecentOTPCodes(ctx context.Context, phone string, since time.Time) (int, *time.Time, error)
+	CreateOTPCode(ctx context.Context, phone, codeHash, ipAddress string, expiresAt time.Time) error
+	GetActiveOTPCode(ctx context.Context, phone string) (*models.OTPCode, error)
+	RegisterOTPAttempt(ctx context.Context, codeID, maxAttempts int) (int, error)
+	ConsumeOTPCode(ctx context.Context, codeID int) error
+
+	PurgeExpiredAuthData(ctx context.Context) (int64, error)
+}
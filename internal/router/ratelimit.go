@@ -0,0 +1,265 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"beep-backend/internal/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Rate limit policy names used by SetupRouter
+const (
+	RateLimitLogin         = "login"
+	RateLimitRegister      = "register"
+	RateLimitOTP           = "otp"
+	RateLimitPasswordReset = "password_reset"
+	RateLimitReview        = "review"
+	RateLimitAppointment   = "appointment"
+	RateLimitQuote         = "quote"
+)
+
+// DefaultRateLimitPolicies are applied when no override is configured
+var DefaultRateLimitPolicies = map[string]string{
+	RateLimitLogin:         "ip=10/1m",
+	RateLimitRegister:      "ip=5/1h",
+	RateLimitOTP:           "ip=10/1h",
+	RateLimitPasswordReset: "ip=5/1h",
+	RateLimitReview:        "user=10/1h,ip=30/1h",
+	RateLimitAppointment:   "user=20/1h,ip=60/1h",
+	RateLimitQuote:         "user=60/1h,ip=120/1h",
+}
+
+// RateLimit allows Requests per Period. Tokens refill continuously, so short bursts
+// up to Requests are allowed after a quiet period.
+type RateLimit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// Enabled reports whether the limit is set
+func (l RateLimit) Enabled() bool {
+	return l.Requests > 0 && l.Period > 0
+}
+
+func (l RateLimit) String() string {
+	return fmt.Sprintf("%d/%s", l.Requests, l.Period)
+}
+
+// ParseRateLimit parses a limit like "10/1m"
+func ParseRateLimit(spec string) (RateLimit, error) {
+	parts := strings.SplitN(strings.TrimSpace(spec), "/", 2)
+	if len(parts) != 2 {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %q, expected <requests>/<period>", spec)
+	}
+	requests, err := strconv.Atoi(parts[0])
+	if err != nil || requests <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid request count in rate limit %q", spec)
+	}
+	period, err := time.ParseDuration(parts[1])
+	if err != nil || period <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid period in rate limit %q", spec)
+	}
+	return RateLimit{Requests: requests, Period: period}, nil
+}
+
+// RateLimitPolicy limits a group of routes per client IP and per authenticated user.
+// Either limit may be left zero to disable it.
+type RateLimitPolicy struct {
+	PerIP   RateLimit
+	PerUser RateLimit
+}
+
+// ParseRateLimitPolicy parses a policy like "user=10/1h,ip=30/1h"
+func ParseRateLimitPolicy(spec string) (RateLimitPolicy, error) {
+	var policy RateLimitPolicy
+	for _, part := range strings.Split(spec, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return policy, fmt.Errorf("invalid rate limit policy %q, expected ip=<limit> or user=<limit>", spec)
+		}
+		limit, err := ParseRateLimit(value)
+		if err != nil {
+			return policy, err
+		}
+		switch key {
+		case "ip":
+			policy.PerIP = limit
+		case "user":
+			policy.PerUser = limit
+		default:
+			return policy, fmt.Errorf("unknown rate limit key %q in policy %q", key, spec)
+		}
+	}
+	return policy, nil
+}
+
+// RateLimitStore keeps token buckets. Implementations backed by a shared store
+// (e.g. Redis) let several instances enforce the same limits.
+type RateLimitStore interface {
+	// Allow takes a token from each of the buckets, or from none of them if any is empty.
+	// In that case it returns false and how long until every bucket has a token again.
+	Allow(ctx context.Context, buckets ...RateLimitBucket) (bool, time.Duration, error)
+}
+
+// RateLimitBucket names a token bucket and the limit it refills at
+type RateLimitBucket struct {
+	Key   string
+	Limit RateLimit
+}
+
+// MemoryRateLimitStore keeps buckets in process memory
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type tokenBucket struct {
+	tokens    float64
+	updatedAt time.Time
+	period    time.Duration
+}
+
+// rateLimitSweepInterval is how often idle buckets are dropped from memory
+const rateLimitSweepInterval = time.Minute
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets: make(map[string]*tokenBucket),
+		now:     time.Now,
+	}
+}
+
+func (s *MemoryRateLimitStore) Allow(ctx context.Context, buckets ...RateLimitBucket) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	// Refill every bucket first so a request rejected by one limit does not use up the others
+	var wait time.Duration
+	refilled := make([]*tokenBucket, len(buckets))
+	for i, b := range buckets {
+		bucket := s.refill(b, now)
+		refilled[i] = bucket
+		if bucket.tokens < 1 {
+			perToken := b.Limit.Period / time.Duration(b.Limit.Requests)
+			if w := time.Duration((1 - bucket.tokens) * float64(perToken)); w > wait {
+				wait = w
+			}
+		}
+	}
+	if wait > 0 {
+		return false, wait, nil
+	}
+
+	for _, bucket := range refilled {
+		bucket.tokens--
+	}
+	return true, 0, nil
+}
+
+// refill adds the tokens earned since the bucket was last used, creating a full bucket for a new key
+func (s *MemoryRateLimitStore) refill(b RateLimitBucket, now time.Time) *tokenBucket {
+	capacity := float64(b.Limit.Requests)
+	perToken := b.Limit.Period / time.Duration(b.Limit.Requests)
+
+	bucket, ok := s.buckets[b.Key]
+	if !ok {
+		bucket = &tokenBucket{tokens: capacity, updatedAt: now}
+		s.buckets[b.Key] = bucket
+	}
+	bucket.period = b.Limit.Period
+
+	elapsed := now.Sub(bucket.updatedAt)
+	bucket.tokens = math.Min(capacity, bucket.tokens+float64(elapsed)/float64(perToken))
+	bucket.updatedAt = now
+	return bucket
+}
+
+// sweep drops buckets that have been idle long enough to refill completely
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < rateLimitSweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, bucket := range s.buckets {
+		if now.Sub(bucket.updatedAt) > bucket.period {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// RateLimiter applies named policies to routes
+type RateLimiter struct {
+	store    RateLimitStore
+	policies map[string]RateLimitPolicy
+}
+
+func NewRateLimiter(store RateLimitStore, policies map[string]RateLimitPolicy) *RateLimiter {
+	return &RateLimiter{store: store, policies: policies}
+}
+
+// Limit returns middleware enforcing the named policy. A nil limiter or an
+// unknown policy lets every request through.
+func (l *RateLimiter) Limit(name string) gin.HandlerFunc {
+	if l == nil {
+		return func(c *gin.Context) { c.Next() }
+	}
+	policy, ok := l.policies[name]
+	if !ok {
+		log.Printf("Warning: Rate limit policy %q is not configured", name)
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	return func(c *gin.Context) {
+		var buckets []RateLimitBucket
+		if policy.PerUser.Enabled() {
+			if userID, ok := handlers.CurrentUserID(c); ok {
+				buckets = append(buckets, RateLimitBucket{Key: fmt.Sprintf("%s:user:%d", name, userID), Limit: policy.PerUser})
+			}
+		}
+		if policy.PerIP.Enabled() {
+			buckets = append(buckets, RateLimitBucket{Key: fmt.Sprintf("%s:ip:%s", name, c.ClientIP()), Limit: policy.PerIP})
+		}
+		if len(buckets) > 0 && !l.allow(c, name, buckets) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// allow takes a token from every bucket and aborts the request with 429 if any is empty.
+// Store failures are logged and the request is let through.
+func (l *RateLimiter) allow(c *gin.Context, name string, buckets []RateLimitBucket) bool {
+	allowed, retryAfter, err := l.store.Allow(c.Request.Context(), buckets...)
+	if err != nil {
+		log.Printf("Warning: Rate limit store failed for %s: %v", name, err)
+		return true
+	}
+	if allowed {
+		return true
+	}
+
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+		"error":       "Too many requests, please try again later",
+		"retry_after": seconds,
+	})
+	return false
+}
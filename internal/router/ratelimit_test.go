@@ -0,0 +1,73 @@
+package router
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryRateLimitStoreAllow(t *testing.T) {
+	now := time.Date(2030, time.January, 7, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryRateLimitStore()
+	store.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	user := RateLimitBucket{Key: "quote:user:1", Limit: RateLimit{Requests: 1, Period: time.Hour}}
+	otherUser := RateLimitBucket{Key: "quote:user:2", Limit: RateLimit{Requests: 1, Period: time.Hour}}
+	ip := RateLimitBucket{Key: "quote:ip:10.0.0.1", Limit: RateLimit{Requests: 2, Period: time.Hour}}
+
+	if ok, _, _ := store.Allow(ctx, user, ip); !ok {
+		t.Fatal("first request rejected")
+	}
+	// The user is over their limit; the shared IP must not pay for the rejected request
+	for i := 0; i < 3; i++ {
+		ok, wait, err := store.Allow(ctx, user, ip)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok {
+			t.Fatal("request over the user limit allowed")
+		}
+		if wait != time.Hour {
+			t.Errorf("wait = %s, want %s", wait, time.Hour)
+		}
+	}
+	if ok, _, _ := store.Allow(ctx, otherUser, ip); !ok {
+		t.Fatal("another user behind the same IP rejected")
+	}
+
+	// Now the IP is exhausted; a fresh user's bucket must not be debited either
+	fresh := RateLimitBucket{Key: "quote:user:3", Limit: RateLimit{Requests: 1, Period: time.Hour}}
+	ok, wait, _ := store.Allow(ctx, fresh, ip)
+	if ok {
+		t.Fatal("request over the IP limit allowed")
+	}
+	if wait != 30*time.Minute {
+		t.Errorf("wait = %s, want %s", wait, 30*time.Minute)
+	}
+	if ok, _, _ := store.Allow(ctx, fresh); !ok {
+		t.Error("user bucket was debited by a request rejected for the IP")
+	}
+
+	// Tokens refill over time
+	now = now.Add(30 * time.Minute)
+	if ok, _, _ := store.Allow(ctx, ip); !ok {
+		t.Error("IP bucket did not refill")
+	}
+}
+
+func TestParseRateLimitPolicy(t *testing.T) {
+	policy, err := ParseRateLimitPolicy("user=10/1h, ip=30/1m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := RateLimitPolicy{PerUser: RateLimit{Requests: 10, Period: time.Hour}, PerIP: RateLimit{Requests: 30, Period: time.Minute}}
+	if policy != want {
+		t.Errorf("policy = %+v, want %+v", policy, want)
+	}
+	for _, spec := range []string{"", "ip", "ip=10", "ip=0/1h", "ip=10/0s", "ip=10/soon", "host=10/1h"} {
+		if _, err := ParseRateLimitPolicy(spec); err == nil {
+			t.Errorf("ParseRateLimitPolicy(%q) accepted", spec)
+		}
+	}
+}
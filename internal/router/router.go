@@ -1,19 +1,48 @@
 package router
 
 import (
+	"context"
+	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"beep-backend/internal/handlers"
+	"beep-backend/internal/models"
 
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRouter(h *handlers.Handlers) *gin.Engine {
+// Options configures SetupRouter
+type Options struct {
+	// Limiter throttles abuse-prone routes; nil disables rate limiting
+	Limiter *RateLimiter
+	// CORSOrigins lists origins allowed to call the API; "*" allows any
+	CORSOrigins []string
+	// TrustedProxies lists the reverse proxies whose X-Forwarded-For sets the client IP; empty trusts none
+	TrustedProxies []string
+	// RequestTimeout bounds the database work of each API request; zero disables the deadline
+	RequestTimeout time.Duration
+	// UploadDir is served under UploadURLPath
+	UploadDir     string
+	UploadURLPath string
+	// Feature toggles
+	Registration bool
+	PhoneLogin   bool
+}
+
+func SetupRouter(h *handlers.Handlers, opts Options) *gin.Engine {
 	r := gin.Default()
+	// Without trusted proxies ClientIP is the connection's address, so rate limits cannot be
+	// dodged with a forged X-Forwarded-For
+	if err := r.SetTrustedProxies(opts.TrustedProxies); err != nil {
+		log.Printf("Warning: Invalid trusted proxies %v, trusting none: %v", opts.TrustedProxies, err)
+		r.SetTrustedProxies(nil)
+	}
+	limiter := opts.Limiter
 
 	// CORS middleware
-	r.Use(corsMiddleware())
+	r.Use(corsMiddleware(opts.CORSOrigins))
 
 	// Health check
 	r.GET("/health", func(c *gin.Context) {
@@ -22,19 +51,36 @@ func SetupRouter(h *handlers.Handlers) *gin.Engine {
 
 	// API v1
 	v1 := r.Group("/api/v1")
+	v1.Use(requestTimeoutMiddleware(opts.RequestTimeout))
+	v1.Use(h.Authenticate())
 	{
 		// Auth
 		auth := v1.Group("/auth")
 		{
-			auth.POST("/login", h.Login)
-			auth.POST("/register", h.Register)
+			auth.POST("/login", limiter.Limit(RateLimitLogin), h.Login)
+			if opts.Registration {
+				auth.POST("/register", limiter.Limit(RateLimitRegister), h.Register)
+			}
+			auth.POST("/refresh", h.RefreshToken)
+			auth.POST("/logout", h.Logout)
+			auth.POST("/logout-all", h.RequireAuth(), h.LogoutAll)
+			auth.POST("/forgot-password", limiter.Limit(RateLimitPasswordReset), h.ForgotPassword)
+			auth.POST("/reset-password", limiter.Limit(RateLimitPasswordReset), h.ResetPassword)
+			auth.POST("/verify-email", h.VerifyEmail)
+			auth.POST("/verify-email/resend", h.RequireAuth(), limiter.Limit(RateLimitPasswordReset), h.ResendEmailVerification)
+			if opts.PhoneLogin {
+				auth.POST("/otp/request", limiter.Limit(RateLimitOTP), h.OTPRequest)
+				auth.POST("/otp/verify", limiter.Limit(RateLimitLogin), h.OTPVerify)
+			}
 		}
 
 		// User profile
 		user := v1.Group("/user")
+		user.Use(h.RequireAuth())
 		{
 			user.GET("/profile", h.GetUserProfile)
 			user.PUT("/profile", h.UpdateUserProfile)
+			user.GET("/sessions", h.GetUserSessions)
 			user.POST("/photo", h.UploadProfilePhoto)
 			// (4) Закомментировано для будущего использования: Подписка и пакеты (6.)
 			user.GET("/subscription", h.GetUserSubscription)
@@ -51,29 +97,45 @@ func SetupRouter(h *handlers.Handlers) *gin.Engine {
 
 		// Master profile
 		master := v1.Group("/master")
+		master.Use(h.RequireAuth())
 		{
+			// Any user may look up or create their master profile
 			master.GET("/profile", h.GetMasterProfile)
 			master.POST("/profile", h.CreateMasterProfile)
-			master.PUT("/profile", h.UpdateMasterProfile)
-			master.DELETE("/profile", h.DeleteMasterProfile)
-			master.POST("/photo", h.UploadMasterPhoto)
-			master.GET("/schedule", h.GetMasterScheduleByUser)
-			master.PUT("/schedule", h.UpdateMasterSchedule)
-			master.GET("/works", h.GetMasterWorks)
-			master.POST("/works", h.CreateMasterWork)
-			master.GET("/works/:id", h.GetMasterWork)
-			master.PUT("/works/:id", h.UpdateMasterWork)
-			master.DELETE("/works/:id", h.DeleteMasterWork)
-			master.POST("/work-photo", h.UploadWorkPhoto)
-			master.GET("/payment-info", h.GetMasterPaymentInfo)
-			master.PUT("/payment-info", h.UpdateMasterPaymentInfo)
-			master.GET("/reviews", h.GetMasterReviews)
-			master.POST("/reviews", h.CreateReview)
-			master.DELETE("/reviews/:id", h.DeleteReview)
-			master.GET("/certificates", h.GetMasterCertificates)
-			master.POST("/certificates", h.CreateMasterCertificate)
-			master.DELETE("/certificates/:id", h.DeleteMasterCertificate)
-			master.GET("/notifications", h.GetMasterNotifications)
+
+			masterOnly := master.Group("", h.RequireRole(models.RoleMaster))
+			masterOnly.PUT("/profile", h.UpdateMasterProfile)
+			masterOnly.DELETE("/profile", h.DeleteMasterProfile)
+			masterOnly.POST("/photo", h.UploadMasterPhoto)
+			masterOnly.GET("/schedule", h.GetMasterScheduleByUser)
+			masterOnly.PUT("/schedule", h.UpdateMasterSchedule)
+			masterOnly.GET("/schedule/exceptions", h.GetScheduleExceptions)
+			masterOnly.POST("/schedule/exceptions", h.CreateScheduleException)
+			masterOnly.PUT("/schedule/exceptions/:id", h.UpdateScheduleException)
+			masterOnly.DELETE("/schedule/exceptions/:id", h.DeleteScheduleException)
+			masterOnly.GET("/schedule/exceptions/:id/affected", h.GetAffectedAppointments)
+			masterOnly.POST("/schedule/exceptions/:id/resolve", h.ResolveAffectedAppointments)
+			masterOnly.GET("/services", h.GetMasterServices)
+			masterOnly.PUT("/services/:service_id", h.SaveMasterService)
+			masterOnly.DELETE("/services/:service_id", h.DeleteMasterService)
+			masterOnly.GET("/reschedule-requests", h.GetMasterRescheduleRequests)
+			masterOnly.POST("/reschedule-requests/:id/accept", h.AcceptRescheduleRequest)
+			masterOnly.POST("/reschedule-requests/:id/decline", h.DeclineRescheduleRequest)
+			masterOnly.GET("/works", h.GetMasterWorks)
+			masterOnly.POST("/works", h.CreateMasterWork)
+			masterOnly.GET("/works/:id", h.GetMasterWork)
+			masterOnly.PUT("/works/:id", h.UpdateMasterWork)
+			masterOnly.DELETE("/works/:id", h.DeleteMasterWork)
+			masterOnly.POST("/work-photo", h.UploadWorkPhoto)
+			masterOnly.GET("/payment-info", h.GetMasterPaymentInfo)
+			masterOnly.PUT("/payment-info", h.UpdateMasterPaymentInfo)
+			masterOnly.GET("/reviews", h.GetMasterReviews)
+			masterOnly.POST("/reviews", limiter.Limit(RateLimitReview), h.CreateReview)
+			masterOnly.DELETE("/reviews/:id", h.DeleteReview)
+			masterOnly.GET("/certificates", h.GetMasterCertificates)
+			masterOnly.POST("/certificates", h.CreateMasterCertificate)
+			masterOnly.DELETE("/certificates/:id", h.DeleteMasterCertificate)
+			masterOnly.GET("/notifications", h.GetMasterNotifications)
 		}
 
 		// Categories
@@ -101,6 +163,10 @@ func SetupRouter(h *handlers.Handlers) *gin.Engine {
 		pricing := v1.Group("/pricing")
 		{
 			pricing.POST("/calculate", h.CalculatePrice)
+			pricing.POST("/quote", h.RequireAuth(), limiter.Limit(RateLimitQuote), h.QuotePrice)
+			pricing.GET("/quotes/:id", h.RequireAuth(), h.GetPriceQuote)
+			pricing.GET("/bundles", h.GetBundleDiscounts)
+			pricing.GET("/zones", h.GetPriceZones)
 		}
 
 		// Masters
@@ -110,6 +176,7 @@ func SetupRouter(h *handlers.Handlers) *gin.Engine {
 			masters.GET("/:id", h.GetMasterByID)
 			masters.GET("/:id/reviews", h.GetMasterReviews)
 			masters.GET("/:id/schedule", h.GetMasterSchedule)
+			masters.GET("/:id/services", h.GetMasterServicesPublic)
 			masters.GET("/:id/available-slots", h.GetAvailableSlots)
 			masters.GET("/:id/verification-status", h.GetMasterVerificationStatus)
 			masters.GET("/:id/certificates", h.GetMasterCertificates)
@@ -118,6 +185,7 @@ func SetupRouter(h *handlers.Handlers) *gin.Engine {
 
 		// Favorite Masters
 		favorites := v1.Group("/favorites")
+		favorites.Use(h.RequireAuth())
 		{
 			favorites.GET("", h.GetFavoriteMasters)
 			favorites.POST("", h.AddFavoriteMaster)
@@ -126,24 +194,55 @@ func SetupRouter(h *handlers.Handlers) *gin.Engine {
 
 		// Reviews
 		reviews := v1.Group("/reviews")
+		reviews.Use(h.RequireAuth())
 		{
-			reviews.POST("", h.CreateReview)
+			reviews.POST("", limiter.Limit(RateLimitReview), h.CreateReview)
 		}
 
 		// Appointments
 		appointments := v1.Group("/appointments")
+		appointments.Use(h.RequireAuth())
 		{
-			appointments.POST("", h.CreateAppointment)
+			appointments.POST("", limiter.Limit(RateLimitAppointment), h.CreateAppointment)
 			appointments.GET("", h.GetUserAppointments)
-			appointments.GET("/:id", h.GetAppointmentByID)
-			appointments.PUT("/:id", h.UpdateAppointment)
-			appointments.PUT("/:id/cancel", h.CancelAppointment)
-			appointments.DELETE("/:id", h.DeleteAppointment)
+			appointments.GET("/:id", h.RequireAppointmentAccess(models.RoleCustomer, models.RoleMaster, models.RoleAdmin), h.GetAppointmentByID)
+			appointments.PUT("/:id", h.RequireAppointmentAccess(models.RoleCustomer, models.RoleMaster, models.RoleAdmin), h.UpdateAppointment)
+			appointments.PUT("/:id/cancel", h.RequireAppointmentAccess(models.RoleCustomer, models.RoleMaster, models.RoleAdmin), h.CancelAppointment)
+			appointments.GET("/:id/history", h.RequireAppointmentAccess(models.RoleCustomer, models.RoleMaster, models.RoleAdmin), h.GetAppointmentHistory)
+			appointments.POST("/:id/reschedule", h.RequireAppointmentAccess(models.RoleCustomer, models.RoleAdmin), h.RequestReschedule)
+			appointments.GET("/:id/reschedule", h.RequireAppointmentAccess(models.RoleCustomer, models.RoleMaster, models.RoleAdmin), h.GetAppointmentRescheduleRequests)
+			appointments.DELETE("/:id", h.RequireAppointmentAccess(models.RoleCustomer, models.RoleAdmin), h.DeleteAppointment)
+		}
+
+		// Admin
+		admin := v1.Group("/admin")
+		admin.Use(h.RequireAuth(), h.RequireRole(models.RoleAdmin))
+		{
+			admin.PUT("/users/:id/role", h.UpdateUserRole)
+
+			admin.GET("/pricing/rules", h.GetPricingRules)
+			admin.POST("/pricing/rules", h.CreatePricingRule)
+			admin.PUT("/pricing/rules/:id", h.UpdatePricingRule)
+			admin.DELETE("/pricing/rules/:id", h.DeletePricingRule)
+
+			admin.GET("/pricing/zones", h.GetPriceZones)
+			admin.POST("/pricing/zones", h.CreatePriceZone)
+			admin.PUT("/pricing/zones/:id", h.UpdatePriceZone)
+			admin.DELETE("/pricing/zones/:id", h.DeletePriceZone)
+
+			admin.GET("/pricing/bundles", h.GetBundleDiscounts)
+			admin.POST("/pricing/bundles", h.CreateBundleDiscount)
+			admin.PUT("/pricing/bundles/:id", h.UpdateBundleDiscount)
+			admin.DELETE("/pricing/bundles/:id", h.DeleteBundleDiscount)
 		}
 	}
 
 	// Serve static files (after API routes)
 	r.Static("/static", "./static")
+	if uploadPath := strings.TrimRight(opts.UploadURLPath, "/"); uploadPath != "" && !strings.HasPrefix(uploadPath+"/", "/static/") {
+		// Uploads kept outside ./static need their own route
+		r.Static(uploadPath, opts.UploadDir)
+	}
 
 	// Static pages (before NoRoute to ensure they work)
 	// Order matters - more specific routes first
@@ -175,9 +274,25 @@ func SetupRouter(h *handlers.Handlers) *gin.Engine {
 	return r
 }
 
-func corsMiddleware() gin.HandlerFunc {
+func corsMiddleware(allowedOrigins []string) gin.HandlerFunc {
+	allowAny := false
+	allowed := make(map[string]bool, len(allowedOrigins))
+	for _, origin := range allowedOrigins {
+		if origin == "*" {
+			allowAny = true
+		}
+		allowed[strings.TrimRight(origin, "/")] = true
+	}
+
 	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		if allowAny {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		} else {
+			c.Writer.Header().Add("Vary", "Origin")
+			if origin := c.GetHeader("Origin"); allowed[origin] {
+				c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+		}
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
 		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE, PATCH")
@@ -190,3 +305,20 @@ func corsMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// requestTimeoutMiddleware puts a deadline on the request context, so repository
+// queries stop when it passes or the client disconnects
+func requestTimeoutMiddleware(timeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
@@ -0,0 +1,728 @@
+package router
+
+import (
+	"beep-backend/internal/auth"
+	"beep-backend/internal/booking"
+	"beep-backend/internal/handlers"
+	"beep-backend/internal/mail"
+	"beep-backend/internal/models"
+	"beep-backend/internal/repository/memory"
+	"beep-backend/internal/sms"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testAPI serves the API from a memory store
+type testAPI struct {
+	t      *testing.T
+	store  *memory.Store
+	mailer *mail.MemoryMailer
+	sms    *sms.MemorySender
+	router *gin.Engine
+}
+
+func newTestAPI(t *testing.T, opts Options) *testAPI {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	store := memory.New()
+	mailer := mail.NewMemoryMailer()
+	sender := sms.NewMemorySender()
+	h := handlers.New(store, handlers.Options{
+		Tokens:               auth.NewTokenManager("test-secret", time.Hour),
+		Mailer:               mailer,
+		RefreshTokenTTL:      time.Hour,
+		PasswordResetTTL:     time.Hour,
+		EmailVerificationTTL: time.Hour,
+		Booking:              booking.Settings{SlotGranularity: time.Hour},
+		SMS:                  sender,
+		OTP:                  handlers.OTPSettings{TTL: 5 * time.Minute, MaxPerHour: 10, MaxAttempts: 5, Length: 6},
+	})
+	opts.Registration = true
+	opts.PhoneLogin = true
+	return &testAPI{t: t, store: store, mailer: mailer, sms: sender, router: SetupRouter(h, opts)}
+}
+
+// do sends body as JSON with the bearer token, if any, and decodes the JSON object response
+func (a *testAPI) do(method, path, token string, body any) (int, map[string]any) {
+	a.t.Helper()
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			a.t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	a.router.ServeHTTP(w, req)
+
+	var out map[string]any
+	_ = json.Unmarshal(w.Body.Bytes(), &out)
+	return w.Code, out
+}
+
+// register signs up a user and returns their access token and ID
+func (a *testAPI) register(name, email, phone string) (string, int) {
+	a.t.Helper()
+	code, out := a.do(http.MethodPost, "/api/v1/auth/register", "", map[string]any{
+		"name": name, "email": email, "password": "password123", "phone": phone,
+	})
+	if code != http.StatusCreated {
+		a.t.Fatalf("register %s: %d %v", email, code, out)
+	}
+	user, err := a.store.GetUserByEmail(context.Background(), email)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return out["token"].(string), user.ID
+}
+
+// master signs up a master offering services and returns their token and master ID
+func (a *testAPI) master(services ...models.Service) (string, int) {
+	a.t.Helper()
+	token, _ := a.register("Мастер", "master@example.com", "+77001112200")
+	code, out := a.do(http.MethodPost, "/api/v1/master/profile", token, map[string]any{
+		"name": "Мастер", "email": "master@example.com", "phone": "+77001112200", "specialization": "Детейлинг",
+	})
+	if code != http.StatusCreated {
+		a.t.Fatalf("create master profile: %d %v", code, out)
+	}
+	for _, service := range services {
+		if code, out := a.do(http.MethodPut, "/api/v1/master/services/"+strconv.Itoa(service.ID), token, nil); code != http.StatusOK {
+			a.t.Fatalf("offer service %d: %d %v", service.ID, code, out)
+		}
+	}
+	return token, int(out["id"].(float64))
+}
+
+func id(out map[string]any) string {
+	return strconv.Itoa(int(out["id"].(float64)))
+}
+
+// list GETs path and decodes the JSON array response
+func (a *testAPI) list(path, token string) []map[string]any {
+	a.t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	a.router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		a.t.Fatalf("GET %s: %d %s", path, w.Code, w.Body.String())
+	}
+	var out []map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		a.t.Fatalf("GET %s: %v", path, err)
+	}
+	return out
+}
+
+func TestCatalog(t *testing.T) {
+	api := newTestAPI(t, Options{})
+	wash := api.store.AddService(models.Service{Name: "Мойка", BasePrice: 100, DurationMinutes: 60})
+	api.store.AddCar(models.Car{Brand: "Kia", Model: "Rio", Year: 2018, Type: "Economy"})
+
+	if services := api.list("/api/v1/services", ""); len(services) != 1 || services[0]["name"] != wash.Name {
+		t.Errorf("services = %v, want only %s", services, wash.Name)
+	}
+	if code, out := api.do(http.MethodGet, "/api/v1/services/"+strconv.Itoa(wash.ID), "", nil); code != http.StatusOK || out["name"] != wash.Name {
+		t.Errorf("service: %d %v", code, out)
+	}
+	if code, _ := api.do(http.MethodGet, "/api/v1/services/999", "", nil); code != http.StatusNotFound {
+		t.Errorf("unknown service: status = %d, want %d", code, http.StatusNotFound)
+	}
+	if cars := api.list("/api/v1/cars", ""); len(cars) != 1 {
+		t.Errorf("cars = %v, want one", cars)
+	}
+}
+
+func TestUserCars(t *testing.T) {
+	api := newTestAPI(t, Options{})
+	owner, _ := api.register("Клиент", "customer@example.com", "+77001112233")
+	stranger, _ := api.register("Другой", "other@example.com", "+77001112244")
+
+	if code, _ := api.do(http.MethodGet, "/api/v1/user/cars", "", nil); code != http.StatusUnauthorized {
+		t.Errorf("anonymous: status = %d, want %d", code, http.StatusUnauthorized)
+	}
+	code, car := api.do(http.MethodPost, "/api/v1/user/cars", owner, map[string]any{"name": "Моя машина", "year": 2015})
+	if code != http.StatusCreated {
+		t.Fatalf("create car: %d %v", code, car)
+	}
+	if cars := api.list("/api/v1/user/cars", owner); len(cars) != 1 || cars[0]["name"] != "Моя машина" {
+		t.Errorf("owner's cars = %v", cars)
+	}
+	if cars := api.list("/api/v1/user/cars", stranger); len(cars) != 0 {
+		t.Errorf("another user sees %v", cars)
+	}
+
+	// Deleting another user's car leaves it in place
+	api.do(http.MethodDelete, "/api/v1/user/cars/"+id(car), stranger, nil)
+	if cars := api.list("/api/v1/user/cars", owner); len(cars) != 1 {
+		t.Errorf("car deleted by another user")
+	}
+	if code, out := api.do(http.MethodDelete, "/api/v1/user/cars/"+id(car), owner, nil); code != http.StatusOK {
+		t.Fatalf("delete car: %d %v", code, out)
+	}
+	if cars := api.list("/api/v1/user/cars", owner); len(cars) != 0 {
+		t.Errorf("cars after delete = %v", cars)
+	}
+}
+
+func TestRegisterPasswordLength(t *testing.T) {
+	api := newTestAPI(t, Options{})
+	tests := []struct {
+		name     string
+		password string
+		want     int
+	}{
+		{name: "72 bytes", password: strings.Repeat("a", 72), want: http.StatusCreated},
+		{name: "73 bytes", password: strings.Repeat("a", 73), want: http.StatusBadRequest},
+		{name: "multibyte over 72 bytes", password: strings.Repeat("я", 40), want: http.StatusBadRequest},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, out := api.do(http.MethodPost, "/api/v1/auth/register", "", map[string]any{
+				"name": "Клиент", "email": "user" + strconv.Itoa(i) + "@example.com", "password": tt.password,
+			})
+			if code != tt.want {
+				t.Errorf("status = %d, want %d: %v", code, tt.want, out)
+			}
+		})
+	}
+}
+
+func TestLoginUpgradesLegacyHash(t *testing.T) {
+	api := newTestAPI(t, Options{})
+	ctx := context.Background()
+	user, err := api.store.CreateUser(ctx, "Клиент", "legacy@example.com", "", "hashed-password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	login := func(password string) int {
+		code, _ := api.do(http.MethodPost, "/api/v1/auth/login", "", map[string]any{"email": "legacy@example.com", "password": password})
+		return code
+	}
+
+	if code := login("wrong-password"); code != http.StatusUnauthorized {
+		t.Fatalf("wrong password: status = %d, want %d", code, http.StatusUnauthorized)
+	}
+	if stored, _ := api.store.GetUserByID(ctx, user.ID); stored.PasswordHash != "hashed-password123" {
+		t.Fatalf("a failed login changed the hash to %q", stored.PasswordHash)
+	}
+	if code := login("password123"); code != http.StatusOK {
+		t.Fatalf("legacy password: status = %d, want %d", code, http.StatusOK)
+	}
+	stored, err := api.store.GetUserByID(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.HasPrefix(stored.PasswordHash, "hashed-") || !strings.HasPrefix(stored.PasswordHash, "$2") {
+		t.Fatalf("hash after login = %q, want bcrypt", stored.PasswordHash)
+	}
+	if code := login("password123"); code != http.StatusOK {
+		t.Errorf("login with the upgraded hash: status = %d, want %d", code, http.StatusOK)
+	}
+	if code := login("hashed-password123"); code != http.StatusUnauthorized {
+		t.Errorf("stored legacy value as password: status = %d, want %d", code, http.StatusUnauthorized)
+	}
+}
+
+// login signs in with the test password and returns the access and refresh tokens
+func (a *testAPI) login(email string) (string, string) {
+	a.t.Helper()
+	code, out := a.do(http.MethodPost, "/api/v1/auth/login", "", map[string]any{"email": email, "password": "password123"})
+	if code != http.StatusOK {
+		a.t.Fatalf("login %s: %d %v", email, code, out)
+	}
+	return out["token"].(string), out["refresh_token"].(string)
+}
+
+func (a *testAPI) refresh(refreshToken string) (int, map[string]any) {
+	a.t.Helper()
+	return a.do(http.MethodPost, "/api/v1/auth/refresh", "", map[string]any{"refresh_token": refreshToken})
+}
+
+func TestRefreshTokenReuseRevokesSession(t *testing.T) {
+	api := newTestAPI(t, Options{})
+	api.register("Клиент", "customer@example.com", "+77001112233")
+	_, first := api.login("customer@example.com")
+	_, other := api.login("customer@example.com")
+
+	code, out := api.refresh(first)
+	if code != http.StatusOK {
+		t.Fatalf("refresh: %d %v", code, out)
+	}
+	second := out["refresh_token"].(string)
+	if second == first {
+		t.Fatal("refresh did not rotate the token")
+	}
+	code, out = api.refresh(second)
+	if code != http.StatusOK {
+		t.Fatalf("refresh rotated token: %d %v", code, out)
+	}
+	latest := out["refresh_token"].(string)
+
+	// Replaying a rotated token revokes every token of its session
+	if code, _ := api.refresh(first); code != http.StatusUnauthorized {
+		t.Fatalf("reused token: status = %d, want %d", code, http.StatusUnauthorized)
+	}
+	if code, _ := api.refresh(latest); code != http.StatusUnauthorized {
+		t.Errorf("latest token of the reused session: status = %d, want %d", code, http.StatusUnauthorized)
+	}
+	// Other sessions are not affected
+	if code, out := api.refresh(other); code != http.StatusOK {
+		t.Errorf("other session: %d %v", code, out)
+	}
+	if code, _ := api.refresh("not-a-refresh-token"); code != http.StatusUnauthorized {
+		t.Errorf("unknown token: status = %d, want %d", code, http.StatusUnauthorized)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	api := newTestAPI(t, Options{})
+	api.register("Клиент", "customer@example.com", "+77001112233")
+
+	_, byRefresh := api.login("customer@example.com")
+	if code, out := api.do(http.MethodPost, "/api/v1/auth/logout", "", map[string]any{"refresh_token": byRefresh}); code != http.StatusOK {
+		t.Fatalf("logout with refresh token: %d %v", code, out)
+	}
+	if code, _ := api.refresh(byRefresh); code != http.StatusUnauthorized {
+		t.Errorf("refresh after logout: status = %d, want %d", code, http.StatusUnauthorized)
+	}
+
+	access, byAccess := api.login("customer@example.com")
+	_, kept := api.login("customer@example.com")
+	if code, out := api.do(http.MethodPost, "/api/v1/auth/logout", access, nil); code != http.StatusOK {
+		t.Fatalf("logout with access token: %d %v", code, out)
+	}
+	if code, _ := api.refresh(byAccess); code != http.StatusUnauthorized {
+		t.Errorf("refresh of the logged out session: status = %d, want %d", code, http.StatusUnauthorized)
+	}
+	if code, out := api.refresh(kept); code != http.StatusOK {
+		t.Errorf("refresh of another session: %d %v", code, out)
+	}
+
+	if code, _ := api.do(http.MethodPost, "/api/v1/auth/logout", "", nil); code != http.StatusUnauthorized {
+		t.Errorf("anonymous logout: status = %d, want %d", code, http.StatusUnauthorized)
+	}
+}
+
+func TestLogoutAll(t *testing.T) {
+	api := newTestAPI(t, Options{})
+	api.register("Клиент", "customer@example.com", "+77001112233")
+	api.register("Другой", "other@example.com", "+77001112244")
+	access, first := api.login("customer@example.com")
+	_, second := api.login("customer@example.com")
+	_, stranger := api.login("other@example.com")
+
+	if code, _ := api.do(http.MethodPost, "/api/v1/auth/logout-all", "", nil); code != http.StatusUnauthorized {
+		t.Errorf("anonymous: status = %d, want %d", code, http.StatusUnauthorized)
+	}
+	if code, out := api.do(http.MethodPost, "/api/v1/auth/logout-all", access, nil); code != http.StatusOK {
+		t.Fatalf("logout-all: %d %v", code, out)
+	}
+	for _, token := range []string{first, second} {
+		if code, _ := api.refresh(token); code != http.StatusUnauthorized {
+			t.Errorf("refresh after logout-all: status = %d, want %d", code, http.StatusUnauthorized)
+		}
+	}
+	if code, out := api.refresh(stranger); code != http.StatusOK {
+		t.Errorf("another user's session: %d %v", code, out)
+	}
+}
+
+func TestAdminRoutesUseStoredRole(t *testing.T) {
+	api := newTestAPI(t, Options{})
+	token, userID := api.register("Клиент", "customer@example.com", "+77001112233")
+
+	if code, _ := api.do(http.MethodGet, "/api/v1/admin/pricing/rules", token, nil); code != http.StatusForbidden {
+		t.Fatalf("customer: status = %d, want %d", code, http.StatusForbidden)
+	}
+	// The token still says customer, the stored role decides
+	if err := api.store.UpdateUserRole(context.Background(), userID, models.RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	if code, out := api.do(http.MethodGet, "/api/v1/admin/pricing/rules", token, nil); code != http.StatusOK {
+		t.Fatalf("promoted admin: status = %d, want %d: %v", code, http.StatusOK, out)
+	}
+	if err := api.store.UpdateUserRole(context.Background(), userID, models.RoleCustomer); err != nil {
+		t.Fatal(err)
+	}
+	if code, _ := api.do(http.MethodGet, "/api/v1/admin/pricing/rules", token, nil); code != http.StatusForbidden {
+		t.Errorf("demoted admin: status = %d, want %d", code, http.StatusForbidden)
+	}
+}
+
+var emailedToken = regexp.MustCompile(`[?&]token=(\S+)`)
+
+// emailedToken returns the token from the link in the last email sent to the address
+func (a *testAPI) emailedToken(to string) string {
+	a.t.Helper()
+	messages := a.mailer.Messages()
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].To != to {
+			continue
+		}
+		match := emailedToken.FindStringSubmatch(messages[i].Body)
+		if match == nil {
+			a.t.Fatalf("no token in email %q", messages[i].Body)
+		}
+		token, err := url.QueryUnescape(match[1])
+		if err != nil {
+			a.t.Fatal(err)
+		}
+		return token
+	}
+	a.t.Fatalf("no email sent to %s", to)
+	return ""
+}
+
+func TestResetPassword(t *testing.T) {
+	api := newTestAPI(t, Options{})
+	_, userID := api.register("Клиент", "customer@example.com", "+77001112233")
+	verification := api.emailedToken("customer@example.com")
+	_, session := api.login("customer@example.com")
+
+	forgot := func() string {
+		t.Helper()
+		if code, out := api.do(http.MethodPost, "/api/v1/auth/forgot-password", "", map[string]any{"email": "customer@example.com"}); code != http.StatusOK {
+			t.Fatalf("forgot-password: %d %v", code, out)
+		}
+		return api.emailedToken("customer@example.com")
+	}
+	reset := func(token, password string) int {
+		t.Helper()
+		code, _ := api.do(http.MethodPost, "/api/v1/auth/reset-password", "", map[string]any{"token": token, "password": password})
+		return code
+	}
+
+	superseded := forgot()
+	token := forgot()
+	if code := reset(superseded, "new-password"); code != http.StatusBadRequest {
+		t.Errorf("superseded token: status = %d, want %d", code, http.StatusBadRequest)
+	}
+	if code := reset(verification, "new-password"); code != http.StatusBadRequest {
+		t.Errorf("email verification token: status = %d, want %d", code, http.StatusBadRequest)
+	}
+	// A rejected password does not use the token up
+	if code := reset(token, "short"); code != http.StatusBadRequest {
+		t.Errorf("short password: status = %d, want %d", code, http.StatusBadRequest)
+	}
+	if code := reset(token, "new-password"); code != http.StatusOK {
+		t.Fatalf("reset: status = %d, want %d", code, http.StatusOK)
+	}
+	if code := reset(token, "another-password"); code != http.StatusBadRequest {
+		t.Errorf("reused token: status = %d, want %d", code, http.StatusBadRequest)
+	}
+
+	if code, _ := api.do(http.MethodPost, "/api/v1/auth/login", "", map[string]any{"email": "customer@example.com", "password": "new-password"}); code != http.StatusOK {
+		t.Errorf("login with the new password: status = %d, want %d", code, http.StatusOK)
+	}
+	if code, _ := api.refresh(session); code != http.StatusUnauthorized {
+		t.Errorf("session from before the reset: status = %d, want %d", code, http.StatusUnauthorized)
+	}
+
+	expired, expiredHash, err := auth.NewOpaqueToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := api.store.CreateAuthToken(context.Background(), userID, models.AuthTokenPasswordReset, expiredHash, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if code := reset(expired, "new-password"); code != http.StatusBadRequest {
+		t.Errorf("expired token: status = %d, want %d", code, http.StatusBadRequest)
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	api := newTestAPI(t, Options{})
+	token, userID := api.register("Клиент", "customer@example.com", "+77001112233")
+	verify := func(token string) int {
+		t.Helper()
+		code, _ := api.do(http.MethodPost, "/api/v1/auth/verify-email", "", map[string]any{"token": token})
+		return code
+	}
+
+	if code, out := api.do(http.MethodPost, "/api/v1/auth/forgot-password", "", map[string]any{"email": "customer@example.com"}); code != http.StatusOK {
+		t.Fatalf("forgot-password: %d %v", code, out)
+	}
+	if code := verify(api.emailedToken("customer@example.com")); code != http.StatusBadRequest {
+		t.Errorf("password reset token: status = %d, want %d", code, http.StatusBadRequest)
+	}
+
+	expired, expiredHash, err := auth.NewOpaqueToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := api.store.CreateAuthToken(context.Background(), userID, models.AuthTokenEmailVerification, expiredHash, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if code := verify(expired); code != http.StatusBadRequest {
+		t.Errorf("expired token: status = %d, want %d", code, http.StatusBadRequest)
+	}
+
+	if code, out := api.do(http.MethodPost, "/api/v1/auth/verify-email/resend", token, nil); code != http.StatusOK {
+		t.Fatalf("resend: %d %v", code, out)
+	}
+	verification := api.emailedToken("customer@example.com")
+	if code := verify(verification); code != http.StatusOK {
+		t.Fatalf("verify: status = %d, want %d", code, http.StatusOK)
+	}
+	if code := verify(verification); code != http.StatusBadRequest {
+		t.Errorf("reused token: status = %d, want %d", code, http.StatusBadRequest)
+	}
+	user, err := api.store.GetUserByID(context.Background(), userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.EmailVerifiedAt == nil {
+		t.Error("email not marked verified")
+	}
+}
+
+var smsCode = regexp.MustCompile(`\d{6}`)
+
+// phoneLogin requests a login code for the phone and signs in with it
+func (a *testAPI) phoneLogin(phone, token string) (int, map[string]any) {
+	a.t.Helper()
+	if code, out := a.do(http.MethodPost, "/api/v1/auth/otp/request", token, map[string]any{"phone": phone}); code != http.StatusOK {
+		a.t.Fatalf("otp request: %d %v", code, out)
+	}
+	messages := a.sms.Messages()
+	last := messages[len(messages)-1]
+	return a.do(http.MethodPost, "/api/v1/auth/otp/verify", token, map[string]any{"phone": phone, "code": smsCode.FindString(last.Text)})
+}
+
+func TestPhoneLoginNeedsVerifiedPhone(t *testing.T) {
+	api := newTestAPI(t, Options{})
+	// Anyone can type a phone into a profile
+	_, victimID := api.register("Клиент", "customer@example.com", "+77001112233")
+	_, squatterID := api.register("Чужой", "squatter@example.com", "8 700 111 22 44")
+	squatter, _ := api.login("squatter@example.com")
+	if code, out := api.do(http.MethodPut, "/api/v1/user/profile", squatter, map[string]any{"phone": "+77001112233"}); code != http.StatusOK {
+		t.Fatalf("update profile: %d %v", code, out)
+	}
+
+	// The owner of an unverified number gets a new account, not one of the accounts that typed it in
+	code, out := api.phoneLogin("+77001112233", "")
+	if code != http.StatusCreated {
+		t.Fatalf("first phone login: %d %v", code, out)
+	}
+	phoneUserID := int(out["user"].(map[string]any)["id"].(float64))
+	if phoneUserID == victimID || phoneUserID == squatterID {
+		t.Fatalf("phone login signed in as existing user %d", phoneUserID)
+	}
+	code, out = api.phoneLogin("8 (700) 111-22-33", "")
+	if code != http.StatusOK || int(out["user"].(map[string]any)["id"].(float64)) != phoneUserID {
+		t.Fatalf("second phone login: %d %v", code, out)
+	}
+
+	// Verifying while signed in confirms the profile phone and takes the number over
+	victim, _ := api.login("customer@example.com")
+	code, out = api.phoneLogin("+77001112233", victim)
+	if code != http.StatusOK || int(out["user"].(map[string]any)["id"].(float64)) != victimID {
+		t.Fatalf("verify profile phone: %d %v", code, out)
+	}
+	code, out = api.phoneLogin("+77001112233", "")
+	if code != http.StatusOK || int(out["user"].(map[string]any)["id"].(float64)) != victimID {
+		t.Fatalf("phone login after verification: %d %v", code, out)
+	}
+	phoneUser, err := api.store.GetUserByID(context.Background(), phoneUserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if phoneUser.PhoneVerifiedAt != nil {
+		t.Error("previous holder of the number still verified")
+	}
+
+	// Changing the phone drops the verification
+	if code, out := api.do(http.MethodPut, "/api/v1/user/profile", victim, map[string]any{"phone": "+77001112266"}); code != http.StatusOK {
+		t.Fatalf("update profile: %d %v", code, out)
+	}
+	if code, out := api.do(http.MethodPut, "/api/v1/user/profile", victim, map[string]any{"phone": "+77001112233"}); code != http.StatusOK {
+		t.Fatalf("update profile: %d %v", code, out)
+	}
+	code, out = api.phoneLogin("+77001112233", "")
+	if code != http.StatusCreated {
+		t.Fatalf("phone login after the phone changed: %d %v", code, out)
+	}
+}
+
+func TestDeleteAppointment(t *testing.T) {
+	api := newTestAPI(t, Options{})
+	wash := api.store.AddService(models.Service{Name: "Мойка", BasePrice: 100, DurationMinutes: 60})
+	polish := api.store.AddService(models.Service{Name: "Полировка", BasePrice: 200, DurationMinutes: 60})
+	masterToken, masterID := api.master(wash, polish)
+	customer, _ := api.register("Клиент", "customer@example.com", "+77001112233")
+
+	code, appointment := api.do(http.MethodPost, "/api/v1/appointments", customer, map[string]any{
+		"master_id": masterID, "service_ids": []int{wash.ID, polish.ID}, "date": "2030-01-07", "time": "10:00",
+	})
+	if code != http.StatusCreated {
+		t.Fatalf("book: %d %v", code, appointment)
+	}
+	path := "/api/v1/appointments/" + id(appointment)
+
+	if code, _ := api.do(http.MethodDelete, path, masterToken, nil); code != http.StatusForbidden {
+		t.Errorf("master deletes: status = %d, want %d", code, http.StatusForbidden)
+	}
+	if code, out := api.do(http.MethodDelete, path, customer, nil); code != http.StatusConflict {
+		t.Errorf("delete active appointment: status = %d, want %d: %v", code, http.StatusConflict, out)
+	}
+	if code, out := api.do(http.MethodPut, path+"/cancel", customer, map[string]any{"reason": "Планы изменились"}); code != http.StatusOK {
+		t.Fatalf("cancel: %d %v", code, out)
+	}
+	if code, out := api.do(http.MethodDelete, path, customer, nil); code != http.StatusOK {
+		t.Fatalf("delete cancelled appointment: %d %v", code, out)
+	}
+
+	// The history outlives the appointment and ends with its deletion
+	events, err := api.store.GetAppointmentEvents(context.Background(), int(appointment["id"].(float64)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) == 0 || events[len(events)-1].Type != models.AppointmentEventDeleted {
+		t.Errorf("history = %+v, want it to end with a deletion", events)
+	}
+
+	// The master is told which services were cancelled
+	masterUser, err := api.store.GetUserByEmail(context.Background(), "master@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	notifications, err := api.store.GetUserNotifications(context.Background(), masterUser.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, notification := range notifications {
+		if notification.Type == "appointment_status_changed" {
+			found = true
+			if !strings.Contains(notification.Message, "услуги Мойка, Полировка") {
+				t.Errorf("cancellation message does not name both services: %q", notification.Message)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("the master was not told about the cancellation: %+v", notifications)
+	}
+}
+
+func TestQuotePriceNeedsOneCar(t *testing.T) {
+	api := newTestAPI(t, Options{})
+	wash := api.store.AddService(models.Service{Name: "Мойка", BasePrice: 100, DurationMinutes: 60})
+	car := api.store.AddCar(models.Car{Brand: "Kia", Model: "Rio", Year: 2018, Type: "Economy"})
+	token, _ := api.register("Клиент", "customer@example.com", "+77001112233")
+
+	for name, body := range map[string]map[string]any{
+		"no car":   {"service_ids": []int{wash.ID}},
+		"two cars": {"service_ids": []int{wash.ID}, "car_id": car.ID, "user_car_id": 1},
+	} {
+		if code, out := api.do(http.MethodPost, "/api/v1/pricing/quote", token, body); code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d: %v", name, code, http.StatusBadRequest, out)
+		}
+	}
+}
+
+func TestPriceQuotesBelongToTheirCustomer(t *testing.T) {
+	api := newTestAPI(t, Options{})
+	wash := api.store.AddService(models.Service{Name: "Мойка", BasePrice: 100, DurationMinutes: 60})
+	car := api.store.AddCar(models.Car{Brand: "Kia", Model: "Rio", Year: 2018, Type: "Economy"})
+	otherCar := api.store.AddCar(models.Car{Brand: "BMW", Model: "X5", Year: 2020, Type: "Premium"})
+	_, masterID := api.master(wash)
+	customer, _ := api.register("Клиент", "customer@example.com", "+77001112233")
+	stranger, _ := api.register("Другой", "other@example.com", "+77001112244")
+
+	code, out := api.do(http.MethodPost, "/api/v1/pricing/calculate", "", map[string]any{"service_id": wash.ID, "car_id": car.ID})
+	if code != http.StatusOK {
+		t.Fatalf("calculate: %d %v", code, out)
+	}
+	if _, ok := out["quote_id"]; ok {
+		t.Errorf("calculate stored a quote: %v", out)
+	}
+
+	basket := map[string]any{"car_id": car.ID, "service_ids": []int{wash.ID}, "master_id": masterID}
+	// Only the quoted master's prices can be booked, so a quote needs one
+	if code, out := api.do(http.MethodPost, "/api/v1/pricing/quote", customer, map[string]any{"car_id": car.ID, "service_ids": []int{wash.ID}}); code != http.StatusBadRequest {
+		t.Errorf("quote without a master: status = %d, want %d: %v", code, http.StatusBadRequest, out)
+	}
+	if code, _ := api.do(http.MethodPost, "/api/v1/pricing/quote", "", basket); code != http.StatusUnauthorized {
+		t.Errorf("anonymous quote: status = %d, want %d", code, http.StatusUnauthorized)
+	}
+	code, quote := api.do(http.MethodPost, "/api/v1/pricing/quote", customer, basket)
+	if code != http.StatusOK {
+		t.Fatalf("quote: %d %v", code, quote)
+	}
+
+	path := "/api/v1/pricing/quotes/" + id(quote)
+	for _, tt := range []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{name: "anonymous", want: http.StatusUnauthorized},
+		{name: "another customer", token: stranger, want: http.StatusNotFound},
+		{name: "owner", token: customer, want: http.StatusOK},
+	} {
+		if code, _ := api.do(http.MethodGet, path, tt.token, nil); code != tt.want {
+			t.Errorf("read quote as %s: status = %d, want %d", tt.name, code, tt.want)
+		}
+	}
+
+	book := func(token string, extra map[string]any) (int, map[string]any) {
+		body := map[string]any{"master_id": masterID, "quote_id": quote["id"], "date": "2030-01-07", "time": "10:00"}
+		for key, value := range extra {
+			body[key] = value
+		}
+		return api.do(http.MethodPost, "/api/v1/appointments", token, body)
+	}
+	if code, out := book(stranger, nil); code != http.StatusNotFound {
+		t.Errorf("book another customer's quote: status = %d, want %d: %v", code, http.StatusNotFound, out)
+	}
+	if code, out := book(customer, map[string]any{"car_id": otherCar.ID}); code != http.StatusBadRequest {
+		t.Errorf("book the quote for another car: status = %d, want %d: %v", code, http.StatusBadRequest, out)
+	}
+	code, appointment := book(customer, nil)
+	if code != http.StatusCreated {
+		t.Fatalf("book own quote: %d %v", code, appointment)
+	}
+	if appointment["car_id"] != float64(car.ID) {
+		t.Errorf("appointment car_id = %v, want %d", appointment["car_id"], car.ID)
+	}
+}
+
+func TestQuoteRateLimit(t *testing.T) {
+	policy, err := ParseRateLimitPolicy("user=2/1h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	limiter := NewRateLimiter(NewMemoryRateLimitStore(), map[string]RateLimitPolicy{RateLimitQuote: policy})
+	api := newTestAPI(t, Options{Limiter: limiter})
+	wash := api.store.AddService(models.Service{Name: "Мойка", BasePrice: 100, DurationMinutes: 60})
+	car := api.store.AddCar(models.Car{Brand: "Kia", Model: "Rio", Year: 2018, Type: "Economy"})
+	_, masterID := api.master(wash)
+	customer, _ := api.register("Клиент", "customer@example.com", "+77001112233")
+
+	basket := map[string]any{"car_id": car.ID, "service_ids": []int{wash.ID}, "master_id": masterID}
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		if code, out := api.do(http.MethodPost, "/api/v1/pricing/quote", customer, basket); code != want {
+			t.Errorf("request %d: status = %d, want %d: %v", i+1, code, want, out)
+		}
+	}
+}
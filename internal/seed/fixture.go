@@ -0,0 +1,137 @@
+package seed
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+//go:embed fixtures/*.json
+var fixtureFiles embed.FS
+
+// Fixture is a set of records to upsert. Records refer to each other by natural keys
+// (category and service names, user emails) so the same file can be loaded into any database.
+type Fixture struct {
+	Categories   []Category    `json:"categories"`
+	Services     []Service     `json:"services"`
+	Cars         []Car         `json:"cars"`
+	Users        []User        `json:"users"`
+	Masters      []Master      `json:"masters"`
+	Reviews      []Review      `json:"reviews"`
+	Appointments []Appointment `json:"appointments"`
+}
+
+type Category struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type Service struct {
+	Category        string  `json:"category"`
+	Name            string  `json:"name"`
+	Description     string  `json:"description"`
+	BasePrice       float64 `json:"base_price"`
+	MinPrice        float64 `json:"min_price"`
+	MaxPrice        float64 `json:"max_price"`
+	DurationMinutes int     `json:"duration_minutes"`
+}
+
+type Car struct {
+	Brand string `json:"brand"`
+	Model string `json:"model"`
+	Year  int    `json:"year"`
+	Type  string `json:"type"`
+}
+
+// User is created with Password hashed on load, or with a ready PasswordHash.
+// Existing users keep their password.
+type User struct {
+	Name         string `json:"name"`
+	Email        string `json:"email"`
+	Phone        string `json:"phone"`
+	Password     string `json:"password"`
+	PasswordHash string `json:"password_hash"`
+	Role         string `json:"role"`
+}
+
+// Master is the master profile of the user with the same email
+type Master struct {
+	Email          string        `json:"email"`
+	Name           string        `json:"name"`
+	Phone          string        `json:"phone"`
+	Specialization string        `json:"specialization"`
+	Rating         float64       `json:"rating"`
+	LocationLat    float64       `json:"location_lat"`
+	LocationLng    float64       `json:"location_lng"`
+	Address        string        `json:"address"`
+	Schedule       []ScheduleDay `json:"schedule"`
+	Works          []MasterWork  `json:"works"`
+	// Services is the master's price list; without it the master offers the whole catalog at catalog prices
+	Services []MasterService `json:"services"`
+}
+
+// MasterService is a catalog service by name; a zero price or duration means the catalog's
+type MasterService struct {
+	Service         string  `json:"service"`
+	BasePrice       float64 `json:"base_price"`
+	DurationMinutes int     `json:"duration_minutes"`
+}
+
+type ScheduleDay struct {
+	DayOfWeek int    `json:"day_of_week"`
+	StartTime string `json:"start_time"`
+	EndTime   string `json:"end_time"`
+}
+
+// MasterWork is dated DaysAgo days before the seed runs
+type MasterWork struct {
+	Title        string  `json:"title"`
+	DaysAgo      int     `json:"days_ago"`
+	CustomerName string  `json:"customer_name"`
+	Amount       float64 `json:"amount"`
+}
+
+type Review struct {
+	MasterEmail string `json:"master_email"`
+	UserEmail   string `json:"user_email"`
+	Rating      int    `json:"rating"`
+	Comment     string `json:"comment"`
+	DaysAgo     int    `json:"days_ago"`
+}
+
+// Appointment is scheduled InDays days after the seed runs
+type Appointment struct {
+	UserEmail   string `json:"user_email"`
+	MasterEmail string `json:"master_email"`
+	Service     string `json:"service"`
+	InDays      int    `json:"in_days"`
+	Time        string `json:"time"`
+	Status      string `json:"status"`
+	Comment     string `json:"comment"`
+}
+
+// LoadFixtureFile reads a JSON fixture from disk
+func LoadFixtureFile(path string) (*Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseFixture(path, data)
+}
+
+func builtinFixture(name string) (*Fixture, error) {
+	data, err := fixtureFiles.ReadFile("fixtures/" + name + ".json")
+	if err != nil {
+		return nil, err
+	}
+	return parseFixture(name, data)
+}
+
+func parseFixture(name string, data []byte) (*Fixture, error) {
+	var fixture Fixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return nil, fmt.Errorf("invalid fixture %s: %w", name, err)
+	}
+	return &fixture, nil
+}
@@ -0,0 +1,79 @@
+package seed
+
+import "fmt"
+
+// Size of the load-test set
+const (
+	loadTestMasters      = 50
+	loadTestCustomers    = 500
+	loadTestAppointments = 2500
+	loadTestPassword     = "password123"
+)
+
+// loadTestFixture builds the demo reference data plus many generated masters, customers
+// and appointments spread over the next two weeks. Generated emails use the beep.test domain.
+func loadTestFixture() (*Fixture, error) {
+	demo, err := builtinFixture("demo")
+	if err != nil {
+		return nil, err
+	}
+
+	fixture := &Fixture{
+		Categories: demo.Categories,
+		Services:   demo.Services,
+		Cars:       demo.Cars,
+	}
+
+	weekdays := []ScheduleDay{}
+	for day := 1; day <= 6; day++ {
+		weekdays = append(weekdays, ScheduleDay{DayOfWeek: day, StartTime: "09:00", EndTime: "18:00"})
+	}
+
+	for i := 1; i <= loadTestMasters; i++ {
+		email := fmt.Sprintf("loadtest-master-%d@beep.test", i)
+		phone := fmt.Sprintf("+7701%07d", i)
+		name := fmt.Sprintf("Мастер %d", i)
+		fixture.Users = append(fixture.Users, User{Name: name, Email: email, Phone: phone, Password: loadTestPassword})
+		fixture.Masters = append(fixture.Masters, Master{
+			Email:          email,
+			Name:           name,
+			Phone:          phone,
+			Specialization: demo.Services[i%len(demo.Services)].Category,
+			LocationLat:    43.20 + float64(i%10)*0.005,
+			LocationLng:    76.85 + float64(i/10)*0.005,
+			Address:        fmt.Sprintf("Алматы, тестовый адрес %d", i),
+			Schedule:       weekdays,
+		})
+	}
+
+	for i := 1; i <= loadTestCustomers; i++ {
+		fixture.Users = append(fixture.Users, User{
+			Name:     fmt.Sprintf("Клиент %d", i),
+			Email:    fmt.Sprintf("loadtest-customer-%d@beep.test", i),
+			Phone:    fmt.Sprintf("+7702%07d", i),
+			Password: loadTestPassword,
+		})
+		fixture.Reviews = append(fixture.Reviews, Review{
+			MasterEmail: fmt.Sprintf("loadtest-master-%d@beep.test", i%loadTestMasters+1),
+			UserEmail:   fmt.Sprintf("loadtest-customer-%d@beep.test", i),
+			Rating:      3 + i%3,
+			Comment:     "Отзыв для нагрузочного теста",
+			DaysAgo:     i % 30,
+		})
+	}
+
+	// Each master gets consecutive hourly slots, 9 per day, starting tomorrow
+	const slotsPerDay = 9
+	for i := 0; i < loadTestAppointments; i++ {
+		slot := i / loadTestMasters
+		fixture.Appointments = append(fixture.Appointments, Appointment{
+			UserEmail:   fmt.Sprintf("loadtest-customer-%d@beep.test", i%loadTestCustomers+1),
+			MasterEmail: fmt.Sprintf("loadtest-master-%d@beep.test", i%loadTestMasters+1),
+			Service:     demo.Services[i%len(demo.Services)].Name,
+			InDays:      1 + slot/slotsPerDay,
+			Time:        fmt.Sprintf("%02d:00", 9+slot%slotsPerDay),
+		})
+	}
+
+	return fixture, nil
+}
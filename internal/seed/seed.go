@@ -0,0 +1,497 @@
+// Package seed fills a database with demo or load-test data. It is run explicitly
+// with the "seed" command and never on server start.
+package seed
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"beep-backend/internal/auth"
+	"beep-backend/internal/models"
+)
+
+// Named fixture sets
+const (
+	SetDemo     = "demo"
+	SetLoadTest = "load-test"
+	SetEmpty    = "empty"
+)
+
+var sets = map[string]func() (*Fixture, error){
+	SetDemo:     func() (*Fixture, error) { return builtinFixture("demo") },
+	SetLoadTest: loadTestFixture,
+	SetEmpty:    func() (*Fixture, error) { return &Fixture{}, nil },
+}
+
+// Sets lists the names of the built-in fixture sets
+func Sets() []string {
+	names := make([]string, 0, len(sets))
+	for name := range sets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Run upserts the named fixture set
+func Run(ctx context.Context, db *sql.DB, set string) error {
+	build, ok := sets[set]
+	if !ok {
+		return fmt.Errorf("unknown fixture set %q, available: %v", set, Sets())
+	}
+	fixture, err := build()
+	if err != nil {
+		return err
+	}
+	return Apply(ctx, db, fixture)
+}
+
+// LoadSQLFile executes a SQL script such as sample_data.sql in a single transaction
+func LoadSQLFile(ctx context.Context, db *sql.DB, path string) error {
+	script, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, string(script)); err != nil {
+		return fmt.Errorf("failed to load %s: %w", path, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	log.Printf("Loaded %s", path)
+	return nil
+}
+
+// Apply upserts every record of the fixture in one transaction. Records are matched by
+// natural keys, so applying the same fixture again updates rows instead of duplicating them.
+func Apply(ctx context.Context, db *sql.DB, fixture *Fixture) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	s := &seeder{
+		ctx:        ctx,
+		tx:         tx,
+		now:        time.Now(),
+		categories: make(map[string]int),
+		services:   make(map[string]int),
+		users:      make(map[string]int),
+		masters:    make(map[string]int),
+		hashes:     make(map[string]string),
+	}
+
+	steps := []struct {
+		name string
+		run  func(*Fixture) error
+	}{
+		{"categories", s.seedCategories},
+		{"services", s.seedServices},
+		{"cars", s.seedCars},
+		{"users", s.seedUsers},
+		{"masters", s.seedMasters},
+		{"reviews", s.seedReviews},
+		{"appointments", s.seedAppointments},
+	}
+	for _, step := range steps {
+		if err := step.run(fixture); err != nil {
+			return fmt.Errorf("seeding %s: %w", step.name, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	log.Printf("Seeded %d categories, %d services, %d cars, %d users, %d masters, %d reviews, %d appointments",
+		len(fixture.Categories), len(fixture.Services), len(fixture.Cars), len(fixture.Users),
+		len(fixture.Masters), len(fixture.Reviews), len(fixture.Appointments))
+	return nil
+}
+
+// seeder carries the transaction and the IDs resolved so far
+type seeder struct {
+	ctx        context.Context
+	tx         *sql.Tx
+	now        time.Time
+	categories map[string]int
+	services   map[string]int
+	users      map[string]int
+	masters    map[string]int
+	// hashes caches bcrypt hashes by password; load-test users share one password
+	hashes map[string]string
+}
+
+func (s *seeder) seedCategories(f *Fixture) error {
+	for _, category := range f.Categories {
+		var id int
+		err := s.tx.QueryRowContext(s.ctx, "SELECT id FROM categories WHERE name = $1 ORDER BY id LIMIT 1", category.Name).Scan(&id)
+		switch {
+		case err == sql.ErrNoRows:
+			err = s.tx.QueryRowContext(s.ctx,
+				"INSERT INTO categories (name, description) VALUES ($1, $2) RETURNING id",
+				category.Name, category.Description).Scan(&id)
+		case err == nil:
+			_, err = s.tx.ExecContext(s.ctx, "UPDATE categories SET description = $1 WHERE id = $2", category.Description, id)
+		}
+		if err != nil {
+			return err
+		}
+		s.categories[category.Name] = id
+	}
+	return nil
+}
+
+func (s *seeder) categoryID(name string) (int, error) {
+	if id, ok := s.categories[name]; ok {
+		return id, nil
+	}
+	var id int
+	if err := s.tx.QueryRowContext(s.ctx, "SELECT id FROM categories WHERE name = $1 ORDER BY id LIMIT 1", name).Scan(&id); err != nil {
+		return 0, fmt.Errorf("category %q not found: %w", name, err)
+	}
+	s.categories[name] = id
+	return id, nil
+}
+
+func (s *seeder) seedServices(f *Fixture) error {
+	for _, service := range f.Services {
+		categoryID, err := s.categoryID(service.Category)
+		if err != nil {
+			return err
+		}
+
+		var id int
+		err = s.tx.QueryRowContext(s.ctx,
+			"SELECT id FROM services WHERE category_id = $1 AND name = $2 ORDER BY id LIMIT 1",
+			categoryID, service.Name).Scan(&id)
+		switch {
+		case err == sql.ErrNoRows:
+			err = s.tx.QueryRowContext(s.ctx, `
+				INSERT INTO services (category_id, name, description, base_price, min_price, max_price, duration_minutes)
+				VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id
+			`, categoryID, service.Name, service.Description, service.BasePrice, service.MinPrice, service.MaxPrice, service.DurationMinutes).Scan(&id)
+		case err == nil:
+			_, err = s.tx.ExecContext(s.ctx, `
+				UPDATE services SET description = $1, base_price = $2, min_price = $3, max_price = $4, duration_minutes = $5
+				WHERE id = $6
+			`, service.Description, service.BasePrice, service.MinPrice, service.MaxPrice, service.DurationMinutes, id)
+		}
+		if err != nil {
+			return err
+		}
+		s.services[service.Name] = id
+	}
+	return nil
+}
+
+func (s *seeder) serviceID(name string) (int, error) {
+	if id, ok := s.services[name]; ok {
+		return id, nil
+	}
+	var id int
+	if err := s.tx.QueryRowContext(s.ctx, "SELECT id FROM services WHERE name = $1 ORDER BY id LIMIT 1", name).Scan(&id); err != nil {
+		return 0, fmt.Errorf("service %q not found: %w", name, err)
+	}
+	s.services[name] = id
+	return id, nil
+}
+
+func (s *seeder) seedCars(f *Fixture) error {
+	for _, car := range f.Cars {
+		_, err := s.tx.ExecContext(s.ctx, `
+			INSERT INTO cars (brand, model, year, type)
+			SELECT $1, $2, $3, $4
+			WHERE NOT EXISTS (SELECT 1 FROM cars WHERE brand = $1 AND model = $2 AND year = $3)
+		`, car.Brand, car.Model, car.Year, car.Type)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *seeder) passwordHash(user User) (string, error) {
+	if user.PasswordHash != "" {
+		return user.PasswordHash, nil
+	}
+	if user.Password == "" {
+		return "", fmt.Errorf("user %s has neither password nor password_hash", user.Email)
+	}
+	if hash, ok := s.hashes[user.Password]; ok {
+		return hash, nil
+	}
+	hash, err := auth.HashPassword(user.Password)
+	if err != nil {
+		return "", err
+	}
+	s.hashes[user.Password] = hash
+	return hash, nil
+}
+
+func (s *seeder) seedUsers(f *Fixture) error {
+	for _, user := range f.Users {
+		role := user.Role
+		if role == "" {
+			role = models.RoleCustomer
+		}
+		hash, err := s.passwordHash(user)
+		if err != nil {
+			return err
+		}
+
+		var id int
+		err = s.tx.QueryRowContext(s.ctx, `
+			INSERT INTO users (name, email, phone, password_hash, role, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
+			ON CONFLICT (email) DO UPDATE SET name = EXCLUDED.name, phone = EXCLUDED.phone, updated_at = NOW()
+			RETURNING id
+		`, user.Name, user.Email, user.Phone, hash, role).Scan(&id)
+		if err != nil {
+			return err
+		}
+		s.users[user.Email] = id
+	}
+	return nil
+}
+
+func (s *seeder) userID(email string) (int, error) {
+	if id, ok := s.users[email]; ok {
+		return id, nil
+	}
+	var id int
+	if err := s.tx.QueryRowContext(s.ctx, "SELECT id FROM users WHERE email = $1", email).Scan(&id); err != nil {
+		return 0, fmt.Errorf("user %q not found: %w", email, err)
+	}
+	s.users[email] = id
+	return id, nil
+}
+
+func (s *seeder) seedMasters(f *Fixture) error {
+	for _, master := range f.Masters {
+		userID, err := s.userID(master.Email)
+		if err != nil {
+			return err
+		}
+
+		var id int
+		err = s.tx.QueryRowContext(s.ctx, `
+			INSERT INTO masters (user_id, name, email, phone, specialization, rating, location_lat, location_lng, address, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW(), NOW())
+			ON CONFLICT (email) DO UPDATE SET
+				user_id = EXCLUDED.user_id, name = EXCLUDED.name, phone = EXCLUDED.phone,
+				specialization = EXCLUDED.specialization, location_lat = EXCLUDED.location_lat,
+				location_lng = EXCLUDED.location_lng, address = EXCLUDED.address, updated_at = NOW()
+			RETURNING id
+		`, userID, master.Name, master.Email, master.Phone, master.Specialization, master.Rating,
+			master.LocationLat, master.LocationLng, master.Address).Scan(&id)
+		if err != nil {
+			return err
+		}
+		s.masters[master.Email] = id
+
+		if _, err := s.tx.ExecContext(s.ctx,
+			"UPDATE users SET role = $1 WHERE id = $2 AND role = $3",
+			models.RoleMaster, userID, models.RoleCustomer); err != nil {
+			return err
+		}
+
+		for _, day := range master.Schedule {
+			if err := s.upsertScheduleDay(id, day); err != nil {
+				return err
+			}
+		}
+
+		if err := s.seedMasterServices(id, master.Services); err != nil {
+			return err
+		}
+
+		for _, work := range master.Works {
+			_, err := s.tx.ExecContext(s.ctx, `
+				INSERT INTO master_works (master_id, title, work_date, customer_name, amount, created_at)
+				SELECT $1, $2, $3, $4, $5, NOW()
+				WHERE NOT EXISTS (SELECT 1 FROM master_works WHERE master_id = $1 AND title = $2)
+			`, id, work.Title, s.now.AddDate(0, 0, -work.DaysAgo), work.CustomerName, work.Amount)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// seedMasterServices upserts the master's price list, or like migration 0012 adds every
+// catalog service at its catalog price if the fixture lists none
+func (s *seeder) seedMasterServices(masterID int, services []MasterService) error {
+	if len(services) == 0 {
+		_, err := s.tx.ExecContext(s.ctx, `
+			INSERT INTO master_services (master_id, service_id, base_price, duration_minutes, created_at, updated_at)
+			SELECT $1, id, COALESCE(base_price, 0), COALESCE(NULLIF(duration_minutes, 0), 60), NOW(), NOW()
+			FROM services
+			ON CONFLICT (master_id, service_id) DO NOTHING
+		`, masterID)
+		return err
+	}
+
+	for _, service := range services {
+		serviceID, err := s.serviceID(service.Service)
+		if err != nil {
+			return err
+		}
+		_, err = s.tx.ExecContext(s.ctx, `
+			INSERT INTO master_services (master_id, service_id, base_price, duration_minutes, created_at, updated_at)
+			SELECT $1, id, COALESCE(NULLIF($3::numeric, 0), base_price, 0), COALESCE(NULLIF($4::integer, 0), NULLIF(duration_minutes, 0), 60), NOW(), NOW()
+			FROM services WHERE id = $2
+			ON CONFLICT (master_id, service_id) DO UPDATE SET
+				base_price = EXCLUDED.base_price, duration_minutes = EXCLUDED.duration_minutes,
+				is_available = TRUE, updated_at = NOW()
+		`, masterID, serviceID, service.BasePrice, service.DurationMinutes)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *seeder) upsertScheduleDay(masterID int, day ScheduleDay) error {
+	result, err := s.tx.ExecContext(s.ctx, `
+		UPDATE master_schedule SET start_time = $1, end_time = $2, is_active = true
+		WHERE master_id = $3 AND day_of_week = $4
+	`, day.StartTime, day.EndTime, masterID, day.DayOfWeek)
+	if err != nil {
+		return err
+	}
+	if updated, err := result.RowsAffected(); err != nil || updated > 0 {
+		return err
+	}
+
+	_, err = s.tx.ExecContext(s.ctx, `
+		INSERT INTO master_schedule (master_id, day_of_week, start_time, end_time, is_active, created_at)
+		VALUES ($1, $2, $3, $4, true, NOW())
+	`, masterID, day.DayOfWeek, day.StartTime, day.EndTime)
+	return err
+}
+
+func (s *seeder) masterID(email string) (int, error) {
+	if id, ok := s.masters[email]; ok {
+		return id, nil
+	}
+	var id int
+	if err := s.tx.QueryRowContext(s.ctx, "SELECT id FROM masters WHERE email = $1", email).Scan(&id); err != nil {
+		return 0, fmt.Errorf("master %q not found: %w", email, err)
+	}
+	s.masters[email] = id
+	return id, nil
+}
+
+func (s *seeder) seedReviews(f *Fixture) error {
+	reviewed := make(map[int]bool)
+	for _, review := range f.Reviews {
+		masterID, err := s.masterID(review.MasterEmail)
+		if err != nil {
+			return err
+		}
+		userID, err := s.userID(review.UserEmail)
+		if err != nil {
+			return err
+		}
+
+		_, err = s.tx.ExecContext(s.ctx, `
+			INSERT INTO reviews (master_id, user_id, rating, comment, created_at)
+			SELECT $1, $2, $3, $4, $5
+			WHERE NOT EXISTS (SELECT 1 FROM reviews WHERE master_id = $1 AND user_id = $2)
+		`, masterID, userID, review.Rating, review.Comment, s.now.AddDate(0, 0, -review.DaysAgo))
+		if err != nil {
+			return err
+		}
+		reviewed[masterID] = true
+	}
+
+	// Recalculate ratings from the seeded reviews
+	for masterID := range reviewed {
+		_, err := s.tx.ExecContext(s.ctx, `
+			UPDATE masters SET rating = (SELECT COALESCE(AVG(rating)::decimal, 0.0) FROM reviews WHERE reviews.master_id = masters.id)
+			WHERE id = $1
+		`, masterID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *seeder) seedAppointments(f *Fixture) error {
+	for _, appointment := range f.Appointments {
+		userID, err := s.userID(appointment.UserEmail)
+		if err != nil {
+			return err
+		}
+		masterID, err := s.masterID(appointment.MasterEmail)
+		if err != nil {
+			return err
+		}
+		serviceID, err := s.serviceID(appointment.Service)
+		if err != nil {
+			return err
+		}
+
+		status := appointment.Status
+		if status == "" {
+			status = "pending"
+		}
+		date := s.now.AddDate(0, 0, appointment.InDays).Format("2006-01-02")
+
+		// Active appointments that would overlap an existing one are skipped, the
+		// appointments_no_overlap constraint would reject them anyway.
+		// The history of a seeded appointment starts in its seeded status; its one item is priced like a booking.
+		_, err = s.tx.ExecContext(s.ctx, `
+			WITH offer AS (
+				SELECT m.time_zone, COALESCE(ms.base_price, s.base_price, 0) AS price,
+					COALESCE(ms.duration_minutes, NULLIF(s.duration_minutes, 0), 60) AS duration_minutes
+				FROM services s
+				JOIN masters m ON m.id = $2
+				LEFT JOIN master_services ms ON ms.master_id = m.id AND ms.service_id = s.id
+				WHERE s.id = $3
+			), new AS (
+				SELECT tstzrange(($4::date + $5::time) AT TIME ZONE offer.time_zone,
+					($4::date + $5::time + make_interval(mins => offer.duration_minutes)) AT TIME ZONE offer.time_zone, '[)') AS slot
+				FROM offer
+			), inserted AS (
+				INSERT INTO appointments (user_id, master_id, service_id, date, time, status, comment, slot, created_at, updated_at)
+				SELECT $1, $2, $3, $4, $5, $6, $7, new.slot, NOW(), NOW()
+				FROM new
+				WHERE NOT EXISTS (SELECT 1 FROM appointments WHERE master_id = $2 AND date = $4 AND time = $5)
+				  AND ($6 IN ('cancelled', 'rejected') OR NOT EXISTS (
+					SELECT 1 FROM appointments
+					WHERE master_id = $2 AND status NOT IN ('cancelled', 'rejected') AND slot && new.slot
+				  ))
+				RETURNING id, user_id, status
+			), item AS (
+				INSERT INTO appointment_items (appointment_id, service_id, position, price, duration_minutes)
+				SELECT inserted.id, $3, 0, offer.price, offer.duration_minutes
+				FROM inserted, offer
+			)
+			INSERT INTO appointment_events (appointment_id, type, to_status, actor_id, actor_role, note)
+			SELECT id, 'created', status, user_id, 'customer', 'Seeded'
+			FROM inserted
+		`, userID, masterID, serviceID, date, appointment.Time, status, appointment.Comment)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package sms
+
+import (
+	"context"
+	"log"
+	"sync"
+)
+
+// Sender delivers text messages to phone numbers
+type Sender interface {
+	Send(ctx context.Context, phone, text string) error
+}
+
+// LogSender writes messages to the log instead of sending them. Intended for local runs.
+type LogSender struct{}
+
+func NewLogSender() *LogSender {
+	return &LogSender{}
+}
+
+func (s *LogSender) Send(ctx context.Context, phone, text string) error {
+	log.Printf("SMS to %s: %s", phone, text)
+	return nil
+}
+
+// MemorySender keeps sent messages in memory. Intended for tests.
+type MemorySender struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+// Message is a text sent by MemorySender
+type Message struct {
+	Phone string
+	Text  string
+}
+
+func NewMemorySender() *MemorySender {
+	return &MemorySender{}
+}
+
+func (s *MemorySender) Send(ctx context.Context, phone, text string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, Message{Phone: phone, Text: text})
+	return nil
+}
+
+// Messages returns a copy of all messages sent so far
+func (s *MemorySender) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
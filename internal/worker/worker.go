@@ -0,0 +1,69 @@
+// Package worker runs periodic background jobs alongside the HTTP server
+package worker
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Job is a task run every Interval until the runner is stopped
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+}
+
+// Runner runs jobs in their own goroutines
+type Runner struct {
+	jobs []Job
+	wg   sync.WaitGroup
+}
+
+func NewRunner(jobs ...Job) *Runner {
+	return &Runner{jobs: jobs}
+}
+
+// Start launches every job. Jobs stop when ctx is cancelled; a run in progress receives the cancelled ctx.
+func (r *Runner) Start(ctx context.Context) {
+	for _, job := range r.jobs {
+		r.wg.Add(1)
+		go func(job Job) {
+			defer r.wg.Done()
+			r.loop(ctx, job)
+		}(job)
+	}
+}
+
+func (r *Runner) loop(ctx context.Context, job Job) {
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := job.Run(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("Warning: Background job %s failed: %v", job.Name, err)
+			}
+		}
+	}
+}
+
+// Wait blocks until all jobs have returned or ctx expires, and reports whether they all finished
+func (r *Runner) Wait(ctx context.Context) bool {
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
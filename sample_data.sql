@@ -96,6 +96,19 @@ BEGIN
     (master_maria_id, 6, '11:00', '18:00', true, NOW())   -- Суббота
     ON CONFLICT DO NOTHING;
 
+    -- Прайс-листы мастеров: все услуги каталога; Мария работает дороже и дольше
+    INSERT INTO master_services (master_id, service_id, base_price, duration_minutes, created_at, updated_at)
+    SELECT master_ivan_id, s.id, COALESCE(s.base_price, 0), COALESCE(NULLIF(s.duration_minutes, 0), 60), NOW(), NOW()
+    FROM services s
+    ON CONFLICT (master_id, service_id) DO NOTHING;
+
+    INSERT INTO master_services (master_id, service_id, base_price, duration_minutes, created_at, updated_at)
+    SELECT master_maria_id, s.id,
+           LEAST(COALESCE(s.base_price, 0) * 1.2, COALESCE(NULLIF(s.max_price, 0), COALESCE(s.base_price, 0) * 1.2)),
+           COALESCE(NULLIF(s.duration_minutes, 0), 60) + 15, NOW(), NOW()
+    FROM services s
+    ON CONFLICT (master_id, service_id) DO NOTHING;
+
     -- Платежная информация для мастеров
     INSERT INTO master_payment_info (master_id, kaspi_card, freedom_card, halyk_card, created_at, updated_at) VALUES
     (master_ivan_id, 'KZ123456789012345678', NULL, NULL, NOW(), NOW()),